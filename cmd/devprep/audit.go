@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const auditUsage = `usage: devprep audit <command> [flags]
+
+commands:
+  seal              chain audit records that were not chained yet
+  verify            walk the audit hash chain and checkpoints, report the first broken link
+  checkpoints       export signed checkpoints for external notarization
+  keygen            generate an ed25519 key for AUDIT_SIGNING_KEY`
+
+// errAuditBroken - проверка нашла нарушение; подробности уже выведены
+var errAuditBroken = errors.New("audit log integrity check failed")
+
+// runAudit выполняет подкоманду devprep audit
+func runAudit(args []string) error {
+	if len(args) == 0 {
+		return errors.New(auditUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "seal":
+		return runAuditSeal(ctx, args[1:])
+	case "verify":
+		return runAuditVerify(ctx, args[1:])
+	case "checkpoints":
+		return runAuditCheckpoints(ctx, args[1:])
+	case "keygen":
+		return runAuditKeygen(args[1:])
+	default:
+		return errors.New(auditUsage)
+	}
+}
+
+func runAuditSeal(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("audit seal", "audit seal [flags]")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	sealed, err := deps.AuditChain.Seal(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Sealed int64 `json:"sealed"`
+	}{Sealed: sealed}
+	return printOutput(*output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "Sealed:\t%d\n", result.Sealed)
+	})
+}
+
+func runAuditVerify(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("audit verify", "audit verify [flags]")
+	publicKey := fs.String("public-key", "", "trusted checkpoint public key, base64 (default: derived from AUDIT_SIGNING_KEY)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var trusted ed25519.PublicKey
+	if *publicKey != "" {
+		key, err := audit.ParsePublicKey(*publicKey)
+		if err != nil {
+			return err
+		}
+		trusted = key
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	if trusted == nil {
+		trusted = deps.AuditChain.PublicKey()
+	}
+
+	report, err := deps.AuditChain.Verify(ctx, trusted)
+	if err != nil {
+		return err
+	}
+
+	err = printOutput(*output, report, func(w io.Writer) {
+		fmt.Fprintf(w, "Records:\t%d (%d before the chain)\n", report.Records, report.Unchained)
+		fmt.Fprintf(w, "Head:\t%d %s\n", report.HeadSeq, report.HeadHash)
+		fmt.Fprintf(w, "Checkpoints:\t%d\n", report.Checkpoints)
+		if trusted == nil {
+			fmt.Fprintln(w, "Warning:\tno trusted public key, checkpoint signers are not checked")
+		}
+		if report.OK() {
+			fmt.Fprintln(w, "Status:\tOK")
+			return
+		}
+
+		fmt.Fprintf(w, "Status:\tBROKEN at record %d\n", report.Broken.Seq)
+		if report.Broken.EventID != nil {
+			fmt.Fprintf(w, "Event:\t%s\n", report.Broken.EventID)
+		}
+		if report.Broken.CheckpointID != nil {
+			fmt.Fprintf(w, "Checkpoint:\t%s\n", report.Broken.CheckpointID)
+		}
+		fmt.Fprintf(w, "Reason:\t%s\n", report.Broken.Reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return errAuditBroken
+	}
+	return nil
+}
+
+func runAuditCheckpoints(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("audit checkpoints", "audit checkpoints [flags]")
+	after := fs.Int64("after", 0, "export only checkpoints for records after this seq")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	checkpoints, err := deps.AuditChain.Checkpoints(ctx, *after)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(*output, checkpoints, func(w io.Writer) {
+		fmt.Fprintln(w, "SEQ\tHASH\tCREATED\tPUBLIC KEY\tSIGNATURE")
+		for _, checkpoint := range checkpoints {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", checkpoint.Seq, checkpoint.Hash,
+				checkpoint.CreatedAt.Format(time.RFC3339Nano), checkpoint.PublicKey, checkpoint.Signature)
+		}
+	})
+}
+
+func runAuditKeygen(args []string) error {
+	fs, output := newFlagSet("audit keygen", "audit keygen [flags]")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return err
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+
+	result := struct {
+		SigningKey string `json:"signing_key"`
+		PublicKey  string `json:"public_key"`
+	}{
+		SigningKey: base64.StdEncoding.EncodeToString(seed),
+		PublicKey:  base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	return printOutput(*output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "AUDIT_SIGNING_KEY=%s\n", result.SigningKey)
+		fmt.Fprintf(w, "Public key:\t%s\n", result.PublicKey)
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/app"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/migrator"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// errUsageShown - флаги разобрать не удалось, подсказка уже выведена
+var errUsageShown = errors.New("invalid usage")
+
+// outputFormat - формат вывода CLI-команд (флаг --output)
+type outputFormat string
+
+const (
+	outputTable outputFormat = "table"
+	outputJSON  outputFormat = "json"
+)
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(value string) error {
+	switch outputFormat(value) {
+	case outputTable, outputJSON:
+		*f = outputFormat(value)
+		return nil
+	}
+	return fmt.Errorf("must be %q or %q", outputTable, outputJSON)
+}
+
+// newFlagSet создает набор флагов подкоманды с общим флагом --output (-o)
+func newFlagSet(name, usageLine string) (*flag.FlagSet, *outputFormat) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	output := outputTable
+	fs.Var(&output, "output", "output format: table or json")
+	fs.Var(&output, "o", "shorthand for -output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: devprep %s\n\nflags:\n", usageLine)
+		fs.PrintDefaults()
+	}
+	return fs, &output
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsageShown
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return errUsageShown
+	}
+	return nil
+}
+
+// requireFlag проверяет, что обязательный флаг задан
+func requireFlag(fs *flag.FlagSet, name, value string) error {
+	if value != "" {
+		return nil
+	}
+	fmt.Fprintf(fs.Output(), "flag -%s is required\n", name)
+	fs.Usage()
+	return errUsageShown
+}
+
+// openApp подключается к базе и собирает зависимости приложения так же, как serve,
+// но отказывается работать со схемой, не совпадающей со встроенными миграциями
+func openApp(ctx context.Context) (*app.Dependencies, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := setupLogging(cfg, os.Stderr); err != nil {
+		return nil, nil, err
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkSchema(ctx, db); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	deps, err := app.NewDependencies(db, cfg)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return deps, func() {
+		deps.Close()
+		db.Close()
+	}, nil
+}
+
+// setupLogging делает логгер из LOG_LEVEL и LOG_FORMAT логгером по умолчанию;
+// CLI-команды пишут лог в stderr, чтобы он не смешивался с выводом
+func setupLogging(cfg *config.Config, w io.Writer) error {
+	logger, err := logging.New(w, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+func checkSchema(ctx context.Context, db *database.DB) error {
+	m, err := migrator.New(ctx, db.DB)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	return migrator.CheckStatus(status)
+}
+
+// resolveUser ищет пользователя по id, email или username
+func resolveUser(ctx context.Context, users *service.UserService, ref string) (*models.User, error) {
+	var (
+		user *models.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = users.GetByID(ctx, id)
+	} else if strings.Contains(ref, "@") {
+		user, err = users.GetByEmail(ctx, ref)
+	} else {
+		user, err = users.GetByUsername(ctx, ref)
+	}
+
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, fmt.Errorf("user %q not found", ref)
+	}
+	return user, err
+}
+
+// readPassword берет пароль из флага или первой строки stdin; если не задан ни один источник,
+// генерирует случайный пароль (generated = true), который нужно показать оператору
+func readPassword(value string, fromStdin bool) (password string, generated bool, err error) {
+	if value != "" && fromStdin {
+		return "", false, errors.New("use either -password or -password-stdin")
+	}
+	if value != "" {
+		return value, false, nil
+	}
+
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", false, fmt.Errorf("error reading password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", false, errors.New("empty password on stdin")
+		}
+		return password, false, nil
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
+
+// printOutput выводит value как JSON или как таблицу, которую заполняет table
+func printOutput(format outputFormat, value interface{}, table func(w io.Writer)) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/health"
+	"github.com/AtlasOpx/devprep/internal/migrator"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"net"
+)
+
+// Имена проверок readiness
+const (
+	checkDatabase   = "database"
+	checkMigrations = "migrations"
+	checkRedis      = "redis"
+	checkQueueLag   = "queue_lag"
+)
+
+// registerHealthChecks регистрирует проверки readiness: база и версия схемы критичны,
+// Redis (если задан) и отставание очереди только переводят экземпляр в degraded
+func registerHealthChecks(cfg *config.Config, db *database.DB, jobs *queue.Queue, registry *health.Registry) {
+	registry.Register(health.Check{
+		Name:     checkDatabase,
+		Critical: true,
+		Func:     health.PingDB(db.DB),
+	})
+
+	registry.Register(health.Check{
+		Name:     checkMigrations,
+		Critical: true,
+		Func: func(ctx context.Context) error {
+			status, err := migrator.ReadStatus(ctx, db.DB)
+			if err != nil {
+				return err
+			}
+			if status.Current > status.Latest {
+				// Так же, как при запуске: со схемой новее бинарника работать можно
+				return health.Degraded(fmt.Errorf("database schema version %d is newer than the latest embedded migration %d",
+					status.Current, status.Latest))
+			}
+			return migrator.CheckStatus(status)
+		},
+	})
+
+	if cfg.RedisHost != "" {
+		registry.Register(health.Check{
+			Name: checkRedis,
+			Func: health.PingRedis(net.JoinHostPort(cfg.RedisHost, cfg.RedisPort), cfg.RedisPassword),
+		})
+	}
+
+	registry.Register(health.Check{
+		Name: checkQueueLag,
+		Func: health.MaxLag("queue", cfg.HealthQueueLagThreshold, jobs.Lag),
+	})
+}
@@ -1,139 +1,56 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/AtlasOpx/devprep/internal/app"
-	"github.com/AtlasOpx/devprep/internal/config"
-	"github.com/AtlasOpx/devprep/internal/database"
-	"github.com/AtlasOpx/devprep/internal/routes"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"log"
 	"os"
-	"os/signal"
-	"sync/atomic"
-	"syscall"
-	"time"
-
-	"github.com/gofiber/fiber/v2"
-)
-
-const (
-	_shutdownPeriod      = 15 * time.Second
-	_shutdownHardPeriod  = 3 * time.Second
-	_readinessDrainDelay = 5 * time.Second
 )
 
-var isShuttingDown atomic.Bool
+const usage = `usage: devprep [command] [arguments]
 
-func main() {
-	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+commands:
+  serve                   start the HTTP server (default)
+  migrate <command>       manage database migrations
+  user <command>          manage users: create, set-role, deactivate, reset-password
+  session <command>       manage sessions: list, purge-expired
+  audit <command>         audit log integrity: verify, checkpoints, keygen
+  help                    show this help
 
-	ongoingCtx, stopOngoingGracefully := context.WithCancel(context.Background())
-	defer stopOngoingGracefully()
+Run "devprep <command> -h" for command flags.`
 
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatal(err)
+func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
 
-	db, err := database.Connect(cfg)
-	if err != nil {
-		log.Fatal(err)
+	var err error
+	switch command {
+	case "serve":
+		err = runServe()
+	case "migrate":
+		err = runMigrate(args)
+	case "user":
+		err = runUser(args)
+	case "session":
+		err = runSession(args)
+	case "audit":
+		err = runAudit(args)
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", command, usage)
 	}
 
-	defer db.Close()
-
-	fiberApp := fiber.New(fiber.Config{
-		Prefork:       false,
-		CaseSensitive: true,
-		StrictRouting: true,
-		ServerHeader:  "DevPrep",
-		AppName:       "Dev Prep app v1.0.1",
-		ReadTimeout:   30 * time.Second,
-		WriteTimeout:  30 * time.Second,
-		IdleTimeout:   120 * time.Second,
-	})
-
-	fiberApp.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
-	}))
-
-	fiberApp.Use(func(c *fiber.Ctx) error {
-		if isShuttingDown.Load() {
-			return c.Status(503).SendString("Service Unavailable")
-		}
-		return c.Next()
-	})
-
-	fiberApp.Get("/healthz", func(c *fiber.Ctx) error {
-		if isShuttingDown.Load() {
-			return c.Status(503).JSON(fiber.Map{
-				"status": "shutting_down",
-			})
-		}
-		return c.JSON(fiber.Map{
-			"status": "ok",
-		})
-	})
-
-	fiberApp.Get("/readyz", func(c *fiber.Ctx) error {
-		if isShuttingDown.Load() {
-			return c.Status(503).JSON(fiber.Map{
-				"ready":  false,
-				"reason": "shutting_down",
-			})
-		}
-		return c.JSON(fiber.Map{
-			"ready": true,
-		})
-	})
-
-	fiberApp.Get("/", func(c *fiber.Ctx) error {
-		select {
-		case <-time.After(100 * time.Millisecond):
-			return c.SendString("Hello, World!")
-		case <-ongoingCtx.Done():
-			return c.Status(503).SendString("Request cancelled due to shutdown")
-		}
-	})
-
-	deps := app.NewDependencies(db, cfg)
-	routes.SetupRoutes(fiberApp, deps)
-
-	go func() {
-		log.Println("Server starting on :3000")
-		if err := fiberApp.Listen(fmt.Sprintf(":%v", cfg.ServerPort)); err != nil {
-			log.Printf("Server failed to start: %v", err)
-			stop()
-		}
-	}()
-
-	<-rootCtx.Done()
-	log.Println("Received shutdown signal, initiating graceful shutdown...")
-
-	isShuttingDown.Store(true)
-
-	log.Printf("Waiting %v for readiness checks to propagate...", _readinessDrainDelay)
-	time.Sleep(_readinessDrainDelay)
-
-	log.Println("Stopping acceptance of new requests and waiting for ongoing requests to finish...")
-	stopOngoingGracefully()
-
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), _shutdownPeriod)
-	defer cancel()
-
-	if err := fiberApp.ShutdownWithContext(shutdownCtx); err != nil {
-		log.Printf("Failed to shutdown gracefully within %v: %v", _shutdownPeriod, err)
-
-		log.Printf("Forcing shutdown in %v...", _shutdownHardPeriod)
-		time.Sleep(_shutdownHardPeriod)
-
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if errors.Is(err, errUsageShown) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "devprep: %v\n", err)
 		os.Exit(1)
 	}
-
-	log.Println("Server shut down gracefully")
 }
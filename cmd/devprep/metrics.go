@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/health"
+	"github.com/AtlasOpx/devprep/internal/metrics"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// activeSessionsTimeout - сколько ждем подсчета сессий при сборе метрик
+const activeSessionsTimeout = 2 * time.Second
+
+// registerMetrics публикует метрики, которым нужны зависимости сервера: пул соединений,
+// число активных сессий и признак остановки
+func registerMetrics(cfg *config.Config, db *database.DB, authService *service.AuthService, probes *health.Registry) error {
+	if err := metrics.RegisterDBStats(db.DB, cfg.DBName); err != nil {
+		return err
+	}
+
+	err := metrics.RegisterCount("active_sessions", "Sessions that have not expired yet.",
+		activeSessionsTimeout, authService.CountActiveSessions)
+	if err != nil {
+		return err
+	}
+
+	return metrics.RegisterGauge("shutting_down", "1 while the server drains requests before shutdown.", func() float64 {
+		if probes.ShuttingDown() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// startMetricsServer отдает /metrics на отдельном порту METRICS_PORT, недоступном снаружи кластера
+func startMetricsServer(cfg *config.Config, healthHandler *handlers.HealthHandler, stop context.CancelFunc) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	// Подробный readiness с текстом ошибок проверок отдается только здесь, а не на публичном порту
+	mux.Handle("/readyz", adaptor.FiberHandlerFunc(healthHandler.ReadinessDetail))
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%v", cfg.MetricsPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		slog.Info("metrics server starting", "port", cfg.MetricsPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed to start", "error", err)
+			stop()
+		}
+	}()
+	return server
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/migrator"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: devprep migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down [N]      roll back the last N migrations (default 1)
+  status        show the schema version and embedded migrations
+  force <V>     set the schema version to V without running migrations and clear the dirty flag`
+
+// runMigrate выполняет подкоманду devprep migrate
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(cfg, os.Stderr); err != nil {
+		return err
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrator.New(context.Background(), db.DB)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		if err := m.Up(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		if err := m.Down(steps); err != nil {
+			return err
+		}
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := m.Force(version); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return printMigrationStatus(m)
+}
+
+func printMigrationStatus(m *migrator.Migrator) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version: %d (latest %d)", status.Current, status.Latest)
+	if status.Dirty {
+		fmt.Print(", DIRTY")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, migration := range status.Migrations {
+		applied := "no"
+		if migration.Applied {
+			applied = "yes"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, applied)
+	}
+	return w.Flush()
+}
+
+// prepareSchema перед запуском сервера применяет миграции (если включен AUTO_MIGRATE)
+// и проверяет, что схема соответствует встроенным миграциям
+func prepareSchema(ctx context.Context, cfg *config.Config, db *database.DB) error {
+	m, err := migrator.New(ctx, db.DB)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if cfg.AutoMigrate {
+		if err := m.Up(); err != nil {
+			return fmt.Errorf("error applying migrations: %w", err)
+		}
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if status.Current > status.Latest {
+		// Схема новее бинарника - например, после отката релиза; работать можно, но стоит знать
+		slog.Warn("database schema is newer than the latest embedded migration",
+			"schema_version", status.Current, "latest_migration", status.Latest)
+	}
+	return migrator.CheckStatus(status)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/app"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/health"
+	"github.com/AtlasOpx/devprep/internal/metrics"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/AtlasOpx/devprep/internal/routes"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	_shutdownPeriod      = 15 * time.Second
+	_shutdownHardPeriod  = 3 * time.Second
+	_readinessDrainDelay = 5 * time.Second
+)
+
+// runServe запускает HTTP-сервер и фоновые задачи до получения SIGINT/SIGTERM
+func runServe() error {
+	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ongoingCtx, stopOngoingGracefully := context.WithCancel(context.Background())
+	defer stopOngoingGracefully()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(cfg, os.Stdout); err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(rootCtx, cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Отправляем последние span уже после остановки сервера и фоновых задач
+		ctx, cancel := context.WithTimeout(context.Background(), _shutdownHardPeriod)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	if err := prepareSchema(rootCtx, cfg, db); err != nil {
+		return err
+	}
+
+	probes := health.NewRegistry(health.Options{
+		Timeout:  cfg.HealthCheckTimeout,
+		CacheTTL: cfg.HealthCacheTTL,
+	})
+
+	fiberApp := fiber.New(fiber.Config{
+		Prefork:       false,
+		CaseSensitive: true,
+		StrictRouting: true,
+		ServerHeader:  "DevPrep",
+		AppName:       "Dev Prep app v1.0.1",
+		ReadTimeout:   30 * time.Second,
+		WriteTimeout:  30 * time.Second,
+		IdleTimeout:   120 * time.Second,
+		ErrorHandler:  handlers.NewErrorHandler(cfg.DefaultLocale),
+	})
+
+	// ID запроса, трасса, метрики и access log - первыми, чтобы учитывались и отклоненные ниже запросы
+	fiberApp.Use(middleware.RequestID())
+	fiberApp.Use(middleware.Tracing())
+	if cfg.MetricsEnabled {
+		fiberApp.Use(middleware.Metrics())
+	}
+	fiberApp.Use(middleware.AccessLog(slog.Default()))
+
+	// Метрики отдаются и во время остановки, чтобы было видно shutting_down
+	if cfg.MetricsEnabled && cfg.MetricsPort == "" {
+		fiberApp.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+	}
+
+	// Пробы отвечают и во время остановки: liveness не должна падать, пока сервер дорабатывает запросы
+	healthHandler := handlers.NewHealthHandler(probes)
+	fiberApp.Get("/livez", healthHandler.Liveness)
+	fiberApp.Get("/healthz", healthHandler.Liveness)
+	fiberApp.Get("/startupz", healthHandler.Startup)
+	fiberApp.Get("/readyz", healthHandler.Readiness)
+
+	fiberApp.Use(cors.New(cors.Config{
+		AllowOrigins: "*",
+		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+	}))
+
+	fiberApp.Use(func(c *fiber.Ctx) error {
+		if probes.ShuttingDown() {
+			return c.Status(503).SendString("Service Unavailable")
+		}
+		return c.Next()
+	})
+
+	fiberApp.Use(middleware.RequestContext(ongoingCtx, cfg.DBQueryTimeout))
+
+	fiberApp.Get("/", func(c *fiber.Ctx) error {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return c.SendString("Hello, World!")
+		case <-ongoingCtx.Done():
+			return c.Status(503).SendString("Request cancelled due to shutdown")
+		}
+	})
+
+	deps, err := app.NewDependencies(db, cfg)
+	if err != nil {
+		return err
+	}
+	defer deps.Close()
+	routes.SetupRoutes(fiberApp, deps)
+	registerHealthChecks(cfg, db, deps.Queue, probes)
+
+	var metricsServer *http.Server
+	if cfg.MetricsEnabled {
+		if err := registerMetrics(cfg, db, deps.AuthService, probes); err != nil {
+			return err
+		}
+		if cfg.MetricsPort != "" {
+			metricsServer = startMetricsServer(cfg, healthHandler, stop)
+		}
+	}
+
+	if cfg.SchedulerEnabled {
+		deps.Scheduler.Start()
+	}
+	deps.Queue.Start()
+	deps.AuditSealer.Start()
+	if cfg.OutboxRelayEnabled {
+		deps.Outbox.Start()
+	}
+
+	// Запуск закончен, только когда порт открыт: до этого startup-проба не должна проходить
+	fiberApp.Hooks().OnListen(func(fiber.ListenData) error {
+		probes.MarkStarted()
+		return nil
+	})
+
+	go func() {
+		slog.Info("server starting", "port", cfg.ServerPort)
+		if err := fiberApp.Listen(fmt.Sprintf(":%v", cfg.ServerPort)); err != nil {
+			slog.Error("server failed to start", "error", err)
+			stop()
+		}
+	}()
+
+	<-rootCtx.Done()
+	slog.Info("received shutdown signal, initiating graceful shutdown")
+
+	probes.MarkShuttingDown()
+
+	slog.Info("waiting for readiness checks to propagate", "delay", _readinessDrainDelay.String())
+	time.Sleep(_readinessDrainDelay)
+
+	slog.Info("stopping acceptance of new requests and waiting for ongoing requests to finish")
+	stopOngoingGracefully()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), _shutdownPeriod)
+	defer cancel()
+
+	// Запущенные фоновые задачи дорабатывают параллельно с HTTP-запросами в пределах того же срока
+	schedulerDone := make(chan error, 1)
+	go func() {
+		schedulerDone <- deps.Scheduler.Shutdown(shutdownCtx)
+	}()
+	queueDone := make(chan error, 1)
+	go func() {
+		queueDone <- deps.Queue.Shutdown(shutdownCtx)
+	}()
+	outboxDone := make(chan error, 1)
+	go func() {
+		outboxDone <- deps.Outbox.Shutdown(shutdownCtx)
+	}()
+	sealerDone := make(chan error, 1)
+	go func() {
+		sealerDone <- deps.AuditSealer.Shutdown(shutdownCtx)
+	}()
+
+	if err := fiberApp.ShutdownWithContext(shutdownCtx); err != nil {
+		slog.Error("failed to shut down gracefully", "timeout", _shutdownPeriod.String(), "error", err)
+
+		slog.Warn("forcing shutdown", "delay", _shutdownHardPeriod.String())
+		time.Sleep(_shutdownHardPeriod)
+
+		os.Exit(1)
+	}
+
+	if err := <-schedulerDone; err != nil {
+		slog.Warn("background jobs did not finish in time and were cancelled", "timeout", _shutdownPeriod.String(), "error", err)
+	}
+	if err := <-queueDone; err != nil {
+		slog.Warn("queued jobs did not finish in time and will be retried", "timeout", _shutdownPeriod.String(), "error", err)
+	}
+	if err := <-outboxDone; err != nil {
+		slog.Warn("outbox relay was interrupted, pending events will be published after restart", "error", err)
+	}
+	if err := <-sealerDone; err != nil {
+		slog.Warn("audit sealing was interrupted, new records will be chained after restart", "error", err)
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("metrics server did not shut down cleanly", "error", err)
+		}
+	}
+
+	slog.Info("server shut down gracefully")
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const sessionUsage = `usage: devprep session <command> [flags]
+
+commands:
+  list              list sessions of a user
+  purge-expired     delete expired sessions of all users`
+
+// sessionRow - сессия в выводе CLI; сам токен не показываем
+type sessionRow struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// runSession выполняет подкоманду devprep session
+func runSession(args []string) error {
+	if len(args) == 0 {
+		return errors.New(sessionUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "list":
+		return runSessionList(ctx, args[1:])
+	case "purge-expired":
+		return runSessionPurgeExpired(ctx, args[1:])
+	default:
+		return errors.New(sessionUsage)
+	}
+}
+
+func runSessionList(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("session list", "session list -user USER [flags]")
+	ref := fs.String("user", "", "user id, email or username (required)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "user", *ref); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	user, err := resolveUser(ctx, deps.UserService, *ref)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := deps.AuthService.ListSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([]sessionRow, len(sessions))
+	for i, session := range sessions {
+		rows[i] = sessionRow{
+			ID:        session.ID,
+			UserID:    session.UserID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Expired:   !session.ExpiresAt.After(now),
+			IPAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+		}
+	}
+
+	return printOutput(*output, rows, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tCREATED\tEXPIRES\tSTATUS\tIP\tUSER AGENT")
+		for _, row := range rows {
+			status := "active"
+			if row.Expired {
+				status = "expired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", row.ID, row.CreatedAt.Format(time.RFC3339),
+				row.ExpiresAt.Format(time.RFC3339), status, row.IPAddress, row.UserAgent)
+		}
+	})
+}
+
+func runSessionPurgeExpired(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("session purge-expired", "session purge-expired [flags]")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	deleted, err := deps.AuthService.PurgeExpiredSessions(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Deleted int64 `json:"deleted"`
+	}{deleted}
+	return printOutput(*output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "Deleted %d expired sessions\n", deleted)
+	})
+}
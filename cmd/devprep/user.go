@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"io"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const userUsage = `usage: devprep user <command> [flags]
+
+commands:
+  create            create a user (use -role admin for an administrator)
+  set-role          change the role of a user
+  deactivate        block sign-in and revoke all sessions of a user
+  reset-password    set a new password and revoke all sessions of a user
+
+Users are referenced by id, email or username.`
+
+// userResult - результат команд user; Password заполняется, только если пароль сгенерирован
+type userResult struct {
+	User     dto.UserResponse `json:"user"`
+	Password string           `json:"generated_password,omitempty"`
+}
+
+// runUser выполняет подкоманду devprep user
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return errors.New(userUsage)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// Действия CLI попадают в журнал аудита без исполнителя, с источником cli
+	ctx = audit.WithSource(ctx, audit.SourceCLI)
+
+	switch args[0] {
+	case "create":
+		return runUserCreate(ctx, args[1:])
+	case "set-role":
+		return runUserSetRole(ctx, args[1:])
+	case "deactivate":
+		return runUserDeactivate(ctx, args[1:])
+	case "reset-password":
+		return runUserResetPassword(ctx, args[1:])
+	default:
+		return errors.New(userUsage)
+	}
+}
+
+func runUserCreate(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user create", "user create -email EMAIL -username NAME -first-name NAME -last-name NAME [flags]")
+	req := dto.RegisterRequest{}
+	fs.StringVar(&req.Email, "email", "", "email (required)")
+	fs.StringVar(&req.Username, "username", "", "username (required)")
+	fs.StringVar(&req.FirstName, "first-name", "", "first name (required)")
+	fs.StringVar(&req.LastName, "last-name", "", "last name (required)")
+	password := fs.String("password", "", "password; generated and printed if omitted")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin")
+	role := fs.String("role", string(models.UserRoleUser), "role: user, moderator or admin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var generated bool
+	var err error
+	req.Password, generated, err = readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	if fieldErrs := utils.NewXValidator().Validate(&req); len(fieldErrs) > 0 {
+		return validationError(fieldErrs)
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	userID, err := deps.AuthService.CreateUser(ctx, dto.RegisterRequestToModel(&req), models.UserRole(*role))
+	if err != nil {
+		return err
+	}
+
+	user, err := deps.UserService.GetByID(ctx, *userID)
+	if err != nil {
+		return err
+	}
+
+	result := userResult{User: dto.UserToResponse(user)}
+	if generated {
+		result.Password = req.Password
+	}
+	return printUserResult(*output, result)
+}
+
+func runUserSetRole(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user set-role", "user set-role -user USER -role ROLE [flags]")
+	ref := fs.String("user", "", "user id, email or username (required)")
+	role := fs.String("role", "", "role: user, moderator or admin (required)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "user", *ref); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "role", *role); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	user, err := resolveUser(ctx, deps.UserService, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := deps.UserService.SetRole(ctx, user.ID, models.UserRole(*role)); err != nil {
+		return err
+	}
+
+	return printUpdatedUser(ctx, deps.UserService, user, *output)
+}
+
+func runUserDeactivate(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user deactivate", "user deactivate -user USER [flags]")
+	ref := fs.String("user", "", "user id, email or username (required)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "user", *ref); err != nil {
+		return err
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	user, err := resolveUser(ctx, deps.UserService, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := deps.UserService.Deactivate(ctx, user.ID); err != nil {
+		return err
+	}
+
+	return printUpdatedUser(ctx, deps.UserService, user, *output)
+}
+
+func runUserResetPassword(ctx context.Context, args []string) error {
+	fs, output := newFlagSet("user reset-password", "user reset-password -user USER [flags]")
+	ref := fs.String("user", "", "user id, email or username (required)")
+	password := fs.String("password", "", "new password; generated and printed if omitted")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag(fs, "user", *ref); err != nil {
+		return err
+	}
+
+	newPassword, generated, err := readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	// Те же требования к паролю, что и при регистрации
+	passwordReq := struct {
+		Password string `json:"password" validate:"required,min=6"`
+	}{newPassword}
+	if fieldErrs := utils.NewXValidator().Validate(&passwordReq); len(fieldErrs) > 0 {
+		return validationError(fieldErrs)
+	}
+
+	deps, closeApp, err := openApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeApp()
+
+	user, err := resolveUser(ctx, deps.UserService, *ref)
+	if err != nil {
+		return err
+	}
+
+	if err := deps.AuthService.ResetPassword(ctx, user.ID, newPassword); err != nil {
+		return err
+	}
+
+	result := userResult{User: dto.UserToResponse(user)}
+	if generated {
+		result.Password = newPassword
+	}
+	return printUserResult(*output, result)
+}
+
+// printUpdatedUser перечитывает пользователя после изменения и выводит его
+func printUpdatedUser(ctx context.Context, users *service.UserService, user *models.User, output outputFormat) error {
+	updated, err := users.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return printUserResult(output, userResult{User: dto.UserToResponse(updated)})
+}
+
+func printUserResult(output outputFormat, result userResult) error {
+	return printOutput(output, result, func(w io.Writer) {
+		user := result.User
+		fmt.Fprintln(w, "ID\tEMAIL\tUSERNAME\tNAME\tROLE\tACTIVE\tCREATED")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n", user.ID, user.Email, user.Username,
+			strings.TrimSpace(user.FirstName+" "+user.LastName), user.Role, user.IsActive,
+			user.CreatedAt.Format(time.RFC3339))
+		if result.Password != "" {
+			fmt.Fprintf(w, "\nGenerated password: %s\n", result.Password)
+		}
+	})
+}
+
+func validationError(fieldErrs []apperrors.FieldError) error {
+	messages := make([]string, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		messages[i] = fmt.Sprintf("  %s: %s", fieldErr.Field, fieldErr.Message)
+	}
+	return errors.New("validation failed:\n" + strings.Join(messages, "\n"))
+}
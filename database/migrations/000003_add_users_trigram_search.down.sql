@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_last_name_trgm;
+DROP INDEX IF EXISTS idx_users_first_name_trgm;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP EXTENSION IF EXISTS pg_trgm;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Мягкое удаление: строка остается до очистки фоновой задачей,
+-- поэтому email и username остаются занятыми до окончательного удаления
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_data_exports_user_id_created_at;
+DROP TABLE IF EXISTS data_exports;
\ No newline at end of file
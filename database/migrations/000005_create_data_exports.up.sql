@@ -0,0 +1,13 @@
+CREATE TABLE data_exports
+(
+    id           UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id      UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status       VARCHAR(20) NOT NULL     DEFAULT 'pending',
+    file_key     VARCHAR(255),
+    error        TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at   TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_data_exports_user_id_created_at ON data_exports (user_id, created_at DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_change_requests_user_id;
+DROP TABLE IF EXISTS email_change_requests;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE email_change_requests
+(
+    id                 UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id            UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    old_email          VARCHAR(255)             NOT NULL,
+    new_email          VARCHAR(255)             NOT NULL,
+    confirm_token_hash VARCHAR(64) UNIQUE       NOT NULL,
+    revert_token_hash  VARCHAR(64) UNIQUE       NOT NULL,
+    created_at         TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at         TIMESTAMP WITH TIME ZONE NOT NULL,
+    revert_expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at       TIMESTAMP WITH TIME ZONE,
+    reverted_at        TIMESTAMP WITH TIME ZONE,
+    cancelled_at       TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_email_change_requests_user_id ON email_change_requests (user_id);
@@ -0,0 +1,5 @@
+-- Нормализация данных необратима: исходные значения конфликтующих записей
+-- остаются в identity_dedupe_report до отката этой миграции
+DROP INDEX IF EXISTS idx_users_username_lower;
+DROP INDEX IF EXISTS idx_users_email_lower;
+DROP TABLE IF EXISTS identity_dedupe_report;
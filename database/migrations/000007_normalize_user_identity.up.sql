@@ -0,0 +1,77 @@
+-- Email и username сравниваются без учета регистра: приложение нормализует их
+-- (NFKC, trim, lower) перед записью, а функциональные уникальные индексы
+-- не дают создать дубликаты в обход приложения.
+
+-- Отчет о записях, измененных при устранении конфликтов
+CREATE TABLE identity_dedupe_report
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       UUID         NOT NULL,
+    field         VARCHAR(20)  NOT NULL,
+    old_value     VARCHAR(255) NOT NULL,
+    new_value     VARCHAR(255) NOT NULL,
+    kept_user_id  UUID         NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Из каждой группы конфликтующих аккаунтов оставляем самый старый,
+-- остальным даем уникальное служебное значение и деактивируем их
+WITH ranked AS (SELECT id,
+                       email,
+                       FIRST_VALUE(id) OVER w AS kept_id,
+                       ROW_NUMBER() OVER w    AS rn
+                FROM users
+                WINDOW w AS (PARTITION BY LOWER(NORMALIZE(TRIM(email), NFKC)) ORDER BY created_at, id)),
+     renamed AS (
+         UPDATE users u
+             SET email = 'duplicate+' || u.id || '@invalid.local',
+                 is_active = FALSE,
+                 updated_at = NOW()
+             FROM ranked r
+             WHERE u.id = r.id AND r.rn > 1
+             RETURNING u.id, r.email AS old_email, u.email AS new_email, r.kept_id)
+INSERT
+INTO identity_dedupe_report (user_id, field, old_value, new_value, kept_user_id)
+SELECT id, 'email', old_email, new_email, kept_id
+FROM renamed;
+
+WITH ranked AS (SELECT id,
+                       username,
+                       FIRST_VALUE(id) OVER w AS kept_id,
+                       ROW_NUMBER() OVER w    AS rn
+                FROM users
+                WINDOW w AS (PARTITION BY LOWER(NORMALIZE(TRIM(username), NFKC)) ORDER BY created_at, id)),
+     renamed AS (
+         UPDATE users u
+             -- username - VARCHAR(100): 91 символ имени, '_' и 8 символов id
+             SET username = LEFT(LOWER(NORMALIZE(TRIM(r.username), NFKC)), 91) || '_' || LEFT(u.id::TEXT, 8),
+                 is_active = FALSE,
+                 updated_at = NOW()
+             FROM ranked r
+             WHERE u.id = r.id AND r.rn > 1
+             RETURNING u.id, r.username AS old_username, u.username AS new_username, r.kept_id)
+INSERT
+INTO identity_dedupe_report (user_id, field, old_value, new_value, kept_user_id)
+SELECT id, 'username', old_username, new_username, kept_id
+FROM renamed;
+
+UPDATE users
+SET email    = LOWER(NORMALIZE(TRIM(email), NFKC)),
+    username = LOWER(NORMALIZE(TRIM(username), NFKC))
+WHERE email <> LOWER(NORMALIZE(TRIM(email), NFKC))
+   OR username <> LOWER(NORMALIZE(TRIM(username), NFKC));
+
+DO
+$$
+    DECLARE
+        conflicts INTEGER;
+    BEGIN
+        SELECT COUNT(*) INTO conflicts FROM identity_dedupe_report;
+        IF conflicts > 0 THEN
+            RAISE NOTICE 'identity normalization: % conflicting identifiers renamed, see identity_dedupe_report', conflicts;
+        END IF;
+    END
+$$;
+
+CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email));
+CREATE UNIQUE INDEX idx_users_username_lower ON users (LOWER(username));
@@ -0,0 +1,3 @@
+-- Язык сообщений и писем пользователя; пустая строка - использовать Accept-Language или язык по умолчанию
+ALTER TABLE users
+    ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+DROP FUNCTION IF EXISTS cleanup_expired_sessions();
+
+CREATE FUNCTION cleanup_expired_sessions()
+    RETURNS VOID AS
+$$
+BEGIN
+    DELETE FROM sessions WHERE expires_at < NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS job_runs;
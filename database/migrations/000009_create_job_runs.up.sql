@@ -0,0 +1,32 @@
+-- История запусков фоновых задач планировщика. Уникальность (job_name, scheduled_at)
+-- гарантирует, что запланированный запуск выполнит только одна реплика
+CREATE TABLE job_runs
+(
+    id           UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    job_name     VARCHAR(100)             NOT NULL,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMP WITH TIME ZONE,
+    status       VARCHAR(20)              NOT NULL DEFAULT 'running',
+    processed    BIGINT                   NOT NULL DEFAULT 0,
+    error        TEXT,
+    instance     VARCHAR(255)             NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX idx_job_runs_job_name_scheduled_at ON job_runs (job_name, scheduled_at);
+CREATE INDEX idx_job_runs_started_at ON job_runs (started_at);
+
+-- cleanup_expired_sessions теперь возвращает число удаленных сессий
+DROP FUNCTION IF EXISTS cleanup_expired_sessions();
+
+CREATE FUNCTION cleanup_expired_sessions()
+    RETURNS BIGINT AS
+$$
+DECLARE
+    deleted BIGINT;
+BEGIN
+    DELETE FROM sessions WHERE expires_at < NOW();
+    GET DIAGNOSTICS deleted = ROW_COUNT;
+    RETURN deleted;
+END;
+$$ LANGUAGE plpgsql;
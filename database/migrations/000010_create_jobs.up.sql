@@ -0,0 +1,25 @@
+-- Очередь асинхронных задач. Воркеры забирают задачи через SELECT ... FOR UPDATE SKIP LOCKED
+CREATE TABLE jobs
+(
+    id           UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    kind         VARCHAR(100)             NOT NULL,
+    payload      JSONB                    NOT NULL DEFAULT '{}',
+    status       VARCHAR(20)              NOT NULL DEFAULT 'pending',
+    unique_key   VARCHAR(255),
+    attempts     INT                      NOT NULL DEFAULT 0,
+    max_attempts INT                      NOT NULL DEFAULT 5,
+    run_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_at    TIMESTAMP WITH TIME ZONE,
+    locked_by    VARCHAR(255),
+    last_error   TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_jobs_pending_run_at ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_status_kind ON jobs (status, kind);
+
+-- Ключ уникальности действует, пока задача не завершена: после выполнения ту же работу можно поставить снова
+CREATE UNIQUE INDEX idx_jobs_kind_unique_key ON jobs (kind, unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
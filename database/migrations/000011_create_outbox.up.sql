@@ -0,0 +1,20 @@
+-- Transactional outbox: доменные события пишутся в той же транзакции, что и изменение
+-- состояния, а relay публикует их во внешние системы. id задает порядок публикации
+CREATE TABLE outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        UUID                     NOT NULL UNIQUE,
+    aggregate_type  VARCHAR(50)              NOT NULL,
+    aggregate_id    UUID                     NOT NULL,
+    event_type      VARCHAR(100)             NOT NULL,
+    payload         JSONB                    NOT NULL,
+    occurred_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at    TIMESTAMP WITH TIME ZONE,
+    attempts        INTEGER                  NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error      TEXT
+);
+
+CREATE INDEX idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_aggregate_unpublished ON outbox (aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,51 @@
+-- Исходящие вебхуки: подписки администратора на доменные события
+CREATE TABLE webhook_endpoints
+(
+    id                   UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    url                  TEXT                     NOT NULL,
+    description          VARCHAR(255)             NOT NULL DEFAULT '',
+    events               TEXT[]                   NOT NULL,
+    secret               VARCHAR(255)             NOT NULL,
+    is_active            BOOLEAN                  NOT NULL DEFAULT TRUE,
+    consecutive_failures INTEGER                  NOT NULL DEFAULT 0,
+    disabled_at          TIMESTAMP WITH TIME ZONE,
+    disabled_reason      TEXT,
+    created_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Доставка одного события одному endpoint; уникальность отсекает повторы at-least-once
+CREATE TABLE webhook_deliveries
+(
+    id              UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    endpoint_id     UUID                     NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id        UUID                     NOT NULL,
+    event_type      VARCHAR(100)             NOT NULL,
+    payload         JSONB                    NOT NULL,
+    status          VARCHAR(20)              NOT NULL DEFAULT 'pending',
+    attempts        INTEGER                  NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMP WITH TIME ZONE,
+    UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries (endpoint_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_created_at ON webhook_deliveries (created_at);
+
+-- Журнал попыток доставки с ответом получателя
+CREATE TABLE webhook_delivery_attempts
+(
+    id              BIGSERIAL PRIMARY KEY,
+    delivery_id     UUID                     NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempt         INTEGER                  NOT NULL,
+    response_status INTEGER,
+    response_body   TEXT,
+    error           TEXT,
+    duration_ms     INTEGER                  NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id, id);
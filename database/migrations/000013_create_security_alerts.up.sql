@@ -0,0 +1,20 @@
+-- Уведомления безопасности: пользователь отключает ненужные, новые типы включены по умолчанию
+CREATE TABLE security_alert_settings
+(
+    user_id         UUID PRIMARY KEY         REFERENCES users (id) ON DELETE CASCADE,
+    disabled_alerts TEXT[]                   NOT NULL DEFAULT '{}',
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Одноразовые ссылки сброса пароля; хранится только хеш токена
+CREATE TABLE password_reset_tokens
+(
+    id         UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id    UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE       NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
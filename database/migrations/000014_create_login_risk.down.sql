@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_login_challenges_user_id;
+DROP TABLE IF EXISTS login_challenges;
+DROP INDEX IF EXISTS idx_login_history_user_created;
+DROP TABLE IF EXISTS login_history;
+DROP TABLE IF EXISTS known_devices;
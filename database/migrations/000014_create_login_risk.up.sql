@@ -0,0 +1,50 @@
+-- Устройства, с которых пользователь уже входил; отпечаток - хеш User-Agent без номеров версий
+CREATE TABLE known_devices
+(
+    id          UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id     UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    fingerprint VARCHAR(64)              NOT NULL,
+    user_agent  TEXT                     NOT NULL DEFAULT '',
+    last_ip     VARCHAR(45)              NOT NULL DEFAULT '',
+    first_seen  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, fingerprint)
+);
+
+-- История входов с оценкой риска; по ней считаются новые подсети, время и перемещение
+CREATE TABLE login_history
+(
+    id          UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id     UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    ip_address  VARCHAR(45)              NOT NULL DEFAULT '',
+    user_agent  TEXT                     NOT NULL DEFAULT '',
+    fingerprint VARCHAR(64)              NOT NULL,
+    subnet      VARCHAR(64)              NOT NULL DEFAULT '',
+    latitude    DOUBLE PRECISION,
+    longitude   DOUBLE PRECISION,
+    risk_score  INTEGER                  NOT NULL DEFAULT 0,
+    signals     TEXT[]                   NOT NULL DEFAULT '{}',
+    outcome     VARCHAR(20)              NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_history_user_created ON login_history (user_id, created_at DESC);
+
+-- Коды подтверждения рискованного входа; хранится только хеш кода
+CREATE TABLE login_challenges
+(
+    id          UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id     UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash   VARCHAR(64)              NOT NULL,
+    fingerprint VARCHAR(64)              NOT NULL,
+    ip_address  VARCHAR(45)              NOT NULL DEFAULT '',
+    user_agent  TEXT                     NOT NULL DEFAULT '',
+    risk_score  INTEGER                  NOT NULL DEFAULT 0,
+    signals     TEXT[]                   NOT NULL DEFAULT '{}',
+    attempts    INTEGER                  NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_login_challenges_user_id ON login_challenges (user_id);
@@ -0,0 +1,42 @@
+-- Журнал аудита: кто, что и над чем сделал. Ссылок на users нет - записи переживают удаление пользователя
+CREATE TABLE audit_events
+(
+    id          UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    actor_id    UUID,
+    actor_role  VARCHAR(20)              NOT NULL DEFAULT '',
+    source      VARCHAR(20)              NOT NULL,
+    action      VARCHAR(100)             NOT NULL,
+    target_type VARCHAR(50)              NOT NULL DEFAULT '',
+    target_id   UUID,
+    ip_address  VARCHAR(45)              NOT NULL DEFAULT '',
+    user_agent  TEXT                     NOT NULL DEFAULT '',
+    request_id  VARCHAR(100)             NOT NULL DEFAULT '',
+    changes     JSONB,
+    metadata    JSONB
+);
+
+CREATE INDEX idx_audit_events_occurred_at ON audit_events (occurred_at DESC);
+CREATE INDEX idx_audit_events_actor ON audit_events (actor_id, occurred_at DESC);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id, occurred_at DESC);
+CREATE INDEX idx_audit_events_action ON audit_events (action, occurred_at DESC);
+
+-- Журнал только дополняется: изменить или удалить запись нельзя даже владельцу таблицы
+CREATE FUNCTION audit_events_append_only() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE
+    ON audit_events
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,38 @@
+-- Цепочка хешей журнала аудита: seq задает порядок записей, hash - SHA-256 от prev_hash и содержимого записи.
+-- Существующие записи получают seq в порядке хранения, но остаются без хеша: цепочка начинается после них.
+-- ALTER TABLE не вызывает строковые триггеры, поэтому запрет изменений ему не мешает
+ALTER TABLE audit_events
+    ADD COLUMN seq BIGSERIAL;
+ALTER TABLE audit_events
+    ALTER COLUMN seq DROP DEFAULT;
+DROP SEQUENCE audit_events_seq_seq;
+
+ALTER TABLE audit_events
+    ADD CONSTRAINT audit_events_seq_key UNIQUE (seq),
+    ADD COLUMN prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN hash      VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Подписанные контрольные точки цепочки; их можно выгрузить и заверить вне системы
+CREATE TABLE audit_checkpoints
+(
+    id         UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    seq        BIGINT                   NOT NULL,
+    hash       VARCHAR(64)              NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    public_key TEXT                     NOT NULL,
+    signature  TEXT                     NOT NULL
+);
+
+CREATE INDEX idx_audit_checkpoints_seq ON audit_checkpoints (seq);
+
+CREATE TRIGGER audit_checkpoints_no_update
+    BEFORE UPDATE OR DELETE
+    ON audit_checkpoints
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_checkpoints_no_truncate
+    BEFORE TRUNCATE
+    ON audit_checkpoints
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
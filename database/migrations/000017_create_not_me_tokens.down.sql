@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_not_me_tokens_user_id;
+DROP TABLE IF EXISTS not_me_tokens;
@@ -0,0 +1,14 @@
+-- Одноразовые ссылки «это был не я» из уведомлений безопасности; хранится только хеш токена.
+-- email - адрес, на который ушло уведомление: по нему сервер выбирает, куда отправить сброс пароля
+CREATE TABLE not_me_tokens
+(
+    id         UUID PRIMARY KEY         DEFAULT uuid_generate_v4(),
+    user_id    UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE       NOT NULL,
+    email      VARCHAR(255)             NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_not_me_tokens_user_id ON not_me_tokens (user_id);
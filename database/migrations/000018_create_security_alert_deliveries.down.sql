@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_security_alert_deliveries_created_at;
+DROP TABLE IF EXISTS security_alert_deliveries;
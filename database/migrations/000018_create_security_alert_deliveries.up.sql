@@ -0,0 +1,11 @@
+-- События, по которым уже поставлено уведомление безопасности: повторно доставленное событие
+-- не отправит второе письмо, даже если первое уже ушло из очереди
+CREATE TABLE security_alert_deliveries
+(
+    event_id   UUID PRIMARY KEY,
+    user_id    UUID                     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    alert      VARCHAR(30)              NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_security_alert_deliveries_created_at ON security_alert_deliveries (created_at);
@@ -0,0 +1,14 @@
+-- Перед откатом все записи должны быть в цепочке (devprep audit seal), иначе seq не станет NOT NULL
+DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+DROP FUNCTION IF EXISTS audit_events_seal_only();
+DROP INDEX IF EXISTS idx_audit_events_unsealed;
+
+ALTER TABLE audit_events
+    ALTER COLUMN seq SET NOT NULL;
@@ -0,0 +1,33 @@
+-- Записи журнала добавляются без номера и хеша, в цепочку их ставит отдельный процесс в своей
+-- короткой транзакции. Так транзакции изменений не ждут друг друга на общей блокировке цепочки
+ALTER TABLE audit_events
+    ALTER COLUMN seq DROP NOT NULL;
+
+CREATE INDEX idx_audit_events_unsealed ON audit_events (occurred_at, id) WHERE seq IS NULL;
+
+-- Единственное разрешенное изменение - один раз проставить seq, prev_hash и hash записи без номера.
+-- Содержимое записи при этом меняться не должно, удалять записи по-прежнему нельзя
+CREATE FUNCTION audit_events_seal_only() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND OLD.seq IS NULL AND OLD.hash = ''
+        AND NEW.seq IS NOT NULL AND NEW.hash <> ''
+        AND (NEW.id, NEW.occurred_at, NEW.actor_id, NEW.actor_role, NEW.source, NEW.action, NEW.target_type,
+             NEW.target_id, NEW.ip_address, NEW.user_agent, NEW.request_id, NEW.changes, NEW.metadata)
+            IS NOT DISTINCT FROM
+            (OLD.id, OLD.occurred_at, OLD.actor_id, OLD.actor_role, OLD.source, OLD.action, OLD.target_type,
+             OLD.target_id, OLD.ip_address, OLD.user_agent, OLD.request_id, OLD.changes, OLD.metadata) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER audit_events_no_update ON audit_events;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_seal_only();
@@ -0,0 +1,8 @@
+// Package migrations встраивает SQL-миграции в бинарник, чтобы приложение,
+// CLI и тесты применяли один и тот же набор файлов
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
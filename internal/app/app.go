@@ -1,41 +1,316 @@
 package app
 
 import (
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/audit"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/geoip"
 	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/jobs"
+	"github.com/AtlasOpx/devprep/internal/mail"
 	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/AtlasOpx/devprep/internal/outbox"
+	"github.com/AtlasOpx/devprep/internal/queue"
 	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/scheduler"
 	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+	"log/slog"
+	"os"
 )
 
 // Dependencies содержит все зависимости приложения
 type Dependencies struct {
-	AuthHandler    *handlers.AuthHandler
-	UserHandler    *handlers.UserHandler
-	AuthMiddleware *middleware.AuthMiddleware
+	AuthHandler        *handlers.AuthHandler
+	UserHandler        *handlers.UserHandler
+	DeviceHandler      *handlers.DeviceHandler
+	ExportHandler      *handlers.ExportHandler
+	EmailChangeHandler *handlers.EmailChangeHandler
+	JobHandler         *handlers.JobHandler
+	WebhookHandler     *handlers.WebhookHandler
+	MailHandler        *handlers.MailHandler
+	SecurityHandler    *handlers.SecurityHandler
+	AuditHandler       *handlers.AuditHandler
+	AuthMiddleware     *middleware.AuthMiddleware
+	// LoginRateLimit - общий лимит для входа и ввода кода подтверждения
+	LoginRateLimit fiber.Handler
+	Scheduler      *scheduler.Scheduler
+	Queue          *queue.Queue
+	Outbox         *outbox.Outbox
+	// AuditChain проверяет целостность журнала аудита и подписывает контрольные точки
+	AuditChain *audit.Chain
+	// AuditSealer в фоне ставит новые записи журнала в цепочку хешей
+	AuditSealer *audit.Sealer
+	// EventBus - приемник событий внутри процесса; на него подписываются модули приложения
+	EventBus *events.Bus
+
+	// Сервисы нужны CLI-командам, работающим без HTTP
+	AuthService *service.AuthService
+	UserService *service.UserService
+
+	closers []func()
+}
+
+// Close закрывает соединения с внешними приемниками событий
+func (d *Dependencies) Close() {
+	closeAll(d.closers)
 }
 
 // NewDependencies создает и инициализирует все зависимости
-func NewDependencies(db *database.DB, cfg *config.Config) *Dependencies {
+func NewDependencies(db *database.DB, cfg *config.Config) (*Dependencies, error) {
+	// Хранилище файлов
+	exportStorage, err := storage.NewLocalStorage(cfg.ExportStorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Почта
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mailTemplates, err := mail.NewRenderer()
+	if err != nil {
+		return nil, err
+	}
+
 	// Репозитории
 	userRepo := repository.NewUserRepository(db)
 	authRepo := repository.NewAuthRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	emailChangeRepo := repository.NewEmailChangeRepository(db)
+	jobRunRepo := repository.NewJobRunRepository(db)
+	queueRepo := repository.NewQueueRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	securityRepo := repository.NewSecurityRepository(db)
+	loginRiskRepo := repository.NewLoginRiskRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+
+	// Журнал аудита пишется сервисами в транзакциях изменений
+	auditLog := audit.New(auditRepo)
+	auditChain, err := newAuditChain(cfg, auditRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// GeoIP нужен только для проверки невозможного перемещения
+	geoLocator, err := newGeoLocator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Outbox: сервисы записывают события в транзакциях, relay публикует их в приемники
+	eventBus := events.NewBus()
+	sinks, closers, err := newEventSinks(cfg, eventBus)
+	if err != nil {
+		return nil, err
+	}
+	eventOutbox := outbox.New(outboxRepo, db, sinks, outbox.Options{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		BackoffBase:  cfg.OutboxBackoffBase,
+		BackoffMax:   cfg.OutboxBackoffMax,
+		Retention:    cfg.OutboxRetention,
+	})
+
+	// Очередь задач; обработчики регистрируются ниже, после создания сервисов
+	hostname, _ := os.Hostname()
+	jobQueue := queue.New(queueRepo, queue.Options{
+		Workers:      cfg.QueueWorkers,
+		PollInterval: cfg.QueuePollInterval,
+		MaxAttempts:  cfg.QueueMaxAttempts,
+		BackoffBase:  cfg.QueueBackoffBase,
+		BackoffMax:   cfg.QueueBackoffMax,
+		JobTimeout:   cfg.QueueJobTimeout,
+		StaleAfter:   cfg.QueueStaleAfter,
+		Retention:    cfg.QueueRetention,
+		WorkerID:     hostname,
+	})
 
 	// Сервисы
-	authService := service.NewAuthService(userRepo, authRepo)
-	userService := service.NewUserService(userRepo)
+	loginRiskService := service.NewLoginRiskService(db, loginRiskRepo, geoLocator, jobQueue, auditLog, mailTemplates, cfg)
+	authService := service.NewAuthService(db, userRepo, authRepo, eventOutbox, auditLog, loginRiskService)
+	userService := service.NewUserService(db, userRepo, eventOutbox, auditLog, cfg)
+	exportService, err := service.NewExportService(db, jobQueue, exportRepo, userRepo, authRepo, loginRiskRepo, auditRepo, exportStorage, auditLog, cfg)
+	if err != nil {
+		return nil, err
+	}
+	emailChangeService := service.NewEmailChangeService(db, userRepo, authRepo, emailChangeRepo, jobQueue, eventOutbox, auditLog, mailTemplates, cfg)
+	webhookService := service.NewWebhookService(db, webhookRepo, jobQueue, auditLog, cfg)
+	securityService := service.NewSecurityService(db, userRepo, securityRepo, authService, jobQueue, auditLog, mailTemplates, cfg)
+	auditService := service.NewAuditService(auditRepo)
+
+	// Обработчики задач очереди
+	queue.Register(jobQueue, service.JobSendEmail, service.SendEmailHandler(mailer))
+	queue.Register(jobQueue, service.JobBuildExport, exportService.BuildExport)
+	queue.Register(jobQueue, service.JobDeliverWebhook, webhookService.Deliver)
+
+	// Подписчики шины событий
+	eventBus.Subscribe(events.AllEvents, webhookService.HandleEvent)
+	for _, eventType := range []string{events.UserLoggedIn, events.UserPasswordReset, events.UserEmailChanged, events.SessionRevoked} {
+		eventBus.Subscribe(eventType, securityService.HandleEvent)
+	}
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(authService, authRepo, cfg)
+	authHandler := handlers.NewAuthHandler(authService, cfg)
 	userHandler := handlers.NewUserHandler(userService)
+	deviceHandler := handlers.NewDeviceHandler(loginRiskService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	emailChangeHandler := handlers.NewEmailChangeHandler(emailChangeService)
+	jobHandler := handlers.NewJobHandler(jobQueue, db, auditLog)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	mailHandler := handlers.NewMailHandler(mailTemplates, cfg)
+	securityHandler := handlers.NewSecurityHandler(securityService)
+	auditHandler := handlers.NewAuditHandler(auditService)
 
 	// Middleware
 	authMiddleware := middleware.NewAuthMiddleware(authRepo)
 
+	// Фоновые задачи
+	jobScheduler := scheduler.New(db, jobRunRepo, scheduler.Options{
+		Jitter:   cfg.SchedulerJitter,
+		Timeout:  cfg.SchedulerJobTimeout,
+		Instance: hostname,
+	})
+	err = jobs.RegisterMaintenance(jobScheduler, jobs.MaintenanceDeps{
+		Config:             cfg,
+		AuthService:        authService,
+		UserService:        userService,
+		EmailChangeService: emailChangeService,
+		SecurityService:    securityService,
+		LoginRiskService:   loginRiskService,
+		ExportService:      exportService,
+		WebhookService:     webhookService,
+		JobRuns:            jobRunRepo,
+		Queue:              jobQueue,
+		Outbox:             eventOutbox,
+		AuditChain:         auditChain,
+	})
+	if err != nil {
+		closeAll(closers)
+		return nil, err
+	}
+
 	return &Dependencies{
-		AuthHandler:    authHandler,
-		UserHandler:    userHandler,
-		AuthMiddleware: authMiddleware,
+		AuthHandler:        authHandler,
+		UserHandler:        userHandler,
+		DeviceHandler:      deviceHandler,
+		ExportHandler:      exportHandler,
+		EmailChangeHandler: emailChangeHandler,
+		JobHandler:         jobHandler,
+		WebhookHandler:     webhookHandler,
+		MailHandler:        mailHandler,
+		SecurityHandler:    securityHandler,
+		AuditHandler:       auditHandler,
+		AuthMiddleware:     authMiddleware,
+		LoginRateLimit:     middleware.LoginRateLimit(cfg.LoginRateLimit, cfg.LoginRateLimitWindow),
+		Scheduler:          jobScheduler,
+		Queue:              jobQueue,
+		Outbox:             eventOutbox,
+		AuditChain:         auditChain,
+		AuditSealer:        audit.NewSealer(auditChain, cfg.AuditSealInterval),
+		EventBus:           eventBus,
+		AuthService:        authService,
+		UserService:        userService,
+		closers:            closers,
+	}, nil
+}
+
+// newAuditChain создает цепочку журнала аудита с ключом подписи из AUDIT_SIGNING_KEY
+func newAuditChain(cfg *config.Config, store audit.ChainStore) (*audit.Chain, error) {
+	if cfg.AuditSigningKey == "" {
+		slog.Warn("AUDIT_SIGNING_KEY is not set, audit checkpoints are disabled")
+		return audit.NewChain(store, nil), nil
+	}
+
+	key, err := audit.ParseSigningKey(cfg.AuditSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewChain(store, key), nil
+}
+
+// newMailer выбирает транспорт почты по MAIL_TRANSPORT
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	switch cfg.MailTransport {
+	case "console":
+		return mail.NewConsoleMailer(), nil
+	case "file":
+		return mail.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPOptions{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			TLS:      cfg.SMTPTLS,
+			Timeout:  cfg.SMTPTimeout,
+		})
 	}
-}
\ No newline at end of file
+	return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", cfg.MailTransport)
+}
+
+// newGeoLocator загружает таблицу GeoIP из GEOIP_FILES; без файлов возвращает nil
+func newGeoLocator(cfg *config.Config) (geoip.Locator, error) {
+	if len(cfg.GeoIPFiles) == 0 {
+		return nil, nil
+	}
+	table, err := geoip.LoadCSV(cfg.GeoIPFiles...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading GeoIP data: %w", err)
+	}
+	slog.Info("loaded GeoIP data", "networks", table.Len())
+	return table, nil
+}
+
+// newEventSinks собирает приемники событий из настроек; шина в процессе подключена всегда
+func newEventSinks(cfg *config.Config, bus *events.Bus) ([]outbox.Sink, []func(), error) {
+	sinks := []outbox.Sink{bus}
+	var closers []func()
+
+	if cfg.OutboxWebhookURL != "" {
+		sinks = append(sinks, outbox.NewWebhookSink(cfg.OutboxWebhookURL, cfg.OutboxWebhookTimeout))
+	}
+
+	if cfg.NATSURL != "" {
+		// Без RetryOnFailedConnect недоступный NATS не дал бы запустить приложение;
+		// события тем временем копятся в outbox
+		nc, err := nats.Connect(cfg.NATSURL, nats.Name("devprep"), nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("error connecting to NATS: %w", err)
+		}
+		js, err := nc.JetStream()
+		if err != nil {
+			nc.Close()
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("error creating JetStream context: %w", err)
+		}
+		sinks = append(sinks, outbox.NewNATSSink(js, cfg.NATSSubjectPrefix))
+		closers = append(closers, nc.Close)
+	}
+
+	if len(cfg.KafkaBrokers) > 0 {
+		writer := outbox.NewKafkaWriter(cfg.KafkaBrokers, cfg.KafkaTopic)
+		sinks = append(sinks, outbox.NewKafkaSink(writer))
+		closers = append(closers, func() {
+			if err := writer.Close(); err != nil {
+				slog.Error("failed to close Kafka writer", "error", err)
+			}
+		})
+	}
+
+	return sinks, closers, nil
+}
+
+func closeAll(closers []func()) {
+	for _, closeFn := range closers {
+		closeFn()
+	}
+}
@@ -0,0 +1,130 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code - стабильный машинный код ошибки. Коды попадают в API (поле code и type URI),
+// поэтому менять существующие нельзя
+type Code string
+
+const (
+	CodeInternal           Code = "internal_error"
+	CodeNotFound           Code = "not_found"
+	CodeInvalidBody        Code = "invalid_request_body"
+	CodeValidation         Code = "validation_failed"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeSessionExpired     Code = "session_expired"
+	CodeNoSession          Code = "no_session"
+	CodeForbidden          Code = "forbidden"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeAccountDisabled    Code = "account_disabled"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodeEmailTaken         Code = "email_taken"
+	CodeUsernameTaken      Code = "username_taken"
+	CodeEmailUnchanged     Code = "email_unchanged"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeTokenExpired       Code = "token_expired"
+	CodeRateLimited        Code = "rate_limited"
+	CodeExportNotReady     Code = "export_not_ready"
+	CodeQueryCanceled      Code = "query_canceled"
+	CodeJobNotRetryable    Code = "job_not_retryable"
+	CodeWebhookDisabled    Code = "webhook_disabled"
+	CodeLoginBlocked       Code = "login_blocked"
+)
+
+// FieldError описывает одну ошибку валидации в терминах JSON-полей запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Param - параметр правила (например, длина для min), нужен для перевода сообщения
+	Param string `json:"-"`
+}
+
+// Error - доменная ошибка с кодом. Сравнение через errors.Is идет по коду,
+// поэтому ошибка с уточненным сообщением совпадает со своим sentinel-значением.
+// Key - ключ сообщения в каталоге i18n, Message - английский текст на случай, если перевода нет
+type Error struct {
+	Code    Code
+	Key     string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Key: "error." + string(code), Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Code == e.Code
+}
+
+// WithMessage возвращает копию ошибки с другим сообщением для клиента;
+// key - ключ этого сообщения в каталоге i18n
+func (e *Error) WithMessage(key, message string) *Error {
+	clone := *e
+	clone.Key = key
+	clone.Message = message
+	return &clone
+}
+
+// Wrap возвращает копию ошибки с причиной; причина не показывается клиенту
+func (e *Error) Wrap(err error) *Error {
+	clone := *e
+	clone.Err = err
+	return &clone
+}
+
+// Validation возвращает ошибку валидации со списком полей
+func Validation(fields []FieldError) *Error {
+	return &Error{Code: CodeValidation, Key: ErrValidation.Key, Message: ErrValidation.Message, Fields: fields}
+}
+
+// CodeOf возвращает код доменной ошибки или CodeInternal для всех остальных
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
+
+var (
+	ErrInternal           = New(CodeInternal, "Internal server error")
+	ErrNotFound           = New(CodeNotFound, "Resource not found")
+	ErrInvalidBody        = New(CodeInvalidBody, "Invalid request body")
+	ErrValidation         = New(CodeValidation, "Validation failed")
+	ErrUnauthorized       = New(CodeUnauthorized, "Authentication required")
+	ErrSessionExpired     = New(CodeSessionExpired, "Session expired")
+	ErrNoSession          = New(CodeNoSession, "No session token")
+	ErrForbidden          = New(CodeForbidden, "Access denied")
+	ErrInvalidCredentials = New(CodeInvalidCredentials, "Invalid credentials")
+	ErrAccountDisabled    = New(CodeAccountDisabled, "Account is disabled")
+	ErrInvalidPassword    = New(CodeInvalidPassword, "Invalid current password")
+	ErrEmailTaken         = New(CodeEmailTaken, "Email is already taken")
+	ErrUsernameTaken      = New(CodeUsernameTaken, "Username is already taken")
+	ErrEmailUnchanged     = New(CodeEmailUnchanged, "New email matches the current one")
+	ErrInvalidToken       = New(CodeInvalidToken, "Invalid or already used link")
+	ErrTokenExpired       = New(CodeTokenExpired, "Link expired")
+	ErrRateLimited        = New(CodeRateLimited, "Too many requests")
+	ErrExportNotReady     = New(CodeExportNotReady, "Export is not ready yet")
+	ErrQueryCanceled      = New(CodeQueryCanceled, "Request took too long or was canceled")
+	ErrJobNotRetryable    = New(CodeJobNotRetryable, "Only dead-lettered jobs can be retried")
+	ErrWebhookDisabled    = New(CodeWebhookDisabled, "Webhook endpoint is disabled")
+	ErrLoginBlocked       = New(CodeLoginBlocked, "Sign-in blocked as suspicious")
+)
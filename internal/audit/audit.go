@@ -0,0 +1,190 @@
+// Package audit ведет журнал аудита: сервисы записывают в него действия пользователей
+// и администраторов в той же транзакции, что и само изменение
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/google/uuid"
+	"reflect"
+	"time"
+)
+
+// Действия. Префикс - область: auth - вход и выход, user - учетная запись, session и device - сессии
+// и устройства пользователя, webhook и job - объекты администратора. Сессии и устройства записываются
+// с объектом-пользователем, чтобы вся история учетной записи находилась по target_id
+const (
+	LoginSucceeded     = "auth.login"
+	LoginFailed        = "auth.login_failed"
+	LoginChallenged    = "auth.login_challenged"
+	LoginBlocked       = "auth.login_blocked"
+	Logout             = "auth.logout"
+	UserCreated        = "user.created"
+	ProfileUpdated     = "user.profile_updated"
+	RoleChanged        = "user.role_changed"
+	PasswordReset      = "user.password_reset"
+	EmailChangeRequest = "user.email_change_requested"
+	EmailChanged       = "user.email_changed"
+	EmailReverted      = "user.email_change_reverted"
+	AlertsUpdated      = "user.security_alerts_updated"
+	CompromiseReported = "user.compromise_reported"
+	ExportRequested    = "user.export_requested"
+	UserDeactivated    = "user.deactivated"
+	UserDeleted        = "user.deleted"
+	UserRestored       = "user.restored"
+	UsersPurged        = "user.purged"
+	SessionRevoked     = "session.revoked"
+	SessionsRevoked    = "session.revoked_all"
+	DeviceForgotten    = "device.forgotten"
+	WebhookCreated     = "webhook.created"
+	WebhookUpdated     = "webhook.updated"
+	WebhookDeleted     = "webhook.deleted"
+	WebhookRotated     = "webhook.secret_rotated"
+	WebhookRedelivered = "webhook.redelivered"
+	JobRetried         = "job.retried"
+)
+
+// Типы объектов действия
+const (
+	TargetUser    = "user"
+	TargetWebhook = "webhook"
+	TargetJob     = "job"
+)
+
+// Источники действий
+const (
+	SourceAPI    = "api"
+	SourceCLI    = "cli"
+	SourceSystem = "system"
+)
+
+// Entry - действие, которое нужно записать. Адрес, User-Agent и ID запроса берутся из контекста
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   uuid.UUID
+	// Actor - исполнитель, если его нет в контексте: например, пользователь при входе
+	Actor *Actor
+	// Changes - изменения объекта, обычно из Diff
+	Changes *Changes
+	// Metadata - подробности действия, без секретов
+	Metadata map[string]interface{}
+}
+
+// Changes - значения измененных полей до и после действия
+type Changes struct {
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// Recorder записывает действия в журнал; сервисы зависят от него, а не от Log
+type Recorder interface {
+	Record(ctx context.Context, entry Entry) error
+}
+
+// Store - хранилище журнала (таблица audit_events). Append сохраняет запись без номера и хеша,
+// в цепочку ее потом ставит Chain.Seal
+type Store interface {
+	Append(ctx context.Context, event *models.AuditEvent) error
+}
+
+// Log - журнал аудита в БД
+type Log struct {
+	store Store
+}
+
+func New(store Store) *Log {
+	return &Log{store: store}
+}
+
+// Record дополняет действие исполнителем и клиентом из контекста и записывает его.
+// Вызывается внутри транзакции изменения, чтобы журнал и данные не расходились
+func (l *Log) Record(ctx context.Context, entry Entry) error {
+	event, err := NewEvent(ctx, entry)
+	if err != nil {
+		return err
+	}
+	return l.store.Append(ctx, event)
+}
+
+// NewEvent собирает запись журнала из действия и контекста
+func NewEvent(ctx context.Context, entry Entry) (*models.AuditEvent, error) {
+	client := events.ClientFrom(ctx)
+	event := &models.AuditEvent{
+		ID: uuid.New(),
+		// Время обрезается до микросекунд, как его хранит PostgreSQL, иначе хеш после чтения не сойдется
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Source:     SourceFrom(ctx),
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		IPAddress:  client.IPAddress,
+		UserAgent:  client.UserAgent,
+		RequestID:  client.RequestID,
+	}
+
+	actor, ok := ActorFrom(ctx)
+	if entry.Actor != nil {
+		actor, ok = *entry.Actor, true
+	}
+	if ok {
+		actorID := actor.ID
+		event.ActorID = &actorID
+		event.ActorRole = string(actor.Role)
+	}
+	if entry.TargetID != uuid.Nil {
+		targetID := entry.TargetID
+		event.TargetID = &targetID
+	}
+
+	var err error
+	if entry.Changes != nil {
+		if event.Changes, err = json.Marshal(entry.Changes); err != nil {
+			return nil, fmt.Errorf("error encoding audit changes: %w", err)
+		}
+	}
+	if len(entry.Metadata) > 0 {
+		if event.Metadata, err = json.Marshal(entry.Metadata); err != nil {
+			return nil, fmt.Errorf("error encoding audit metadata: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// Diff сравнивает два состояния объекта по их JSON-представлению и возвращает только
+// различающиеся поля. Поля с json:"-" (например, хеш пароля) в журнал не попадают.
+// nil, если ничего не изменилось
+func Diff(before, after interface{}) *Changes {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+
+	changes := &Changes{Before: map[string]interface{}{}, After: map[string]interface{}{}}
+	for key, value := range beforeFields {
+		if other, ok := afterFields[key]; !ok || !reflect.DeepEqual(value, other) {
+			changes.Before[key] = value
+		}
+	}
+	for key, value := range afterFields {
+		if other, ok := beforeFields[key]; !ok || !reflect.DeepEqual(value, other) {
+			changes.After[key] = value
+		}
+	}
+	if len(changes.Before) == 0 && len(changes.After) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func jsonFields(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,401 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+// verifyBatchSize - сколько записей Verify читает за один запрос
+const verifyBatchSize = 500
+
+// sealBatchSize - сколько записей Seal ставит в цепочку за одну транзакцию
+const sealBatchSize = 500
+
+// checkpointDomain отделяет подписи контрольных точек от любых других подписей тем же ключом
+const checkpointDomain = "devprep-audit-checkpoint"
+
+// ChainStore - хранилище цепочки хешей и контрольных точек
+type ChainStore interface {
+	SealPending(ctx context.Context, limit int, seal func(event *models.AuditEvent, last *models.AuditChainLink) error) (int64, error)
+	ChainHead(ctx context.Context) (*models.AuditChainLink, error)
+	ListChain(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error)
+	CreateCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	LatestCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error)
+	ListCheckpoints(ctx context.Context, afterSeq int64) ([]models.AuditCheckpoint, error)
+}
+
+// Seal ставит запись в цепочку следом за last (nil - журнал пуст): задает номер,
+// хеш предыдущей записи и хеш самой записи
+func Seal(event *models.AuditEvent, last *models.AuditChainLink) error {
+	event.Seq, event.PrevHash = 1, ""
+	if last != nil {
+		event.Seq, event.PrevHash = last.Seq+1, last.Hash
+	}
+
+	hash, err := ChainHash(event)
+	if err != nil {
+		return err
+	}
+	event.Hash = hash
+	return nil
+}
+
+// chainContent - содержимое записи, которое покрывает хеш. Порядок полей фиксирован,
+// JSON-поля приведены к каноническому виду, так что хеш не зависит от того, как их вернул JSONB
+type chainContent struct {
+	Seq        int64           `json:"seq"`
+	ID         uuid.UUID       `json:"id"`
+	OccurredAt string          `json:"occurred_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Source     string          `json:"source"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   *uuid.UUID      `json:"target_id"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+	Changes    json.RawMessage `json:"changes"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// ChainHash считает SHA-256 (hex) от хеша предыдущей записи и содержимого записи
+func ChainHash(event *models.AuditEvent) (string, error) {
+	changes, err := canonicalJSON(event.Changes)
+	if err != nil {
+		return "", fmt.Errorf("error canonicalizing audit changes: %w", err)
+	}
+	metadata, err := canonicalJSON(event.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("error canonicalizing audit metadata: %w", err)
+	}
+
+	content, err := json.Marshal(chainContent{
+		Seq:        event.Seq,
+		ID:         event.ID,
+		OccurredAt: event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    event.ActorID,
+		ActorRole:  event.ActorRole,
+		Source:     event.Source,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IPAddress:  event.IPAddress,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Changes:    changes,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(event.PrevHash))
+	sum.Write([]byte("\n"))
+	sum.Write(content)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// canonicalJSON заново кодирует JSON: ключи объектов сортируются, пробелы убираются, числа
+// сохраняются как записаны. Пустое значение - null
+func canonicalJSON(data json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return json.RawMessage("null"), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// CheckpointMessage - байты, которые подписывает контрольная точка. По ним подпись можно
+// проверить вне системы: "devprep-audit-checkpoint\n<seq>\n<hash>\n<created_at в RFC 3339>"
+func CheckpointMessage(checkpoint *models.AuditCheckpoint) []byte {
+	return []byte(fmt.Sprintf("%s\n%d\n%s\n%s", checkpointDomain, checkpoint.Seq, checkpoint.Hash,
+		checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// ParseSigningKey разбирает ключ подписи ed25519 в base64: 32-байтовый seed или 64-байтовый закрытый ключ
+func ParseSigningKey(value string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit signing key: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		// Вторая половина 64-байтового ключа - публичный ключ, он должен соответствовать seed
+		key := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize])
+		if !bytes.Equal(key, raw) {
+			return nil, errors.New("invalid audit signing key: public part does not match the seed")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid audit signing key: expected %d or %d bytes, got %d",
+		ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+// ParsePublicKey разбирает публичный ключ ed25519 в base64
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid audit public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return raw, nil
+}
+
+// Chain подписывает контрольные точки и проверяет целостность журнала
+type Chain struct {
+	store ChainStore
+	key   ed25519.PrivateKey
+}
+
+// NewChain создает цепочку; без ключа (nil) контрольные точки не создаются, но проверка работает
+func NewChain(store ChainStore, key ed25519.PrivateKey) *Chain {
+	return &Chain{store: store, key: key}
+}
+
+// CanSign сообщает, задан ли ключ подписи контрольных точек
+func (c *Chain) CanSign() bool {
+	return c.key != nil
+}
+
+// PublicKey возвращает публичный ключ подписи; nil, если ключа нет
+func (c *Chain) PublicKey() ed25519.PublicKey {
+	if c.key == nil {
+		return nil
+	}
+	return c.key.Public().(ed25519.PublicKey)
+}
+
+// Seal ставит в цепочку все записи, добавленные в журнал после прошлого вызова, и возвращает их число.
+// Если цепочку сейчас продолжает другая реплика, возвращает 0
+func (c *Chain) Seal(ctx context.Context) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		sealed, err := c.store.SealPending(ctx, sealBatchSize, Seal)
+		total += sealed
+		if err != nil || sealed < sealBatchSize {
+			return total, err
+		}
+	}
+	return total, ctx.Err()
+}
+
+// Checkpoint подписывает текущую голову цепочки, если она сдвинулась с прошлой контрольной точки.
+// Перед этим ставит в цепочку накопившиеся записи, чтобы точка их покрыла.
+// Возвращает число созданных точек (0 или 1) - в таком виде его ждет планировщик
+func (c *Chain) Checkpoint(ctx context.Context) (int64, error) {
+	if c.key == nil {
+		return 0, errors.New("audit signing key is not configured")
+	}
+	if _, err := c.Seal(ctx); err != nil {
+		return 0, err
+	}
+
+	head, err := c.store.ChainHead(ctx)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if head.Hash == "" {
+		// В журнале только записи, сделанные до появления цепочки
+		return 0, nil
+	}
+
+	latest, err := c.store.LatestCheckpoint(ctx)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		return 0, err
+	}
+	if latest != nil && latest.Seq >= head.Seq {
+		return 0, nil
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		ID:        uuid.New(),
+		Seq:       head.Seq,
+		Hash:      head.Hash,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		PublicKey: base64.StdEncoding.EncodeToString(c.PublicKey()),
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(c.key, CheckpointMessage(checkpoint)))
+
+	if err := c.store.CreateCheckpoint(ctx, checkpoint); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// Checkpoints возвращает контрольные точки после записи afterSeq для внешнего заверения
+func (c *Chain) Checkpoints(ctx context.Context, afterSeq int64) ([]models.AuditCheckpoint, error) {
+	return c.store.ListCheckpoints(ctx, afterSeq)
+}
+
+// Report - результат проверки журнала
+type Report struct {
+	// Records - сколько записей проверено, Unchained - из них записей, сделанных до появления цепочки
+	Records   int64  `json:"records"`
+	Unchained int64  `json:"unchained"`
+	HeadSeq   int64  `json:"head_seq"`
+	HeadHash  string `json:"head_hash,omitempty"`
+	// Checkpoints - сколько контрольных точек проверено
+	Checkpoints int `json:"checkpoints"`
+	// Broken - первое нарушение; nil, если журнал цел
+	Broken *BrokenLink `json:"broken,omitempty"`
+}
+
+// OK сообщает, что нарушений не найдено
+func (r *Report) OK() bool {
+	return r.Broken == nil
+}
+
+// BrokenLink - первое найденное нарушение цепочки или контрольной точки
+type BrokenLink struct {
+	Seq          int64      `json:"seq"`
+	EventID      *uuid.UUID `json:"event_id,omitempty"`
+	CheckpointID *uuid.UUID `json:"checkpoint_id,omitempty"`
+	Reason       string     `json:"reason"`
+}
+
+// Verify проходит журнал от первой записи и останавливается на первом нарушении: пропуске номера
+// (удаленная запись), несовпадении хеша предыдущей записи или хеша содержимого (измененная запись),
+// записи без хеша после начала цепочки. Контрольные точки проверяются по пути: подпись, ключ trusted
+// (если задан) и совпадение хеша записи; точка дальше конца журнала означает, что его хвост удален.
+// Цепочку, пересчитанную целиком после правки, выдают только контрольные точки.
+// Записи, которые еще не поставлены в цепочку, не проверяются
+func (c *Chain) Verify(ctx context.Context, trusted ed25519.PublicKey) (*Report, error) {
+	checkpoints, err := c.store.ListCheckpoints(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	var (
+		prevHash string
+		chained  bool
+	)
+	for {
+		batch, err := c.store.ListChain(ctx, report.HeadSeq, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range batch {
+			event := &batch[i]
+			if broken := checkLink(event, report.HeadSeq, prevHash, chained); broken != nil {
+				report.Broken = broken
+				return report, nil
+			}
+
+			report.Records++
+			if event.Hash == "" {
+				report.Unchained++
+			}
+			chained = chained || event.Hash != ""
+			prevHash, report.HeadSeq, report.HeadHash = event.Hash, event.Seq, event.Hash
+
+			for len(checkpoints) > 0 && checkpoints[0].Seq <= event.Seq {
+				if broken := checkCheckpoint(&checkpoints[0], event, trusted); broken != nil {
+					report.Broken = broken
+					return report, nil
+				}
+				report.Checkpoints++
+				checkpoints = checkpoints[1:]
+			}
+		}
+
+		if len(batch) < verifyBatchSize {
+			break
+		}
+	}
+
+	if len(checkpoints) > 0 {
+		checkpoint := checkpoints[0]
+		report.Broken = &BrokenLink{
+			Seq:          checkpoint.Seq,
+			CheckpointID: &checkpoint.ID,
+			Reason:       fmt.Sprintf("checkpoint references record %d, but the log ends at %d", checkpoint.Seq, report.HeadSeq),
+		}
+	}
+	return report, nil
+}
+
+// checkLink проверяет запись относительно предыдущей (prevSeq, prevHash)
+func checkLink(event *models.AuditEvent, prevSeq int64, prevHash string, chained bool) *BrokenLink {
+	broken := func(reason string) *BrokenLink {
+		id := event.ID
+		return &BrokenLink{Seq: event.Seq, EventID: &id, Reason: reason}
+	}
+
+	if event.Seq != prevSeq+1 {
+		return broken(fmt.Sprintf("records %d-%d are missing", prevSeq+1, event.Seq-1))
+	}
+	if event.Hash == "" {
+		if chained {
+			return broken("record is not hashed")
+		}
+		return nil
+	}
+	if event.PrevHash != prevHash {
+		return broken("previous hash does not match the previous record")
+	}
+
+	hash, err := ChainHash(event)
+	if err != nil {
+		return broken(err.Error())
+	}
+	if hash != event.Hash {
+		return broken("record content does not match its hash")
+	}
+	return nil
+}
+
+// checkCheckpoint проверяет подпись точки и то, что она указывает на запись event
+func checkCheckpoint(checkpoint *models.AuditCheckpoint, event *models.AuditEvent, trusted ed25519.PublicKey) *BrokenLink {
+	broken := func(reason string) *BrokenLink {
+		id := checkpoint.ID
+		return &BrokenLink{Seq: checkpoint.Seq, CheckpointID: &id, Reason: reason}
+	}
+
+	publicKey, err := ParsePublicKey(checkpoint.PublicKey)
+	if err != nil {
+		return broken(err.Error())
+	}
+	if trusted != nil && !publicKey.Equal(trusted) {
+		return broken("checkpoint is signed by an untrusted key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil || !ed25519.Verify(publicKey, CheckpointMessage(checkpoint), signature) {
+		return broken("checkpoint signature is invalid")
+	}
+
+	if checkpoint.Seq != event.Seq {
+		return broken(fmt.Sprintf("checkpoint references missing record %d", checkpoint.Seq))
+	}
+	if checkpoint.Hash != event.Hash {
+		return broken("record hash does not match the signed checkpoint")
+	}
+	return nil
+}
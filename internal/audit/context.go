@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/google/uuid"
+)
+
+// Actor - пользователь, выполняющий действие
+type Actor struct {
+	ID   uuid.UUID
+	Role models.UserRole
+}
+
+type actorKey struct{}
+
+type sourceKey struct{}
+
+// WithActor кладет в контекст пользователя текущего запроса
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom возвращает пользователя из контекста; false, если запрос без сессии
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// WithSource помечает контекст источником действий: SourceAPI или SourceCLI
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom возвращает источник действий; без пометки - SourceSystem
+func SourceFrom(ctx context.Context) string {
+	if source, ok := ctx.Value(sourceKey{}).(string); ok {
+		return source
+	}
+	return SourceSystem
+}
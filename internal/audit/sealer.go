@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"sync"
+	"time"
+)
+
+// Sealer в фоне ставит новые записи журнала в цепочку хешей. Записи сервисов попадают
+// в журнал без номера, поэтому транзакции изменений не выстраиваются в очередь на блокировке цепочки
+type Sealer struct {
+	chain    *Chain
+	interval time.Duration
+
+	mu         sync.Mutex
+	started    bool
+	stopLoop   context.CancelFunc
+	cancelRuns context.CancelFunc
+	done       chan struct{}
+}
+
+// NewSealer создает фоновый процесс; interval - пауза между проходами, по умолчанию секунда
+func NewSealer(chain *Chain, interval time.Duration) *Sealer {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Sealer{chain: chain, interval: interval}
+}
+
+// Start запускает фоновый проход по новым записям
+func (s *Sealer) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	s.stopLoop, s.cancelRuns = stopLoop, cancelRuns
+	s.done = make(chan struct{})
+
+	go s.loop(loopCtx, runCtx)
+}
+
+// Shutdown останавливает процесс и ждет завершения текущего прохода. Если ctx истекает раньше,
+// проход отменяется: оставшиеся записи встанут в цепочку после перезапуска
+func (s *Sealer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopLoop()
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		s.cancelRuns()
+		return nil
+	case <-ctx.Done():
+		s.cancelRuns()
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+func (s *Sealer) loop(loopCtx, runCtx context.Context) {
+	defer close(s.done)
+
+	for {
+		if _, err := s.chain.Seal(runCtx); err != nil && loopCtx.Err() == nil {
+			logging.FromContext(runCtx).Error("audit chain sealing failed", "error", err)
+		}
+
+		select {
+		case <-loopCtx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
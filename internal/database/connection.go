@@ -1,11 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/Masterminds/squirrel"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
@@ -17,33 +19,52 @@ func Connect(cfg *config.Config) (*DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
 
-	sqlDB, err := sql.Open("postgres", dsn)
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
-
-	if err = sqlDB.Ping(); err != nil {
+	db := Open(connector)
+	if err = db.Ping(); err != nil {
 		return nil, fmt.Errorf("error pinging database: %w", err)
 	}
 
+	return db, nil
+}
+
+// Open открывает пул соединений поверх коннектора драйвера. Запросы внутри трассы становятся span,
+// см. tracedConnector
+func Open(connector driver.Connector) *DB {
+	return New(sql.OpenDB(tracedConnector{Connector: connector}))
+}
+
+// New оборачивает уже открытое соединение
+func New(sqlDB *sql.DB) *DB {
 	return &DB{
 		DB:      sqlDB,
 		Builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).RunWith(sqlDB),
-	}, nil
+	}
+}
+
+// builder возвращает построитель запросов транзакции из ctx, а вне транзакции - пула соединений
+func (db *DB) builder(ctx context.Context) squirrel.StatementBuilderType {
+	if scope, ok := ctx.Value(txKey{}).(*txScope); ok {
+		return scope.builder
+	}
+	return db.Builder
 }
 
-func (db *DB) Select(columns ...string) squirrel.SelectBuilder {
-	return db.Builder.Select(columns...)
+func (db *DB) Select(ctx context.Context, columns ...string) squirrel.SelectBuilder {
+	return db.builder(ctx).Select(columns...)
 }
 
-func (db *DB) Insert(table string) squirrel.InsertBuilder {
-	return db.Builder.Insert(table)
+func (db *DB) Insert(ctx context.Context, table string) squirrel.InsertBuilder {
+	return db.builder(ctx).Insert(table)
 }
 
-func (db *DB) Update(table string) squirrel.UpdateBuilder {
-	return db.Builder.Update(table)
+func (db *DB) Update(ctx context.Context, table string) squirrel.UpdateBuilder {
+	return db.builder(ctx).Update(table)
 }
 
-func (db *DB) Delete(table string) squirrel.DeleteBuilder {
-	return db.Builder.Delete(table)
+func (db *DB) Delete(ctx context.Context, table string) squirrel.DeleteBuilder {
+	return db.builder(ctx).Delete(table)
 }
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"hash/fnv"
+)
+
+// TryAdvisoryLock берет сессионный advisory lock Postgres по имени, не дожидаясь его освобождения.
+// Lock держит отдельное соединение из пула до вызова unlock; acquired = false, если lock занят
+func (db *DB) TryAdvisoryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error) {
+	key := advisoryLockKey(name)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error acquiring advisory lock %q: %w", name, err)
+	}
+
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("error acquiring advisory lock %q: %w", name, err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock = func() {
+		// Снимаем lock и при отмененном ctx задачи. Если не получилось, соединение
+		// выбрасывается из пула: lock освободится вместе с сессией Postgres
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			logging.FromContext(ctx).Error("failed to release advisory lock", "lock", name, "error", err)
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// advisoryLockKey переводит имя lock в ключ bigint для pg_*_advisory_lock
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("devprep:" + name))
+	return int64(h.Sum64())
+}
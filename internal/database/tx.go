@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"math/rand"
+	"time"
+)
+
+const (
+	// maxTxAttempts - сколько раз выполняется транзакция при конфликтах сериализации
+	maxTxAttempts = 3
+	txRetryDelay  = 20 * time.Millisecond
+
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// TxFunc - тело транзакции. Все запросы через переданный ctx выполняются внутри нее
+type TxFunc func(ctx context.Context) error
+
+// Transactor запускает функцию в транзакции; сервисы зависят от него, а не от DB
+type Transactor interface {
+	WithTx(ctx context.Context, fn TxFunc) error
+}
+
+type txKey struct{}
+
+// txScope - транзакция, привязанная к контексту. depth - уровень вложенности для имен savepoint
+type txScope struct {
+	tx      *sql.Tx
+	builder squirrel.StatementBuilderType
+	depth   int
+}
+
+// WithTx выполняет fn в транзакции с уровнем изоляции по умолчанию
+func (db *DB) WithTx(ctx context.Context, fn TxFunc) error {
+	return db.WithTxOptions(ctx, nil, fn)
+}
+
+// WithTxOptions выполняет fn в транзакции: коммит, если fn вернула nil, иначе откат.
+// Если в ctx уже есть транзакция, fn выполняется внутри нее под savepoint, и ее ошибка
+// откатывает только изменения fn. Конфликты сериализации и дедлоки повторяются целиком
+// только на верхнем уровне, поэтому fn должна быть готова к повторному запуску
+func (db *DB) WithTxOptions(ctx context.Context, opts *sql.TxOptions, fn TxFunc) error {
+	if scope, ok := ctx.Value(txKey{}).(*txScope); ok {
+		return withSavepoint(ctx, scope, fn)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = db.runTx(ctx, opts, fn)
+		if err == nil || !isRetryableTxError(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		delay := time.Duration(attempt)*txRetryDelay + time.Duration(rand.Int63n(int64(txRetryDelay)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
+
+func (db *DB) runTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) (err error) {
+	// span транзакции закрывается последним, после коммита или отката
+	ctx, span := tracing.StartChild(ctx, "db.transaction")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("error committing transaction: %w", err)
+		}
+	}()
+
+	scope := &txScope{
+		tx:      tx,
+		builder: db.Builder.RunWith(tx),
+	}
+	return fn(context.WithValue(ctx, txKey{}, scope))
+}
+
+func withSavepoint(ctx context.Context, parent *txScope, fn TxFunc) (err error) {
+	scope := &txScope{tx: parent.tx, builder: parent.builder, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", scope.depth)
+
+	if _, err := scope.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("error creating savepoint: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = scope.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+		if err != nil {
+			if _, rbErr := scope.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+				err = fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+			}
+			return
+		}
+		if _, relErr := scope.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); relErr != nil {
+			err = fmt.Errorf("error releasing savepoint: %w", relErr)
+		}
+	}()
+
+	return fn(context.WithValue(ctx, txKey{}, scope))
+}
+
+// InTx сообщает, выполняется ли код с этим контекстом внутри транзакции
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txScope)
+	return ok
+}
+
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+}
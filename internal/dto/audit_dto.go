@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type AuditEventResponse struct {
+	ID         uuid.UUID       `json:"id"`
+	Seq        int64           `json:"seq"`
+	Hash       string          `json:"hash,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *uuid.UUID      `json:"actor_id,omitempty"`
+	ActorRole  string          `json:"actor_role,omitempty"`
+	Source     string          `json:"source"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   *uuid.UUID      `json:"target_id,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+type AuditEventsListResponse struct {
+	Events []AuditEventResponse `json:"events"`
+	Total  int64                `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
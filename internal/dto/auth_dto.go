@@ -1,10 +1,13 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
 type RegisterRequest struct {
 	Email     string `json:"email" validate:"required,email"`
-	Username  string `json:"username" validate:"required,min=3,max=100"`
+	Username  string `json:"username" validate:"required,min=3,max=100,username,notreserved"`
 	FirstName string `json:"first_name" validate:"required,min=1,max=100"`
 	LastName  string `json:"last_name" validate:"required,min=1,max=100"`
 	Password  string `json:"password" validate:"required,min=6"`
@@ -25,6 +28,20 @@ type LoginResponse struct {
 	User    UserDTO `json:"user"`
 }
 
+// LoginChallengeResponse - вход признан рискованным: на почту отправлен код, которым
+// вход завершается через /auth/login/verify
+type LoginChallengeResponse struct {
+	Message     string    `json:"message"`
+	ChallengeID uuid.UUID `json:"challenge_id"`
+	Method      string    `json:"method"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type VerifyLoginRequest struct {
+	ChallengeID uuid.UUID `json:"challenge_id" validate:"required"`
+	Code        string    `json:"code" validate:"required"`
+}
+
 type LogoutResponse struct {
 	Message string `json:"message"`
 }
@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// HealthCheckResponse - результат одной проверки. Остальные поля, кроме имени и статуса, заполняются
+// только на внутреннем порту: в тексте ошибок бывают адреса и имена внутренних сервисов
+type HealthCheckResponse struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Critical   *bool      `json:"critical,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	DurationMs *float64   `json:"duration_ms,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+}
+
+// ReadinessDetail - сколько подробностей о проверках попадает в ответ /readyz
+type ReadinessDetail int
+
+const (
+	// ReadinessSummary - только итоговый статус
+	ReadinessSummary ReadinessDetail = iota
+	// ReadinessChecks - имя и статус каждой проверки; публичный ?verbose
+	ReadinessChecks
+	// ReadinessFull - все результаты проверок, включая ошибки; только для внутреннего порта
+	ReadinessFull
+)
+
+// ReadinessResponse - ответ /readyz; Checks заполняются только для ?verbose
+type ReadinessResponse struct {
+	Ready     bool                  `json:"ready"`
+	Status    string                `json:"status"`
+	Reason    string                `json:"reason,omitempty"`
+	CheckedAt *time.Time            `json:"checked_at,omitempty"`
+	Checks    []HealthCheckResponse `json:"checks,omitempty"`
+}
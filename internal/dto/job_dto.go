@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type JobResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      string          `json:"status"`
+	Payload     json.RawMessage `json:"payload"`
+	UniqueKey   *string         `json:"unique_key,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    *string         `json:"locked_by,omitempty"`
+	LastError   *string         `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+type JobsListResponse struct {
+	Jobs   []JobResponse `json:"jobs"`
+	Total  int64         `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+type QueueStatResponse struct {
+	Kind        string    `json:"kind"`
+	Status      string    `json:"status"`
+	Count       int64     `json:"count"`
+	OldestRunAt time.Time `json:"oldest_run_at"`
+}
+
+type QueueStatsResponse struct {
+	Stats []QueueStatResponse `json:"stats"`
+}
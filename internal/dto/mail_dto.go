@@ -0,0 +1,13 @@
+package dto
+
+type MailTemplatesResponse struct {
+	Templates []string `json:"templates"`
+}
+
+type MailPreviewResponse struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+}
@@ -1,7 +1,9 @@
 package dto
 
 import (
+	"github.com/AtlasOpx/devprep/internal/health"
 	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/utils"
 )
 
 func RegisterRequestToModel(dto *RegisterRequest) *models.RegisterRequest {
@@ -41,8 +43,10 @@ func UserToProfileResponse(user *models.User) UserProfileResponse {
 		LastName:  user.LastName,
 		Role:      string(user.Role),
 		IsActive:  user.IsActive,
+		Locale:    user.Locale,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
 	}
 }
 
@@ -51,6 +55,7 @@ func UpdateProfileRequestToModel(dto *UpdateProfileRequest) *models.UpdateProfil
 		FirstName: dto.FirstName,
 		LastName:  dto.LastName,
 		Username:  dto.Username,
+		Locale:    dto.Locale,
 	}
 }
 
@@ -79,3 +84,326 @@ func UsersToListResponse(users []models.User) UsersListResponse {
 		Total: len(users),
 	}
 }
+
+func UserSearchResultsToResponse(query string, results []models.UserSearchResult) UserSearchResponse {
+	items := make([]UserSearchResult, len(results))
+	for i, result := range results {
+		items[i] = UserSearchResult{
+			User:       UserToProfileResponse(&result.User),
+			Score:      result.Score,
+			Highlights: highlightUserFields(&result.User, query),
+		}
+	}
+
+	return UserSearchResponse{
+		Query:   query,
+		Results: items,
+		Total:   len(results),
+	}
+}
+
+func highlightUserFields(user *models.User, query string) map[string]string {
+	fields := map[string]string{
+		"email":      user.Email,
+		"username":   user.Username,
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+	}
+
+	highlights := make(map[string]string)
+	for field, value := range fields {
+		if highlighted, ok := utils.HighlightMatches(value, query); ok {
+			highlights[field] = highlighted
+		}
+	}
+	return highlights
+}
+
+func ExportToResponse(export *models.DataExport, downloadURL string) ExportResponse {
+	return ExportResponse{
+		ID:          export.ID,
+		Status:      string(export.Status),
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+		DownloadURL: downloadURL,
+	}
+}
+
+func ChangeEmailRequestToModel(dto *ChangeEmailRequest) *models.ChangeEmailRequest {
+	return &models.ChangeEmailRequest{
+		NewEmail:        dto.NewEmail,
+		CurrentPassword: dto.CurrentPassword,
+	}
+}
+
+func JobToResponse(job *models.QueueJob) JobResponse {
+	return JobResponse{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		Status:      string(job.Status),
+		Payload:     job.Payload,
+		UniqueKey:   job.UniqueKey,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LockedBy:    job.LockedBy,
+		LastError:   job.LastError,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		FinishedAt:  job.FinishedAt,
+	}
+}
+
+func JobsToListResponse(jobs []models.QueueJob, total int64, limit, offset int) JobsListResponse {
+	items := make([]JobResponse, len(jobs))
+	for i := range jobs {
+		items[i] = JobToResponse(&jobs[i])
+	}
+
+	return JobsListResponse{
+		Jobs:   items,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+}
+
+func QueueStatsToResponse(stats []models.QueueStat) QueueStatsResponse {
+	items := make([]QueueStatResponse, len(stats))
+	for i, stat := range stats {
+		items[i] = QueueStatResponse{
+			Kind:        stat.Kind,
+			Status:      string(stat.Status),
+			Count:       stat.Count,
+			OldestRunAt: stat.OldestRunAt,
+		}
+	}
+	return QueueStatsResponse{Stats: items}
+}
+
+func CreateWebhookRequestToModel(dto *CreateWebhookRequest) *models.CreateWebhookRequest {
+	return &models.CreateWebhookRequest{
+		URL:         dto.URL,
+		Description: dto.Description,
+		Events:      dto.Events,
+		Secret:      dto.Secret,
+	}
+}
+
+func UpdateWebhookRequestToModel(dto *UpdateWebhookRequest) *models.UpdateWebhookRequest {
+	return &models.UpdateWebhookRequest{
+		URL:         dto.URL,
+		Description: dto.Description,
+		Events:      dto.Events,
+		IsActive:    dto.IsActive,
+	}
+}
+
+func WebhookToResponse(endpoint *models.WebhookEndpoint) WebhookResponse {
+	return WebhookResponse{
+		ID:                  endpoint.ID,
+		URL:                 endpoint.URL,
+		Description:         endpoint.Description,
+		Events:              endpoint.Events,
+		IsActive:            endpoint.IsActive,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		DisabledAt:          endpoint.DisabledAt,
+		DisabledReason:      endpoint.DisabledReason,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+	}
+}
+
+func WebhookToSecretResponse(endpoint *models.WebhookEndpoint) WebhookSecretResponse {
+	return WebhookSecretResponse{
+		WebhookResponse: WebhookToResponse(endpoint),
+		Secret:          endpoint.Secret,
+	}
+}
+
+func WebhooksToListResponse(endpoints []models.WebhookEndpoint) WebhooksListResponse {
+	items := make([]WebhookResponse, len(endpoints))
+	for i := range endpoints {
+		items[i] = WebhookToResponse(&endpoints[i])
+	}
+	return WebhooksListResponse{Webhooks: items}
+}
+
+// WebhookDeliveryToResponse - доставка без тела запроса, для списков
+func WebhookDeliveryToResponse(delivery *models.WebhookDelivery) WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		EndpointID:     delivery.EndpointID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
+
+func WebhookDeliveriesToListResponse(deliveries []models.WebhookDelivery, total int64, limit, offset int) WebhookDeliveriesListResponse {
+	items := make([]WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		items[i] = WebhookDeliveryToResponse(&deliveries[i])
+	}
+
+	return WebhookDeliveriesListResponse{
+		Deliveries: items,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	}
+}
+
+func WebhookDeliveryToDetailResponse(delivery *models.WebhookDelivery, attempts []models.WebhookAttempt) WebhookDeliveryDetailResponse {
+	response := WebhookDeliveryDetailResponse{
+		WebhookDeliveryResponse: WebhookDeliveryToResponse(delivery),
+		AttemptLog:              make([]WebhookAttemptResponse, len(attempts)),
+	}
+	response.Payload = delivery.Payload
+
+	for i, attempt := range attempts {
+		response.AttemptLog[i] = WebhookAttemptResponse{
+			Attempt:        attempt.Attempt,
+			ResponseStatus: attempt.ResponseStatus,
+			ResponseBody:   attempt.ResponseBody,
+			Error:          attempt.Error,
+			DurationMs:     attempt.DurationMs,
+			CreatedAt:      attempt.CreatedAt,
+		}
+	}
+	return response
+}
+
+func SecurityAlertsToResponse(settings map[models.SecurityAlert]bool) SecurityAlertsResponse {
+	alerts := make(map[string]bool, len(settings))
+	for alert, enabled := range settings {
+		alerts[string(alert)] = enabled
+	}
+	return SecurityAlertsResponse{Alerts: alerts}
+}
+
+func SessionsToListResponse(sessions []models.Session, currentToken string) SessionsListResponse {
+	response := SessionsListResponse{Sessions: make([]SessionResponse, len(sessions))}
+	for i, session := range sessions {
+		response.Sessions[i] = SessionResponse{
+			ID:        session.ID,
+			Device:    utils.DescribeDevice(session.UserAgent),
+			UserAgent: session.UserAgent,
+			IPAddress: session.IPAddress,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.SessionToken == currentToken,
+		}
+	}
+	return response
+}
+
+func KnownDevicesToListResponse(devices []models.KnownDevice) KnownDevicesListResponse {
+	response := KnownDevicesListResponse{Devices: make([]KnownDeviceResponse, len(devices))}
+	for i, device := range devices {
+		response.Devices[i] = KnownDeviceResponse{
+			ID:        device.ID,
+			Device:    utils.DescribeDevice(device.UserAgent),
+			UserAgent: device.UserAgent,
+			LastIP:    device.LastIP,
+			FirstSeen: device.FirstSeen,
+			LastSeen:  device.LastSeen,
+		}
+	}
+	return response
+}
+
+func LoginRecordsToHistoryResponse(records []models.LoginRecord) LoginHistoryResponse {
+	response := LoginHistoryResponse{Logins: make([]LoginRecordResponse, len(records))}
+	for i, record := range records {
+		signals := record.Signals
+		if signals == nil {
+			signals = []string{}
+		}
+		response.Logins[i] = LoginRecordResponse{
+			ID:        record.ID,
+			Device:    utils.DescribeDevice(record.UserAgent),
+			IPAddress: record.IPAddress,
+			RiskScore: record.RiskScore,
+			Signals:   signals,
+			Outcome:   string(record.Outcome),
+			CreatedAt: record.CreatedAt,
+		}
+	}
+	return response
+}
+
+func AuditEventToResponse(event *models.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:         event.ID,
+		Seq:        event.Seq,
+		Hash:       event.Hash,
+		OccurredAt: event.OccurredAt,
+		ActorID:    event.ActorID,
+		ActorRole:  event.ActorRole,
+		Source:     event.Source,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IPAddress:  event.IPAddress,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Changes:    event.Changes,
+		Metadata:   event.Metadata,
+	}
+}
+
+func AuditEventsToListResponse(auditEvents []models.AuditEvent, total int64, limit, offset int) AuditEventsListResponse {
+	items := make([]AuditEventResponse, len(auditEvents))
+	for i := range auditEvents {
+		items[i] = AuditEventToResponse(&auditEvents[i])
+	}
+
+	return AuditEventsListResponse{
+		Events: items,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+}
+
+// ReadinessToResponse - итог проверок и, в зависимости от detail, результат каждой проверки
+func ReadinessToResponse(report *health.Report, detail ReadinessDetail) ReadinessResponse {
+	response := ReadinessResponse{
+		Ready:  report.Ready(),
+		Status: string(report.Status),
+	}
+	if !response.Ready {
+		response.Reason = "dependency_unavailable"
+	}
+	if detail == ReadinessSummary {
+		return response
+	}
+
+	checkedAt := report.CheckedAt
+	response.CheckedAt = &checkedAt
+	response.Checks = make([]HealthCheckResponse, len(report.Checks))
+	for i, result := range report.Checks {
+		response.Checks[i] = HealthCheckResponse{
+			Name:   result.Name,
+			Status: string(result.Status),
+		}
+		if detail == ReadinessFull {
+			critical := result.Critical
+			durationMs := float64(result.Duration.Microseconds()) / 1000
+			checkedAt := result.CheckedAt
+			response.Checks[i].Critical = &critical
+			response.Checks[i].Error = result.Error
+			response.Checks[i].DurationMs = &durationMs
+			response.Checks[i].CheckedAt = &checkedAt
+		}
+	}
+	return response
+}
@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SecurityAlertsRequest - новые настройки уведомлений; непереданные типы не меняются
+type SecurityAlertsRequest struct {
+	Alerts map[string]bool `json:"alerts" validate:"required"`
+}
+
+type SecurityAlertsResponse struct {
+	Alerts map[string]bool `json:"alerts"`
+}
+
+type SessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Device    string    `json:"device"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Current - сессия, с которой пришел запрос
+	Current bool `json:"current"`
+}
+
+type SessionsListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// ResetPasswordRequest - токен можно передать и в query, как в ссылке из письма
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+type PasswordResetStatusResponse struct {
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type NotMeStatusResponse struct {
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type KnownDeviceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Device    string    `json:"device"`
+	UserAgent string    `json:"user_agent"`
+	LastIP    string    `json:"last_ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type KnownDevicesListResponse struct {
+	Devices []KnownDeviceResponse `json:"devices"`
+}
+
+type LoginRecordResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Device    string    `json:"device"`
+	IPAddress string    `json:"ip_address"`
+	RiskScore int       `json:"risk_score"`
+	Signals   []string  `json:"signals"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginHistoryResponse struct {
+	Logins []LoginRecordResponse `json:"logins"`
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
 	"github.com/google/uuid"
 	"time"
 )
@@ -8,19 +9,22 @@ import (
 type UpdateProfileRequest struct {
 	FirstName string `json:"first_name,omitempty" validate:"omitempty,min=1,max=100"`
 	LastName  string `json:"last_name,omitempty" validate:"omitempty,min=1,max=100"`
-	Username  string `json:"username,omitempty" validate:"omitempty,min=3,max=100"`
+	Username  string `json:"username,omitempty" validate:"omitempty,min=3,max=100,username,notreserved"`
+	Locale    string `json:"locale,omitempty" validate:"omitempty,oneof=en ru"`
 }
 
 type UserProfileResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Role      string    `json:"role"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID  `json:"id"`
+	Email     string     `json:"email"`
+	Username  string     `json:"username"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Role      string     `json:"role"`
+	IsActive  bool       `json:"is_active"`
+	Locale    string     `json:"locale"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type UpdateProfileResponse struct {
@@ -44,11 +48,46 @@ type UserResponse struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+type UserSearchResult struct {
+	User       UserProfileResponse `json:"user"`
+	Score      float64             `json:"score"`
+	Highlights map[string]string   `json:"highlights"`
+}
+
+type UserSearchResponse struct {
+	Query   string             `json:"query"`
+	Results []UserSearchResult `json:"results"`
+	Total   int                `json:"total"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type EmailTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// EmailChangeStatusResponse - ответ на открытие ссылки из письма; Email - адрес, который будет установлен
+type EmailChangeStatusResponse struct {
+	Message   string    `json:"message"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ProblemResponse - тело ошибки в формате RFC 7807 (application/problem+json)
+type ProblemResponse struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,82 @@
+package dto
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048"`
+	Description string   `json:"description,omitempty" validate:"omitempty,max=255"`
+	Events      []string `json:"events" validate:"required"`
+	// Secret можно задать самому; иначе он будет сгенерирован и показан в ответе один раз
+	Secret string `json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,http_url,max=2048"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Events      []string `json:"events,omitempty"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
+
+type WebhookResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Description         string     `json:"description"`
+	Events              []string   `json:"events"`
+	IsActive            bool       `json:"is_active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookSecretResponse отдается только при создании endpoint и смене секрета
+type WebhookSecretResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhooksListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveriesListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int64                     `json:"total"`
+	Limit      int                       `json:"limit"`
+	Offset     int                       `json:"offset"`
+}
+
+type WebhookAttemptResponse struct {
+	Attempt        int       `json:"attempt"`
+	ResponseStatus *int      `json:"response_status,omitempty"`
+	ResponseBody   *string   `json:"response_body,omitempty"`
+	Error          *string   `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// WebhookDeliveryDetailResponse - доставка с телом запроса и журналом попыток
+type WebhookDeliveryDetailResponse struct {
+	WebhookDeliveryResponse
+	AttemptLog []WebhookAttemptResponse `json:"attempt_log"`
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// AllEvents - подписка на события всех типов
+const AllEvents = "*"
+
+// Handler обрабатывает событие. Ошибка приводит к повторной доставке события всем
+// подписчикам, поэтому обработчики должны быть идемпотентными
+type Handler func(ctx context.Context, event Event) error
+
+// Bus - шина событий внутри процесса; подключается к relay как один из приемников
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe подписывает handler на события типа eventType или на все (AllEvents)
+func (b *Bus) Subscribe(eventType string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+// Publish вызывает подписчиков по очереди и возвращает их объединенные ошибки
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler(nil), b.handlers[event.Type]...), b.handlers[AllEvents]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := safeHandle(ctx, handler, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func safeHandle(ctx context.Context, handler Handler, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s handler: %v", event.Type, r)
+		}
+	}()
+	return handler(ctx, event)
+}
@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// AggregateUser - все события сейчас относятся к пользователю; порядок доставки
+// гарантируется в пределах одного агрегата
+const AggregateUser = "user"
+
+// Типы доменных событий
+const (
+	UserRegistered    = "user.registered"
+	UserLoggedIn      = "user.logged_in"
+	UserLoginBlocked  = "user.login_blocked"
+	UserUpdated       = "user.updated"
+	UserEmailChanged  = "user.email_changed"
+	UserRoleChanged   = "user.role_changed"
+	UserPasswordReset = "user.password_reset"
+	UserDeactivated   = "user.deactivated"
+	UserDeleted       = "user.deleted"
+	UserRestored      = "user.restored"
+	SessionRevoked    = "session.revoked"
+)
+
+// Types - все типы событий, например для проверки подписок
+var Types = []string{
+	UserRegistered, UserLoggedIn, UserLoginBlocked, UserUpdated, UserEmailChanged, UserRoleChanged,
+	UserPasswordReset, UserDeactivated, UserDeleted, UserRestored, SessionRevoked,
+}
+
+// Known сообщает, есть ли такой тип события
+func Known(eventType string) bool {
+	for _, known := range Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Причины завершения сессий в session.revoked
+const (
+	RevokeLogout        = "logout"
+	RevokePasswordReset = "password_reset"
+	RevokeDeactivated   = "deactivated"
+	RevokeDeleted       = "deleted"
+	RevokeEmailReverted = "email_reverted"
+	// RevokeUser - пользователь завершил одну из своих сессий из списка сессий
+	RevokeUser = "revoked"
+	// RevokeCompromised - пользователь перешел по ссылке «это был не я» из уведомления безопасности
+	RevokeCompromised = "compromised"
+)
+
+// Event - событие в том виде, в котором его получают подписчики. Доставка at-least-once:
+// одно событие может прийти повторно, подписчики отсеивают дубли по ID
+type Event struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Client - адрес и User-Agent клиента, выполнившего действие. Пустой, если действие
+// выполнено не через HTTP (CLI, задачи)
+type Client struct {
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// RequestID связывает событие с записью журнала аудита и логами запроса
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type clientKey struct{}
+
+// WithClient кладет в контекст данные клиента текущего запроса
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFrom возвращает данные клиента из контекста
+func ClientFrom(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+type UserRegisteredPayload struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+}
+
+type UserLoggedInPayload struct {
+	UserID    uuid.UUID `json:"user_id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	// RiskScore и Signals - оценка риска входа; RiskScore пустой, если оценка отключена
+	RiskScore *int     `json:"risk_score,omitempty"`
+	Signals   []string `json:"signals,omitempty"`
+	// Verified - вход подтвержден кодом из письма
+	Verified bool `json:"verified,omitempty"`
+}
+
+// UserLoginBlockedPayload - вход с верным паролем, заблокированный по оценке риска
+type UserLoginBlockedPayload struct {
+	UserID    uuid.UUID `json:"user_id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	RiskScore int       `json:"risk_score"`
+	Signals   []string  `json:"signals"`
+}
+
+type UserUpdatedPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	Fields []string  `json:"fields"`
+}
+
+type UserEmailChangedPayload struct {
+	UserID   uuid.UUID `json:"user_id"`
+	OldEmail string    `json:"old_email"`
+	NewEmail string    `json:"new_email"`
+	// Reverted - email вернули по ссылке из уведомления
+	Reverted bool `json:"reverted"`
+	Client
+}
+
+type UserRoleChangedPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+// UserPayload - данные событий, которым достаточно идентификатора пользователя
+type UserPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type UserPasswordResetPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	Client
+}
+
+type SessionRevokedPayload struct {
+	UserID uuid.UUID `json:"user_id"`
+	Reason string    `json:"reason"`
+	// All - завершены все сессии пользователя, а не одна
+	All bool `json:"all"`
+	// SessionUserAgent и SessionIPAddress описывают завершенную сессию, если она одна
+	SessionUserAgent string `json:"session_user_agent,omitempty"`
+	SessionIPAddress string `json:"session_ip_address,omitempty"`
+	Client
+}
@@ -0,0 +1,149 @@
+// Package geoip определяет примерные координаты IP-адреса по таблице сетей
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"strconv"
+)
+
+// earthRadiusKm - средний радиус Земли для формулы гаверсинусов
+const earthRadiusKm = 6371.0
+
+// Point - координаты в градусах
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Locator определяет координаты адреса; false, если адрес неизвестен
+type Locator interface {
+	Locate(addr netip.Addr) (Point, bool)
+}
+
+// Table - таблица сетей в памяти. Поиск идет от самого длинного префикса к короткому
+type Table struct {
+	networks map[netip.Prefix]Point
+	lengths  []int
+}
+
+// LoadCSV загружает таблицу из CSV с колонками network, latitude и longitude - например,
+// GeoLite2-City-Blocks-IPv4.csv и GeoLite2-City-Blocks-IPv6.csv. Остальные колонки игнорируются
+func LoadCSV(paths ...string) (*Table, error) {
+	table := &Table{networks: make(map[netip.Prefix]Point)}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = table.read(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+	}
+	return table, nil
+}
+
+func (t *Table) read(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := map[string]int{"network": -1, "latitude": -1, "longitude": -1}
+	for i, name := range header {
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	for name, index := range columns {
+		if index < 0 {
+			return fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	seen := make(map[int]bool, len(t.lengths))
+	for _, length := range t.lengths {
+		seen[length] = true
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		prefix, err := netip.ParsePrefix(record[columns["network"]])
+		if err != nil {
+			return err
+		}
+		// В GeoLite2 у части сетей нет координат - такие строки пропускаем
+		latitude, latErr := strconv.ParseFloat(record[columns["latitude"]], 64)
+		longitude, lonErr := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+
+		prefix = prefix.Masked()
+		t.networks[prefix] = Point{Latitude: latitude, Longitude: longitude}
+		if !seen[prefix.Bits()] {
+			seen[prefix.Bits()] = true
+			t.lengths = append(t.lengths, prefix.Bits())
+		}
+	}
+
+	// От длинных префиксов к коротким: первая найденная сеть - самая точная
+	for i := 1; i < len(t.lengths); i++ {
+		for j := i; j > 0 && t.lengths[j] > t.lengths[j-1]; j-- {
+			t.lengths[j], t.lengths[j-1] = t.lengths[j-1], t.lengths[j]
+		}
+	}
+	return nil
+}
+
+// Locate возвращает координаты самой узкой сети, в которую входит адрес
+func (t *Table) Locate(addr netip.Addr) (Point, bool) {
+	addr = addr.Unmap()
+	for _, length := range t.lengths {
+		if length > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(length)
+		if err != nil {
+			continue
+		}
+		if point, ok := t.networks[prefix]; ok {
+			return point, true
+		}
+	}
+	return Point{}, false
+}
+
+// Len возвращает число сетей в таблице
+func (t *Table) Len() int {
+	return len(t.networks)
+}
+
+// DistanceKm - расстояние по поверхности Земли между двумя точками
+func DistanceKm(a, b Point) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
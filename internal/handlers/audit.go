@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AuditHandler - журнал аудита для администратора и история безопасности для пользователя
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListEvents - журнал с фильтрами ?actor_id=&target_type=&target_id=&action=&from=&to=.
+// action с точкой на конце, например "webhook.", выбирает все действия области; from и to - RFC 3339
+func (h *AuditHandler) ListEvents(c *fiber.Ctx) error {
+	filter := models.AuditFilter{
+		TargetType: strings.TrimSpace(c.Query("target_type")),
+		Action:     strings.TrimSpace(c.Query("action")),
+	}
+	filter.Limit, filter.Offset = pageParams(c)
+
+	var fieldErrors []apperrors.FieldError
+	filter.ActorID = queryUUID(c, "actor_id", &fieldErrors)
+	filter.TargetID = queryUUID(c, "target_id", &fieldErrors)
+	filter.From = queryTime(c, "from", &fieldErrors)
+	filter.To = queryTime(c, "to", &fieldErrors)
+	if len(fieldErrors) > 0 {
+		return apperrors.Validation(fieldErrors)
+	}
+
+	auditEvents, total, err := h.auditService.ListEvents(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	response := dto.AuditEventsToListResponse(auditEvents, total, filter.Limit, filter.Offset)
+	return c.JSON(response)
+}
+
+// MyActivity - история безопасности текущего пользователя
+func (h *AuditHandler) MyActivity(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+	limit, offset := pageParams(c)
+
+	auditEvents, total, err := h.auditService.UserActivity(c.UserContext(), userID, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	response := dto.AuditEventsToListResponse(auditEvents, total, limit, offset)
+	return c.JSON(response)
+}
+
+// pageParams читает limit и offset; недопустимые значения заменяются значениями по умолчанию
+func pageParams(c *fiber.Ctx) (int, int) {
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func queryUUID(c *fiber.Ctx, name string, fieldErrors *[]apperrors.FieldError) *uuid.UUID {
+	value := strings.TrimSpace(c.Query(name))
+	if value == "" {
+		return nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		*fieldErrors = append(*fieldErrors, apperrors.FieldError{Field: name, Rule: "uuid", Message: "must be a valid UUID"})
+		return nil
+	}
+	return &id
+}
+
+func queryTime(c *fiber.Ctx, name string, fieldErrors *[]apperrors.FieldError) *time.Time {
+	value := strings.TrimSpace(c.Query(name))
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		*fieldErrors = append(*fieldErrors, apperrors.FieldError{
+			Field:   name,
+			Rule:    "datetime",
+			Message: "must be a date and time in RFC 3339 format",
+		})
+		return nil
+	}
+	return &t
+}
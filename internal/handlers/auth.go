@@ -1,40 +1,39 @@
 package handlers
 
 import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/dto"
-	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/models"
 	"github.com/AtlasOpx/devprep/internal/service"
-	"github.com/AtlasOpx/devprep/internal/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
 	authService *service.AuthService
-	authRepo    *repository.AuthRepository
 	cfg         *config.Config
 }
 
-func NewAuthHandler(authService *service.AuthService, authRepo *repository.AuthRepository, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
-		authRepo:    authRepo,
 		cfg:         cfg,
 	}
 }
 
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
-	var req dto.RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "Invalid request body"})
+	req, err := bindAndValidate[dto.RegisterRequest](c)
+	if err != nil {
+		return err
 	}
 
-	modelReq := dto.RegisterRequestToModel(&req)
-	userID, err := h.authService.Register(modelReq)
+	modelReq := dto.RegisterRequestToModel(req)
+	userID, err := h.authService.Register(c.UserContext(), modelReq)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "User already exists or failed to create"})
+		return err
 	}
 
 	response := dto.RegisterResponse{
@@ -46,29 +45,57 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
-	var req dto.LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "Invalid request body"})
+	req, err := bindAndValidate[dto.LoginRequest](c)
+	if err != nil {
+		return err
 	}
 
-	modelReq := dto.LoginRequestToModel(&req)
-	response, err := h.authService.Login(modelReq)
+	modelReq := dto.LoginRequestToModel(req)
+	modelReq.UserAgent = c.Get("User-Agent")
+	modelReq.IPAddress = c.IP()
+	response, err := h.authService.Login(c.UserContext(), modelReq)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(dto.ErrorResponse{Error: "Invalid credentials"})
+		return err
+	}
+
+	if response.Challenge != nil {
+		return c.Status(fiber.StatusAccepted).JSON(dto.LoginChallengeResponse{
+			Message:     response.Message,
+			ChallengeID: response.Challenge.ID,
+			Method:      "email",
+			ExpiresAt:   response.Challenge.ExpiresAt,
+		})
 	}
 
-	sessionToken, _ := utils.GenerateSessionToken()
-	expiresAt := time.Now().Add(time.Hour * 24)
+	return h.sendSession(c, response)
+}
+
+// VerifyLogin завершает вход, для которого потребовался код из письма
+func (h *AuthHandler) VerifyLogin(c *fiber.Ctx) error {
+	req, err := bindAndValidate[dto.VerifyLoginRequest](c)
+	if err != nil {
+		return err
+	}
 
-	err = h.authRepo.CreateSession(response.User.ID, sessionToken, expiresAt, c.Get("User-Agent"), c.IP())
+	response, err := h.authService.VerifyLogin(c.UserContext(), &models.VerifyLoginRequest{
+		ChallengeID: req.ChallengeID,
+		Code:        req.Code,
+		UserAgent:   c.Get("User-Agent"),
+		IPAddress:   c.IP(),
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "Failed to create session"})
+		return err
 	}
 
+	return h.sendSession(c, response)
+}
+
+// sendSession ставит cookie сессии и отвечает данными пользователя
+func (h *AuthHandler) sendSession(c *fiber.Ctx, response *models.LoginResponse) error {
 	c.Cookie(&fiber.Cookie{
 		Name:     "session_token",
-		Value:    sessionToken,
-		Expires:  expiresAt,
+		Value:    response.SessionToken,
+		Expires:  response.SessionExpiresAt,
 		HTTPOnly: true,
 		Secure:   false,
 		SameSite: "Lax",
@@ -85,12 +112,12 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	sessionToken := c.Cookies("session_token")
 	if sessionToken == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "No session token"})
+		return apperrors.ErrNoSession
 	}
 
-	err := h.authService.Logout(sessionToken)
+	err := h.authService.Logout(c.UserContext(), sessionToken)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "Failed to logout"})
+		return err
 	}
 
 	c.Cookie(&fiber.Cookie{
@@ -103,3 +130,31 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	response := dto.LogoutResponse{Message: "Logout successful"}
 	return c.JSON(response)
 }
+
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	sessions, err := h.authService.ListSessions(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.SessionsToListResponse(sessions, c.Cookies("session_token")))
+}
+
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.ErrSessionNotFound
+	}
+
+	err = h.authService.RevokeSession(c.UserContext(), userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Session revoked"}
+	return c.JSON(response)
+}
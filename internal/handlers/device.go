@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// DeviceHandler показывает пользователю известные устройства и историю входов с оценкой риска
+type DeviceHandler struct {
+	loginRiskService *service.LoginRiskService
+}
+
+func NewDeviceHandler(loginRiskService *service.LoginRiskService) *DeviceHandler {
+	return &DeviceHandler{loginRiskService: loginRiskService}
+}
+
+func (h *DeviceHandler) ListDevices(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	devices, err := h.loginRiskService.ListDevices(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.KnownDevicesToListResponse(devices))
+}
+
+func (h *DeviceHandler) ForgetDevice(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return service.ErrDeviceNotFound
+	}
+
+	if err := h.loginRiskService.ForgetDevice(c.UserContext(), userID, deviceID); err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Device removed"}
+	return c.JSON(response)
+}
+
+func (h *DeviceHandler) ListLogins(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+
+	records, err := h.loginRiskService.ListLogins(c.UserContext(), userID, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.LoginRecordsToHistoryResponse(records))
+}
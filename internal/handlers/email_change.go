@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type EmailChangeHandler struct {
+	emailChangeService *service.EmailChangeService
+}
+
+func NewEmailChangeHandler(emailChangeService *service.EmailChangeService) *EmailChangeHandler {
+	return &EmailChangeHandler{emailChangeService: emailChangeService}
+}
+
+func (h *EmailChangeHandler) RequestChange(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	req, err := bindAndValidate[dto.ChangeEmailRequest](c)
+	if err != nil {
+		return err
+	}
+
+	modelReq := dto.ChangeEmailRequestToModel(req)
+	modelReq.AcceptLanguage = c.Get(fiber.HeaderAcceptLanguage)
+
+	err = h.emailChangeService.RequestChange(c.UserContext(), userID, modelReq)
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Confirmation link sent to the new email address"}
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+// CheckConfirm отвечает на переход по ссылке подтверждения: GET ничего не меняет, так как почтовые
+// сканеры и предпросмотр открывают ссылки сами. Смену применяет POST на тот же адрес
+func (h *EmailChangeHandler) CheckConfirm(c *fiber.Ctx) error {
+	change, err := h.emailChangeService.CheckConfirm(c.UserContext(), c.Query("token"))
+	if err != nil {
+		return err
+	}
+
+	response := dto.EmailChangeStatusResponse{
+		Message:   "Send POST to this URL to confirm the new email",
+		Email:     change.NewEmail,
+		ExpiresAt: change.ExpiresAt,
+	}
+	return c.JSON(response)
+}
+
+func (h *EmailChangeHandler) Confirm(c *fiber.Ctx) error {
+	err := h.emailChangeService.Confirm(c.UserContext(), linkToken(c))
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Email changed successfully"}
+	return c.JSON(response)
+}
+
+// CheckRevert отвечает на переход по ссылке отмены, как CheckConfirm
+func (h *EmailChangeHandler) CheckRevert(c *fiber.Ctx) error {
+	change, err := h.emailChangeService.CheckRevert(c.UserContext(), c.Query("token"))
+	if err != nil {
+		return err
+	}
+
+	response := dto.EmailChangeStatusResponse{
+		Message:   "Send POST to this URL to restore this email and sign out all sessions",
+		Email:     change.OldEmail,
+		ExpiresAt: change.RevertExpiresAt,
+	}
+	return c.JSON(response)
+}
+
+func (h *EmailChangeHandler) Revert(c *fiber.Ctx) error {
+	err := h.emailChangeService.Revert(c.UserContext(), linkToken(c))
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Email change reverted, all sessions were signed out"}
+	return c.JSON(response)
+}
+
+// linkToken берет токен ссылки из письма из тела запроса или из query, чтобы POST можно было
+// отправить на адрес самой ссылки
+func linkToken(c *fiber.Ctx) string {
+	var req dto.EmailTokenRequest
+	if err := c.BodyParser(&req); err == nil && req.Token != "" {
+		return req.Token
+	}
+	return c.Query("token")
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fiberUtils "github.com/gofiber/fiber/v2/utils"
+)
+
+// ProblemTypeBase - префикс type URI в ответах problem+json; к нему добавляется код ошибки
+const ProblemTypeBase = "https://devprep.dev/problems/"
+
+var problemStatuses = map[apperrors.Code]int{
+	apperrors.CodeInternal:           fiber.StatusInternalServerError,
+	apperrors.CodeNotFound:           fiber.StatusNotFound,
+	apperrors.CodeInvalidBody:        fiber.StatusBadRequest,
+	apperrors.CodeValidation:         fiber.StatusUnprocessableEntity,
+	apperrors.CodeUnauthorized:       fiber.StatusUnauthorized,
+	apperrors.CodeSessionExpired:     fiber.StatusUnauthorized,
+	apperrors.CodeNoSession:          fiber.StatusBadRequest,
+	apperrors.CodeForbidden:          fiber.StatusForbidden,
+	apperrors.CodeInvalidCredentials: fiber.StatusUnauthorized,
+	apperrors.CodeAccountDisabled:    fiber.StatusForbidden,
+	apperrors.CodeInvalidPassword:    fiber.StatusForbidden,
+	apperrors.CodeEmailTaken:         fiber.StatusConflict,
+	apperrors.CodeUsernameTaken:      fiber.StatusConflict,
+	apperrors.CodeEmailUnchanged:     fiber.StatusBadRequest,
+	apperrors.CodeInvalidToken:       fiber.StatusBadRequest,
+	apperrors.CodeTokenExpired:       fiber.StatusGone,
+	apperrors.CodeRateLimited:        fiber.StatusTooManyRequests,
+	apperrors.CodeExportNotReady:     fiber.StatusConflict,
+	apperrors.CodeQueryCanceled:      fiber.StatusServiceUnavailable,
+	apperrors.CodeJobNotRetryable:    fiber.StatusConflict,
+	apperrors.CodeWebhookDisabled:    fiber.StatusConflict,
+	apperrors.CodeLoginBlocked:       fiber.StatusForbidden,
+}
+
+// ErrorHandler - единая точка преобразования ошибок в ответы application/problem+json (RFC 7807).
+// Язык по умолчанию - английский; для языка из конфига используйте NewErrorHandler
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	return handleError(c, err, i18n.DefaultLocale)
+}
+
+// NewErrorHandler возвращает ErrorHandler, который использует defaultLocale,
+// если язык не удалось определить ни по Accept-Language, ни по профилю пользователя
+func NewErrorHandler(defaultLocale string) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		return handleError(c, err, i18n.Locale(defaultLocale))
+	}
+}
+
+func handleError(c *fiber.Ctx, err error, defaultLocale i18n.Locale) error {
+	problem := dto.ProblemResponse{Instance: c.OriginalURL()}
+	locale := requestLocale(c, defaultLocale)
+
+	var appErr *apperrors.Error
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &appErr):
+		problem.Code = string(appErr.Code)
+		problem.Status = problemStatus(appErr.Code)
+		problem.Detail = localizedMessage(locale, appErr)
+		problem.Errors = localizedFields(locale, appErr.Fields)
+		if appErr.Err != nil && problem.Status >= fiber.StatusInternalServerError {
+			logRequestError(c, appErr.Err)
+		}
+	case errors.As(err, &fiberErr):
+		problem.Code = strings.ReplaceAll(strings.ToLower(fiberUtils.StatusMessage(fiberErr.Code)), " ", "_")
+		problem.Status = fiberErr.Code
+		problem.Detail = fiberErr.Message
+	default:
+		logRequestError(c, err)
+		problem.Code = string(apperrors.CodeInternal)
+		problem.Status = fiber.StatusInternalServerError
+		problem.Detail = localizedMessage(locale, apperrors.ErrInternal)
+	}
+	problem.Type = ProblemTypeBase + strings.ReplaceAll(problem.Code, "_", "-")
+	problem.Title = fiberUtils.StatusMessage(problem.Status)
+
+	c.Set(fiber.HeaderContentLanguage, string(locale))
+	return c.Status(problem.Status).JSON(problem, "application/problem+json")
+}
+
+// logRequestError пишет в лог запроса ошибку, которую клиент увидит только как internal_error
+func logRequestError(c *fiber.Ctx, err error) {
+	logging.FromContext(c.UserContext()).Error("request failed",
+		"method", c.Method(), "path", c.Path(), "error", err)
+}
+
+// requestLocale определяет язык ответа: Accept-Language, затем язык из профиля
+// (его кладет в Locals middleware авторизации), затем defaultLocale
+func requestLocale(c *fiber.Ctx, defaultLocale i18n.Locale) i18n.Locale {
+	userLocale, _ := c.Locals("user_locale").(string)
+	return i18n.Resolve(c.Get(fiber.HeaderAcceptLanguage), userLocale, defaultLocale)
+}
+
+func localizedMessage(locale i18n.Locale, err *apperrors.Error) string {
+	if err.Key == "" || !i18n.Has(err.Key) {
+		return err.Message
+	}
+	return i18n.T(locale, err.Key, nil)
+}
+
+func localizedFields(locale i18n.Locale, fields []apperrors.FieldError) []apperrors.FieldError {
+	if fields == nil {
+		return nil
+	}
+
+	localized := make([]apperrors.FieldError, len(fields))
+	for i, field := range fields {
+		localized[i] = field
+		if field.Rule != "" {
+			localized[i].Message = utils.ValidationMessage(locale, field.Rule, field.Param)
+		}
+	}
+	return localized
+}
+
+func problemStatus(code apperrors.Code) int {
+	if status, ok := problemStatuses[code]; ok {
+		return status
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var errExportNotFound = apperrors.ErrNotFound.WithMessage("error.export_not_found", "Export not found")
+
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+func (h *ExportHandler) RequestExport(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	export, err := h.exportService.RequestExport(c.UserContext(), userID)
+	if errors.Is(err, service.ErrExportRateLimited) {
+		retryAfter := time.Until(h.exportService.NextAllowedAt(export))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(dto.ExportToResponse(export, ""))
+}
+
+func (h *ExportHandler) GetExport(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	exportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errExportNotFound
+	}
+
+	export, err := h.exportService.GetExport(c.UserContext(), userID, exportID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errExportNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	downloadURL, _ := h.exportService.DownloadURL(export)
+	return c.JSON(dto.ExportToResponse(export, downloadURL))
+}
+
+func (h *ExportHandler) Download(c *fiber.Ctx) error {
+	exportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errExportNotFound
+	}
+
+	file, err := h.exportService.OpenSigned(c.UserContext(), exportID, c.Query("expires"), c.Query("signature"))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errExportNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Attachment("devprep-export-" + exportID.String() + ".zip")
+	return c.SendStream(file)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthHandler отдает пробы Kubernetes: liveness, startup и readiness
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{registry: registry}
+}
+
+// Liveness отвечает, пока процесс обрабатывает запросы. Зависимости не проверяются:
+// их отказ не лечится перезапуском, а остановка не должна выглядеть как зависание
+func (h *HealthHandler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "alive",
+	})
+}
+
+// Startup возвращает 503, пока экземпляр не закончил запуск
+func (h *HealthHandler) Startup(c *fiber.Ctx) error {
+	if !h.registry.Started() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"started": false,
+		})
+	}
+	return c.JSON(fiber.Map{
+		"started": true,
+	})
+}
+
+// Readiness возвращает 503 во время запуска и остановки и при отказе критичной зависимости.
+// В состоянии degraded экземпляр продолжает принимать трафик. ?verbose добавляет имя и статус каждой проверки
+func (h *HealthHandler) Readiness(c *fiber.Ctx) error {
+	detail := dto.ReadinessSummary
+	if c.Request().URI().QueryArgs().Has("verbose") {
+		detail = dto.ReadinessChecks
+	}
+	return h.readiness(c, detail)
+}
+
+// ReadinessDetail - readiness с ошибками и длительностью каждой проверки; только для внутреннего порта
+func (h *HealthHandler) ReadinessDetail(c *fiber.Ctx) error {
+	return h.readiness(c, dto.ReadinessFull)
+}
+
+func (h *HealthHandler) readiness(c *fiber.Ctx, detail dto.ReadinessDetail) error {
+	switch {
+	case h.registry.ShuttingDown():
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dto.ReadinessResponse{
+			Status: string(health.StatusDown),
+			Reason: "shutting_down",
+		})
+	case !h.registry.Started():
+		return c.Status(fiber.StatusServiceUnavailable).JSON(dto.ReadinessResponse{
+			Status: string(health.StatusDown),
+			Reason: "starting",
+		})
+	}
+
+	report := h.registry.Check(c.UserContext())
+	response := dto.ReadinessToResponse(report, detail)
+	if !response.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	}
+	return c.JSON(response)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var errJobNotFound = apperrors.ErrNotFound.WithMessage("error.job_not_found", "Job not found")
+
+type JobHandler struct {
+	queue    *queue.Queue
+	tx       database.Transactor
+	auditLog audit.Recorder
+}
+
+func NewJobHandler(queue *queue.Queue, tx database.Transactor, auditLog audit.Recorder) *JobHandler {
+	return &JobHandler{queue: queue, tx: tx, auditLog: auditLog}
+}
+
+// ListJobs - задачи очереди с фильтром по статусу и виду, например ?status=dead
+func (h *JobHandler) ListJobs(c *fiber.Ctx) error {
+	filter := models.QueueJobFilter{
+		Status: models.QueueJobStatus(strings.TrimSpace(c.Query("status"))),
+		Kind:   strings.TrimSpace(c.Query("kind")),
+		Limit:  c.QueryInt("limit", defaultSearchLimit),
+		Offset: c.QueryInt("offset", 0),
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return apperrors.Validation([]apperrors.FieldError{{
+			Field:   "status",
+			Rule:    "oneof",
+			Param:   "pending running completed dead",
+			Message: "must be one of: pending running completed dead",
+		}})
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSearchLimit {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	jobs, total, err := h.queue.ListJobs(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	response := dto.JobsToListResponse(jobs, total, filter.Limit, filter.Offset)
+	return c.JSON(response)
+}
+
+func (h *JobHandler) GetJob(c *fiber.Ctx) error {
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errJobNotFound
+	}
+
+	job, err := h.queue.GetJob(c.UserContext(), jobID)
+	if err != nil {
+		return jobError(err)
+	}
+
+	response := dto.JobToResponse(job)
+	return c.JSON(response)
+}
+
+// RetryJob ставит задачу из dead letter в очередь заново со сброшенным счетчиком попыток
+func (h *JobHandler) RetryJob(c *fiber.Ctx) error {
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errJobNotFound
+	}
+
+	var job *models.QueueJob
+	err = h.tx.WithTx(c.UserContext(), func(ctx context.Context) error {
+		var err error
+		if job, err = h.queue.RetryJob(ctx, jobID); err != nil {
+			return err
+		}
+		return h.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.JobRetried,
+			TargetType: audit.TargetJob,
+			TargetID:   jobID,
+			Metadata:   map[string]interface{}{"kind": job.Kind},
+		})
+	})
+	if err != nil {
+		return jobError(err)
+	}
+
+	response := dto.JobToResponse(job)
+	return c.JSON(response)
+}
+
+// Stats - число задач по виду и статусу и время самой старой из них
+func (h *JobHandler) Stats(c *fiber.Ctx) error {
+	stats, err := h.queue.Stats(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	response := dto.QueueStatsToResponse(stats)
+	return c.JSON(response)
+}
+
+func jobError(err error) error {
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errJobNotFound
+	}
+	return err
+}
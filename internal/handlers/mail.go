@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/mail"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errTemplateNotFound = apperrors.ErrNotFound.WithMessage("error.template_not_found", "Email template not found")
+
+type MailHandler struct {
+	templates     *mail.Renderer
+	defaultLocale i18n.Locale
+}
+
+func NewMailHandler(templates *mail.Renderer, cfg *config.Config) *MailHandler {
+	return &MailHandler{templates: templates, defaultLocale: i18n.Locale(cfg.DefaultLocale)}
+}
+
+func (h *MailHandler) ListTemplates(c *fiber.Ctx) error {
+	response := dto.MailTemplatesResponse{Templates: h.templates.Templates()}
+	return c.JSON(response)
+}
+
+// PreviewTemplate отрисовывает письмо на примерных данных. ?format=html или text отдает
+// одну версию как есть, чтобы открыть ее в браузере; язык - ?locale= или Accept-Language
+func (h *MailHandler) PreviewTemplate(c *fiber.Ctx) error {
+	locale := i18n.Locale(c.Query("locale"))
+	if !i18n.Supported(locale) {
+		locale = requestLocale(c, h.defaultLocale)
+	}
+
+	format := c.Query("format")
+	if format != "" && format != "html" && format != "text" {
+		return apperrors.Validation([]apperrors.FieldError{{
+			Field:   "format",
+			Rule:    "oneof",
+			Param:   "html text",
+			Message: "must be one of: html text",
+		}})
+	}
+
+	msg, err := h.templates.Preview(locale, c.Params("name"))
+	if errors.Is(err, mail.ErrUnknownTemplate) {
+		return errTemplateNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentLanguage, string(locale))
+	switch format {
+	case "html":
+		c.Type("html", "utf-8")
+		return c.SendString(msg.HTML)
+	case "text":
+		c.Type("txt", "utf-8")
+		return c.SendString(msg.Text)
+	}
+
+	response := dto.MailPreviewResponse{
+		Template: c.Params("name"),
+		Locale:   string(locale),
+		Subject:  msg.Subject,
+		Text:     msg.Text,
+		HTML:     msg.HTML,
+	}
+	return c.JSON(response)
+}
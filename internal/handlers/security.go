@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SecurityHandler struct {
+	securityService *service.SecurityService
+}
+
+func NewSecurityHandler(securityService *service.SecurityService) *SecurityHandler {
+	return &SecurityHandler{securityService: securityService}
+}
+
+func (h *SecurityHandler) GetAlertSettings(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	settings, err := h.securityService.AlertSettings(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.SecurityAlertsToResponse(settings))
+}
+
+func (h *SecurityHandler) UpdateAlertSettings(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+
+	req, err := bindAndValidate[dto.SecurityAlertsRequest](c)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[models.SecurityAlert]bool, len(req.Alerts))
+	for alert, enabled := range req.Alerts {
+		changes[models.SecurityAlert(alert)] = enabled
+	}
+
+	settings, err := h.securityService.UpdateAlertSettings(c.UserContext(), userID, changes)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.SecurityAlertsToResponse(settings))
+}
+
+// CheckNotMe отвечает на переход по ссылке «это был не я»: GET ничего не меняет, так как почтовые
+// сканеры открывают ссылки сами. Сессии завершает POST на тот же адрес
+func (h *SecurityHandler) CheckNotMe(c *fiber.Ctx) error {
+	notMe, err := h.securityService.CheckNotMe(c.UserContext(), c.Query("token"))
+	if err != nil {
+		return err
+	}
+
+	response := dto.NotMeStatusResponse{
+		Message:   "Send POST to this URL to sign out all sessions and get a password reset link",
+		ExpiresAt: notMe.ExpiresAt,
+	}
+	return c.JSON(response)
+}
+
+// NotMe - ссылка «это был не я» из уведомления; работает без сессии, по одноразовому токену
+func (h *SecurityHandler) NotMe(c *fiber.Ctx) error {
+	err := h.securityService.ReportCompromise(c.UserContext(), linkToken(c))
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "All sessions were signed out, a password reset link was sent to your email"}
+	return c.JSON(response)
+}
+
+func (h *SecurityHandler) CheckPasswordReset(c *fiber.Ctx) error {
+	reset, err := h.securityService.CheckPasswordReset(c.UserContext(), c.Query("token"))
+	if err != nil {
+		return err
+	}
+
+	response := dto.PasswordResetStatusResponse{
+		Message:   "Send a new password to this URL with POST",
+		ExpiresAt: reset.ExpiresAt,
+	}
+	return c.JSON(response)
+}
+
+func (h *SecurityHandler) ResetPassword(c *fiber.Ctx) error {
+	req, err := bindAndValidate[dto.ResetPasswordRequest](c)
+	if err != nil {
+		return err
+	}
+
+	token := req.Token
+	if token == "" {
+		token = c.Query("token")
+	}
+
+	err = h.securityService.ResetPassword(c.UserContext(), token, req.Password)
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "Password changed, sign in with the new password"}
+	return c.JSON(response)
+}
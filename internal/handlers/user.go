@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
 	"github.com/AtlasOpx/devprep/internal/dto"
 	"github.com/AtlasOpx/devprep/internal/service"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
 type UserHandler struct {
 	userService *service.UserService
 }
@@ -19,9 +27,9 @@ func NewUserHandler(userService *service.UserService) *UserHandler {
 func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uuid.UUID)
 
-	user, err := h.userService.GetProfile(userID)
+	user, err := h.userService.GetProfile(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "User not found"})
+		return err
 	}
 
 	response := dto.UserToResponse(user)
@@ -31,15 +39,15 @@ func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uuid.UUID)
 
-	var req dto.UpdateProfileRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "Invalid request body"})
+	req, err := bindAndValidate[dto.UpdateProfileRequest](c)
+	if err != nil {
+		return err
 	}
 
-	modelReq := dto.UpdateProfileRequestToModel(&req)
-	err := h.userService.UpdateProfile(userID, modelReq)
+	modelReq := dto.UpdateProfileRequestToModel(req)
+	err = h.userService.UpdateProfile(c.UserContext(), userID, modelReq)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "Failed to update profile"})
+		return err
 	}
 
 	response := dto.UpdateProfileResponse{Message: "Profile updated successfully"}
@@ -49,21 +57,73 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uuid.UUID)
 
-	err := h.userService.DeleteUser(userID)
+	err := h.userService.DeleteUser(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "Failed to delete user"})
+		return err
 	}
 
+	c.Cookie(&fiber.Cookie{
+		Name:     "session_token",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+	})
+
 	response := dto.SuccessResponse{Message: "User deleted successfully"}
 	return c.JSON(response)
 }
 
 func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
-	users, err := h.userService.GetAllUsers()
+	users, err := h.userService.GetAllUsers(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	response := dto.UsersToListResponse(users)
+	return c.JSON(response)
+}
+
+func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return apperrors.Validation([]apperrors.FieldError{{Field: "q", Rule: "required", Message: "is required"}})
+	}
+
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+
+	results, err := h.userService.SearchUsers(c.UserContext(), query, limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "Failed to get users"})
+		return err
+	}
+
+	response := dto.UserSearchResultsToResponse(query, results)
+	return c.JSON(response)
+}
+
+func (h *UserHandler) GetDeletedUsers(c *fiber.Ctx) error {
+	users, err := h.userService.GetDeletedUsers(c.UserContext())
+	if err != nil {
+		return err
 	}
 
 	response := dto.UsersToListResponse(users)
 	return c.JSON(response)
 }
+
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return apperrors.ErrNotFound.WithMessage("error.user_not_found", "User not found")
+	}
+
+	err = h.userService.RestoreUser(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	response := dto.SuccessResponse{Message: "User restored successfully"}
+	return c.JSON(response)
+}
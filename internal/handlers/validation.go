@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var requestValidator = utils.NewXValidator()
+
+// bindAndValidate разбирает тело запроса в T и проверяет его по validate-тегам.
+// Ошибку можно вернуть из хендлера как есть: ErrorHandler отдаст 400 или 422 со списком полей
+func bindAndValidate[T any](c *fiber.Ctx) (*T, error) {
+	req := new(T)
+	if err := c.BodyParser(req); err != nil {
+		return nil, apperrors.ErrInvalidBody.Wrap(err)
+	}
+
+	if fields := requestValidator.Validate(req); len(fields) > 0 {
+		return nil, apperrors.Validation(fields)
+	}
+
+	return req, nil
+}
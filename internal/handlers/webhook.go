@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var (
+	errWebhookNotFound  = apperrors.ErrNotFound.WithMessage("error.webhook_not_found", "Webhook not found")
+	errDeliveryNotFound = apperrors.ErrNotFound.WithMessage("error.delivery_not_found", "Webhook delivery not found")
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	endpoints, err := h.webhookService.ListEndpoints(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	response := dto.WebhooksToListResponse(endpoints)
+	return c.JSON(response)
+}
+
+// CreateWebhook регистрирует endpoint; секрет подписи возвращается в ответе
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	req, err := bindAndValidate[dto.CreateWebhookRequest](c)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := h.webhookService.CreateEndpoint(c.UserContext(), dto.CreateWebhookRequestToModel(req))
+	if err != nil {
+		return err
+	}
+
+	response := dto.WebhookToSecretResponse(endpoint)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func (h *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	endpoint, err := h.webhookService.GetEndpoint(c.UserContext(), endpointID)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	response := dto.WebhookToResponse(endpoint)
+	return c.JSON(response)
+}
+
+// UpdateWebhook меняет адрес, описание, события или включает и отключает endpoint
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	req, err := bindAndValidate[dto.UpdateWebhookRequest](c)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := h.webhookService.UpdateEndpoint(c.UserContext(), endpointID, dto.UpdateWebhookRequestToModel(req))
+	if err != nil {
+		return webhookError(err)
+	}
+
+	response := dto.WebhookToResponse(endpoint)
+	return c.JSON(response)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	if err := h.webhookService.DeleteEndpoint(c.UserContext(), endpointID); err != nil {
+		return webhookError(err)
+	}
+
+	response := dto.SuccessResponse{Message: "Webhook deleted successfully"}
+	return c.JSON(response)
+}
+
+func (h *WebhookHandler) RotateSecret(c *fiber.Ctx) error {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	endpoint, err := h.webhookService.RotateSecret(c.UserContext(), endpointID)
+	if err != nil {
+		return webhookError(err)
+	}
+
+	response := dto.WebhookToSecretResponse(endpoint)
+	return c.JSON(response)
+}
+
+// ListDeliveries - доставки endpoint с фильтром по статусу, например ?status=failed
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return errWebhookNotFound
+	}
+
+	filter := models.WebhookDeliveryFilter{
+		EndpointID: endpointID,
+		Status:     models.WebhookDeliveryStatus(strings.TrimSpace(c.Query("status"))),
+		Limit:      c.QueryInt("limit", defaultSearchLimit),
+		Offset:     c.QueryInt("offset", 0),
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return apperrors.Validation([]apperrors.FieldError{{
+			Field:   "status",
+			Rule:    "oneof",
+			Param:   "pending succeeded failed",
+			Message: "must be one of: pending succeeded failed",
+		}})
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSearchLimit {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	if _, err := h.webhookService.GetEndpoint(c.UserContext(), endpointID); err != nil {
+		return webhookError(err)
+	}
+
+	deliveries, total, err := h.webhookService.ListDeliveries(c.UserContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	response := dto.WebhookDeliveriesToListResponse(deliveries, total, filter.Limit, filter.Offset)
+	return c.JSON(response)
+}
+
+// GetDelivery - доставка с телом запроса и журналом попыток
+func (h *WebhookHandler) GetDelivery(c *fiber.Ctx) error {
+	endpointID, deliveryID, err := deliveryParams(c)
+	if err != nil {
+		return err
+	}
+
+	delivery, attempts, err := h.webhookService.GetDelivery(c.UserContext(), endpointID, deliveryID)
+	if err != nil {
+		return deliveryError(err)
+	}
+
+	response := dto.WebhookDeliveryToDetailResponse(delivery, attempts)
+	return c.JSON(response)
+}
+
+// Redeliver ставит доставку в очередь заново
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	endpointID, deliveryID, err := deliveryParams(c)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := h.webhookService.Redeliver(c.UserContext(), endpointID, deliveryID)
+	if err != nil {
+		return deliveryError(err)
+	}
+
+	response := dto.WebhookDeliveryToResponse(delivery)
+	return c.Status(fiber.StatusAccepted).JSON(response)
+}
+
+func deliveryParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	endpointID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errWebhookNotFound
+	}
+	deliveryID, err := uuid.Parse(c.Params("deliveryId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errDeliveryNotFound
+	}
+	return endpointID, deliveryID, nil
+}
+
+func webhookError(err error) error {
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errWebhookNotFound
+	}
+	return err
+}
+
+func deliveryError(err error) error {
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errDeliveryNotFound
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// PingDB проверяет, что пул может получить соединение и база отвечает
+func PingDB(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// PingRedis отправляет Redis по адресу addr команду PING (после AUTH, если задан пароль) и ждет PONG
+func PingRedis(addr, password string) CheckFunc {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+
+		reader := bufio.NewReader(conn)
+		if password != "" {
+			if _, err := redisCommand(conn, reader, "AUTH", password); err != nil {
+				return fmt.Errorf("redis auth failed: %w", err)
+			}
+		}
+		reply, err := redisCommand(conn, reader, "PING")
+		if err != nil {
+			return err
+		}
+		if reply != "PONG" {
+			return fmt.Errorf("unexpected redis reply to PING: %q", reply)
+		}
+		return nil
+	}
+}
+
+// redisCommand отправляет команду в формате RESP и читает однострочный ответ
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(command.String())); err != nil {
+		return "", err
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", errors.New(line[1:])
+	}
+	return "", fmt.Errorf("unexpected redis reply %q", line)
+}
+
+// MaxLag проверяет, что отставание, которое возвращает lag, не больше threshold. Большее отставание -
+// degraded: снятие экземпляра с трафика не ускорит обработку
+func MaxLag(what string, threshold time.Duration, lag func(ctx context.Context) (time.Duration, error)) CheckFunc {
+	return func(ctx context.Context) error {
+		value, err := lag(ctx)
+		if err != nil {
+			return err
+		}
+		if value > threshold {
+			return Degraded(fmt.Errorf("%s lag %s exceeds %s", what, value.Round(time.Second), threshold))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,262 @@
+// Package health - реестр проверок зависимостей для проб Kubernetes. Liveness говорит только о том,
+// что процесс жив, startup - что он закончил запуск, а readiness - что он может принимать трафик:
+// запущен, не останавливается и все критичные проверки прошли. Результаты проверок кэшируются,
+// чтобы частые пробы нескольких балансировщиков не нагружали базу
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status - состояние проверки или экземпляра в целом
+type Status string
+
+const (
+	// StatusUp - проверка прошла
+	StatusUp Status = "up"
+	// StatusDegraded - зависимость работает плохо или некритична, но экземпляр продолжает обслуживать запросы
+	StatusDegraded Status = "degraded"
+	// StatusDown - зависимость недоступна
+	StatusDown Status = "down"
+)
+
+const (
+	defaultTimeout  = time.Second
+	defaultCacheTTL = 2 * time.Second
+)
+
+// CheckFunc проверяет зависимость. Ошибка означает down, ошибка Degraded - degraded
+type CheckFunc func(ctx context.Context) error
+
+// Check - проверка одной зависимости
+type Check struct {
+	Name string
+	// Critical - отказ проверки снимает экземпляр с трафика; отказ некритичной проверки только понижает статус до degraded
+	Critical bool
+	// Timeout ограничивает одну проверку; 0 - таймаут реестра
+	Timeout time.Duration
+	Func    CheckFunc
+}
+
+type degradedError struct {
+	err error
+}
+
+func (e *degradedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *degradedError) Unwrap() error {
+	return e.err
+}
+
+// Degraded помечает ошибку проверки как некритичную: даже у критичной проверки статус будет degraded, а не down
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &degradedError{err: err}
+}
+
+// Result - результат одной проверки
+type Result struct {
+	Name      string
+	Status    Status
+	Critical  bool
+	Error     string
+	Duration  time.Duration
+	CheckedAt time.Time
+}
+
+// Report - результаты всех проверок и итоговый статус
+type Report struct {
+	Status    Status
+	Checks    []Result
+	CheckedAt time.Time
+}
+
+// Ready сообщает, может ли экземпляр принимать трафик: degraded - может
+func (r *Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// Options - настройки реестра
+type Options struct {
+	// Timeout - таймаут проверки по умолчанию
+	Timeout time.Duration
+	// CacheTTL - сколько отдается последний отчет, прежде чем проверки выполнятся снова
+	CacheTTL time.Duration
+}
+
+// Registry хранит проверки, кэширует их отчет и состояние жизненного цикла экземпляра
+type Registry struct {
+	opts Options
+
+	mu     sync.Mutex
+	checks []Check
+	report *Report
+	// previous - последний статус каждой проверки, чтобы писать в лог только изменения
+	previous map[string]Status
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry(opts Options) *Registry {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = defaultCacheTTL
+	}
+	return &Registry{opts: opts, previous: make(map[string]Status)}
+}
+
+// Register добавляет проверку; имена должны быть уникальны
+func (r *Registry) Register(check Check) {
+	if check.Name == "" || check.Func == nil {
+		panic("health: check must have a name and a function")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.checks {
+		if existing.Name == check.Name {
+			panic(fmt.Sprintf("health: check %s is already registered", check.Name))
+		}
+	}
+	r.checks = append(r.checks, check)
+	r.report = nil
+}
+
+// MarkStarted отмечает, что экземпляр закончил запуск: схема проверена, маршруты и фоновые задачи запущены
+func (r *Registry) MarkStarted() {
+	r.started.Store(true)
+}
+
+// Started сообщает, закончил ли экземпляр запуск
+func (r *Registry) Started() bool {
+	return r.started.Load()
+}
+
+// MarkShuttingDown отмечает начало остановки: readiness сразу перестает проходить
+func (r *Registry) MarkShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown сообщает, останавливается ли экземпляр
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Check возвращает отчет не старше CacheTTL, при необходимости выполняя все проверки параллельно.
+// Одновременные вызовы ждут одного выполнения проверок
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.report != nil && time.Since(r.report.CheckedAt) < r.opts.CacheTTL {
+		return r.report
+	}
+
+	results := make([]Result, len(r.checks))
+	var wg sync.WaitGroup
+	for i, check := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	r.logChanges(results)
+
+	r.report = &Report{Status: overall(results), Checks: results, CheckedAt: time.Now()}
+	return r.report
+}
+
+func (r *Registry) run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = r.opts.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := safeCheck(ctx, check.Func)
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		Duration:  time.Since(start),
+		CheckedAt: start,
+	}
+
+	var degraded *degradedError
+	switch {
+	case err == nil:
+	case errors.As(err, &degraded):
+		result.Status = StatusDegraded
+		result.Error = err.Error()
+	default:
+		result.Status = StatusDown
+		result.Error = err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Error = fmt.Sprintf("timed out after %s: %v", timeout, err)
+		}
+	}
+	return result
+}
+
+// safeCheck превращает панику проверки в ошибку
+func safeCheck(ctx context.Context, fn CheckFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx)
+}
+
+// overall - down, если отказала критичная проверка; degraded, если отказала некритичная или какая-то проверка degraded
+func overall(results []Result) Status {
+	status := StatusUp
+	for _, result := range results {
+		switch {
+		case result.Status == StatusDown && result.Critical:
+			return StatusDown
+		case result.Status != StatusUp:
+			status = StatusDegraded
+		}
+	}
+	return status
+}
+
+func (r *Registry) logChanges(results []Result) {
+	for _, result := range results {
+		previous, seen := r.previous[result.Name]
+		r.previous[result.Name] = result.Status
+		if previous == result.Status || (!seen && result.Status == StatusUp) {
+			continue
+		}
+
+		if result.Status == StatusUp {
+			slog.Info("health check recovered", "check", result.Name)
+			continue
+		}
+		slog.Warn("health check failed", "check", result.Name, "status", result.Status,
+			"critical", result.Critical, "error", result.Error)
+	}
+}
@@ -0,0 +1,187 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Locale string
+
+const (
+	English Locale = "en"
+	Russian Locale = "ru"
+
+	DefaultLocale = English
+)
+
+// Args - именованные параметры для подстановки в сообщение
+type Args map[string]interface{}
+
+var catalogs = map[Locale]map[string]string{
+	English: messagesEN,
+	Russian: messagesRU,
+}
+
+// Supported сообщает, есть ли каталог для локали
+func Supported(locale Locale) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Has сообщает, есть ли сообщение с таким ключом хотя бы в каталоге по умолчанию
+func Has(key string) bool {
+	_, ok := catalogs[DefaultLocale][key]
+	return ok
+}
+
+// T возвращает сообщение по ключу с подставленными параметрами.
+// Если перевода нет, используется английский вариант, а если нет и его - сам ключ
+func T(locale Locale, key string, args Args) string {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		locale = DefaultLocale
+		if message, ok = catalogs[DefaultLocale][key]; !ok {
+			return key
+		}
+	}
+	return Format(locale, message, args)
+}
+
+// Format подставляет параметры в шаблон сообщения:
+//
+//	{name}             - значение параметра name
+//	{n|файл|файла|файлов} - форма слова по правилам множественного числа локали для n
+//
+// Для английского используются две формы (one|other), для русского - три (one|few|many)
+func Format(locale Locale, message string, args Args) string {
+	var b strings.Builder
+
+	for {
+		start := strings.IndexByte(message, '{')
+		if start < 0 {
+			b.WriteString(message)
+			break
+		}
+		end := strings.IndexByte(message[start:], '}')
+		if end < 0 {
+			b.WriteString(message)
+			break
+		}
+		end += start
+
+		b.WriteString(message[:start])
+		b.WriteString(formatPlaceholder(locale, message[start+1:end], args))
+		message = message[end+1:]
+	}
+
+	return b.String()
+}
+
+func formatPlaceholder(locale Locale, placeholder string, args Args) string {
+	parts := strings.Split(placeholder, "|")
+	value, ok := args[parts[0]]
+	if !ok {
+		return "{" + placeholder + "}"
+	}
+
+	if len(parts) == 1 {
+		return fmt.Sprint(value)
+	}
+
+	n, err := strconv.Atoi(fmt.Sprint(value))
+	if err != nil {
+		return parts[len(parts)-1]
+	}
+	forms := parts[1:]
+	index := PluralIndex(locale, n)
+	if index >= len(forms) {
+		index = len(forms) - 1
+	}
+	return forms[index]
+}
+
+// PluralIndex возвращает номер формы множественного числа для n (CLDR, целые числа)
+func PluralIndex(locale Locale, n int) int {
+	if n < 0 {
+		n = -n
+	}
+
+	switch locale {
+	case Russian:
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		default:
+			return 2
+		}
+	default:
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+}
+
+// ParseAcceptLanguage выбирает поддерживаемую локаль из заголовка Accept-Language
+// с учетом q-весов. Возвращает пустую строку, если подходящей локали нет
+func ParseAcceptLanguage(header string) Locale {
+	type candidate struct {
+		locale Locale
+		q      float64
+		order  int
+	}
+
+	var candidates []candidate
+	for i, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		base := Locale(strings.SplitN(tag, "-", 2)[0])
+		if Supported(base) {
+			candidates = append(candidates, candidate{locale: base, q: q, order: i})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].locale
+}
+
+// Resolve выбирает локаль: сначала Accept-Language, затем настройка пользователя, затем значение по умолчанию
+func Resolve(acceptLanguage string, userLocale string, fallback Locale) Locale {
+	if locale := ParseAcceptLanguage(acceptLanguage); locale != "" {
+		return locale
+	}
+	if Supported(Locale(userLocale)) {
+		return Locale(userLocale)
+	}
+	if Supported(fallback) {
+		return fallback
+	}
+	return DefaultLocale
+}
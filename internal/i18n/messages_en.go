@@ -0,0 +1,99 @@
+package i18n
+
+// messagesEN - каталог по умолчанию: в нем должны быть все ключи
+var messagesEN = map[string]string{
+	// Ошибки API: ключ error.<code> или уточненный ключ из apperrors.Error.WithMessage
+	"error.internal_error":         "Internal server error",
+	"error.not_found":              "Resource not found",
+	"error.invalid_request_body":   "Invalid request body",
+	"error.validation_failed":      "Validation failed",
+	"error.unauthorized":           "Authentication required",
+	"error.session_expired":        "Session expired",
+	"error.no_session":             "No session token",
+	"error.forbidden":              "Access denied",
+	"error.invalid_credentials":    "Invalid credentials",
+	"error.account_disabled":       "Account is disabled",
+	"error.invalid_password":       "Invalid current password",
+	"error.email_taken":            "Email is already taken",
+	"error.username_taken":         "Username is already taken",
+	"error.email_unchanged":        "New email matches the current one",
+	"error.invalid_token":          "Invalid or already used link",
+	"error.token_expired":          "Link expired",
+	"error.rate_limited":           "Too many requests",
+	"error.export_not_ready":       "Export is not ready yet",
+	"error.query_canceled":         "Request took too long or was canceled",
+	"error.job_not_retryable":      "Only dead-lettered jobs can be retried",
+	"error.job_not_found":          "Job not found",
+	"error.invalid_session":        "Invalid session",
+	"error.user_not_found":         "User not found",
+	"error.deleted_user_not_found": "Deleted user not found or retention period expired",
+	"error.export_not_found":       "Export not found",
+	"error.export_rate_limited":    "Data export can be requested once per day",
+	"error.invalid_download_link":  "Invalid download link",
+	"error.download_link_expired":  "Download link expired",
+	"error.invalid_role":           "Unknown user role",
+	"error.webhook_disabled":       "Webhook endpoint is disabled",
+	"error.webhook_not_found":      "Webhook not found",
+	"error.delivery_not_found":     "Webhook delivery not found",
+	"error.template_not_found":     "Email template not found",
+	"error.session_not_found":      "Session not found",
+	"error.invalid_security_link":  "Invalid or outdated security link",
+	"error.login_blocked":          "Sign-in blocked as suspicious. If this is you, try again from a device you have used before",
+	"error.invalid_login_code":     "Invalid or expired sign-in code",
+	"error.device_not_found":       "Device not found",
+
+	// Ошибки валидации: ключ validation.<rule>, param - параметр правила
+	"validation.required":    "is required",
+	"validation.email":       "must be a valid email address",
+	"validation.min":         "must be at least {param} {param|character|characters} long",
+	"validation.max":         "must be at most {param} {param|character|characters} long",
+	"validation.oneof":       "must be one of: {param}",
+	"validation.username":    "may contain only latin letters, digits, '.', '_' and '-'",
+	"validation.notreserved": "is reserved",
+	"validation.http_url":    "must be an http or https URL",
+	"validation.uuid":        "must be a valid UUID",
+	"validation.datetime":    "must be a date and time in RFC 3339 format",
+	"validation.invalid":     "failed the {rule} rule",
+
+	// Письма: тема email.<template>.subject и фразы, из которых собираются шаблоны internal/mail/templates
+	"email.greeting": "Hi {name},",
+	"email.footer":   "You are receiving this email because you have a DevPrep account.",
+
+	"email.email_change_confirm.subject": "Confirm your new email address",
+	"email.email_change_confirm.intro":   "Confirm that {new_email} should become the email for your account.",
+	"email.email_change_confirm.action":  "Confirm email",
+	"email.email_change_confirm.expiry":  "The link is valid for {hours} {hours|hour|hours}.",
+
+	"email.email_change_notice.subject": "Your email address is being changed",
+	"email.email_change_notice.intro":   "Someone requested to change the email for your account to {new_email}.",
+	"email.email_change_notice.warning": "If this wasn't you, revert the change and sign out all sessions within {days} {days|day|days}.",
+	"email.email_change_notice.action":  "Revert the change",
+
+	"email.security.time":           "Time",
+	"email.security.device":         "Device",
+	"email.security.ip":             "IP address",
+	"email.security.unknown_device": "an unknown device",
+	"email.security.not_me":         "If this wasn't you, sign out of all sessions and set a new password right away.",
+	"email.security.action":         "This wasn't me",
+	"email.security.settings":       "You can choose which security alerts you receive in your account settings.",
+
+	"email.security_new_login.subject":        "New sign-in to your account",
+	"email.security_new_login.intro":          "Your account was just signed in to.",
+	"email.security_password_changed.subject": "Your password was changed",
+	"email.security_password_changed.intro":   "The password for your account was changed.",
+	"email.security_email_changed.subject":    "Your account email was changed",
+	"email.security_email_changed.intro":      "The email for your account was changed to {new_email}. This address will no longer receive messages about the account.",
+	"email.security_email_changed.revert":     "To get your address back, use the revert link from the earlier email about this change.",
+	"email.security_session_revoked.subject":  "A session was signed out",
+	"email.security_session_revoked.intro":    "The session on {session_device} was signed out of your account.",
+
+	"email.password_reset.subject": "Set a new password",
+	"email.password_reset.intro":   "All sessions of your account were signed out. Set a new password using the link below.",
+	"email.password_reset.action":  "Set a new password",
+	"email.password_reset.expiry":  "The link is valid for {minutes} {minutes|minute|minutes}.",
+
+	"email.login_challenge.subject": "Your sign-in code",
+	"email.login_challenge.intro":   "We noticed a sign-in to your account that looks unusual. Enter this code to finish signing in:",
+	"email.login_challenge.expiry":  "The code is valid for {minutes} {minutes|minute|minutes}.",
+	"email.login_challenge.not_me":  "If this wasn't you, someone knows your password. Don't share the code and change your password.",
+}
@@ -0,0 +1,95 @@
+package i18n
+
+var messagesRU = map[string]string{
+	"error.internal_error":         "Внутренняя ошибка сервера",
+	"error.not_found":              "Ресурс не найден",
+	"error.invalid_request_body":   "Некорректное тело запроса",
+	"error.validation_failed":      "Ошибка валидации",
+	"error.unauthorized":           "Требуется аутентификация",
+	"error.session_expired":        "Сессия истекла",
+	"error.no_session":             "Отсутствует токен сессии",
+	"error.forbidden":              "Доступ запрещен",
+	"error.invalid_credentials":    "Неверный email или пароль",
+	"error.account_disabled":       "Учетная запись отключена",
+	"error.invalid_password":       "Неверный текущий пароль",
+	"error.email_taken":            "Email уже занят",
+	"error.username_taken":         "Имя пользователя уже занято",
+	"error.email_unchanged":        "Новый email совпадает с текущим",
+	"error.invalid_token":          "Ссылка недействительна или уже использована",
+	"error.token_expired":          "Срок действия ссылки истек",
+	"error.rate_limited":           "Слишком много запросов",
+	"error.export_not_ready":       "Выгрузка еще не готова",
+	"error.query_canceled":         "Запрос выполнялся слишком долго или был отменен",
+	"error.job_not_retryable":      "Повторить можно только задачу, исчерпавшую попытки",
+	"error.job_not_found":          "Задача не найдена",
+	"error.invalid_session":        "Недействительная сессия",
+	"error.user_not_found":         "Пользователь не найден",
+	"error.deleted_user_not_found": "Удаленный пользователь не найден или срок хранения истек",
+	"error.export_not_found":       "Выгрузка не найдена",
+	"error.export_rate_limited":    "Выгрузку данных можно запрашивать не чаще раза в сутки",
+	"error.invalid_download_link":  "Недействительная ссылка для скачивания",
+	"error.download_link_expired":  "Срок действия ссылки для скачивания истек",
+	"error.invalid_role":           "Неизвестная роль пользователя",
+	"error.webhook_disabled":       "Вебхук отключен",
+	"error.webhook_not_found":      "Вебхук не найден",
+	"error.delivery_not_found":     "Доставка вебхука не найдена",
+	"error.template_not_found":     "Шаблон письма не найден",
+	"error.session_not_found":      "Сессия не найдена",
+	"error.invalid_security_link":  "Ссылка из уведомления безопасности недействительна",
+	"error.login_blocked":          "Вход заблокирован как подозрительный. Если это вы, войдите с устройства, которым уже пользовались",
+	"error.invalid_login_code":     "Неверный или истекший код входа",
+	"error.device_not_found":       "Устройство не найдено",
+
+	"validation.required":    "обязательное поле",
+	"validation.email":       "должно быть корректным email-адресом",
+	"validation.min":         "минимальная длина - {param} {param|символ|символа|символов}",
+	"validation.max":         "максимальная длина - {param} {param|символ|символа|символов}",
+	"validation.oneof":       "допустимые значения: {param}",
+	"validation.username":    "может содержать только латинские буквы, цифры, '.', '_' и '-'",
+	"validation.notreserved": "зарезервировано",
+	"validation.http_url":    "должно быть URL с протоколом http или https",
+	"validation.uuid":        "должно быть корректным UUID",
+	"validation.datetime":    "должно быть датой и временем в формате RFC 3339",
+	"validation.invalid":     "не прошло проверку {rule}",
+
+	"email.greeting": "Здравствуйте, {name}!",
+	"email.footer":   "Вы получили это письмо, потому что у вас есть учетная запись DevPrep.",
+
+	"email.email_change_confirm.subject": "Подтвердите новый адрес электронной почты",
+	"email.email_change_confirm.intro":   "Подтвердите, что {new_email} станет адресом вашей учетной записи.",
+	"email.email_change_confirm.action":  "Подтвердить адрес",
+	"email.email_change_confirm.expiry":  "Ссылка действует {hours} {hours|час|часа|часов}.",
+
+	"email.email_change_notice.subject": "Адрес электронной почты меняется",
+	"email.email_change_notice.intro":   "Кто-то запросил смену адреса вашей учетной записи на {new_email}.",
+	"email.email_change_notice.warning": "Если это были не вы, отмените смену и завершите все сессии в течение {days} {days|дня|дней|дней}.",
+	"email.email_change_notice.action":  "Отменить смену",
+
+	"email.security.time":           "Время",
+	"email.security.device":         "Устройство",
+	"email.security.ip":             "IP-адрес",
+	"email.security.unknown_device": "неизвестное устройство",
+	"email.security.not_me":         "Если это были не вы, сразу завершите все сессии и задайте новый пароль.",
+	"email.security.action":         "Это был не я",
+	"email.security.settings":       "Выбрать, какие уведомления безопасности получать, можно в настройках учетной записи.",
+
+	"email.security_new_login.subject":        "Новый вход в учетную запись",
+	"email.security_new_login.intro":          "В вашу учетную запись только что выполнен вход.",
+	"email.security_password_changed.subject": "Пароль изменен",
+	"email.security_password_changed.intro":   "Пароль вашей учетной записи изменен.",
+	"email.security_email_changed.subject":    "Адрес электронной почты изменен",
+	"email.security_email_changed.intro":      "Адрес вашей учетной записи изменен на {new_email}. На этот адрес больше не будут приходить письма об учетной записи.",
+	"email.security_email_changed.revert":     "Чтобы вернуть адрес, воспользуйтесь ссылкой отмены из предыдущего письма об этой смене.",
+	"email.security_session_revoked.subject":  "Сессия завершена",
+	"email.security_session_revoked.intro":    "Завершена сессия вашей учетной записи: {session_device}.",
+
+	"email.password_reset.subject": "Задайте новый пароль",
+	"email.password_reset.intro":   "Все сессии вашей учетной записи завершены. Задайте новый пароль по ссылке ниже.",
+	"email.password_reset.action":  "Задать новый пароль",
+	"email.password_reset.expiry":  "Ссылка действует {minutes} {minutes|минуту|минуты|минут}.",
+
+	"email.login_challenge.subject": "Код для входа",
+	"email.login_challenge.intro":   "Мы заметили необычный вход в вашу учетную запись. Чтобы завершить вход, введите код:",
+	"email.login_challenge.expiry":  "Код действует {minutes} {minutes|минуту|минуты|минут}.",
+	"email.login_challenge.not_me":  "Если это были не вы, кто-то знает ваш пароль. Никому не сообщайте код и смените пароль.",
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/outbox"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/scheduler"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"time"
+)
+
+// Имена задач обслуживания; под ними задачи записываются в историю job_runs
+const (
+	SessionCleanup      = "session_cleanup"
+	UserPurge           = "user_purge"
+	TokenExpiry         = "token_expiry"
+	PasswordResetExpiry = "password_reset_expiry"
+	LoginHistoryCleanup = "login_history_cleanup"
+	ExportCleanup       = "export_cleanup"
+	JobHistoryCleanup   = "job_history_cleanup"
+	QueueRescue         = "queue_rescue"
+	QueueCleanup        = "queue_cleanup"
+	OutboxCleanup       = "outbox_cleanup"
+	WebhookCleanup      = "webhook_cleanup"
+	AuditCheckpoint     = "audit_checkpoint"
+)
+
+// История запусков и журнал вебхуков нужны для разбора инцидентов, чистить их чаще раза в сутки незачем
+const (
+	jobHistoryCleanupSchedule = "@daily"
+	webhookCleanupSchedule    = "@daily"
+)
+
+// Задачи упавших воркеров должны вернуться в очередь быстро, а проверка дешевая
+const (
+	queueRescueSchedule   = "@every 1m"
+	queueCleanupSchedule  = "@hourly"
+	outboxCleanupSchedule = "@hourly"
+)
+
+// MaintenanceDeps - сервисы и репозитории, чьи задачи обслуживания регистрирует RegisterMaintenance
+type MaintenanceDeps struct {
+	Config             *config.Config
+	AuthService        *service.AuthService
+	UserService        *service.UserService
+	EmailChangeService *service.EmailChangeService
+	SecurityService    *service.SecurityService
+	LoginRiskService   *service.LoginRiskService
+	ExportService      *service.ExportService
+	WebhookService     *service.WebhookService
+	JobRuns            *repository.JobRunRepository
+	Queue              *queue.Queue
+	Outbox             *outbox.Outbox
+	AuditChain         *audit.Chain
+}
+
+// RegisterMaintenance регистрирует задачи обслуживания базы
+func RegisterMaintenance(s *scheduler.Scheduler, deps MaintenanceDeps) error {
+	cfg := deps.Config
+
+	// Истекшие сессии; RequireAuth удаляет их лениво, только если с ними пришел запрос
+	if err := s.Register(SessionCleanup, cfg.SessionCleanupSchedule, deps.AuthService.PurgeExpiredSessions); err != nil {
+		return err
+	}
+
+	// Пользователи, у которых истек срок хранения после мягкого удаления
+	if err := s.Register(UserPurge, cfg.UserPurgeSchedule, deps.UserService.PurgeDeletedUsers); err != nil {
+		return err
+	}
+
+	// Запросы смены email с истекшими ссылками подтверждения и отмены
+	if err := s.Register(TokenExpiry, cfg.TokenExpirySchedule, deps.EmailChangeService.PurgeExpired); err != nil {
+		return err
+	}
+
+	// Истекшие и использованные ссылки сброса пароля
+	if err := s.Register(PasswordResetExpiry, cfg.TokenExpirySchedule, deps.SecurityService.PurgePasswordResets); err != nil {
+		return err
+	}
+
+	// История входов старше LOGIN_HISTORY_TTL и отработавшие коды подтверждения входа
+	if err := s.Register(LoginHistoryCleanup, cfg.TokenExpirySchedule, deps.LoginRiskService.PurgeHistory); err != nil {
+		return err
+	}
+
+	// Архивы выгрузок с истекшими ссылками и неудавшиеся выгрузки
+	if err := s.Register(ExportCleanup, cfg.ExportCleanupSchedule, deps.ExportService.PurgeExpired); err != nil {
+		return err
+	}
+
+	err := s.Register(JobHistoryCleanup, jobHistoryCleanupSchedule, func(ctx context.Context) (int64, error) {
+		return deps.JobRuns.PruneBefore(ctx, time.Now().Add(-cfg.JobHistoryRetention))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Задачи очереди, зависшие в running после падения воркера
+	if err := s.Register(QueueRescue, queueRescueSchedule, deps.Queue.RescueStale); err != nil {
+		return err
+	}
+
+	// Выполненные задачи очереди старше QUEUE_RETENTION
+	if err := s.Register(QueueCleanup, queueCleanupSchedule, deps.Queue.PurgeCompleted); err != nil {
+		return err
+	}
+
+	// Опубликованные события старше OUTBOX_RETENTION
+	if err := s.Register(OutboxCleanup, outboxCleanupSchedule, deps.Outbox.PurgePublished); err != nil {
+		return err
+	}
+
+	// Журнал завершенных доставок вебхуков старше WEBHOOK_RETENTION
+	if err := s.Register(WebhookCleanup, webhookCleanupSchedule, deps.WebhookService.PurgeDeliveries); err != nil {
+		return err
+	}
+
+	// Подписанная контрольная точка журнала аудита; без AUDIT_SIGNING_KEY не создается
+	if !deps.AuditChain.CanSign() {
+		return nil
+	}
+	return s.Register(AuditCheckpoint, cfg.AuditCheckpointSchedule, deps.AuditChain.Checkpoint)
+}
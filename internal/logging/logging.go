@@ -0,0 +1,78 @@
+// Package logging настраивает структурированный лог (log/slog) и передает логгер через context:
+// middleware дополняет его ID запроса и пользователя, очередь и планировщик - задачей,
+// а сервисы и репозитории берут его из ctx через FromContext. WithTrace добавляет ID трассы OpenTelemetry
+package logging
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Форматы вывода лога
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type loggerKey struct{}
+
+// New создает логгер, пишущий в w записи уровня level и выше в формате format
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatJSON, "":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: must be %q or %q", format, FormatJSON, FormatText)
+}
+
+// ParseLevel разбирает уровень лога: debug, info, warn или error
+func ParseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
+
+// WithLogger кладет логгер в контекст
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext возвращает логгер из контекста, а если его там нет - slog.Default()
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With дополняет логгер из контекста атрибутами args и возвращает контекст с новым логгером
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// WithTrace дополняет логгер из контекста trace_id и span_id span из ctx, если он есть,
+// чтобы по записи лога можно было найти трассу
+func WithTrace(ctx context.Context) context.Context {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ctx
+	}
+	return With(ctx, "trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+}
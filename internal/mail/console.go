@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ConsoleMailer печатает письма в stdout, удобно для локальной разработки
+type ConsoleMailer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewConsoleMailer() *ConsoleMailer {
+	return &ConsoleMailer{out: os.Stdout}
+}
+
+// NewConsoleMailerTo печатает письма в out
+func NewConsoleMailerTo(out io.Writer) *ConsoleMailer {
+	return &ConsoleMailer{out: out}
+}
+
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	html := ""
+	if msg.HTML != "" {
+		html = fmt.Sprintf("\n[HTML version: %d bytes]", len(msg.HTML))
+	}
+	_, err := fmt.Fprintf(m.out, "----- email -----\nTo: %s\nSubject: %s\n\n%s%s\n-----------------\n",
+		strings.Join(msg.To, ", "), msg.Subject, msg.Text, html)
+	return err
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer складывает письма в каталог формата Maildir (tmp/new/cur): его можно открыть
+// почтовым клиентом или просмотреть как обычные .eml-файлы. Для разработки
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("error creating maildir %s: %w", dir, err)
+		}
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send пишет письмо в tmp и переносит в new: читатель Maildir не увидит недописанный файл
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := Build(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	unique := make([]byte, 8)
+	_, _ = rand.Read(unique)
+	hostname, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%s.%s.eml", now.UnixNano(), hex.EncodeToString(unique), hostname)
+
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing email: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(m.dir, "new", name)); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("error delivering email to maildir: %w", err)
+	}
+	return nil
+}
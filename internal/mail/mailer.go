@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"errors"
+)
+
+// Message - письмо с текстовой и (опционально) HTML-версией
+type Message struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// Mailer отправляет письма через конкретный транспорт
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// permanentError - письмо не будет доставлено и при повторе: неверный адрес, отказ сервера 5xx
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку отправки как неисправимую
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent сообщает, что повторять отправку бессмысленно
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,63 @@
+package mail
+
+import "github.com/AtlasOpx/devprep/internal/i18n"
+
+// previewData - примерные данные шаблонов для предпросмотра в админке.
+// Новый шаблон без записи здесь отрисуется с пустыми параметрами
+var previewData = map[string]i18n.Args{
+	"email_change_confirm": {
+		"name":      "Alex",
+		"new_email": "alex.new@example.com",
+		"link":      "https://devprep.example.com/api/v1/email/confirm?token=preview",
+		"hours":     24,
+	},
+	"email_change_notice": {
+		"name":      "Alex",
+		"new_email": "alex.new@example.com",
+		"link":      "https://devprep.example.com/api/v1/email/revert?token=preview",
+		"days":      7,
+	},
+	"security_new_login": {
+		"name":   "Alex",
+		"time":   "2025-01-15 09:30 UTC",
+		"device": "Chrome (macOS)",
+		"ip":     "203.0.113.7",
+		"link":   "https://devprep.example.com/api/v1/security/not-me?token=preview",
+	},
+	"security_password_changed": {
+		"name":   "Alex",
+		"time":   "2025-01-15 09:30 UTC",
+		"device": "Firefox (Windows)",
+		"ip":     "203.0.113.7",
+		"link":   "https://devprep.example.com/api/v1/security/not-me?token=preview",
+	},
+	"security_email_changed": {
+		"name":      "Alex",
+		"new_email": "alex.new@example.com",
+		"time":      "2025-01-15 09:30 UTC",
+		"device":    "Safari (iOS)",
+		"ip":        "203.0.113.7",
+		"link":      "https://devprep.example.com/api/v1/security/not-me?token=preview",
+	},
+	"security_session_revoked": {
+		"name":           "Alex",
+		"session_device": "Chrome (Android)",
+		"time":           "2025-01-15 09:30 UTC",
+		"device":         "Chrome (macOS)",
+		"ip":             "203.0.113.7",
+		"link":           "https://devprep.example.com/api/v1/security/not-me?token=preview",
+	},
+	"password_reset": {
+		"name":    "Alex",
+		"link":    "https://devprep.example.com/api/v1/password/reset?token=preview",
+		"minutes": 60,
+	},
+	"login_challenge": {
+		"name":    "Alex",
+		"code":    "482913",
+		"time":    "2025-01-15 09:30 UTC",
+		"device":  "Firefox (Linux)",
+		"ip":      "198.51.100.23",
+		"minutes": 10,
+	},
+}
@@ -0,0 +1,167 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// Режимы шифрования SMTP
+const (
+	// SMTPStartTLS - обычное соединение с обязательным переходом на TLS командой STARTTLS (порт 587)
+	SMTPStartTLS = "starttls"
+	// SMTPImplicitTLS - TLS с первого байта (порт 465)
+	SMTPImplicitTLS = "tls"
+	// SMTPPlain - без шифрования; только для локальных серверов и тестов
+	SMTPPlain = "none"
+)
+
+type SMTPOptions struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	TLS      string
+	Timeout  time.Duration
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер; соединение открывается на каждое письмо
+type SMTPMailer struct {
+	opts SMTPOptions
+}
+
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	switch opts.TLS {
+	case "":
+		opts.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPImplicitTLS, SMTPPlain:
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q", opts.TLS)
+	}
+	if opts.Host == "" {
+		return nil, errors.New("SMTP host is not set")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &SMTPMailer{opts: opts}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	recipients, err := Recipients(msg)
+	if err != nil {
+		return err
+	}
+	data, err := Build(m.opts.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(m.opts.From)
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid sender address %q: %w", m.opts.From, err))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// net/smtp не принимает контекст: закрываем соединение, если он истек раньше
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	if err := m.session(client, sender.Address, recipients, data); err != nil {
+		return classifySMTPError(err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(m.opts.Host, m.opts.Port)
+	tlsConfig := &tls.Config{ServerName: m.opts.Host}
+
+	var conn net.Conn
+	var err error
+	if m.opts.TLS == SMTPImplicitTLS {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to SMTP server %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error starting SMTP session: %w", err)
+	}
+
+	if m.opts.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("error starting TLS: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+func (m *SMTPMailer) session(client *smtp.Client, from string, recipients []string, data []byte) error {
+	if m.opts.Username != "" {
+		auth := smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// classifySMTPError помечает отказы 5xx как неисправимые: повтор получит тот же ответ
+func classifySMTPError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return Permanent(fmt.Errorf("SMTP server rejected message: %w", err))
+	}
+	return fmt.Errorf("error sending email: %w", err)
+}
@@ -0,0 +1,155 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Шаблоны писем: templates/<name>.html и templates/<name>.txt определяют блок content,
+// который подставляется в общий layout.html и layout.txt. Тексты берутся из каталогов i18n
+// функцией t, тема - из ключа email.<name>.subject
+//
+//go:embed templates
+var templateFS embed.FS
+
+const (
+	htmlLayout = "layout.html"
+	textLayout = "layout.txt"
+)
+
+// ErrUnknownTemplate - шаблона с таким именем нет
+var ErrUnknownTemplate = errors.New("mail: unknown template")
+
+// Renderer собирает письма из встроенных шаблонов на нужном языке
+type Renderer struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// NewRenderer разбирает встроенные шаблоны; у каждого письма должны быть обе версии
+func NewRenderer() (*Renderer, error) {
+	files, err := fs.Glob(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+	for _, file := range files {
+		if path.Base(file) == htmlLayout {
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(file), ".html")
+
+		html, err := htmltemplate.New(htmlLayout).Funcs(templateFuncs(i18n.DefaultLocale, "")).
+			ParseFS(templateFS, "templates/"+htmlLayout, file)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing email template %s: %w", file, err)
+		}
+		text, err := texttemplate.New(textLayout).Funcs(templateFuncs(i18n.DefaultLocale, "")).
+			ParseFS(templateFS, "templates/"+textLayout, "templates/"+name+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing email template %s.txt: %w", name, err)
+		}
+
+		r.html[name] = html
+		r.text[name] = text
+	}
+
+	return r, nil
+}
+
+// Templates возвращает имена шаблонов по алфавиту
+func (r *Renderer) Templates() []string {
+	names := make([]string, 0, len(r.html))
+	for name := range r.html {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render собирает письмо name для получателя to на языке locale
+func (r *Renderer) Render(locale i18n.Locale, name, to string, data i18n.Args) (Message, error) {
+	html, ok := r.html[name]
+	if !ok {
+		return Message{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	if data == nil {
+		data = i18n.Args{}
+	}
+
+	subject := i18n.T(locale, "email."+name+".subject", data)
+	funcs := templateFuncs(locale, subject)
+
+	// Клоны нужны, чтобы подставить функции нужного языка, не трогая разобранные шаблоны
+	textTemplate, err := r.text[name].Clone()
+	if err != nil {
+		return Message{}, err
+	}
+	var text bytes.Buffer
+	if err := textTemplate.Funcs(funcs).Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("error rendering email template %s.txt: %w", name, err)
+	}
+
+	htmlTemplate, err := html.Clone()
+	if err != nil {
+		return Message{}, err
+	}
+	var body bytes.Buffer
+	if err := htmlTemplate.Funcs(htmltemplate.FuncMap(funcs)).Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("error rendering email template %s.html: %w", name, err)
+	}
+
+	return Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    body.String(),
+	}, nil
+}
+
+// Preview собирает письмо name на примерных данных
+func (r *Renderer) Preview(locale i18n.Locale, name string) (Message, error) {
+	return r.Render(locale, name, "preview@example.com", previewData[name])
+}
+
+func templateFuncs(locale i18n.Locale, subject string) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"t": func(key string, args i18n.Args) string {
+			return i18n.T(locale, key, args)
+		},
+		"locale": func() string {
+			return string(locale)
+		},
+		"subject": func() string {
+			return subject
+		},
+		// dict собирает параметры для вложенного шаблона: dict "link" .link "label" "..."
+		"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+			if len(pairs)%2 != 0 {
+				return nil, errors.New("dict expects key-value pairs")
+			}
+			dict := make(map[string]interface{}, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+				}
+				dict[key] = pairs[i+1]
+			}
+			return dict, nil
+		},
+	}
+}
@@ -0,0 +1,134 @@
+// Package metrics - метрики Prometheus приложения. Все они регистрируются в Registry,
+// который отдает /metrics; глобальный реестр client_golang не используется
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const namespace = "devprep"
+
+// Registry - реестр метрик приложения, включая метрики рантайма Go и процесса
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Результаты входа для LoginsTotal
+const (
+	LoginSuccess    = "success"
+	LoginFailure    = "failure"
+	LoginChallenged = "challenged"
+)
+
+var (
+	// HTTPRequests - обработанные запросы по методу, шаблону маршрута Fiber и статусу
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration - время обработки запросов
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// PasswordHashDuration - время argon2: operation = hash (новый хеш) или verify (проверка пароля)
+	PasswordHashDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "password_hash_duration_seconds",
+		Help:      "Argon2 password hashing latency by operation.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	// LoginsTotal - попытки входа по результату и причине
+	LoginsTotal = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "logins_total",
+		Help:      "Login attempts by result (success, failure, challenged) and reason.",
+	}, []string{"result", "reason"})
+)
+
+// Handler отдает метрики Registry в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObservePasswordHash записывает время операции argon2, начатой в start
+func ObservePasswordHash(operation string, start time.Time) {
+	PasswordHashDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// RecordLogin увеличивает счетчик входов
+func RecordLogin(result, reason string) {
+	LoginsTotal.WithLabelValues(result, reason).Inc()
+}
+
+// RegisterDBStats публикует sql.DBStats пула соединений как go_sql_* с меткой db_name
+func RegisterDBStats(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterGauge публикует значение value как gauge devprep_<name>; value вызывается при каждом сборе
+func RegisterGauge(name, help string, value func() float64) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, value))
+}
+
+// countCollector - gauge, значение которого при каждом сборе считает запрос к БД
+type countCollector struct {
+	name    string
+	desc    *prometheus.Desc
+	count   func(ctx context.Context) (int64, error)
+	timeout time.Duration
+}
+
+// RegisterCount публикует gauge devprep_<name>, который при каждом сборе вызывает count с таймаутом.
+// Если count не удался, метрика в этом сборе пропускается, а ошибка пишется в лог
+func RegisterCount(name, help string, timeout time.Duration, count func(ctx context.Context) (int64, error)) error {
+	fqName := prometheus.BuildFQName(namespace, "", name)
+	return Registry.Register(&countCollector{
+		name:    fqName,
+		desc:    prometheus.NewDesc(fqName, help, nil, nil),
+		count:   count,
+		timeout: timeout,
+	})
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	value, err := c.count(ctx)
+	if err != nil {
+		slog.Error("failed to collect metric", "metric", c.name, "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(value))
+}
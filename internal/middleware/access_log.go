@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AccessLog пишет в logger по записи на каждый запрос: метод, шаблон маршрута, статус, время обработки,
+// размер ответа, пользователя, если он вошел, и ID трассы, если перед ним стоит Tracing. Ошибку хендлера сразу превращает в ответ через
+// ErrorHandler приложения, чтобы в лог попал итоговый статус. Ставится после RequestID
+func AccessLog(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		respondWithError(c, c.Next())
+
+		status := c.Response().StatusCode()
+		attrs := []slog.Attr{
+			slog.String("request_id", GetRequestID(c)),
+			slog.String("method", c.Method()),
+			slog.String("route", c.Route().Path),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", len(c.Response().Body())),
+			slog.String("ip", c.IP()),
+		}
+		if userID, ok := c.Locals("user_id").(uuid.UUID); ok {
+			attrs = append(attrs, slog.String("user_id", userID.String()))
+		}
+		if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.IsValid() {
+			attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(context.Background(), level, "request", attrs...)
+		return nil
+	}
+}
+
+// respondWithError превращает ошибку цепочки в ответ через ErrorHandler приложения,
+// чтобы middleware снаружи видели итоговый статус
+func respondWithError(c *fiber.Ctx, err error) {
+	if err == nil {
+		return
+	}
+	if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+		_ = c.SendStatus(fiber.StatusInternalServerError)
+	}
+}
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/logging"
 	"github.com/AtlasOpx/devprep/internal/models"
 	"github.com/AtlasOpx/devprep/internal/repository"
 	"time"
@@ -9,50 +13,64 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+var errInvalidSession = apperrors.ErrUnauthorized.WithMessage("error.invalid_session", "Invalid session")
+
 type AuthMiddleware struct {
-	authRepo *repository.AuthRepository
+	authRepo repository.AuthRepositoryInterface
 }
 
-func NewAuthMiddleware(authRepo *repository.AuthRepository) *AuthMiddleware {
+func NewAuthMiddleware(authRepo repository.AuthRepositoryInterface) *AuthMiddleware {
 	return &AuthMiddleware{authRepo: authRepo}
 }
 
 func (m *AuthMiddleware) RequireAuth(c *fiber.Ctx) error {
 	sessionToken := c.Cookies("session_token")
 	if sessionToken == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authentication required"})
+		return apperrors.ErrUnauthorized
 	}
 
-	session, err := m.authRepo.GetSessionByToken(sessionToken)
+	session, err := m.authRepo.GetSessionByToken(c.UserContext(), sessionToken)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid session"})
+		return sessionLookupError(err)
 	}
 
 	if session.ExpiresAt.Before(time.Now()) {
-		err := m.authRepo.DeleteSession(sessionToken)
+		err := m.authRepo.DeleteSession(c.UserContext(), sessionToken)
 		if err != nil {
 			return fmt.Errorf("couldn't delete the session: %w", err)
 		}
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Session expired"})
+		return apperrors.ErrSessionExpired
 	}
 
-	user, err := m.authRepo.ValidateSession(sessionToken)
+	user, err := m.authRepo.ValidateSession(c.UserContext(), sessionToken)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid session"})
+		return sessionLookupError(err)
 	}
 
 	c.Locals("user_id", user.ID)
 	c.Locals("user_role", user.Role)
+	c.Locals("user_locale", user.Locale)
+	ctx := audit.WithActor(c.UserContext(), audit.Actor{ID: user.ID, Role: user.Role})
+	c.SetUserContext(logging.With(ctx, "user_id", user.ID))
 
 	return c.Next()
 }
 
+// sessionLookupError скрывает причину неудачной проверки сессии, кроме прерванного запроса к БД:
+// иначе клиент получил бы 401 и потерял сессию из-за таймаута
+func sessionLookupError(err error) error {
+	if errors.Is(err, apperrors.ErrQueryCanceled) {
+		return err
+	}
+	return errInvalidSession
+}
+
 func (m *AuthMiddleware) RequireRole(requiredRole string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userRole := c.Locals("user_role").(models.UserRole)
 
 		if string(userRole) != requiredRole {
-			return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+			return apperrors.ErrForbidden
 		}
 
 		return c.Next()
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext кладет в UserContext контекст, который отменяется при остановке сервера
+// (отмене base) и истекает через timeout. Хендлеры передают его в сервисы и репозитории,
+// так что запросы к БД не переживают ни дедлайн, ни завершение приложения.
+// Разрыв соединения клиентом fasthttp не сообщает, поэтому такие запросы ограничены только дедлайном.
+// Адрес, User-Agent и ID запроса попадают в контекст для доменных событий и журнала аудита,
+// а логгер запроса с его ID и ID трассы - для логов сервисов и репозиториев.
+// span запроса из Tracing переносится в новый контекст
+func RequestContext(base context.Context, timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(base, timeout)
+		} else {
+			ctx, cancel = context.WithCancel(base)
+		}
+		defer cancel()
+
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(c.UserContext()))
+
+		requestID := ensureRequestID(c)
+		ctx = events.WithClient(ctx, events.Client{
+			IPAddress: c.IP(),
+			UserAgent: c.Get(fiber.HeaderUserAgent),
+			RequestID: requestID,
+		})
+		ctx = audit.WithSource(ctx, audit.SourceAPI)
+		ctx = logging.WithTrace(logging.With(ctx, "request_id", requestID))
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/AtlasOpx/devprep/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics считает запросы и время их обработки по методу, шаблону маршрута Fiber и статусу.
+// Шаблон (/users/:id), а не путь, держит число рядов метрик ограниченным
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		respondWithError(c, c.Next())
+
+		labels := []string{c.Method(), c.Route().Path, strconv.Itoa(c.Response().StatusCode())}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// LoginRateLimit пропускает с одного IP не больше max запросов за скользящее окно window
+// и отвечает на остальные 429 с Retry-After. Один обработчик ставится и на вход, и на ввод кода,
+// чтобы у них был общий счетчик. Счетчики хранятся в памяти процесса, у каждой реплики свои
+func LoginRateLimit(max int, window time.Duration) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:               max,
+		Expiration:        window,
+		LimiterMiddleware: limiter.SlidingWindow{},
+		LimitReached: func(c *fiber.Ctx) error {
+			return apperrors.ErrRateLimited
+		},
+	})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength - длиннее ID запроса от клиента не принимаем, чтобы не раздувать журнал
+const maxRequestIDLength = 100
+
+// RequestID присваивает запросу ID: берет его из X-Request-ID, если клиент его прислал,
+// иначе генерирует UUID. ID возвращается в том же заголовке ответа и доступен через GetRequestID.
+// Ставится первым, чтобы ID был и у ответов, которые не дошли до хендлеров
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ensureRequestID(c)
+		return c.Next()
+	}
+}
+
+// GetRequestID возвращает ID текущего запроса; пустая строка, если его еще не присвоили
+func GetRequestID(c *fiber.Ctx) string {
+	requestID, _ := c.Locals("request_id").(string)
+	return requestID
+}
+
+// ensureRequestID возвращает ID запроса, присваивая его, если RequestID не стоит в цепочке
+func ensureRequestID(c *fiber.Ctx) string {
+	if requestID := GetRequestID(c); requestID != "" {
+		return requestID
+	}
+
+	requestID := requestIDFrom(c)
+	c.Locals("request_id", requestID)
+	c.Set(fiber.HeaderXRequestID, requestID)
+	return requestID
+}
+
+// requestIDFrom возвращает ID запроса из заголовка или новый, если заголовка нет или он непригоден
+func requestIDFrom(c *fiber.Ctx) string {
+	requestID := c.Get(fiber.HeaderXRequestID)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return requestID
+}
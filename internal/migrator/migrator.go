@@ -0,0 +1,215 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/database/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// Migrator применяет встроенные миграции. Таблица версий совместима с утилитой migrate
+// (schema_migrations), а изменяющие схему операции выполняются под advisory lock Postgres,
+// поэтому несколько экземпляров приложения могут мигрировать одновременно
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// MigrationStatus - состояние одной встроенной миграции
+type MigrationStatus struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// Status - версия схемы в базе относительно встроенных миграций
+type Status struct {
+	Current    uint              `json:"current"`
+	Dirty      bool              `json:"dirty"`
+	Latest     uint              `json:"latest"`
+	Migrations []MigrationStatus `json:"migrations"`
+}
+
+// Pending сообщает, есть ли неприменённые миграции
+func (s *Status) Pending() bool {
+	return s.Current < s.Latest
+}
+
+// New создает мигратор на отдельном соединении из пула db; пул остается открытым после Close
+func New(ctx context.Context, db *sql.DB) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error creating migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error creating migrator: %w", err)
+	}
+	m.Log = logger{}
+
+	return &Migrator{m: m}, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Up применяет все неприменённые миграции
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down откатывает steps последних миграций
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	return ignoreNoChange(m.m.Steps(-steps))
+}
+
+// Force записывает версию без выполнения миграций и снимает флаг dirty.
+// Нужен, чтобы вручную восстановиться после упавшей миграции; -1 - схема без миграций
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Version возвращает текущую версию схемы; 0 - миграции еще не применялись
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Status сравнивает версию в базе со встроенными миграциями
+func (m *Migrator) Status() (*Status, error) {
+	current, dirty, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	embedded, err := Embedded()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Current: current, Dirty: dirty, Migrations: embedded}
+	for i := range status.Migrations {
+		migration := &status.Migrations[i]
+		migration.Applied = migration.Version <= current && !(dirty && migration.Version == current)
+		status.Latest = max(status.Latest, migration.Version)
+	}
+	return status, nil
+}
+
+// ReadStatus читает версию схемы прямо из schema_migrations, без мигратора и его отдельного соединения.
+// Подходит для частых проверок, например readiness
+func ReadStatus(ctx context.Context, db *sql.DB) (*Status, error) {
+	status := &Status{}
+	var version int64
+	err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &status.Dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("error reading schema version: %w", err)
+	case version > 0:
+		status.Current = uint(version)
+	}
+
+	latest, err := LatestVersion()
+	if err != nil {
+		return nil, err
+	}
+	status.Latest = latest
+	return status, nil
+}
+
+// Embedded возвращает список встроенных миграций по возрастанию версии
+func Embedded() ([]MigrationStatus, error) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]MigrationStatus)
+	for _, entry := range entries {
+		migration, err := source.DefaultParse(entry.Name())
+		if err != nil {
+			continue
+		}
+		byVersion[migration.Version] = MigrationStatus{Version: migration.Version, Name: migration.Identifier}
+	}
+
+	result := make([]MigrationStatus, 0, len(byVersion))
+	for _, migration := range byVersion {
+		result = append(result, migration)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// LatestVersion возвращает версию последней встроенной миграции
+func LatestVersion() (uint, error) {
+	embedded, err := Embedded()
+	if err != nil || len(embedded) == 0 {
+		return 0, err
+	}
+	return embedded[len(embedded)-1].Version, nil
+}
+
+// CheckStatus проверяет, что приложение может работать с текущей схемой:
+// не прерванная миграция и нет неприменённых миграций
+func CheckStatus(status *Status) error {
+	if status.Dirty {
+		return fmt.Errorf("database schema is dirty at version %d: fix it manually and run `devprep migrate force %d`",
+			status.Current, status.Current)
+	}
+	if status.Pending() {
+		return fmt.Errorf("database schema is at version %d, but version %d is required: run `devprep migrate up` or set AUTO_MIGRATE=true",
+			status.Current, status.Latest)
+	}
+	return nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+type logger struct{}
+
+func (logger) Printf(format string, v ...interface{}) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "migrate")
+}
+
+// Verbose включает сообщения о каждой применённой миграции
+func (logger) Verbose() bool {
+	return true
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuditEvent - запись журнала аудита
+type AuditEvent struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// Seq - номер записи в цепочке хешей, Hash - SHA-256 от PrevHash и содержимого записи.
+	// У записей, сделанных до появления цепочки, Hash пустой
+	Seq        int64      `json:"seq" db:"seq"`
+	PrevHash   string     `json:"prev_hash,omitempty" db:"prev_hash"`
+	Hash       string     `json:"hash,omitempty" db:"hash"`
+	OccurredAt time.Time  `json:"occurred_at" db:"occurred_at"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty" db:"actor_id"`
+	ActorRole  string     `json:"actor_role,omitempty" db:"actor_role"`
+	// Source - откуда пришло действие: api, cli или system (фоновые задачи и подписчики событий)
+	Source     string     `json:"source" db:"source"`
+	Action     string     `json:"action" db:"action"`
+	TargetType string     `json:"target_type,omitempty" db:"target_type"`
+	TargetID   *uuid.UUID `json:"target_id,omitempty" db:"target_id"`
+	IPAddress  string     `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent  string     `json:"user_agent,omitempty" db:"user_agent"`
+	RequestID  string     `json:"request_id,omitempty" db:"request_id"`
+	// Changes - измененные поля: {"before": {...}, "after": {...}}
+	Changes  json.RawMessage `json:"changes,omitempty" db:"changes"`
+	Metadata json.RawMessage `json:"metadata,omitempty" db:"metadata"`
+}
+
+// AuditFilter - условия выборки журнала аудита; пустые поля не фильтруют.
+// Action, оканчивающийся на точку (например, "webhook."), выбирает все действия с этим префиксом
+type AuditFilter struct {
+	// Subject - пользователь, чья история нужна: он исполнитель или объект действия
+	Subject    *uuid.UUID
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   *uuid.UUID
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditChainLink - звено цепочки хешей: номер записи и ее хеш
+type AuditChainLink struct {
+	Seq  int64  `json:"seq" db:"seq"`
+	Hash string `json:"hash" db:"hash"`
+}
+
+// AuditCheckpoint - подписанная ed25519 контрольная точка: хеш записи Seq на момент CreatedAt.
+// PublicKey и Signature - в base64; по ним точку можно проверить без доступа к базе
+type AuditCheckpoint struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Seq       int64     `json:"seq" db:"seq"`
+	Hash      string    `json:"hash" db:"hash"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	PublicKey string    `json:"public_key" db:"public_key"`
+	Signature string    `json:"signature" db:"signature"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type EmailChangeRequest struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	OldEmail         string     `json:"old_email" db:"old_email"`
+	NewEmail         string     `json:"new_email" db:"new_email"`
+	ConfirmTokenHash string     `json:"-" db:"confirm_token_hash"`
+	RevertTokenHash  string     `json:"-" db:"revert_token_hash"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`
+	RevertExpiresAt  time.Time  `json:"revert_expires_at" db:"revert_expires_at"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	RevertedAt       *time.Time `json:"reverted_at,omitempty" db:"reverted_at"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+	// AcceptLanguage - заголовок запроса; по нему выбирается язык писем
+	AcceptLanguage string `json:"-"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+type DataExport struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	Status      ExportStatus `json:"status" db:"status"`
+	FileKey     string       `json:"-" db:"file_key"`
+	Error       string       `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunStatusRunning   JobRunStatus = "running"
+	JobRunStatusSucceeded JobRunStatus = "succeeded"
+	JobRunStatusFailed    JobRunStatus = "failed"
+)
+
+// JobRun - запись истории запусков фоновой задачи
+type JobRun struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	JobName     string       `json:"job_name" db:"job_name"`
+	ScheduledAt time.Time    `json:"scheduled_at" db:"scheduled_at"`
+	StartedAt   time.Time    `json:"started_at" db:"started_at"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty" db:"finished_at"`
+	Status      JobRunStatus `json:"status" db:"status"`
+	Processed   int64        `json:"processed" db:"processed"`
+	Error       string       `json:"error,omitempty" db:"error"`
+	Instance    string       `json:"instance" db:"instance"`
+}
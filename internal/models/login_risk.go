@@ -0,0 +1,99 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LoginSignal - признак подозрительного входа
+type LoginSignal string
+
+const (
+	LoginSignalNewDevice        LoginSignal = "new_device"
+	LoginSignalNewSubnet        LoginSignal = "new_subnet"
+	LoginSignalImpossibleTravel LoginSignal = "impossible_travel"
+	LoginSignalUnusualHour      LoginSignal = "unusual_hour"
+)
+
+// LoginDecision - что делать со входом по итогам оценки риска
+type LoginDecision string
+
+const (
+	LoginDecisionAllow     LoginDecision = "allow"
+	LoginDecisionChallenge LoginDecision = "challenge"
+	LoginDecisionBlock     LoginDecision = "block"
+)
+
+// LoginOutcome - чем закончился вход в истории входов
+type LoginOutcome string
+
+const (
+	LoginOutcomeAllowed    LoginOutcome = "allowed"
+	LoginOutcomeChallenged LoginOutcome = "challenged"
+	LoginOutcomeVerified   LoginOutcome = "verified"
+	LoginOutcomeBlocked    LoginOutcome = "blocked"
+)
+
+// LoginAssessment - оценка риска одного входа
+type LoginAssessment struct {
+	Score       int
+	Signals     []LoginSignal
+	Decision    LoginDecision
+	Fingerprint string
+	Subnet      string
+	// Location - координаты адреса, если его удалось найти в базе GeoIP
+	Location *GeoPoint
+}
+
+// SignalNames возвращает признаки строками - в таком виде они хранятся и попадают в события
+func (a *LoginAssessment) SignalNames() []string {
+	names := make([]string, len(a.Signals))
+	for i, signal := range a.Signals {
+		names[i] = string(signal)
+	}
+	return names
+}
+
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type KnownDevice struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Fingerprint string    `json:"-" db:"fingerprint"`
+	UserAgent   string    `json:"user_agent" db:"user_agent"`
+	LastIP      string    `json:"last_ip" db:"last_ip"`
+	FirstSeen   time.Time `json:"first_seen" db:"first_seen"`
+	LastSeen    time.Time `json:"last_seen" db:"last_seen"`
+}
+
+type LoginRecord struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	IPAddress   string       `json:"ip_address" db:"ip_address"`
+	UserAgent   string       `json:"user_agent" db:"user_agent"`
+	Fingerprint string       `json:"-" db:"fingerprint"`
+	Subnet      string       `json:"subnet" db:"subnet"`
+	Location    *GeoPoint    `json:"location,omitempty"`
+	RiskScore   int          `json:"risk_score" db:"risk_score"`
+	Signals     []string     `json:"signals" db:"signals"`
+	Outcome     LoginOutcome `json:"outcome" db:"outcome"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+type LoginChallenge struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	CodeHash    string     `json:"-" db:"code_hash"`
+	Fingerprint string     `json:"-" db:"fingerprint"`
+	IPAddress   string     `json:"ip_address" db:"ip_address"`
+	UserAgent   string     `json:"user_agent" db:"user_agent"`
+	RiskScore   int        `json:"risk_score" db:"risk_score"`
+	Signals     []string   `json:"signals" db:"signals"`
+	Attempts    int        `json:"attempts" db:"attempts"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// OutboxEvent - доменное событие, ожидающее публикации или уже опубликованное relay
+type OutboxEvent struct {
+	ID            int64           `json:"id" db:"id"`
+	EventID       uuid.UUID       `json:"event_id" db:"event_id"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at" db:"occurred_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" db:"published_at"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+}
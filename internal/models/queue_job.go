@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type QueueJobStatus string
+
+const (
+	QueueJobStatusPending   QueueJobStatus = "pending"
+	QueueJobStatusRunning   QueueJobStatus = "running"
+	QueueJobStatusCompleted QueueJobStatus = "completed"
+	// QueueJobStatusDead - попытки исчерпаны или ошибка неисправима; задача ждет ручного повтора
+	QueueJobStatusDead QueueJobStatus = "dead"
+)
+
+func (s QueueJobStatus) Valid() bool {
+	switch s {
+	case QueueJobStatusPending, QueueJobStatusRunning, QueueJobStatusCompleted, QueueJobStatusDead:
+		return true
+	}
+	return false
+}
+
+// QueueJob - задача асинхронной очереди
+type QueueJob struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      QueueJobStatus  `json:"status" db:"status"`
+	UniqueKey   *string         `json:"unique_key,omitempty" db:"unique_key"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty" db:"locked_at"`
+	LockedBy    *string         `json:"locked_by,omitempty" db:"locked_by"`
+	LastError   *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// QueueJobFilter - условия выборки задач для администратора; пустые поля не фильтруют
+type QueueJobFilter struct {
+	Status QueueJobStatus
+	Kind   string
+	Limit  int
+	Offset int
+}
+
+// QueueStat - число задач одного вида в одном статусе
+type QueueStat struct {
+	Kind   string         `json:"kind"`
+	Status QueueJobStatus `json:"status"`
+	Count  int64          `json:"count"`
+	// OldestRunAt - самое раннее время запуска среди задач группы; для pending показывает отставание очереди
+	OldestRunAt time.Time `json:"oldest_run_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SecurityAlert - тип уведомления безопасности, которое пользователь может отключить
+type SecurityAlert string
+
+const (
+	SecurityAlertNewLogin        SecurityAlert = "new_login"
+	SecurityAlertPasswordChanged SecurityAlert = "password_changed"
+	SecurityAlertEmailChanged    SecurityAlert = "email_changed"
+	SecurityAlertSessionRevoked  SecurityAlert = "session_revoked"
+)
+
+// SecurityAlerts - все типы уведомлений в порядке показа в настройках
+var SecurityAlerts = []SecurityAlert{
+	SecurityAlertNewLogin, SecurityAlertPasswordChanged, SecurityAlertEmailChanged, SecurityAlertSessionRevoked,
+}
+
+// Valid сообщает, является ли тип уведомления одним из известных
+func (a SecurityAlert) Valid() bool {
+	for _, alert := range SecurityAlerts {
+		if alert == a {
+			return true
+		}
+	}
+	return false
+}
+
+type PasswordResetToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// NotMeToken - одноразовая ссылка «это был не я» из уведомления. Email - адрес, на который ушло уведомление
+type NotMeToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	Email     string     `json:"email" db:"email"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
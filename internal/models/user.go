@@ -13,17 +13,28 @@ const (
 	UserRoleModerator UserRole = "moderator"
 )
 
+// Valid сообщает, является ли роль одной из известных
+func (r UserRole) Valid() bool {
+	switch r {
+	case UserRoleUser, UserRoleAdmin, UserRoleModerator:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	Username     string    `json:"username" db:"username"`
-	FirstName    string    `json:"first_name" db:"first_name"`
-	LastName     string    `json:"last_name" db:"last_name"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	Role         UserRole  `json:"role" db:"role"`
-	IsActive     bool      `json:"is_active" db:"is_active"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	Email        string     `json:"email" db:"email"`
+	Username     string     `json:"username" db:"username"`
+	FirstName    string     `json:"first_name" db:"first_name"`
+	LastName     string     `json:"last_name" db:"last_name"`
+	PasswordHash string     `json:"-" db:"password_hash"`
+	Role         UserRole   `json:"role" db:"role"`
+	IsActive     bool       `json:"is_active" db:"is_active"`
+	Locale       string     `json:"locale" db:"locale"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type RegisterRequest struct {
@@ -37,15 +48,36 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	// UserAgent и IPAddress берутся из запроса и сохраняются в сессии
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
 type UpdateProfileRequest struct {
 	FirstName string `json:"first_name,omitempty"`
 	LastName  string `json:"last_name,omitempty"`
 	Username  string `json:"username,omitempty"`
+	Locale    string `json:"locale,omitempty"`
 }
 
 type LoginResponse struct {
-	Message string `json:"message"`
-	User    User   `json:"user"`
+	Message          string    `json:"message"`
+	User             User      `json:"user"`
+	SessionToken     string    `json:"-"`
+	SessionExpiresAt time.Time `json:"-"`
+	// Challenge - вход признан рискованным и ждет кода из письма; сессия в этом случае не создается
+	Challenge *LoginChallenge `json:"-"`
+}
+
+// VerifyLoginRequest - подтверждение рискованного входа кодом из письма
+type VerifyLoginRequest struct {
+	ChallengeID uuid.UUID
+	Code        string
+	UserAgent   string
+	IPAddress   string
+}
+
+type UserSearchResult struct {
+	User  User    `json:"user"`
+	Score float64 `json:"score"`
 }
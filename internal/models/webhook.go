@@ -0,0 +1,106 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+// WebhookAllEvents в списке событий подписывает endpoint на все события
+const WebhookAllEvents = "*"
+
+// WebhookEndpoint - адрес, на который отправляются подписанные уведомления о событиях
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	Description string    `json:"description" db:"description"`
+	Events      []string  `json:"events" db:"events"`
+	Secret      string    `json:"-" db:"secret"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	// ConsecutiveFailures - неудачные попытки подряд; при достижении порога endpoint отключается
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty" db:"disabled_reason"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Subscribed сообщает, нужно ли отправлять на endpoint события типа eventType
+func (e *WebhookEndpoint) Subscribed(eventType string) bool {
+	for _, subscribed := range e.Events {
+		if subscribed == WebhookAllEvents || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest - параметры нового endpoint; пустой Secret генерируется
+type CreateWebhookRequest struct {
+	URL         string
+	Description string
+	Events      []string
+	Secret      string
+}
+
+// UpdateWebhookRequest - изменение endpoint; nil-поля не меняются.
+// Включение отключенного endpoint сбрасывает счетчик неудач
+type UpdateWebhookRequest struct {
+	URL         *string
+	Description *string
+	Events      []string
+	IsActive    *bool
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed - попытки исчерпаны или endpoint отключен; можно отправить повторно вручную
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+func (s WebhookDeliveryStatus) Valid() bool {
+	switch s {
+	case WebhookDeliveryPending, WebhookDeliverySucceeded, WebhookDeliveryFailed:
+		return true
+	}
+	return false
+}
+
+// WebhookDelivery - доставка одного события на один endpoint. Payload - тело запроса
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	EndpointID     uuid.UUID             `json:"endpoint_id" db:"endpoint_id"`
+	EventID        uuid.UUID             `json:"event_id" db:"event_id"`
+	EventType      string                `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	ResponseStatus *int                  `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string               `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at" db:"updated_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// WebhookAttempt - запись журнала попыток доставки
+type WebhookAttempt struct {
+	ID             int64     `json:"id" db:"id"`
+	DeliveryID     uuid.UUID `json:"delivery_id" db:"delivery_id"`
+	Attempt        int       `json:"attempt" db:"attempt"`
+	ResponseStatus *int      `json:"response_status,omitempty" db:"response_status"`
+	ResponseBody   *string   `json:"response_body,omitempty" db:"response_body"`
+	Error          *string   `json:"error,omitempty" db:"error"`
+	DurationMs     int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// WebhookDeliveryFilter - выборка доставок endpoint; пустой статус не фильтрует
+type WebhookDeliveryFilter struct {
+	EndpointID uuid.UUID
+	Status     WebhookDeliveryStatus
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// KafkaWriter - часть kafka.Writer, нужная приемнику; в тестах ее заменяет заглушка
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaSink пишет события в топик с ключом - идентификатором агрегата, поэтому события
+// одного агрегата попадают в одну партицию и читаются в порядке публикации
+type KafkaSink struct {
+	writer KafkaWriter
+}
+
+func NewKafkaSink(writer KafkaWriter) *KafkaSink {
+	return &KafkaSink{writer: writer}
+}
+
+// NewKafkaWriter создает синхронный writer: WriteMessages возвращается после
+// подтверждения всеми репликами, иначе relay не узнает о потере сообщения
+func NewKafkaWriter(brokers []string, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.AggregateID.String()),
+		Value: data,
+		Time:  event.OccurredAt,
+		Headers: []kafka.Header{
+			{Key: "event_id", Value: []byte(event.ID.String())},
+			{Key: "event_type", Value: []byte(event.Type)},
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/nats-io/nats.go"
+)
+
+// JetStreamPublisher - часть nats.JetStreamContext, нужная приемнику; в тестах ее заменяет заглушка
+type JetStreamPublisher interface {
+	PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error)
+}
+
+// NATSSink публикует события в JetStream в subject <prefix>.<тип события>.
+// JetStream подтверждает запись, а заголовок Nats-Msg-Id отсекает повторы
+// внутри окна дедупликации стрима
+type NATSSink struct {
+	js     JetStreamPublisher
+	prefix string
+}
+
+func NewNATSSink(js JetStreamPublisher, subjectPrefix string) *NATSSink {
+	return &NATSSink{js: js, prefix: subjectPrefix}
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSSink) Publish(ctx context.Context, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.prefix + "." + event.Type)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, event.ID.String())
+	msg.Header.Set("Aggregate-ID", event.AggregateID.String())
+
+	_, err = s.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
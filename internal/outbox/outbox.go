@@ -0,0 +1,276 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// finishTimeout - сколько ждем записи результата публикации, если ctx relay уже отменен
+const finishTimeout = 5 * time.Second
+
+// relayLock - advisory lock, под которым публикует события только одна реплика
+const relayLock = "outbox:relay"
+
+// Store - хранилище событий (таблица outbox)
+type Store interface {
+	Append(ctx context.Context, event *models.OutboxEvent) error
+	FetchPending(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	DeletePublished(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+// Locker дает relay эксклюзивный доступ к outbox между репликами (advisory lock Postgres)
+type Locker interface {
+	TryAdvisoryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+}
+
+// Recorder записывает доменные события; сервисы зависят от него, а не от Outbox
+type Recorder interface {
+	Record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload interface{}) error
+}
+
+// Sink - приемник опубликованных событий: шина в процессе, вебхук, NATS, Kafka
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event events.Event) error
+}
+
+type Options struct {
+	// PollInterval - как часто relay проверяет outbox, если новых событий не было
+	PollInterval time.Duration
+	// BatchSize - сколько событий relay забирает за один запрос
+	BatchSize int
+	// BackoffBase и BackoffMax - задержка перед повторной публикацией после неудачи
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Retention - сколько хранятся опубликованные события
+	Retention time.Duration
+}
+
+// Outbox записывает события в транзакциях сервисов и публикует их в приемники.
+// Публикует одна реплика, события идут по порядку записи; если публикация события
+// не удалась, следующие события того же агрегата ждут его повтора
+type Outbox struct {
+	store  Store
+	locker Locker
+	sinks  []Sink
+	opts   Options
+	wake   chan struct{}
+
+	mu         sync.Mutex
+	started    bool
+	stopLoop   context.CancelFunc
+	cancelRuns context.CancelFunc
+	done       chan struct{}
+}
+
+func New(store Store, locker Locker, sinks []Sink, opts Options) *Outbox {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = time.Second
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = 5 * time.Minute
+	}
+	return &Outbox{
+		store:  store,
+		locker: locker,
+		sinks:  sinks,
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Record записывает событие. Чтобы событие не разошлось с состоянием, вызывать его нужно
+// в той же транзакции, что и изменение: тогда событие появится только после коммита
+func (o *Outbox) Record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outbox: error encoding payload of %s: %w", eventType, err)
+	}
+
+	err = o.store.Append(ctx, &models.OutboxEvent{
+		EventID:       uuid.New(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       data,
+		OccurredAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Если транзакция еще не закоммичена, relay не увидит событие и заберет его при следующем опросе
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start запускает relay
+func (o *Outbox) Start() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started {
+		return
+	}
+	o.started = true
+
+	loopCtx, stopLoop := context.WithCancel(context.Background())
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	o.stopLoop, o.cancelRuns = stopLoop, cancelRuns
+	o.done = make(chan struct{})
+
+	go o.loop(loopCtx, runCtx)
+}
+
+// Shutdown останавливает relay и ждет завершения текущей публикации. Если ctx истекает
+// раньше, публикация отменяется: неопубликованные события останутся в outbox
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	o.mu.Lock()
+	if !o.started {
+		o.mu.Unlock()
+		return nil
+	}
+	o.stopLoop()
+	o.mu.Unlock()
+
+	select {
+	case <-o.done:
+		o.cancelRuns()
+		return nil
+	case <-ctx.Done():
+		o.cancelRuns()
+		<-o.done
+		return ctx.Err()
+	}
+}
+
+func (o *Outbox) loop(loopCtx, runCtx context.Context) {
+	defer close(o.done)
+
+	for {
+		if _, err := o.RelayOnce(runCtx); err != nil && loopCtx.Err() == nil {
+			logging.FromContext(runCtx).Error("outbox relay failed", "error", err)
+		}
+
+		select {
+		case <-loopCtx.Done():
+			return
+		case <-o.wake:
+		case <-time.After(o.opts.PollInterval):
+		}
+	}
+}
+
+// RelayOnce публикует все готовые события и возвращает число опубликованных.
+// Если relay работает на другой реплике, ничего не делает
+func (o *Outbox) RelayOnce(ctx context.Context) (int, error) {
+	unlock, acquired, err := o.locker.TryAdvisoryLock(ctx, relayLock)
+	if err != nil || !acquired {
+		return 0, err
+	}
+	defer unlock()
+
+	published := 0
+	for ctx.Err() == nil {
+		batch, err := o.store.FetchPending(ctx, o.opts.BatchSize)
+		if err != nil {
+			return published, err
+		}
+
+		n, err := o.publishBatch(ctx, batch)
+		published += n
+		if err != nil {
+			return published, err
+		}
+		if len(batch) < o.opts.BatchSize || n == 0 {
+			return published, nil
+		}
+	}
+	return published, ctx.Err()
+}
+
+// publishBatch публикует события по порядку. После неудачи события того же агрегата
+// в этой пачке пропускаются, чтобы не обогнать неопубликованное
+func (o *Outbox) publishBatch(ctx context.Context, batch []models.OutboxEvent) (int, error) {
+	blocked := make(map[string]bool)
+	published := 0
+
+	for i := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+
+		event := &batch[i]
+		aggregate := event.AggregateType + ":" + event.AggregateID.String()
+		if blocked[aggregate] {
+			continue
+		}
+
+		publishErr := o.publish(ctx, event)
+
+		finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		var err error
+		if publishErr == nil {
+			err = o.store.MarkPublished(finishCtx, event.ID)
+		} else {
+			blocked[aggregate] = true
+			delay := queue.Backoff(event.Attempts+1, o.opts.BackoffBase, o.opts.BackoffMax)
+			logging.FromContext(ctx).Warn("failed to publish event, retrying", "event_id", event.EventID,
+				"event_type", event.EventType, "retry_in", delay.String(), "error", publishErr)
+			err = o.store.MarkFailed(finishCtx, event.ID, time.Now().Add(delay), publishErr.Error())
+		}
+		cancel()
+
+		if err != nil {
+			// Без записи результата порядок не гарантирован: прерываем пачку, событие опубликуется повторно
+			return published, fmt.Errorf("error recording result of event %s: %w", event.EventID, err)
+		}
+		if publishErr == nil {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// publish отправляет событие во все приемники. Если хотя бы один не принял событие,
+// оно повторяется целиком, и остальные приемники получат его еще раз
+func (o *Outbox) publish(ctx context.Context, record *models.OutboxEvent) error {
+	event := events.Event{
+		ID:            record.EventID,
+		Type:          record.EventType,
+		AggregateType: record.AggregateType,
+		AggregateID:   record.AggregateID,
+		OccurredAt:    record.OccurredAt,
+		Payload:       record.Payload,
+	}
+
+	for _, sink := range o.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// PurgePublished удаляет опубликованные события старше Retention
+func (o *Outbox) PurgePublished(ctx context.Context) (int64, error) {
+	return o.store.DeletePublished(ctx, time.Now().Add(-o.opts.Retention))
+}
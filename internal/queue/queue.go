@@ -0,0 +1,466 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// finishTimeout - сколько ждем записи результата, если ctx задачи уже отменен
+const finishTimeout = 5 * time.Second
+
+// Store - хранилище задач (таблица jobs)
+type Store interface {
+	Enqueue(ctx context.Context, job *models.QueueJob) (bool, error)
+	Claim(ctx context.Context, kinds []string, worker string) (*models.QueueJob, error)
+	Complete(ctx context.Context, id uuid.UUID, worker string) error
+	Retry(ctx context.Context, id uuid.UUID, worker string, runAt time.Time, lastError string) error
+	Bury(ctx context.Context, id uuid.UUID, worker string, lastError string) error
+	RescueStale(ctx context.Context, lockedBefore time.Time) (int64, error)
+	Requeue(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteCompleted(ctx context.Context, finishedBefore time.Time) (int64, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.QueueJob, error)
+	List(ctx context.Context, filter models.QueueJobFilter) ([]models.QueueJob, int64, error)
+	Stats(ctx context.Context) ([]models.QueueStat, error)
+}
+
+// Enqueuer ставит задачи в очередь; сервисы зависят от него, а не от Queue
+type Enqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload interface{}, opts ...Option) (uuid.UUID, error)
+}
+
+// Handler выполняет задачу. Ошибка означает повтор с backoff, ошибка Permanent - сразу dead letter
+type Handler func(ctx context.Context, job *models.QueueJob) error
+
+type Options struct {
+	// Workers - число воркеров этого процесса
+	Workers int
+	// PollInterval - как часто воркер проверяет очередь, если задач не было
+	PollInterval time.Duration
+	// MaxAttempts - попыток по умолчанию, после которых задача уходит в dead letter
+	MaxAttempts int
+	// BackoffBase и BackoffMax - задержка перед n-й повторной попыткой растет как BackoffBase * 2^(n-1)
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// JobTimeout ограничивает время одной попытки
+	JobTimeout time.Duration
+	// StaleAfter - через сколько выполняющаяся задача считается брошенной упавшим воркером
+	StaleAfter time.Duration
+	// Retention - сколько хранятся выполненные задачи
+	Retention time.Duration
+	// WorkerID записывается в locked_by
+	WorkerID string
+}
+
+// Queue - очередь асинхронных задач поверх Postgres
+type Queue struct {
+	store    Store
+	opts     Options
+	handlers map[string]Handler
+	wake     chan struct{}
+
+	mu         sync.Mutex
+	started    bool
+	stopPoll   context.CancelFunc
+	cancelRuns context.CancelFunc
+	workers    sync.WaitGroup
+}
+
+func New(store Store, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	return &Queue{
+		store:    store,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register задает обработчик задач вида kind с payload типа T
+func Register[T any](q *Queue, kind string, fn func(ctx context.Context, payload T) error) {
+	q.Handle(kind, func(ctx context.Context, job *models.QueueJob) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Handle задает обработчик задач вида kind; регистрировать нужно до Start
+func (q *Queue) Handle(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		panic("queue: handler registered after start: " + kind)
+	}
+	q.handlers[kind] = handler
+}
+
+// Option - параметр постановки задачи
+type Option func(job *models.QueueJob)
+
+// WithUniqueKey не дает поставить вторую незавершенную задачу того же вида с тем же ключом
+func WithUniqueKey(key string) Option {
+	return func(job *models.QueueJob) {
+		job.UniqueKey = &key
+	}
+}
+
+// WithRunAt откладывает выполнение до runAt
+func WithRunAt(runAt time.Time) Option {
+	return func(job *models.QueueJob) {
+		job.RunAt = runAt
+	}
+}
+
+// WithDelay откладывает выполнение на delay
+func WithDelay(delay time.Duration) Option {
+	return func(job *models.QueueJob) {
+		job.RunAt = job.CreatedAt.Add(delay)
+	}
+}
+
+// WithMaxAttempts задает число попыток вместо значения из настроек очереди
+func WithMaxAttempts(attempts int) Option {
+	return func(job *models.QueueJob) {
+		job.MaxAttempts = attempts
+	}
+}
+
+// Enqueue ставит задачу в очередь. Внутри транзакции задача появится только после коммита.
+// Если задача с таким же ключом уникальности уже ждет выполнения, возвращает uuid.Nil без ошибки
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...Option) (uuid.UUID, error) {
+	if _, ok := q.handler(kind); !ok {
+		return uuid.Nil, fmt.Errorf("queue: no handler for job kind %s", kind)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("queue: error encoding payload of %s: %w", kind, err)
+	}
+
+	now := time.Now()
+	job := &models.QueueJob{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Payload:     data,
+		MaxAttempts: q.opts.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	enqueued, err := q.store.Enqueue(ctx, job)
+	if err != nil || !enqueued {
+		return uuid.Nil, err
+	}
+
+	// Будим свободного воркера; если задача поставлена в незакоммиченной транзакции,
+	// он ее не увидит и заберет при следующем опросе
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job.ID, nil
+}
+
+// Start запускает пул воркеров
+func (q *Queue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+
+	pollCtx, stopPoll := context.WithCancel(context.Background())
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	q.stopPoll, q.cancelRuns = stopPoll, cancelRuns
+
+	for i := 1; i <= q.opts.Workers; i++ {
+		q.workers.Add(1)
+		go q.work(pollCtx, runCtx, fmt.Sprintf("%s/%d", q.opts.WorkerID, i))
+	}
+}
+
+// Shutdown перестает забирать задачи и ждет завершения выполняющихся. Если ctx истекает
+// раньше, задачи отменяются и возвращаются в очередь, а Shutdown возвращает ошибку ctx
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.started {
+		q.mu.Unlock()
+		return nil
+	}
+	q.stopPoll()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancelRuns()
+		return nil
+	case <-ctx.Done():
+		q.cancelRuns()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work(pollCtx, runCtx context.Context, worker string) {
+	defer q.workers.Done()
+
+	kinds := q.kinds()
+	for {
+		if pollCtx.Err() != nil {
+			return
+		}
+
+		job, err := q.store.Claim(pollCtx, kinds, worker)
+		if err != nil && pollCtx.Err() == nil {
+			logging.FromContext(pollCtx).Error("queue worker failed to claim a job", "worker", worker, "error", err)
+		}
+		if job != nil {
+			q.process(runCtx, job, worker)
+			continue
+		}
+
+		select {
+		case <-pollCtx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
+
+func (q *Queue) process(ctx context.Context, job *models.QueueJob, worker string) {
+	// Каждая попытка - отдельная трасса
+	ctx, span := tracing.Start(ctx, "queue "+job.Kind, trace.WithNewRoot(), trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("job.id", job.ID.String()),
+			attribute.String("job.kind", job.Kind),
+			attribute.Int("job.attempt", job.Attempts),
+		))
+	defer span.End()
+
+	// Логи обработчика и самой очереди несут ID и тип задачи
+	ctx = logging.WithTrace(logging.With(ctx, "job_id", job.ID, "job_kind", job.Kind, "attempt", job.Attempts))
+	logger := logging.FromContext(ctx)
+
+	if q.opts.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.opts.JobTimeout)
+		defer cancel()
+	}
+
+	handler, ok := q.handler(job.Kind)
+	var err error
+	if ok {
+		err = safeHandle(withAttempt(ctx, job), handler, job)
+	} else {
+		err = Permanent(fmt.Errorf("no handler for job kind %s", job.Kind))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	switch {
+	case err == nil:
+		err = q.store.Complete(finishCtx, job.ID, worker)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		logger.Error("job moved to dead letter", "error", err)
+		err = q.store.Bury(finishCtx, job.ID, worker, err.Error())
+	default:
+		delay := Backoff(job.Attempts, q.opts.BackoffBase, q.opts.BackoffMax)
+		logger.Warn("job attempt failed, retrying", "retry_in", delay.String(), "error", err)
+		err = q.store.Retry(finishCtx, job.ID, worker, time.Now().Add(delay), err.Error())
+	}
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		// Задачу сочли брошенной и вернули в очередь; результат этой попытки не записываем,
+		// чтобы не затереть состояние, которое теперь ведет другой воркер
+		logger.Warn("job was rescued from this worker, result discarded", "worker", worker)
+	case err != nil:
+		logger.Error("failed to record job result", "error", err)
+	}
+}
+
+// safeHandle превращает панику обработчика в ошибку, чтобы она не уронила воркер
+func safeHandle(ctx context.Context, handler Handler, job *models.QueueJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Backoff возвращает задержку перед повтором после attempt-й неудачной попытки:
+// base * 2^(attempt-1), не больше max, со случайным разбросом в нижнюю половину
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := base
+	for i := 1; i < attempt && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Lag возвращает, сколько ждет самая старая задача, время запуска которой уже наступило; 0 - очередь не отстает
+func (q *Queue) Lag(ctx context.Context) (time.Duration, error) {
+	stats, err := q.store.Stats(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var lag time.Duration
+	now := time.Now()
+	for _, stat := range stats {
+		if stat.Status == models.QueueJobStatusPending && stat.Count > 0 {
+			lag = max(lag, now.Sub(stat.OldestRunAt))
+		}
+	}
+	return lag, nil
+}
+
+// RescueStale возвращает в очередь задачи, брошенные упавшими воркерами
+func (q *Queue) RescueStale(ctx context.Context) (int64, error) {
+	return q.store.RescueStale(ctx, time.Now().Add(-q.opts.StaleAfter))
+}
+
+// PurgeCompleted удаляет выполненные задачи старше Retention
+func (q *Queue) PurgeCompleted(ctx context.Context) (int64, error) {
+	return q.store.DeleteCompleted(ctx, time.Now().Add(-q.opts.Retention))
+}
+
+func (q *Queue) GetJob(ctx context.Context, id uuid.UUID) (*models.QueueJob, error) {
+	return q.store.GetByID(ctx, id)
+}
+
+func (q *Queue) ListJobs(ctx context.Context, filter models.QueueJobFilter) ([]models.QueueJob, int64, error) {
+	return q.store.List(ctx, filter)
+}
+
+func (q *Queue) Stats(ctx context.Context) ([]models.QueueStat, error) {
+	return q.store.Stats(ctx)
+}
+
+// RetryJob ставит задачу из dead letter в очередь заново
+func (q *Queue) RetryJob(ctx context.Context, id uuid.UUID) (*models.QueueJob, error) {
+	requeued, err := q.store.Requeue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := q.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		return nil, apperrors.ErrJobNotRetryable
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+func (q *Queue) kinds() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (q *Queue) handler(kind string) (Handler, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	handler, ok := q.handlers[kind]
+	return handler, ok
+}
+
+// permanentError - ошибка, которую бессмысленно повторять
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку обработчика как неисправимую: задача сразу уходит в dead letter
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+type attemptKey struct{}
+
+type attempt struct {
+	number, max int
+}
+
+func withAttempt(ctx context.Context, job *models.QueueJob) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt{number: job.Attempts, max: job.MaxAttempts})
+}
+
+// LastAttempt сообщает обработчику, что при ошибке повторов больше не будет,
+// например чтобы отметить связанную сущность как неудавшуюся
+func LastAttempt(ctx context.Context) bool {
+	a, ok := ctx.Value(attemptKey{}).(attempt)
+	return ok && a.number >= a.max
+}
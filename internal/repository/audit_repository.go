@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"strings"
+)
+
+var auditEventColumns = []string{
+	"id", "seq", "prev_hash", "hash", "occurred_at", "actor_id", "actor_role", "source", "action", "target_type", "target_id",
+	"ip_address", "user_agent", "request_id", "changes", "metadata",
+}
+
+type AuditRepository struct {
+	db *database.DB
+}
+
+// auditChainLock - advisory-блокировка, под которой цепочку продолжает только одна реплика
+const auditChainLock = "audit:chain"
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+var auditCheckpointColumns = []string{"id", "seq", "hash", "created_at", "public_key", "signature"}
+
+// Append добавляет запись без номера и хеша; изменить или удалить ее потом нельзя.
+// В цепочку запись ставит SealPending после коммита транзакции изменения, так что
+// транзакции, пишущие в журнал, не ждут друг друга
+func (r *AuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	_, err := r.db.Insert(ctx, "audit_events").
+		Columns("id", "occurred_at", "actor_id", "actor_role", "source", "action", "target_type", "target_id",
+			"ip_address", "user_agent", "request_id", "changes", "metadata").
+		Values(event.ID, event.OccurredAt, event.ActorID, event.ActorRole, event.Source, event.Action, event.TargetType,
+			event.TargetID, event.IPAddress, event.UserAgent, event.RequestID, nullJSON(event.Changes), nullJSON(event.Metadata)).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// SealPending ставит в цепочку до limit записей без номера в порядке времени и возвращает их число.
+// seal получает запись и последнее звено цепочки (nil для пустого журнала) и заполняет Seq, PrevHash и Hash.
+// Работает в своей короткой транзакции под advisory-блокировкой цепочки; если цепочку сейчас
+// продолжает другая реплика, ничего не делает
+func (r *AuditRepository) SealPending(ctx context.Context, limit int, seal func(event *models.AuditEvent, last *models.AuditChainLink) error) (int64, error) {
+	var sealed int64
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		var acquired bool
+		err := r.db.Select(ctx).
+			Column(squirrel.Expr("pg_try_advisory_xact_lock(hashtextextended(?, 0))", auditChainLock)).
+			QueryRowContext(ctx).
+			Scan(&acquired)
+		if err != nil || !acquired {
+			return mapError(err)
+		}
+
+		last, err := r.ChainHead(ctx)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			last = nil
+		} else if err != nil {
+			return err
+		}
+
+		pending, err := r.listEvents(ctx, r.db.Select(ctx, auditEventColumns...).
+			From("audit_events").
+			Where("seq IS NULL").
+			OrderBy("occurred_at", "id").
+			Limit(uint64(limit)))
+		if err != nil {
+			return err
+		}
+
+		for i := range pending {
+			event := &pending[i]
+			if err := seal(event, last); err != nil {
+				return err
+			}
+
+			result, err := r.db.Update(ctx, "audit_events").
+				Set("seq", event.Seq).
+				Set("prev_hash", event.PrevHash).
+				Set("hash", event.Hash).
+				Where("id = ? AND seq IS NULL", event.ID).
+				ExecContext(ctx)
+			if err := expectAffected(result, err); err != nil {
+				return err
+			}
+			last = &models.AuditChainLink{Seq: event.Seq, Hash: event.Hash}
+		}
+		sealed = int64(len(pending))
+		return nil
+	})
+	return sealed, err
+}
+
+// ChainHead возвращает последнее звено цепочки; ErrNotFound, если журнал пуст
+func (r *AuditRepository) ChainHead(ctx context.Context) (*models.AuditChainLink, error) {
+	var link models.AuditChainLink
+	err := r.db.Select(ctx, "seq", "hash").
+		From("audit_events").
+		Where("seq IS NOT NULL").
+		OrderBy("seq DESC").
+		Limit(1).
+		QueryRowContext(ctx).
+		Scan(&link.Seq, &link.Hash)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &link, nil
+}
+
+// ListChain возвращает записи с номером больше afterSeq по возрастанию номера
+func (r *AuditRepository) ListChain(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	return r.listEvents(ctx, r.db.Select(ctx, auditEventColumns...).
+		From("audit_events").
+		Where("seq > ?", afterSeq).
+		OrderBy("seq").
+		Limit(uint64(limit)))
+}
+
+func (r *AuditRepository) listEvents(ctx context.Context, query squirrel.SelectBuilder) ([]models.AuditEvent, error) {
+	rows, err := query.QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var auditEvents []models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		auditEvents = append(auditEvents, *event)
+	}
+	return auditEvents, rows.Err()
+}
+
+// CreateCheckpoint сохраняет подписанную контрольную точку
+func (r *AuditRepository) CreateCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	_, err := r.db.Insert(ctx, "audit_checkpoints").
+		Columns(auditCheckpointColumns...).
+		Values(checkpoint.ID, checkpoint.Seq, checkpoint.Hash, checkpoint.CreatedAt, checkpoint.PublicKey, checkpoint.Signature).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// LatestCheckpoint возвращает последнюю контрольную точку; ErrNotFound, если их нет
+func (r *AuditRepository) LatestCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	checkpoints, err := r.listCheckpoints(ctx, r.db.Select(ctx, auditCheckpointColumns...).
+		From("audit_checkpoints").
+		OrderBy("seq DESC", "created_at DESC").
+		Limit(1))
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, apperrors.ErrNotFound
+	}
+	return &checkpoints[0], nil
+}
+
+// ListCheckpoints возвращает контрольные точки с номером записи больше afterSeq по возрастанию
+func (r *AuditRepository) ListCheckpoints(ctx context.Context, afterSeq int64) ([]models.AuditCheckpoint, error) {
+	return r.listCheckpoints(ctx, r.db.Select(ctx, auditCheckpointColumns...).
+		From("audit_checkpoints").
+		Where("seq > ?", afterSeq).
+		OrderBy("seq", "created_at"))
+}
+
+func (r *AuditRepository) listCheckpoints(ctx context.Context, query squirrel.SelectBuilder) ([]models.AuditCheckpoint, error) {
+	rows, err := query.QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var checkpoints []models.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint models.AuditCheckpoint
+		err := rows.Scan(&checkpoint.ID, &checkpoint.Seq, &checkpoint.Hash, &checkpoint.CreatedAt,
+			&checkpoint.PublicKey, &checkpoint.Signature)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+// List возвращает записи по фильтру, новые первыми, и общее число подходящих записей.
+// Записи, еще не поставленные в цепочку, - самые новые
+func (r *AuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error) {
+	where := squirrel.And{}
+	if filter.Subject != nil {
+		where = append(where, squirrel.Or{
+			squirrel.Eq{"actor_id": *filter.Subject},
+			squirrel.Eq{"target_type": "user", "target_id": *filter.Subject},
+		})
+	}
+	if filter.ActorID != nil {
+		where = append(where, squirrel.Eq{"actor_id": *filter.ActorID})
+	}
+	if filter.TargetType != "" {
+		where = append(where, squirrel.Eq{"target_type": filter.TargetType})
+	}
+	if filter.TargetID != nil {
+		where = append(where, squirrel.Eq{"target_id": *filter.TargetID})
+	}
+	if strings.HasSuffix(filter.Action, ".") {
+		where = append(where, squirrel.Like{"action": escapeLike(filter.Action) + "%"})
+	} else if filter.Action != "" {
+		where = append(where, squirrel.Eq{"action": filter.Action})
+	}
+	if filter.From != nil {
+		where = append(where, squirrel.GtOrEq{"occurred_at": *filter.From})
+	}
+	if filter.To != nil {
+		where = append(where, squirrel.Lt{"occurred_at": *filter.To})
+	}
+
+	var total int64
+	err := r.db.Select(ctx, "COUNT(*)").
+		From("audit_events").
+		Where(where).
+		QueryRowContext(ctx).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	rows, err := r.db.Select(ctx, auditEventColumns...).
+		From("audit_events").
+		Where(where).
+		OrderBy("seq DESC NULLS FIRST", "occurred_at DESC").
+		Limit(uint64(filter.Limit)).
+		Offset(uint64(filter.Offset)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	var auditEvents []models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		auditEvents = append(auditEvents, *event)
+	}
+
+	return auditEvents, total, rows.Err()
+}
+
+func scanAuditEvent(rows *sql.Rows) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var seq sql.NullInt64
+	var changes, metadata []byte
+	err := rows.Scan(&event.ID, &seq, &event.PrevHash, &event.Hash, &event.OccurredAt, &event.ActorID,
+		&event.ActorRole, &event.Source, &event.Action, &event.TargetType, &event.TargetID, &event.IPAddress,
+		&event.UserAgent, &event.RequestID, &changes, &metadata)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	event.Seq, event.Changes, event.Metadata = seq.Int64, changes, metadata
+	return &event, nil
+}
+
+// nullJSON записывает пустой JSON как NULL
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
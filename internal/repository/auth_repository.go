@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"github.com/AtlasOpx/devprep/internal/database"
 	"github.com/AtlasOpx/devprep/internal/models"
 	"github.com/google/uuid"
@@ -11,53 +12,111 @@ type AuthRepository struct {
 	db *database.DB
 }
 
-func NewAuthRepository(db *database.DB) *AuthRepository {
+func NewAuthRepository(db *database.DB) AuthRepositoryInterface {
 	return &AuthRepository{db: db}
 }
 
-func (r *AuthRepository) CreateSession(userID uuid.UUID, sessionToken string, expiresAt time.Time, userAgent, ipAddress string) error {
-	_, err := r.db.Insert("sessions").
+func (r *AuthRepository) CreateSession(ctx context.Context, userID uuid.UUID, sessionToken string, expiresAt time.Time, userAgent, ipAddress string) error {
+	_, err := r.db.Insert(ctx, "sessions").
 		Columns("user_id", "session_token", "expires_at", "user_agent", "ip_address").
 		Values(userID, sessionToken, expiresAt, userAgent, ipAddress).
-		Exec()
-	return err
+		ExecContext(ctx)
+	return mapError(err)
 }
 
-func (r *AuthRepository) DeleteSession(sessionToken string) error {
-	_, err := r.db.Delete("sessions").
+func (r *AuthRepository) DeleteSession(ctx context.Context, sessionToken string) error {
+	_, err := r.db.Delete(ctx, "sessions").
 		Where("session_token = ?", sessionToken).
-		Exec()
-	return err
+		ExecContext(ctx)
+	return mapError(err)
 }
 
-func (r *AuthRepository) GetSessionByToken(sessionToken string) (*models.Session, error) {
+func (r *AuthRepository) GetSessionByToken(ctx context.Context, sessionToken string) (*models.Session, error) {
 	var session models.Session
-	err := r.db.Select("user_id", "session_token", "expires_at", "user_agent", "ip_address", "created_at").
+	err := r.db.Select(ctx, "user_id", "session_token", "expires_at", "user_agent", "ip_address", "created_at").
 		From("sessions").
 		Where("session_token = ? AND expires_at > NOW()", sessionToken).
-		QueryRow().
+		QueryRowContext(ctx).
 		Scan(&session.UserID, &session.SessionToken, &session.ExpiresAt,
 			&session.UserAgent, &session.IPAddress, &session.CreatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &session, nil
 }
 
-func (r *AuthRepository) ValidateSession(sessionToken string) (*models.User, error) {
+func (r *AuthRepository) ValidateSession(ctx context.Context, sessionToken string) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("u.id", "u.email", "u.username", "u.first_name", "u.last_name", "u.password_hash", "u.role", "u.is_active", "u.created_at", "u.updated_at").
+	err := r.db.Select(ctx, "u.id", "u.email", "u.username", "u.first_name", "u.last_name", "u.password_hash", "u.role", "u.is_active", "u.locale", "u.created_at", "u.updated_at").
 		From("users u").
 		Join("sessions s ON u.id = s.user_id").
-		Where("s.session_token = ? AND s.expires_at > NOW() AND u.is_active = true", sessionToken).
-		QueryRow().
+		Where("s.session_token = ? AND s.expires_at > NOW() AND u.is_active = true AND u.deleted_at IS NULL", sessionToken).
+		QueryRowContext(ctx).
 		Scan(&user.ID, &user.Email, &user.Username, &user.FirstName,
-			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
 			&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
+
+func (r *AuthRepository) GetSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	rows, err := r.db.Select(ctx, "id", "user_id", "session_token", "expires_at", "COALESCE(user_agent, '')", "COALESCE(HOST(ip_address), '')", "created_at").
+		From("sessions").
+		Where("user_id = ?", userID).
+		OrderBy("created_at DESC").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.SessionToken, &session.ExpiresAt,
+			&session.UserAgent, &session.IPAddress, &session.CreatedAt)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *AuthRepository) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Delete(ctx, "sessions").
+		Where("user_id = ?", userID).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// CountActiveSessions возвращает число неистекших сессий всех пользователей
+func (r *AuthRepository) CountActiveSessions(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.Select(ctx, "COUNT(*)").
+		From("sessions").
+		Where("expires_at > NOW()").
+		QueryRowContext(ctx).
+		Scan(&count)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return count, nil
+}
+
+// CleanupExpiredSessions удаляет истекшие сессии функцией cleanup_expired_sessions() и возвращает их количество
+func (r *AuthRepository) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := r.db.Select(ctx, "cleanup_expired_sessions()").
+		QueryRowContext(ctx).
+		Scan(&deleted)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+type EmailChangeRepository struct {
+	db *database.DB
+}
+
+func NewEmailChangeRepository(db *database.DB) EmailChangeRepositoryInterface {
+	return &EmailChangeRepository{db: db}
+}
+
+func (r *EmailChangeRepository) Create(ctx context.Context, req *models.EmailChangeRequest) error {
+	_, err := r.db.Insert(ctx, "email_change_requests").
+		Columns("id", "user_id", "old_email", "new_email", "confirm_token_hash", "revert_token_hash", "created_at", "expires_at", "revert_expires_at").
+		Values(req.ID, req.UserID, req.OldEmail, req.NewEmail, req.ConfirmTokenHash, req.RevertTokenHash, req.CreatedAt, req.ExpiresAt, req.RevertExpiresAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *EmailChangeRepository) GetByConfirmTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error) {
+	return r.scanOne(ctx, r.selectRequest(ctx).Where("confirm_token_hash = ?", tokenHash))
+}
+
+func (r *EmailChangeRepository) GetByRevertTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error) {
+	return r.scanOne(ctx, r.selectRequest(ctx).Where("revert_token_hash = ?", tokenHash))
+}
+
+// GetForUpdate перечитывает запрос и блокирует строку до конца транзакции, чтобы одновременные
+// подтверждение и отмена по одному запросу выполнялись по очереди и видели результат друг друга
+func (r *EmailChangeRepository) GetForUpdate(ctx context.Context, id uuid.UUID) (*models.EmailChangeRequest, error) {
+	return r.scanOne(ctx, r.selectRequest(ctx).Where("id = ?", id).Suffix("FOR UPDATE"))
+}
+
+// CancelPending отменяет все неподтвержденные запросы пользователя
+func (r *EmailChangeRepository) CancelPending(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Update(ctx, "email_change_requests").
+		Set("cancelled_at", squirrel.Expr("NOW()")).
+		Where("user_id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL", userID).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// MarkConfirmed отмечает запрос подтвержденным. ErrNotFound, если его уже подтвердили, отменили
+// или откатили: по одной ссылке email меняется только один раз
+func (r *EmailChangeRepository) MarkConfirmed(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Update(ctx, "email_change_requests").
+		Set("confirmed_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND confirmed_at IS NULL AND reverted_at IS NULL AND cancelled_at IS NULL", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// MarkReverted отмечает запрос откаченным. ErrNotFound, если его уже откатили
+func (r *EmailChangeRepository) MarkReverted(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Update(ctx, "email_change_requests").
+		Set("reverted_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND reverted_at IS NULL", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// DeleteExpired удаляет запросы, ссылки которых больше нельзя использовать: неподтвержденные
+// после истечения ссылки подтверждения и любые после истечения ссылки отмены
+func (r *EmailChangeRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.Delete(ctx, "email_change_requests").
+		Where("(confirmed_at IS NULL AND expires_at < NOW()) OR revert_expires_at < NOW()").
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *EmailChangeRepository) selectRequest(ctx context.Context) squirrel.SelectBuilder {
+	return r.db.Select(ctx, "id", "user_id", "old_email", "new_email", "confirm_token_hash", "revert_token_hash",
+		"created_at", "expires_at", "revert_expires_at", "confirmed_at", "reverted_at", "cancelled_at").
+		From("email_change_requests")
+}
+
+func (r *EmailChangeRepository) scanOne(ctx context.Context, query squirrel.SelectBuilder) (*models.EmailChangeRequest, error) {
+	var req models.EmailChangeRequest
+	err := query.QueryRowContext(ctx).
+		Scan(&req.ID, &req.UserID, &req.OldEmail, &req.NewEmail, &req.ConfirmTokenHash, &req.RevertTokenHash,
+			&req.CreatedAt, &req.ExpiresAt, &req.RevertExpiresAt, &req.ConfirmedAt, &req.RevertedAt, &req.CancelledAt)
+
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &req, nil
+}
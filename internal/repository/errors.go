@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/lib/pq"
+	"strings"
+)
+
+const (
+	pqUniqueViolation = "23505"
+	pqQueryCanceled   = "57014"
+)
+
+// mapError переводит ошибки драйвера в доменные. Исходная ошибка сохраняется
+// в цепочке, так что errors.Is(err, sql.ErrNoRows) продолжает работать
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrNotFound.Wrap(err)
+	}
+
+	// Запрос прерван по дедлайну или отмене контекста (клиент ушел, сервер завершается)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return apperrors.ErrQueryCanceled.Wrap(err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqQueryCanceled {
+		return apperrors.ErrQueryCanceled.Wrap(err)
+	}
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+		switch {
+		case strings.Contains(pqErr.Constraint, "email"):
+			return apperrors.ErrEmailTaken.Wrap(err)
+		case strings.Contains(pqErr.Constraint, "username"):
+			return apperrors.ErrUsernameTaken.Wrap(err)
+		}
+	}
+
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
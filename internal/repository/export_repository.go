@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+type ExportRepository struct {
+	db *database.DB
+}
+
+func NewExportRepository(db *database.DB) ExportRepositoryInterface {
+	return &ExportRepository{db: db}
+}
+
+func (r *ExportRepository) Create(ctx context.Context, export *models.DataExport) error {
+	_, err := r.db.Insert(ctx, "data_exports").
+		Columns("id", "user_id", "status", "created_at").
+		Values(export.ID, export.UserID, export.Status, export.CreatedAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// LockUser берет транзакционную advisory-блокировку выгрузок пользователя: запросы
+// одного пользователя проверяют лимит и создают выгрузку по очереди
+func (r *ExportRepository) LockUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Select(ctx).
+		Column(squirrel.Expr("pg_advisory_xact_lock(hashtextextended(?, 0))", "export:"+userID.String())).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	return r.scanOne(ctx, r.db.Select(ctx, "id", "user_id", "status", "COALESCE(file_key, '')", "COALESCE(error, '')", "created_at", "completed_at", "expires_at").
+		From("data_exports").
+		Where("id = ?", id))
+}
+
+func (r *ExportRepository) GetLatestByUserID(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	return r.scanOne(ctx, r.db.Select(ctx, "id", "user_id", "status", "COALESCE(file_key, '')", "COALESCE(error, '')", "created_at", "completed_at", "expires_at").
+		From("data_exports").
+		Where("user_id = ?", userID).
+		OrderBy("created_at DESC").
+		Limit(1))
+}
+
+func (r *ExportRepository) MarkCompleted(ctx context.Context, id uuid.UUID, fileKey string, expiresAt time.Time) error {
+	_, err := r.db.Update(ctx, "data_exports").
+		Set("status", models.ExportStatusCompleted).
+		Set("file_key", fileKey).
+		Set("completed_at", squirrel.Expr("NOW()")).
+		Set("expires_at", expiresAt).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *ExportRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := r.db.Update(ctx, "data_exports").
+		Set("status", models.ExportStatusFailed).
+		Set("error", reason).
+		Set("completed_at", squirrel.Expr("NOW()")).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// ListExpired возвращает выгрузки, созданные раньше createdBefore, у которых больше нечего скачивать:
+// завершенные с истекшей к now ссылкой и неудавшиеся. Старые первыми
+func (r *ExportRepository) ListExpired(ctx context.Context, now, createdBefore time.Time, limit int) ([]models.DataExport, error) {
+	rows, err := r.db.Select(ctx, "id", "user_id", "status", "COALESCE(file_key, '')", "COALESCE(error, '')", "created_at", "completed_at", "expires_at").
+		From("data_exports").
+		Where(squirrel.Lt{"created_at": createdBefore}).
+		Where(squirrel.Or{
+			squirrel.And{squirrel.Eq{"status": models.ExportStatusCompleted}, squirrel.Lt{"expires_at": now}},
+			squirrel.Eq{"status": models.ExportStatusFailed},
+		}).
+		OrderBy("created_at").
+		Limit(uint64(limit)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var exports []models.DataExport
+	for rows.Next() {
+		var export models.DataExport
+		err := rows.Scan(&export.ID, &export.UserID, &export.Status, &export.FileKey, &export.Error,
+			&export.CreatedAt, &export.CompletedAt, &export.ExpiresAt)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+func (r *ExportRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Delete(ctx, "data_exports").
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+func (r *ExportRepository) scanOne(ctx context.Context, query squirrel.SelectBuilder) (*models.DataExport, error) {
+	var export models.DataExport
+	err := query.QueryRowContext(ctx).
+		Scan(&export.ID, &export.UserID, &export.Status, &export.FileKey, &export.Error,
+			&export.CreatedAt, &export.CompletedAt, &export.ExpiresAt)
+
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &export, nil
+}
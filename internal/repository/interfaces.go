@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+// UserRepositoryInterface - доступ к пользователям; сервисы зависят от него, а не от реализации,
+// чтобы в unit-тестах можно было подставить мок
+type UserRepositoryInterface interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	Update(ctx context.Context, id uuid.UUID, req *models.UpdateProfileRequest) error
+	UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+	Deactivate(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error)
+	GetDeleted(ctx context.Context) ([]models.User, error)
+	GetAll(ctx context.Context) ([]models.User, error)
+	Search(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error)
+}
+
+// AuthRepositoryInterface - доступ к сессиям пользователей
+type AuthRepositoryInterface interface {
+	CreateSession(ctx context.Context, userID uuid.UUID, sessionToken string, expiresAt time.Time, userAgent, ipAddress string) error
+	GetSessionByToken(ctx context.Context, sessionToken string) (*models.Session, error)
+	ValidateSession(ctx context.Context, sessionToken string) (*models.User, error)
+	GetSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	DeleteSession(ctx context.Context, sessionToken string) error
+	DeleteUserSessions(ctx context.Context, userID uuid.UUID) error
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
+	CountActiveSessions(ctx context.Context) (int64, error)
+}
+
+// WebhookRepositoryInterface - endpoint вебхуков, доставки и журнал попыток
+type WebhookRepositoryInterface interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+	ListSubscribedEndpoints(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	RecordEndpointFailure(ctx context.Context, id uuid.UUID, threshold int, reason string) (bool, error)
+	ResetEndpointFailures(ctx context.Context, id uuid.UUID) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error)
+	GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error)
+	RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, status models.WebhookDeliveryStatus) error
+	SetDeliveryStatus(ctx context.Context, id uuid.UUID, status models.WebhookDeliveryStatus, lastError *string) error
+	ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]models.WebhookAttempt, error)
+	DeleteDeliveriesBefore(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// SecurityRepositoryInterface - настройки уведомлений безопасности, ссылки сброса пароля и «это был не я»
+type SecurityRepositoryInterface interface {
+	GetDisabledAlerts(ctx context.Context, userID uuid.UUID) ([]string, error)
+	SetDisabledAlerts(ctx context.Context, userID uuid.UUID, alerts []string) error
+	CreatePasswordReset(ctx context.Context, token *models.PasswordResetToken) error
+	GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	InvalidatePasswordResets(ctx context.Context, userID uuid.UUID) error
+	MarkPasswordResetUsed(ctx context.Context, id uuid.UUID) error
+	DeleteExpiredPasswordResets(ctx context.Context) (int64, error)
+	CreateNotMeToken(ctx context.Context, token *models.NotMeToken) error
+	GetNotMeTokenByHash(ctx context.Context, tokenHash string) (*models.NotMeToken, error)
+	MarkNotMeTokenUsed(ctx context.Context, id uuid.UUID) error
+	DeleteExpiredNotMeTokens(ctx context.Context) (int64, error)
+	IsConfirmedPreviousEmail(ctx context.Context, userID uuid.UUID, email string) (bool, error)
+	RecordAlertDelivery(ctx context.Context, eventID, userID uuid.UUID, alert models.SecurityAlert) (bool, error)
+	DeleteAlertDeliveriesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginRiskRepositoryInterface - известные устройства, история входов и коды подтверждения входа
+type LoginRiskRepositoryInterface interface {
+	IsKnownDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error)
+	UpsertKnownDevice(ctx context.Context, device *models.KnownDevice) error
+	ListKnownDevices(ctx context.Context, userID uuid.UUID) ([]models.KnownDevice, error)
+	DeleteKnownDevice(ctx context.Context, userID, deviceID uuid.UUID) error
+	HasLoginFromSubnet(ctx context.Context, userID uuid.UUID, subnet string) (bool, error)
+	RecentLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error)
+	ListLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error)
+	RecordLogin(ctx context.Context, record *models.LoginRecord) error
+	DeleteLoginHistoryBefore(ctx context.Context, before time.Time) (int64, error)
+	CreateLoginChallenge(ctx context.Context, challenge *models.LoginChallenge) error
+	GetLoginChallenge(ctx context.Context, id uuid.UUID) (*models.LoginChallenge, error)
+	UseLoginChallengeAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error)
+	MarkLoginChallengeVerified(ctx context.Context, id uuid.UUID, maxAttempts int) error
+	DeleteExpiredLoginChallenges(ctx context.Context) (int64, error)
+}
+
+// EmailChangeRepositoryInterface - запросы смены email со ссылками подтверждения и отмены
+type EmailChangeRepositoryInterface interface {
+	Create(ctx context.Context, req *models.EmailChangeRequest) error
+	GetByConfirmTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error)
+	GetByRevertTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error)
+	GetForUpdate(ctx context.Context, id uuid.UUID) (*models.EmailChangeRequest, error)
+	CancelPending(ctx context.Context, userID uuid.UUID) error
+	MarkConfirmed(ctx context.Context, id uuid.UUID) error
+	MarkReverted(ctx context.Context, id uuid.UUID) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// ExportRepositoryInterface - выгрузки данных пользователей
+type ExportRepositoryInterface interface {
+	Create(ctx context.Context, export *models.DataExport) error
+	LockUser(ctx context.Context, userID uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error)
+	GetLatestByUserID(ctx context.Context, userID uuid.UUID) (*models.DataExport, error)
+	MarkCompleted(ctx context.Context, id uuid.UUID, fileKey string, expiresAt time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, reason string) error
+	ListExpired(ctx context.Context, now, createdBefore time.Time, limit int) ([]models.DataExport, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// AuditRepositoryInterface - журнал аудита с цепочкой хешей и контрольными точками; записи только добавляются
+type AuditRepositoryInterface interface {
+	Append(ctx context.Context, event *models.AuditEvent) error
+	SealPending(ctx context.Context, limit int, seal func(event *models.AuditEvent, last *models.AuditChainLink) error) (int64, error)
+	List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error)
+	ChainHead(ctx context.Context) (*models.AuditChainLink, error)
+	ListChain(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error)
+	CreateCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	LatestCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error)
+	ListCheckpoints(ctx context.Context, afterSeq int64) ([]models.AuditCheckpoint, error)
+}
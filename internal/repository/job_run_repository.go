@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+type JobRunRepository struct {
+	db *database.DB
+}
+
+func NewJobRunRepository(db *database.DB) *JobRunRepository {
+	return &JobRunRepository{db: db}
+}
+
+// StartRun записывает начало запуска. started = false, если запуск задачи на это время
+// уже записан - его выполнила другая реплика
+func (r *JobRunRepository) StartRun(ctx context.Context, jobName string, scheduledAt time.Time, instance string) (uuid.UUID, bool, error) {
+	var id uuid.UUID
+	err := r.db.Insert(ctx, "job_runs").
+		Columns("job_name", "scheduled_at", "status", "instance").
+		Values(jobName, scheduledAt, models.JobRunStatusRunning, instance).
+		Suffix("ON CONFLICT (job_name, scheduled_at) DO NOTHING RETURNING id").
+		QueryRowContext(ctx).
+		Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, mapError(err)
+	}
+	return id, true, nil
+}
+
+// FinishRun записывает результат запуска
+func (r *JobRunRepository) FinishRun(ctx context.Context, id uuid.UUID, processed int64, runErr error) error {
+	update := r.db.Update(ctx, "job_runs").
+		Set("finished_at", squirrel.Expr("NOW()")).
+		Set("processed", processed).
+		Where("id = ?", id)
+
+	if runErr != nil {
+		update = update.Set("status", models.JobRunStatusFailed).Set("error", runErr.Error())
+	} else {
+		update = update.Set("status", models.JobRunStatusSucceeded)
+	}
+
+	_, err := update.ExecContext(ctx)
+	return mapError(err)
+}
+
+// GetRecent возвращает последние запуски задачи; пустое jobName - всех задач
+func (r *JobRunRepository) GetRecent(ctx context.Context, jobName string, limit int) ([]models.JobRun, error) {
+	query := r.db.Select(ctx, "id", "job_name", "scheduled_at", "started_at", "finished_at", "status", "processed", "COALESCE(error, '')", "instance").
+		From("job_runs").
+		OrderBy("started_at DESC").
+		Limit(uint64(limit))
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+
+	rows, err := query.QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		err := rows.Scan(&run.ID, &run.JobName, &run.ScheduledAt, &run.StartedAt, &run.FinishedAt,
+			&run.Status, &run.Processed, &run.Error, &run.Instance)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// PruneBefore удаляет историю запусков, начатых до startedBefore
+func (r *JobRunRepository) PruneBefore(ctx context.Context, startedBefore time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "job_runs").
+		Where("started_at < ?", startedBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
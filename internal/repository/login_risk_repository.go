@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// successfulLogin - входы, которые закончились сессией; только они составляют привычки пользователя
+var successfulLogin = squirrel.Eq{"outcome": []string{string(models.LoginOutcomeAllowed), string(models.LoginOutcomeVerified)}}
+
+type LoginRiskRepository struct {
+	db *database.DB
+}
+
+func NewLoginRiskRepository(db *database.DB) LoginRiskRepositoryInterface {
+	return &LoginRiskRepository{db: db}
+}
+
+func (r *LoginRiskRepository) IsKnownDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error) {
+	var known bool
+	err := r.db.Select(ctx).
+		Column(squirrel.Expr("EXISTS (SELECT 1 FROM known_devices WHERE user_id = ? AND fingerprint = ?)", userID, fingerprint)).
+		QueryRowContext(ctx).
+		Scan(&known)
+	return known, mapError(err)
+}
+
+// UpsertKnownDevice добавляет устройство или обновляет время и адрес последнего входа с него
+func (r *LoginRiskRepository) UpsertKnownDevice(ctx context.Context, device *models.KnownDevice) error {
+	_, err := r.db.Insert(ctx, "known_devices").
+		Columns("id", "user_id", "fingerprint", "user_agent", "last_ip", "first_seen", "last_seen").
+		Values(device.ID, device.UserID, device.Fingerprint, device.UserAgent, device.LastIP, device.FirstSeen, device.LastSeen).
+		Suffix("ON CONFLICT (user_id, fingerprint) DO UPDATE SET user_agent = EXCLUDED.user_agent, " +
+			"last_ip = EXCLUDED.last_ip, last_seen = EXCLUDED.last_seen").
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *LoginRiskRepository) ListKnownDevices(ctx context.Context, userID uuid.UUID) ([]models.KnownDevice, error) {
+	rows, err := r.db.Select(ctx, "id", "user_id", "fingerprint", "user_agent", "last_ip", "first_seen", "last_seen").
+		From("known_devices").
+		Where("user_id = ?", userID).
+		OrderBy("last_seen DESC").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var devices []models.KnownDevice
+	for rows.Next() {
+		var device models.KnownDevice
+		err := rows.Scan(&device.ID, &device.UserID, &device.Fingerprint, &device.UserAgent, &device.LastIP,
+			&device.FirstSeen, &device.LastSeen)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// DeleteKnownDevice забывает устройство пользователя: следующий вход с него снова будет новым
+func (r *LoginRiskRepository) DeleteKnownDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	result, err := r.db.Delete(ctx, "known_devices").
+		Where("id = ? AND user_id = ?", deviceID, userID).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// HasLoginFromSubnet сообщает, был ли успешный вход из подсети
+func (r *LoginRiskRepository) HasLoginFromSubnet(ctx context.Context, userID uuid.UUID, subnet string) (bool, error) {
+	var found bool
+	err := r.db.Select(ctx).
+		Column(squirrel.Expr("EXISTS (SELECT 1 FROM login_history WHERE user_id = ? AND subnet = ? AND outcome IN (?, ?))",
+			userID, subnet, models.LoginOutcomeAllowed, models.LoginOutcomeVerified)).
+		QueryRowContext(ctx).
+		Scan(&found)
+	return found, mapError(err)
+}
+
+// RecentLogins возвращает последние успешные входы пользователя, новые первыми
+func (r *LoginRiskRepository) RecentLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error) {
+	return r.listLogins(ctx, squirrel.And{squirrel.Eq{"user_id": userID}, successfulLogin}, limit)
+}
+
+// ListLogins возвращает историю входов пользователя, включая заблокированные
+func (r *LoginRiskRepository) ListLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error) {
+	return r.listLogins(ctx, squirrel.Eq{"user_id": userID}, limit)
+}
+
+func (r *LoginRiskRepository) listLogins(ctx context.Context, where squirrel.Sqlizer, limit int) ([]models.LoginRecord, error) {
+	rows, err := r.db.Select(ctx, "id", "user_id", "ip_address", "user_agent", "fingerprint", "subnet",
+		"latitude", "longitude", "risk_score", "signals", "outcome", "created_at").
+		From("login_history").
+		Where(where).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var records []models.LoginRecord
+	for rows.Next() {
+		var record models.LoginRecord
+		var latitude, longitude sql.NullFloat64
+		err := rows.Scan(&record.ID, &record.UserID, &record.IPAddress, &record.UserAgent, &record.Fingerprint,
+			&record.Subnet, &latitude, &longitude, &record.RiskScore, pq.Array(&record.Signals), &record.Outcome,
+			&record.CreatedAt)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		if latitude.Valid && longitude.Valid {
+			record.Location = &models.GeoPoint{Latitude: latitude.Float64, Longitude: longitude.Float64}
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (r *LoginRiskRepository) RecordLogin(ctx context.Context, record *models.LoginRecord) error {
+	var latitude, longitude sql.NullFloat64
+	if record.Location != nil {
+		latitude = sql.NullFloat64{Float64: record.Location.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: record.Location.Longitude, Valid: true}
+	}
+	signals := record.Signals
+	if signals == nil {
+		signals = []string{}
+	}
+
+	_, err := r.db.Insert(ctx, "login_history").
+		Columns("id", "user_id", "ip_address", "user_agent", "fingerprint", "subnet", "latitude", "longitude",
+			"risk_score", "signals", "outcome", "created_at").
+		Values(record.ID, record.UserID, record.IPAddress, record.UserAgent, record.Fingerprint, record.Subnet,
+			latitude, longitude, record.RiskScore, pq.Array(signals), record.Outcome, record.CreatedAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// DeleteLoginHistoryBefore удаляет записи истории входов старше before
+func (r *LoginRiskRepository) DeleteLoginHistoryBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "login_history").
+		Where("created_at < ?", before).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *LoginRiskRepository) CreateLoginChallenge(ctx context.Context, challenge *models.LoginChallenge) error {
+	signals := challenge.Signals
+	if signals == nil {
+		signals = []string{}
+	}
+
+	_, err := r.db.Insert(ctx, "login_challenges").
+		Columns("id", "user_id", "code_hash", "fingerprint", "ip_address", "user_agent", "risk_score", "signals",
+			"created_at", "expires_at").
+		Values(challenge.ID, challenge.UserID, challenge.CodeHash, challenge.Fingerprint, challenge.IPAddress,
+			challenge.UserAgent, challenge.RiskScore, pq.Array(signals), challenge.CreatedAt, challenge.ExpiresAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *LoginRiskRepository) GetLoginChallenge(ctx context.Context, id uuid.UUID) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	err := r.db.Select(ctx, "id", "user_id", "code_hash", "fingerprint", "ip_address", "user_agent", "risk_score",
+		"signals", "attempts", "created_at", "expires_at", "verified_at").
+		From("login_challenges").
+		Where("id = ?", id).
+		QueryRowContext(ctx).
+		Scan(&challenge.ID, &challenge.UserID, &challenge.CodeHash, &challenge.Fingerprint, &challenge.IPAddress,
+			&challenge.UserAgent, &challenge.RiskScore, pq.Array(&challenge.Signals), &challenge.Attempts,
+			&challenge.CreatedAt, &challenge.ExpiresAt, &challenge.VerifiedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &challenge, nil
+}
+
+// UseLoginChallengeAttempt атомарно расходует попытку ввода кода и возвращает их число.
+// ErrNotFound, если код уже использован или попыток было maxAttempts: одновременные
+// запросы не могут проверить больше maxAttempts вариантов кода
+func (r *LoginRiskRepository) UseLoginChallengeAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error) {
+	var attempts int
+	err := r.db.Update(ctx, "login_challenges").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Where("id = ? AND verified_at IS NULL AND attempts < ?", id, maxAttempts).
+		Suffix("RETURNING attempts").
+		QueryRowContext(ctx).
+		Scan(&attempts)
+	return attempts, mapError(err)
+}
+
+// MarkLoginChallengeVerified отмечает код использованным. ErrNotFound, если его уже использовали
+// или попыток больше maxAttempts: по одному коду создается только одна сессия
+func (r *LoginRiskRepository) MarkLoginChallengeVerified(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	result, err := r.db.Update(ctx, "login_challenges").
+		Set("verified_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND verified_at IS NULL AND attempts <= ?", id, maxAttempts).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// DeleteExpiredLoginChallenges удаляет истекшие и использованные коды подтверждения входа
+func (r *LoginRiskRepository) DeleteExpiredLoginChallenges(ctx context.Context) (int64, error) {
+	result, err := r.db.Delete(ctx, "login_challenges").
+		Where("expires_at < NOW() OR verified_at IS NOT NULL").
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
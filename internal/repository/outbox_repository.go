@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"time"
+)
+
+var outboxColumns = []string{
+	"id", "event_id", "aggregate_type", "aggregate_id", "event_type", "payload",
+	"occurred_at", "published_at", "attempts", "next_attempt_at", "last_error",
+}
+
+type OutboxRepository struct {
+	db *database.DB
+}
+
+func NewOutboxRepository(db *database.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Append записывает событие; вызывается в транзакции, меняющей состояние агрегата.
+// Транзакционная advisory-блокировка агрегата выстраивает такие транзакции в очередь:
+// событие, записанное позже, получает больший id и становится видимым после предыдущего
+func (r *OutboxRepository) Append(ctx context.Context, event *models.OutboxEvent) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		_, err := r.db.Select(ctx).
+			Column(squirrel.Expr("pg_advisory_xact_lock(hashtextextended(?, 0))",
+				"outbox:"+event.AggregateType+":"+event.AggregateID.String())).
+			ExecContext(ctx)
+		if err != nil {
+			return mapError(err)
+		}
+
+		return mapError(r.db.Insert(ctx, "outbox").
+			Columns("event_id", "aggregate_type", "aggregate_id", "event_type", "payload", "occurred_at", "next_attempt_at").
+			Values(event.EventID, event.AggregateType, event.AggregateID, event.EventType, []byte(event.Payload),
+				event.OccurredAt, event.OccurredAt).
+			Suffix("RETURNING id").
+			QueryRowContext(ctx).
+			Scan(&event.ID))
+	})
+}
+
+// FetchPending возвращает неопубликованные события в порядке записи. Событие пропускается,
+// если более раннее событие того же агрегата ждет повторной попытки: порядок внутри
+// агрегата важнее, чем задержка
+func (r *OutboxRepository) FetchPending(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	blocked := "NOT EXISTS (SELECT 1 FROM outbox e WHERE e.published_at IS NULL " +
+		"AND e.aggregate_type = outbox.aggregate_type AND e.aggregate_id = outbox.aggregate_id " +
+		"AND e.id < outbox.id AND e.next_attempt_at > NOW())"
+
+	rows, err := r.db.Select(ctx, outboxColumns...).
+		From("outbox").
+		Where("published_at IS NULL AND next_attempt_at <= NOW()").
+		Where(blocked).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		err := rows.Scan(&event.ID, &event.EventID, &event.AggregateType, &event.AggregateID, &event.EventType, &payload,
+			&event.OccurredAt, &event.PublishedAt, &event.Attempts, &event.NextAttemptAt, &event.LastError)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, mapError(rows.Err())
+}
+
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.Update(ctx, "outbox").
+		Set("published_at", squirrel.Expr("NOW()")).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("last_error", nil).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// MarkFailed откладывает следующую попытку публикации до nextAttemptAt
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Update(ctx, "outbox").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("next_attempt_at", nextAttemptAt).
+		Set("last_error", lastError).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// DeletePublished удаляет события, опубликованные до publishedBefore
+func (r *OutboxRepository) DeletePublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "outbox").
+		Where("published_at < ?", publishedBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var queueJobColumns = []string{"id", "kind", "payload", "status", "unique_key", "attempts", "max_attempts", "run_at",
+	"locked_at", "locked_by", "last_error", "created_at", "updated_at", "finished_at"}
+
+type QueueRepository struct {
+	db *database.DB
+}
+
+func NewQueueRepository(db *database.DB) *QueueRepository {
+	return &QueueRepository{db: db}
+}
+
+// Enqueue добавляет задачу. Если незавершенная задача того же вида с тем же ключом
+// уникальности уже есть, ничего не делает и возвращает false. Вызов внутри транзакции
+// ставит задачу только при ее коммите
+func (r *QueueRepository) Enqueue(ctx context.Context, job *models.QueueJob) (bool, error) {
+	err := r.db.Insert(ctx, "jobs").
+		Columns("id", "kind", "payload", "status", "unique_key", "max_attempts", "run_at", "created_at", "updated_at").
+		Values(job.ID, job.Kind, []byte(job.Payload), models.QueueJobStatusPending, job.UniqueKey, job.MaxAttempts,
+			job.RunAt, job.CreatedAt, job.CreatedAt).
+		Suffix("ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running') DO NOTHING RETURNING id").
+		QueryRowContext(ctx).
+		Scan(&job.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, mapError(err)
+	}
+	return true, nil
+}
+
+// Claim забирает самую раннюю готовую задачу одного из видов kinds и помечает ее выполняемой.
+// SKIP LOCKED позволяет воркерам разных реплик разбирать очередь без ожидания друг друга.
+// Возвращает nil, если готовых задач нет
+func (r *QueueRepository) Claim(ctx context.Context, kinds []string, worker string) (*models.QueueJob, error) {
+	next := "id = (SELECT id FROM jobs WHERE status = ? AND run_at <= NOW() AND kind = ANY(?) " +
+		"ORDER BY run_at, created_at LIMIT 1 FOR UPDATE SKIP LOCKED)"
+
+	job, err := r.scanOne(r.db.Update(ctx, "jobs").
+		Set("status", models.QueueJobStatusRunning).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("locked_at", squirrel.Expr("NOW()")).
+		Set("locked_by", worker).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where(next, models.QueueJobStatusPending, pq.Array(kinds)).
+		Suffix("RETURNING " + strings.Join(queueJobColumns, ", ")).
+		QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Complete, Retry и Bury записывают результат, только пока задача выполняется воркером worker.
+// ErrNotFound означает, что воркер потерял задачу: RescueStale вернул ее в очередь, и теперь
+// ее мог забрать другой воркер
+func (r *QueueRepository) Complete(ctx context.Context, id uuid.UUID, worker string) error {
+	result, err := r.db.Update(ctx, "jobs").
+		Set("status", models.QueueJobStatusCompleted).
+		Set("locked_at", nil).
+		Set("locked_by", nil).
+		Set("finished_at", squirrel.Expr("NOW()")).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.QueueJobStatusRunning, worker).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// Retry возвращает задачу в очередь с запуском не раньше runAt
+func (r *QueueRepository) Retry(ctx context.Context, id uuid.UUID, worker string, runAt time.Time, lastError string) error {
+	result, err := r.db.Update(ctx, "jobs").
+		Set("status", models.QueueJobStatusPending).
+		Set("run_at", runAt).
+		Set("last_error", lastError).
+		Set("locked_at", nil).
+		Set("locked_by", nil).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.QueueJobStatusRunning, worker).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// Bury переносит задачу в dead letter: она больше не выполняется без ручного повтора
+func (r *QueueRepository) Bury(ctx context.Context, id uuid.UUID, worker string, lastError string) error {
+	result, err := r.db.Update(ctx, "jobs").
+		Set("status", models.QueueJobStatusDead).
+		Set("last_error", lastError).
+		Set("locked_at", nil).
+		Set("locked_by", nil).
+		Set("finished_at", squirrel.Expr("NOW()")).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.QueueJobStatusRunning, worker).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// RescueStale возвращает в очередь задачи, которые выполняются дольше lockedBefore, - их
+// воркер, скорее всего, упал. Задачи с исчерпанными попытками уходят в dead letter
+func (r *QueueRepository) RescueStale(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	result, err := r.db.Update(ctx, "jobs").
+		Set("status", squirrel.Expr("CASE WHEN attempts >= max_attempts THEN ? ELSE ? END",
+			models.QueueJobStatusDead, models.QueueJobStatusPending)).
+		Set("finished_at", squirrel.Expr("CASE WHEN attempts >= max_attempts THEN NOW() END")).
+		Set("last_error", "worker stopped responding").
+		Set("locked_at", nil).
+		Set("locked_by", nil).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("status = ? AND locked_at < ?", models.QueueJobStatusRunning, lockedBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+// Requeue ставит задачу из dead letter в очередь заново с обнуленным счетчиком попыток.
+// Возвращает false, если задача не в dead letter
+func (r *QueueRepository) Requeue(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.Update(ctx, "jobs").
+		Set("status", models.QueueJobStatusPending).
+		Set("attempts", 0).
+		Set("run_at", squirrel.Expr("NOW()")).
+		Set("finished_at", nil).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND status = ?", id, models.QueueJobStatusDead).
+		ExecContext(ctx)
+	if isUniqueViolation(err) {
+		// Такая же работа уже стоит в очереди
+		return false, nil
+	}
+	if err != nil {
+		return false, mapError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteCompleted удаляет выполненные задачи, завершенные до finishedBefore
+func (r *QueueRepository) DeleteCompleted(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "jobs").
+		Where("status = ? AND finished_at < ?", models.QueueJobStatusCompleted, finishedBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *QueueRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.QueueJob, error) {
+	return r.scanOne(r.db.Select(ctx, queueJobColumns...).
+		From("jobs").
+		Where("id = ?", id).
+		QueryRowContext(ctx))
+}
+
+// List возвращает задачи по фильтру, начиная с последних созданных, и их общее число
+func (r *QueueRepository) List(ctx context.Context, filter models.QueueJobFilter) ([]models.QueueJob, int64, error) {
+	where := squirrel.And{}
+	if filter.Status != "" {
+		where = append(where, squirrel.Eq{"status": filter.Status})
+	}
+	if filter.Kind != "" {
+		where = append(where, squirrel.Eq{"kind": filter.Kind})
+	}
+
+	var total int64
+	err := r.db.Select(ctx, "COUNT(*)").
+		From("jobs").
+		Where(where).
+		QueryRowContext(ctx).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	rows, err := r.db.Select(ctx, queueJobColumns...).
+		From("jobs").
+		Where(where).
+		OrderBy("created_at DESC").
+		Limit(uint64(filter.Limit)).
+		Offset(uint64(filter.Offset)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	var jobs []models.QueueJob
+	for rows.Next() {
+		job, err := r.scanOne(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, total, rows.Err()
+}
+
+// Stats возвращает число задач по видам и статусам
+func (r *QueueRepository) Stats(ctx context.Context) ([]models.QueueStat, error) {
+	rows, err := r.db.Select(ctx, "kind", "status", "COUNT(*)", "MIN(run_at)").
+		From("jobs").
+		GroupBy("kind", "status").
+		OrderBy("kind", "status").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var stats []models.QueueStat
+	for rows.Next() {
+		var stat models.QueueStat
+		if err := rows.Scan(&stat.Kind, &stat.Status, &stat.Count, &stat.OldestRunAt); err != nil {
+			return nil, mapError(err)
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (r *QueueRepository) scanOne(row rowScanner) (*models.QueueJob, error) {
+	var job models.QueueJob
+	var payload []byte
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.UniqueKey, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.LockedAt, &job.LockedBy, &job.LastError, &job.CreatedAt, &job.UpdatedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	job.Payload = payload
+	return &job, nil
+}
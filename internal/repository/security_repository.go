@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+type SecurityRepository struct {
+	db *database.DB
+}
+
+func NewSecurityRepository(db *database.DB) SecurityRepositoryInterface {
+	return &SecurityRepository{db: db}
+}
+
+// GetDisabledAlerts возвращает уведомления, которые пользователь отключил; без настроек - пустой список
+func (r *SecurityRepository) GetDisabledAlerts(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	var disabled []string
+	err := r.db.Select(ctx, "disabled_alerts").
+		From("security_alert_settings").
+		Where("user_id = ?", userID).
+		QueryRowContext(ctx).
+		Scan(pq.Array(&disabled))
+	if err != nil {
+		if err = mapError(err); errors.Is(err, apperrors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return disabled, nil
+}
+
+func (r *SecurityRepository) SetDisabledAlerts(ctx context.Context, userID uuid.UUID, alerts []string) error {
+	if alerts == nil {
+		alerts = []string{}
+	}
+	_, err := r.db.Insert(ctx, "security_alert_settings").
+		Columns("user_id", "disabled_alerts").
+		Values(userID, pq.Array(alerts)).
+		Suffix("ON CONFLICT (user_id) DO UPDATE SET disabled_alerts = EXCLUDED.disabled_alerts, updated_at = NOW()").
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *SecurityRepository) CreatePasswordReset(ctx context.Context, token *models.PasswordResetToken) error {
+	_, err := r.db.Insert(ctx, "password_reset_tokens").
+		Columns("id", "user_id", "token_hash", "created_at", "expires_at").
+		Values(token.ID, token.UserID, token.TokenHash, token.CreatedAt, token.ExpiresAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *SecurityRepository) GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Select(ctx, "id", "user_id", "token_hash", "created_at", "expires_at", "used_at").
+		From("password_reset_tokens").
+		Where("token_hash = ?", tokenHash).
+		QueryRowContext(ctx).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &token, nil
+}
+
+// InvalidatePasswordResets гасит все неиспользованные ссылки сброса пароля пользователя
+func (r *SecurityRepository) InvalidatePasswordResets(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.Update(ctx, "password_reset_tokens").
+		Set("used_at", squirrel.Expr("NOW()")).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// MarkPasswordResetUsed отмечает ссылку использованной. ErrNotFound, если ее уже использовали:
+// из двух одновременных запросов пароль сменит только один
+func (r *SecurityRepository) MarkPasswordResetUsed(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Update(ctx, "password_reset_tokens").
+		Set("used_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND used_at IS NULL", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// DeleteExpiredPasswordResets удаляет истекшие и использованные ссылки сброса пароля
+func (r *SecurityRepository) DeleteExpiredPasswordResets(ctx context.Context) (int64, error) {
+	result, err := r.db.Delete(ctx, "password_reset_tokens").
+		Where("expires_at < NOW() OR used_at IS NOT NULL").
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SecurityRepository) CreateNotMeToken(ctx context.Context, token *models.NotMeToken) error {
+	_, err := r.db.Insert(ctx, "not_me_tokens").
+		Columns("id", "user_id", "token_hash", "email", "created_at", "expires_at").
+		Values(token.ID, token.UserID, token.TokenHash, token.Email, token.CreatedAt, token.ExpiresAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *SecurityRepository) GetNotMeTokenByHash(ctx context.Context, tokenHash string) (*models.NotMeToken, error) {
+	var token models.NotMeToken
+	err := r.db.Select(ctx, "id", "user_id", "token_hash", "email", "created_at", "expires_at", "used_at").
+		From("not_me_tokens").
+		Where("token_hash = ?", tokenHash).
+		QueryRowContext(ctx).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Email, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &token, nil
+}
+
+// MarkNotMeTokenUsed отмечает ссылку «это был не я» использованной. ErrNotFound, если ее уже использовали
+func (r *SecurityRepository) MarkNotMeTokenUsed(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Update(ctx, "not_me_tokens").
+		Set("used_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND used_at IS NULL", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// DeleteExpiredNotMeTokens удаляет истекшие и использованные ссылки «это был не я»
+func (r *SecurityRepository) DeleteExpiredNotMeTokens(ctx context.Context) (int64, error) {
+	result, err := r.db.Delete(ctx, "not_me_tokens").
+		Where("expires_at < NOW() OR used_at IS NOT NULL").
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+// RecordAlertDelivery запоминает, что по событию eventID поставлено уведомление. false, если событие
+// уже обработано: повторная доставка события не отправит второе письмо
+func (r *SecurityRepository) RecordAlertDelivery(ctx context.Context, eventID, userID uuid.UUID, alert models.SecurityAlert) (bool, error) {
+	err := r.db.Insert(ctx, "security_alert_deliveries").
+		Columns("event_id", "user_id", "alert").
+		Values(eventID, userID, alert).
+		Suffix("ON CONFLICT (event_id) DO NOTHING RETURNING event_id").
+		QueryRowContext(ctx).
+		Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, mapError(err)
+	}
+	return true, nil
+}
+
+// DeleteAlertDeliveriesBefore удаляет отметки об уведомлениях, поставленных раньше before
+func (r *SecurityRepository) DeleteAlertDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "security_alert_deliveries").
+		Where("created_at < ?", before).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+// IsConfirmedPreviousEmail сообщает, был ли email адресом пользователя до подтвержденной смены.
+// Владение таким адресом пользователь доказал раньше, поэтому на него можно отправить сброс пароля
+func (r *SecurityRepository) IsConfirmedPreviousEmail(ctx context.Context, userID uuid.UUID, email string) (bool, error) {
+	var found bool
+	err := r.db.Select(ctx).
+		Column(squirrel.Expr("EXISTS (SELECT 1 FROM email_change_requests WHERE user_id = ? AND old_email = ? AND confirmed_at IS NOT NULL)",
+			userID, email)).
+		QueryRowContext(ctx).
+		Scan(&found)
+	return found, mapError(err)
+}
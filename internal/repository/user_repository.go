@@ -1,77 +1,82 @@
 package repository
 
 import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
 	"github.com/AtlasOpx/devprep/internal/database"
 	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"strings"
+	"time"
 )
 
 type UserRepository struct {
 	db *database.DB
 }
 
-func NewUserRepository(db *database.DB) *UserRepository {
+func NewUserRepository(db *database.DB) UserRepositoryInterface {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	_, err := r.db.Insert("users").
-		Columns("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "created_at", "updated_at").
-		Values(user.ID, user.Email, user.Username, user.FirstName, user.LastName, user.PasswordHash, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt).
-		Exec()
-	return err
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.db.Insert(ctx, "users").
+		Columns("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
+		Values(user.ID, user.Email, user.Username, user.FirstName, user.LastName, user.PasswordHash, user.Role, user.IsActive, user.Locale, user.CreatedAt, user.UpdatedAt).
+		ExecContext(ctx)
+	return mapError(err)
 }
 
-func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "created_at", "updated_at").
+	err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
 		From("users").
-		Where("id = ?", id).
-		QueryRow().
+		Where("id = ? AND deleted_at IS NULL", id).
+		QueryRowContext(ctx).
 		Scan(&user.ID, &user.Email, &user.Username, &user.FirstName,
-			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
 			&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "created_at", "updated_at").
+	err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
 		From("users").
-		Where("email = ?", email).
-		QueryRow().
+		Where("LOWER(email) = LOWER(?) AND deleted_at IS NULL", email).
+		QueryRowContext(ctx).
 		Scan(&user.ID, &user.Email, &user.Username, &user.FirstName,
-			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
 			&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "created_at", "updated_at").
+	err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
 		From("users").
-		Where("username = ?", username).
-		QueryRow().
+		Where("LOWER(username) = LOWER(?) AND deleted_at IS NULL", username).
+		QueryRowContext(ctx).
 		Scan(&user.ID, &user.Email, &user.Username, &user.FirstName,
-			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
 			&user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) Update(id uuid.UUID, req *models.UpdateProfileRequest) error {
-	update := r.db.Update("users").Set("updated_at", "NOW()").Where("id = ?", id)
+func (r *UserRepository) Update(ctx context.Context, id uuid.UUID, req *models.UpdateProfileRequest) error {
+	update := r.db.Update(ctx, "users").Set("updated_at", "NOW()").Where("id = ? AND deleted_at IS NULL", id)
 
 	if req.FirstName != "" {
 		update = update.Set("first_name", req.FirstName)
@@ -85,24 +90,113 @@ func (r *UserRepository) Update(id uuid.UUID, req *models.UpdateProfileRequest)
 		update = update.Set("username", req.Username)
 	}
 
-	_, err := update.Exec()
-	return err
+	if req.Locale != "" {
+		update = update.Set("locale", req.Locale)
+	}
+
+	_, err := update.ExecContext(ctx)
+	return mapError(err)
+}
+
+// Delete помечает пользователя удаленным и завершает все его сессии.
+// Строка физически удаляется только в PurgeDeleted
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		result, err := r.db.Update(ctx, "users").
+			Set("deleted_at", squirrel.Expr("NOW()")).
+			Set("updated_at", squirrel.Expr("NOW()")).
+			Where("id = ? AND deleted_at IS NULL", id).
+			ExecContext(ctx)
+		if err := expectAffected(result, err); err != nil {
+			return err
+		}
+
+		_, err = r.db.Delete(ctx, "sessions").
+			Where("user_id = ?", id).
+			ExecContext(ctx)
+		return mapError(err)
+	})
+}
+
+// Restore снимает пометку об удалении, если пользователь удален позже deletedAfter
+func (r *UserRepository) Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error {
+	result, err := r.db.Update(ctx, "users").
+		Set("deleted_at", nil).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", id, deletedAfter).
+		ExecContext(ctx)
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if affected == 0 {
+		return apperrors.ErrNotFound.WithMessage("error.deleted_user_not_found", "Deleted user not found or retention period expired")
+	}
+	return nil
+}
+
+// PurgeDeleted окончательно удаляет пользователей, помеченных удаленными до deletedBefore,
+// и возвращает их ID
+func (r *UserRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Delete(ctx, "users").
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", deletedBefore).
+		Suffix("RETURNING id").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, mapError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, mapError(rows.Err())
 }
 
-func (r *UserRepository) Delete(id uuid.UUID) error {
-	_, err := r.db.Delete("users").
-		Where("id = ?", id).
-		Exec()
-	return err
+func (r *UserRepository) GetDeleted(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at", "deleted_at").
+		From("users").
+		Where("deleted_at IS NOT NULL").
+		OrderBy("deleted_at DESC").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.Username, &user.FirstName,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
+			&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
-func (r *UserRepository) GetAll() ([]models.User, error) {
-	rows, err := r.db.Select("id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "created_at", "updated_at").
+func (r *UserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
 		From("users").
+		Where("deleted_at IS NULL").
 		OrderBy("created_at DESC").
-		Query()
+		QueryContext(ctx)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
@@ -111,13 +205,124 @@ func (r *UserRepository) GetAll() ([]models.User, error) {
 		var user models.User
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.Username, &user.FirstName,
-			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
 			&user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
+}
+
+// Search ищет пользователей по части email, username, имени или фамилии
+// и сортирует результаты по триграммной похожести (pg_trgm)
+func (r *UserRepository) Search(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error) {
+	pattern := "%" + escapeLike(query) + "%"
+	score := squirrel.Expr(
+		"GREATEST(word_similarity(?, email), word_similarity(?, username), "+
+			"word_similarity(?, COALESCE(first_name, '')), word_similarity(?, COALESCE(last_name, ''))) AS score",
+		query, query, query, query)
+
+	rows, err := r.db.Select(ctx, "id", "email", "username", "first_name", "last_name", "password_hash", "role", "is_active", "locale", "created_at", "updated_at").
+		Column(score).
+		From("users").
+		Where("deleted_at IS NULL").
+		Where(squirrel.Or{
+			squirrel.ILike{"email": pattern},
+			squirrel.ILike{"username": pattern},
+			squirrel.ILike{"first_name": pattern},
+			squirrel.ILike{"last_name": pattern},
+			squirrel.Expr("? <% email", query),
+			squirrel.Expr("? <% username", query),
+			squirrel.Expr("? <% first_name", query),
+			squirrel.Expr("? <% last_name", query),
+		}).
+		OrderBy("score DESC", "created_at DESC").
+		Limit(uint64(limit)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var results []models.UserSearchResult
+	for rows.Next() {
+		var result models.UserSearchResult
+		user := &result.User
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.Username, &user.FirstName,
+			&user.LastName, &user.PasswordHash, &user.Role, &user.IsActive, &user.Locale,
+			&user.CreatedAt, &user.UpdatedAt, &result.Score)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// UpdateEmail меняет email; при конфликте с другим пользователем возвращает apperrors.ErrEmailTaken
+// UpdateEmail меняет email пользователя, только если текущий адрес все еще oldEmail;
+// иначе ErrNotFound
+func (r *UserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) error {
+	result, err := r.db.Update(ctx, "users").
+		Set("email", newEmail).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND email = ? AND deleted_at IS NULL", id, oldEmail).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error {
+	_, err := r.db.Update(ctx, "users").
+		Set("role", role).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// UpdatePassword меняет хеш пароля и завершает все сессии пользователя
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		_, err := r.db.Update(ctx, "users").
+			Set("password_hash", passwordHash).
+			Set("updated_at", squirrel.Expr("NOW()")).
+			Where("id = ? AND deleted_at IS NULL", id).
+			ExecContext(ctx)
+		if err != nil {
+			return mapError(err)
+		}
+
+		_, err = r.db.Delete(ctx, "sessions").
+			Where("user_id = ?", id).
+			ExecContext(ctx)
+		return mapError(err)
+	})
+}
+
+// Deactivate блокирует вход пользователя и завершает все его сессии
+func (r *UserRepository) Deactivate(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		result, err := r.db.Update(ctx, "users").
+			Set("is_active", false).
+			Set("updated_at", squirrel.Expr("NOW()")).
+			Where("id = ? AND deleted_at IS NULL", id).
+			ExecContext(ctx)
+		if err := expectAffected(result, err); err != nil {
+			return err
+		}
+
+		_, err = r.db.Delete(ctx, "sessions").
+			Where("user_id = ?", id).
+			ExecContext(ctx)
+		return mapError(err)
+	})
 }
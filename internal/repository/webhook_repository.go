@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+var (
+	webhookEndpointColumns = []string{"id", "url", "description", "events", "secret", "is_active", "consecutive_failures",
+		"disabled_at", "disabled_reason", "created_at", "updated_at"}
+	webhookDeliveryColumns = []string{"id", "endpoint_id", "event_id", "event_type", "payload", "status", "attempts",
+		"response_status", "last_error", "created_at", "updated_at", "delivered_at"}
+	webhookAttemptColumns = []string{"id", "delivery_id", "attempt", "response_status", "response_body", "error",
+		"duration_ms", "created_at"}
+)
+
+type WebhookRepository struct {
+	db *database.DB
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	_, err := r.db.Insert(ctx, "webhook_endpoints").
+		Columns("id", "url", "description", "events", "secret", "is_active", "created_at", "updated_at").
+		Values(endpoint.ID, endpoint.URL, endpoint.Description, pq.Array(endpoint.Events), endpoint.Secret,
+			endpoint.IsActive, endpoint.CreatedAt, endpoint.UpdatedAt).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+func (r *WebhookRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	return r.scanEndpoint(r.db.Select(ctx, webhookEndpointColumns...).
+		From("webhook_endpoints").
+		Where("id = ?", id).
+		QueryRowContext(ctx))
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, r.db.Select(ctx, webhookEndpointColumns...).
+		From("webhook_endpoints").
+		OrderBy("created_at"))
+}
+
+// ListSubscribedEndpoints возвращает включенные endpoint, подписанные на eventType
+func (r *WebhookRepository) ListSubscribedEndpoints(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, r.db.Select(ctx, webhookEndpointColumns...).
+		From("webhook_endpoints").
+		Where("is_active AND (? = ANY(events) OR ? = ANY(events))", eventType, models.WebhookAllEvents).
+		OrderBy("created_at"))
+}
+
+// UpdateEndpoint сохраняет изменяемые поля endpoint
+func (r *WebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	result, err := r.db.Update(ctx, "webhook_endpoints").
+		Set("url", endpoint.URL).
+		Set("description", endpoint.Description).
+		Set("events", pq.Array(endpoint.Events)).
+		Set("secret", endpoint.Secret).
+		Set("is_active", endpoint.IsActive).
+		Set("consecutive_failures", endpoint.ConsecutiveFailures).
+		Set("disabled_at", endpoint.DisabledAt).
+		Set("disabled_reason", endpoint.DisabledReason).
+		Set("updated_at", endpoint.UpdatedAt).
+		Where("id = ?", endpoint.ID).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Delete(ctx, "webhook_endpoints").
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// RecordEndpointFailure увеличивает счетчик неудач подряд и отключает endpoint,
+// когда счетчик достигает threshold. Возвращает true, если endpoint отключен этим вызовом
+func (r *WebhookRepository) RecordEndpointFailure(ctx context.Context, id uuid.UUID, threshold int, reason string) (bool, error) {
+	var disabled bool
+	err := r.db.Update(ctx, "webhook_endpoints").
+		Set("consecutive_failures", squirrel.Expr("consecutive_failures + 1")).
+		Set("is_active", squirrel.Expr("is_active AND consecutive_failures + 1 < ?", threshold)).
+		Set("disabled_at", squirrel.Expr("CASE WHEN is_active AND consecutive_failures + 1 >= ? THEN NOW() ELSE disabled_at END", threshold)).
+		Set("disabled_reason", squirrel.Expr("CASE WHEN is_active AND consecutive_failures + 1 >= ? THEN ? ELSE disabled_reason END", threshold, reason)).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ?", id).
+		Suffix("RETURNING disabled_at IS NOT NULL AND consecutive_failures = ?", threshold).
+		QueryRowContext(ctx).
+		Scan(&disabled)
+	return disabled, mapError(err)
+}
+
+// ResetEndpointFailures обнуляет счетчик неудач после успешной доставки
+func (r *WebhookRepository) ResetEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Update(ctx, "webhook_endpoints").
+		Set("consecutive_failures", 0).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ? AND consecutive_failures > 0", id).
+		ExecContext(ctx)
+	return mapError(err)
+}
+
+// CreateDelivery добавляет доставку события на endpoint. Если доставка этого события
+// на этот endpoint уже есть, ничего не делает и возвращает false
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	err := r.db.Insert(ctx, "webhook_deliveries").
+		Columns("id", "endpoint_id", "event_id", "event_type", "payload", "status", "created_at", "updated_at").
+		Values(delivery.ID, delivery.EndpointID, delivery.EventID, delivery.EventType, []byte(delivery.Payload),
+			models.WebhookDeliveryPending, delivery.CreatedAt, delivery.CreatedAt).
+		Suffix("ON CONFLICT (endpoint_id, event_id) DO NOTHING RETURNING id").
+		QueryRowContext(ctx).
+		Scan(&delivery.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, mapError(err)
+	}
+	return true, nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	return r.scanDelivery(r.db.Select(ctx, webhookDeliveryColumns...).
+		From("webhook_deliveries").
+		Where("id = ?", id).
+		QueryRowContext(ctx))
+}
+
+// ListDeliveries возвращает доставки endpoint, начиная с последних, и их общее число
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error) {
+	where := squirrel.And{squirrel.Eq{"endpoint_id": filter.EndpointID}}
+	if filter.Status != "" {
+		where = append(where, squirrel.Eq{"status": filter.Status})
+	}
+
+	var total int64
+	err := r.db.Select(ctx, "COUNT(*)").
+		From("webhook_deliveries").
+		Where(where).
+		QueryRowContext(ctx).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	rows, err := r.db.Select(ctx, webhookDeliveryColumns...).
+		From("webhook_deliveries").
+		Where(where).
+		OrderBy("created_at DESC").
+		Limit(uint64(filter.Limit)).
+		Offset(uint64(filter.Offset)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := r.scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, total, rows.Err()
+}
+
+// RecordAttempt пишет попытку в журнал и обновляет по ней состояние доставки
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, status models.WebhookDeliveryStatus) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		err := r.db.Insert(ctx, "webhook_delivery_attempts").
+			Columns("delivery_id", "attempt", "response_status", "response_body", "error", "duration_ms", "created_at").
+			Values(attempt.DeliveryID, attempt.Attempt, attempt.ResponseStatus, attempt.ResponseBody, attempt.Error,
+				attempt.DurationMs, attempt.CreatedAt).
+			Suffix("RETURNING id").
+			QueryRowContext(ctx).
+			Scan(&attempt.ID)
+		if err != nil {
+			return mapError(err)
+		}
+
+		update := r.db.Update(ctx, "webhook_deliveries").
+			Set("status", status).
+			Set("attempts", attempt.Attempt).
+			Set("response_status", attempt.ResponseStatus).
+			Set("last_error", attempt.Error).
+			Set("updated_at", squirrel.Expr("NOW()")).
+			Where("id = ?", attempt.DeliveryID)
+		if status == models.WebhookDeliverySucceeded {
+			update = update.Set("delivered_at", attempt.CreatedAt)
+		}
+		_, err = update.ExecContext(ctx)
+		return mapError(err)
+	})
+}
+
+// SetDeliveryStatus меняет статус доставки без новой попытки
+func (r *WebhookRepository) SetDeliveryStatus(ctx context.Context, id uuid.UUID, status models.WebhookDeliveryStatus, lastError *string) error {
+	result, err := r.db.Update(ctx, "webhook_deliveries").
+		Set("status", status).
+		Set("last_error", lastError).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where("id = ?", id).
+		ExecContext(ctx)
+	return expectAffected(result, err)
+}
+
+// ListAttempts возвращает журнал попыток доставки по порядку
+func (r *WebhookRepository) ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]models.WebhookAttempt, error) {
+	rows, err := r.db.Select(ctx, webhookAttemptColumns...).
+		From("webhook_delivery_attempts").
+		Where("delivery_id = ?", deliveryID).
+		OrderBy("id").
+		QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var attempts []models.WebhookAttempt
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.Attempt, &attempt.ResponseStatus,
+			&attempt.ResponseBody, &attempt.Error, &attempt.DurationMs, &attempt.CreatedAt)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// DeleteDeliveriesBefore удаляет завершенные доставки, созданные до createdBefore, вместе с журналом попыток
+func (r *WebhookRepository) DeleteDeliveriesBefore(ctx context.Context, createdBefore time.Time) (int64, error) {
+	result, err := r.db.Delete(ctx, "webhook_deliveries").
+		Where("status <> ? AND created_at < ?", models.WebhookDeliveryPending, createdBefore).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *WebhookRepository) queryEndpoints(ctx context.Context, query squirrel.SelectBuilder) ([]models.WebhookEndpoint, error) {
+	rows, err := query.QueryContext(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := r.scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+func (r *WebhookRepository) scanEndpoint(row rowScanner) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := row.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, pq.Array(&endpoint.Events), &endpoint.Secret,
+		&endpoint.IsActive, &endpoint.ConsecutiveFailures, &endpoint.DisabledAt, &endpoint.DisabledReason,
+		&endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &endpoint, nil
+}
+
+func (r *WebhookRepository) scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload []byte
+	err := row.Scan(&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &delivery.CreatedAt,
+		&delivery.UpdatedAt, &delivery.DeliveredAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	delivery.Payload = payload
+	return &delivery, nil
+}
+
+// expectAffected возвращает ErrNotFound, если запрос не затронул ни одной строки
+func expectAffected(result sql.Result, err error) error {
+	if err != nil {
+		return mapError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if affected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
@@ -6,12 +6,37 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupAdminRoutes(api fiber.Router, userHandler *handlers.UserHandler, authMiddleware *middleware.AuthMiddleware) {
+func SetupAdminRoutes(api fiber.Router, userHandler *handlers.UserHandler, jobHandler *handlers.JobHandler,
+	webhookHandler *handlers.WebhookHandler, mailHandler *handlers.MailHandler,
+	auditHandler *handlers.AuditHandler, authMiddleware *middleware.AuthMiddleware) {
 	admin := api.Group("/admin")
 	admin.Use(authMiddleware.RequireAuth)
 	admin.Use(authMiddleware.RequireRole("admin"))
 
 	admin.Get("/users", userHandler.GetAllUsers)
+	admin.Get("/users/search", userHandler.SearchUsers)
+	admin.Get("/users/deleted", userHandler.GetDeletedUsers)
+	admin.Post("/users/:id/restore", userHandler.RestoreUser)
+
+	admin.Get("/jobs", jobHandler.ListJobs)
+	admin.Get("/jobs/stats", jobHandler.Stats)
+	admin.Get("/jobs/:id", jobHandler.GetJob)
+	admin.Post("/jobs/:id/retry", jobHandler.RetryJob)
+
+	admin.Get("/webhooks", webhookHandler.ListWebhooks)
+	admin.Post("/webhooks", webhookHandler.CreateWebhook)
+	admin.Get("/webhooks/:id", webhookHandler.GetWebhook)
+	admin.Patch("/webhooks/:id", webhookHandler.UpdateWebhook)
+	admin.Delete("/webhooks/:id", webhookHandler.DeleteWebhook)
+	admin.Post("/webhooks/:id/rotate-secret", webhookHandler.RotateSecret)
+	admin.Get("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+	admin.Get("/webhooks/:id/deliveries/:deliveryId", webhookHandler.GetDelivery)
+	admin.Post("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+
+	admin.Get("/mail/templates", mailHandler.ListTemplates)
+	admin.Get("/mail/templates/:name/preview", mailHandler.PreviewTemplate)
+
+	admin.Get("/audit", auditHandler.ListEvents)
 	//admin.Get("/users/:id", userHandler.GetUserByID)
 	//admin.Put("/users/:id", userHandler.UpdateUserByID)
 	//admin.Delete("/users/:id", userHandler.DeleteUserByID)
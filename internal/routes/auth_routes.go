@@ -7,9 +7,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupAuthRoutes(api fiber.Router, authHandler *handlers.AuthHandler, authMiddleware *middleware.AuthMiddleware) {
+func SetupAuthRoutes(api fiber.Router, authHandler *handlers.AuthHandler, authMiddleware *middleware.AuthMiddleware, loginRateLimit fiber.Handler) {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
-	auth.Post("/login", authHandler.Login)
+	// Код подтверждения подбирается так же, как пароль, поэтому у маршрутов общий лимит
+	auth.Post("/login", loginRateLimit, authHandler.Login)
+	auth.Post("/login/verify", loginRateLimit, authHandler.VerifyLogin)
 	auth.Post("/logout", authMiddleware.RequireAuth, authHandler.Logout)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupEmailRoutes регистрирует ссылки из писем: они работают по токену, без сессии
+func SetupEmailRoutes(api fiber.Router, emailChangeHandler *handlers.EmailChangeHandler) {
+	email := api.Group("/email")
+
+	// GET только проверяет ссылку, изменения применяет POST
+	email.Get("/confirm", emailChangeHandler.CheckConfirm)
+	email.Post("/confirm", emailChangeHandler.Confirm)
+	email.Get("/revert", emailChangeHandler.CheckRevert)
+	email.Post("/revert", emailChangeHandler.Revert)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupExportRoutes регистрирует скачивание выгрузок по подписанной ссылке, без сессии
+func SetupExportRoutes(api fiber.Router, exportHandler *handlers.ExportHandler) {
+	exports := api.Group("/exports")
+	exports.Get("/:id/download", exportHandler.Download)
+}
@@ -9,7 +9,10 @@ import (
 func SetupRoutes(fiberApp *fiber.App, deps *app.Dependencies) {
 	api := fiberApp.Group("/api/v1")
 
-	SetupAuthRoutes(api, deps.AuthHandler, deps.AuthMiddleware)
-	SetupUserRoutes(api, deps.UserHandler, deps.AuthMiddleware)
-	SetupAdminRoutes(api, deps.UserHandler, deps.AuthMiddleware)
+	SetupAuthRoutes(api, deps.AuthHandler, deps.AuthMiddleware, deps.LoginRateLimit)
+	SetupUserRoutes(api, deps.UserHandler, deps.AuthHandler, deps.SecurityHandler, deps.DeviceHandler, deps.ExportHandler, deps.EmailChangeHandler, deps.AuditHandler, deps.AuthMiddleware)
+	SetupExportRoutes(api, deps.ExportHandler)
+	SetupEmailRoutes(api, deps.EmailChangeHandler)
+	SetupSecurityRoutes(api, deps.SecurityHandler)
+	SetupAdminRoutes(api, deps.UserHandler, deps.JobHandler, deps.WebhookHandler, deps.MailHandler, deps.AuditHandler, deps.AuthMiddleware)
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupSecurityRoutes регистрирует ссылки из уведомлений безопасности: они работают без сессии
+func SetupSecurityRoutes(api fiber.Router, securityHandler *handlers.SecurityHandler) {
+	// GET только проверяет ссылку «это был не я», сессии завершает POST
+	api.Get("/security/not-me", securityHandler.CheckNotMe)
+	api.Post("/security/not-me", securityHandler.NotMe)
+
+	api.Get("/password/reset", securityHandler.CheckPasswordReset)
+	api.Post("/password/reset", securityHandler.ResetPassword)
+}
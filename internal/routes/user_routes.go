@@ -6,10 +6,29 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupUserRoutes(api fiber.Router, userHandler *handlers.UserHandler, authMiddleware *middleware.AuthMiddleware) {
+func SetupUserRoutes(api fiber.Router, userHandler *handlers.UserHandler, authHandler *handlers.AuthHandler,
+	securityHandler *handlers.SecurityHandler, deviceHandler *handlers.DeviceHandler, exportHandler *handlers.ExportHandler, emailChangeHandler *handlers.EmailChangeHandler,
+	auditHandler *handlers.AuditHandler, authMiddleware *middleware.AuthMiddleware) {
 	user := api.Group("/user")
 	user.Use(authMiddleware.RequireAuth)
 
 	user.Get("/profile", userHandler.GetProfile)
 	user.Put("/profile", userHandler.UpdateProfile)
+	user.Delete("/profile", userHandler.DeleteUser)
+
+	user.Post("/email/change", emailChangeHandler.RequestChange)
+
+	user.Get("/sessions", authHandler.ListSessions)
+	user.Delete("/sessions/:id", authHandler.RevokeSession)
+
+	user.Get("/security-alerts", securityHandler.GetAlertSettings)
+	user.Put("/security-alerts", securityHandler.UpdateAlertSettings)
+
+	user.Get("/devices", deviceHandler.ListDevices)
+	user.Delete("/devices/:id", deviceHandler.ForgetDevice)
+	user.Get("/logins", deviceHandler.ListLogins)
+	user.Get("/activity", auditHandler.MyActivity)
+
+	user.Post("/export", exportHandler.RequestExport)
+	user.Get("/export/:id", exportHandler.GetExport)
 }
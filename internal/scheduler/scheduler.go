@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// finishTimeout - сколько ждем записи результата в историю, если ctx задачи уже отменен
+const finishTimeout = 5 * time.Second
+
+// JobFunc - тело фоновой задачи; возвращает число обработанных записей для истории запусков
+type JobFunc func(ctx context.Context) (int64, error)
+
+// Locker дает эксклюзивный доступ к задаче между репликами (advisory lock Postgres)
+type Locker interface {
+	TryAdvisoryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+}
+
+// History хранит историю запусков и не дает выполнить один запланированный запуск дважды
+type History interface {
+	StartRun(ctx context.Context, jobName string, scheduledAt time.Time, instance string) (uuid.UUID, bool, error)
+	FinishRun(ctx context.Context, id uuid.UUID, processed int64, runErr error) error
+}
+
+type Options struct {
+	// Jitter - максимальная случайная задержка перед запуском, чтобы реплики не били в базу одновременно
+	Jitter time.Duration
+	// Timeout ограничивает время одного запуска; 0 - без ограничения
+	Timeout time.Duration
+	// Instance записывается в историю, чтобы было видно, какая реплика выполнила запуск
+	Instance string
+}
+
+type job struct {
+	name     string
+	schedule cron.Schedule
+	fn       JobFunc
+}
+
+// Scheduler запускает задачи по cron-расписанию. Каждый запланированный запуск выполняет
+// ровно одна реплика: задача берет advisory lock, а история отсекает повторы того же времени
+type Scheduler struct {
+	locker  Locker
+	history History
+	opts    Options
+	jobs    []*job
+
+	mu         sync.Mutex
+	started    bool
+	stopLoops  context.CancelFunc
+	cancelRuns context.CancelFunc
+	runCtx     context.Context
+	loops      sync.WaitGroup
+}
+
+func New(locker Locker, history History, opts Options) *Scheduler {
+	return &Scheduler{
+		locker:  locker,
+		history: history,
+		opts:    opts,
+	}
+}
+
+// Register добавляет задачу. spec - стандартное cron-выражение из пяти полей
+// или дескриптор (@hourly, @daily, @every 10m)
+func (s *Scheduler) Register(name, spec string, fn JobFunc) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", spec, name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("scheduler already started, can't register job %s", name)
+	}
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{name: name, schedule: schedule, fn: fn})
+	return nil
+}
+
+// Start запускает планирование зарегистрированных задач
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	loopCtx, stopLoops := context.WithCancel(context.Background())
+	s.stopLoops = stopLoops
+	s.runCtx, s.cancelRuns = context.WithCancel(context.Background())
+
+	for _, j := range s.jobs {
+		s.loops.Add(1)
+		go s.loop(loopCtx, j)
+	}
+}
+
+// Shutdown перестает планировать новые запуски и ждет завершения выполняющихся.
+// Если ctx истекает раньше, выполняющиеся задачи отменяются и возвращается ошибка ctx
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopLoops()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.loops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancelRuns()
+		return nil
+	case <-ctx.Done():
+		s.cancelRuns()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// RunNow выполняет задачу вне расписания с теми же блокировкой и записью в историю
+func (s *Scheduler) RunNow(ctx context.Context, name string) error {
+	for _, j := range s.jobs {
+		if j.name == name {
+			return s.run(ctx, j, time.Now())
+		}
+	}
+	return fmt.Errorf("unknown job %s", name)
+}
+
+// Jobs возвращает имена зарегистрированных задач
+func (s *Scheduler) Jobs() []string {
+	names := make([]string, len(s.jobs))
+	for i, j := range s.jobs {
+		names[i] = j.name
+	}
+	return names
+}
+
+// loop ждет очередного времени по расписанию и выполняет задачу. Запуски одной задачи
+// внутри процесса не пересекаются: следующее время считается после завершения текущего
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.loops.Done()
+
+	for {
+		scheduledAt := next(j.schedule, time.Now())
+		timer := time.NewTimer(time.Until(scheduledAt) + s.jitter())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.run(s.runCtx, j, scheduledAt); err != nil {
+			logging.FromContext(s.runCtx).Error("scheduled job failed", "job", j.name, "error", err)
+		}
+	}
+}
+
+// next возвращает время следующего запуска после now. Интервал @every отсчитывается не от now,
+// а от фиксированной точки (нулевого времени): иначе у реплик, запущенных в разное время,
+// запланированные времена не совпадут и история не отсечет повторный запуск
+func next(schedule cron.Schedule, now time.Time) time.Time {
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return now.Truncate(every.Delay).Add(every.Delay)
+	}
+	return schedule.Next(now)
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, scheduledAt time.Time) (err error) {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	// Каждый запуск - отдельная трасса
+	ctx, span := tracing.Start(ctx, "job "+j.name, trace.WithNewRoot(), trace.WithAttributes(attribute.String("job.name", j.name)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	ctx = logging.WithTrace(logging.With(ctx, "job", j.name))
+	logger := logging.FromContext(ctx)
+
+	unlock, acquired, err := s.locker.TryAdvisoryLock(ctx, "job:"+j.name)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		logger.Info("scheduled job is running on another instance, skipping")
+		return nil
+	}
+	defer unlock()
+
+	runID, started, err := s.history.StartRun(ctx, j.name, scheduledAt, s.opts.Instance)
+	if err != nil {
+		return fmt.Errorf("error recording job start: %w", err)
+	}
+	if !started {
+		// Этот запуск уже выполнила другая реплика, пока мы ждали jitter
+		return nil
+	}
+
+	startedAt := time.Now()
+	processed, runErr := safeRun(ctx, j.fn)
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+	if err := s.history.FinishRun(finishCtx, runID, processed, runErr); err != nil {
+		logger.Error("failed to record scheduled job result", "error", err)
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	if processed > 0 {
+		logger.Info("scheduled job finished", "processed", processed, "duration", time.Since(startedAt).Round(time.Millisecond).String())
+	}
+	return nil
+}
+
+// safeRun превращает панику задачи в ошибку, чтобы она не уронила процесс
+func safeRun(ctx context.Context, fn JobFunc) (processed int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (s *Scheduler) jitter() time.Duration {
+	if s.opts.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.opts.Jitter)))
+}
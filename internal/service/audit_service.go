@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// AuditService - чтение журнала аудита; записывают в него сами сервисы через audit.Recorder
+type AuditService struct {
+	auditRepo repository.AuditRepositoryInterface
+}
+
+func NewAuditService(auditRepo repository.AuditRepositoryInterface) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// ListEvents возвращает записи журнала по фильтру администратора
+func (s *AuditService) ListEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error) {
+	ctx, span := tracing.StartChild(ctx, "AuditService.ListEvents")
+	defer span.End()
+
+	return s.auditRepo.List(ctx, filter)
+}
+
+// UserActivity - история безопасности пользователя: его собственные действия и действия
+// над его учетной записью, включая неудачные входы и действия администраторов
+func (s *AuditService) UserActivity(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.AuditEvent, int64, error) {
+	ctx, span := tracing.StartChild(ctx, "AuditService.UserActivity")
+	defer span.End()
+
+	return s.auditRepo.List(ctx, models.AuditFilter{
+		Subject: &userID,
+		Limit:   limit,
+		Offset:  offset,
+	})
+}
@@ -1,33 +1,88 @@
 package service
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/metrics"
 	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/outbox"
 	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
 	"github.com/AtlasOpx/devprep/internal/utils"
 	"github.com/google/uuid"
 	"time"
 )
 
+// ErrInvalidRole возвращается при попытке назначить неизвестную роль
+var ErrInvalidRole = apperrors.ErrValidation.WithMessage("error.invalid_role", "Unknown user role")
+
+// ErrSessionNotFound - у пользователя нет сессии с таким ID
+var ErrSessionNotFound = apperrors.ErrNotFound.WithMessage("error.session_not_found", "Session not found")
+
+// sessionTTL - время жизни сессии после входа
+const sessionTTL = 24 * time.Hour
+
+// dummyPasswordHash - хеш, по которому проверяется пароль при неизвестном email,
+// чтобы время ответа не выдавало, зарегистрирован ли адрес
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=1,p=4$r2GjJse/ifpYJKwtBPR70w$oKlYjYTcVpXDurwXt0TOsr6f2I2leoEwcdev95DxRz8"
+
 type AuthService struct {
-	userRepo *repository.UserRepository
-	authRepo *repository.AuthRepository
+	tx       database.Transactor
+	userRepo repository.UserRepositoryInterface
+	authRepo repository.AuthRepositoryInterface
+	events   outbox.Recorder
+	auditLog audit.Recorder
+	risk     *LoginRiskService
 }
 
-func NewAuthService(userRepo *repository.UserRepository, authRepo *repository.AuthRepository) *AuthService {
+// NewAuthService создает сервис; risk может быть nil - тогда риск входа не оценивается
+func NewAuthService(tx database.Transactor, userRepo repository.UserRepositoryInterface,
+	authRepo repository.AuthRepositoryInterface, events outbox.Recorder, auditLog audit.Recorder,
+	risk *LoginRiskService) *AuthService {
 	return &AuthService{
+		tx:       tx,
 		userRepo: userRepo,
 		authRepo: authRepo,
+		events:   events,
+		auditLog: auditLog,
+		risk:     risk,
 	}
 }
 
-func (s *AuthService) Register(req *models.RegisterRequest) (*uuid.UUID, error) {
-	existingUser, _ := s.userRepo.GetByEmail(req.Email)
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*uuid.UUID, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.Register")
+	defer span.End()
+
+	return s.CreateUser(ctx, req, models.UserRoleUser)
+}
+
+// CreateUser создает активного пользователя с указанной ролью; используется регистрацией и CLI
+func (s *AuthService) CreateUser(ctx context.Context, req *models.RegisterRequest, role models.UserRole) (*uuid.UUID, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.CreateUser")
+	defer span.End()
+
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	email := utils.NormalizeEmail(req.Email)
+	username := utils.NormalizeUsername(req.Username)
+
+	existingUser, _ := s.userRepo.GetByEmail(ctx, email)
+	if existingUser != nil {
+		return nil, apperrors.ErrEmailTaken
+	}
+
+	existingUser, _ = s.userRepo.GetByUsername(ctx, username)
 	if existingUser != nil {
-		return nil, sql.ErrNoRows
+		return nil, apperrors.ErrUsernameTaken
 	}
 
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := hashPassword(ctx, req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -35,18 +90,39 @@ func (s *AuthService) Register(req *models.RegisterRequest) (*uuid.UUID, error)
 	userID := uuid.New()
 	user := &models.User{
 		ID:           userID,
-		Email:        req.Email,
-		Username:     req.Username,
+		Email:        email,
+		Username:     username,
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		PasswordHash: hashedPassword,
-		Role:         models.UserRoleUser,
+		Role:         role,
 		IsActive:     true,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
-	err = s.userRepo.Create(user)
+	// Проверки выше не защищают от гонки двух регистраций: тогда репозиторий
+	// вернет ту же доменную ошибку по нарушению уникального индекса
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.UserCreated,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Metadata:   map[string]interface{}{"email": user.Email, "role": string(user.Role)},
+		})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, userID, events.UserRegistered, events.UserRegisteredPayload{
+			UserID:   userID,
+			Email:    user.Email,
+			Username: user.Username,
+			Role:     string(user.Role),
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -54,28 +130,427 @@ func (s *AuthService) Register(req *models.RegisterRequest) (*uuid.UUID, error)
 	return &userID, nil
 }
 
-func (s *AuthService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
-	user, err := s.userRepo.GetByEmail(req.Email)
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.Login")
+	defer span.End()
+
+	email := utils.NormalizeEmail(req.Email)
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		checkPassword(ctx, req.Password, dummyPasswordHash)
+		return nil, s.loginFailed(ctx, nil, email, "unknown_email", apperrors.ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !checkPassword(ctx, req.Password, user.PasswordHash) {
+		return nil, s.loginFailed(ctx, user, email, "invalid_password", apperrors.ErrInvalidCredentials)
+	}
+
+	if !user.IsActive {
+		return nil, s.loginFailed(ctx, user, email, "account_disabled", apperrors.ErrAccountDisabled)
+	}
+
+	if s.risk == nil {
+		response, err := s.startSession(ctx, user, req.UserAgent, req.IPAddress, nil, models.LoginOutcomeAllowed)
+		return s.loginSucceeded(models.LoginOutcomeAllowed, response, err)
+	}
+
+	assessment, err := s.risk.Assess(ctx, user.ID, req.IPAddress, req.UserAgent)
 	if err != nil {
 		return nil, err
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.PasswordHash) {
-		return nil, sql.ErrNoRows
+	switch assessment.Decision {
+	case models.LoginDecisionBlock:
+		err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+			err := s.risk.RecordLogin(ctx, user.ID, req.IPAddress, req.UserAgent, assessment, models.LoginOutcomeBlocked)
+			if err != nil {
+				return err
+			}
+			err = s.auditLog.Record(ctx, audit.Entry{
+				Action:     audit.LoginBlocked,
+				TargetType: audit.TargetUser,
+				TargetID:   user.ID,
+				Actor:      actorOf(user),
+				Metadata:   riskMetadata(assessment),
+			})
+			if err != nil {
+				return err
+			}
+			return s.events.Record(ctx, events.AggregateUser, user.ID, events.UserLoginBlocked, events.UserLoginBlockedPayload{
+				UserID:    user.ID,
+				IPAddress: req.IPAddress,
+				UserAgent: req.UserAgent,
+				RiskScore: assessment.Score,
+				Signals:   assessment.SignalNames(),
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		metrics.RecordLogin(metrics.LoginFailure, "risk_blocked")
+		return nil, apperrors.ErrLoginBlocked
+
+	case models.LoginDecisionChallenge:
+		var challenge *models.LoginChallenge
+		err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			challenge, err = s.risk.StartChallenge(ctx, user, req.IPAddress, req.UserAgent, assessment)
+			if err != nil {
+				return err
+			}
+			metadata := riskMetadata(assessment)
+			metadata["challenge_id"] = challenge.ID
+			return s.auditLog.Record(ctx, audit.Entry{
+				Action:     audit.LoginChallenged,
+				TargetType: audit.TargetUser,
+				TargetID:   user.ID,
+				Actor:      actorOf(user),
+				Metadata:   metadata,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		metrics.RecordLogin(metrics.LoginChallenged, "risk")
+		return &models.LoginResponse{
+			Message:   "Verification code sent to your email",
+			User:      *user,
+			Challenge: challenge,
+		}, nil
+	}
+
+	response, err := s.startSession(ctx, user, req.UserAgent, req.IPAddress, assessment, models.LoginOutcomeAllowed)
+	return s.loginSucceeded(models.LoginOutcomeAllowed, response, err)
+}
+
+// VerifyLogin завершает рискованный вход кодом из письма. Код принимается только
+// с того же устройства, с которого начат вход
+func (s *AuthService) VerifyLogin(ctx context.Context, req *models.VerifyLoginRequest) (*models.LoginResponse, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.VerifyLogin")
+	defer span.End()
+
+	if s.risk == nil {
+		return nil, ErrInvalidLoginCode
+	}
+
+	challenge, err := s.risk.CheckChallenge(ctx, req.ChallengeID, req.Code, req.UserAgent)
+	if errors.Is(err, ErrInvalidLoginCode) {
+		metrics.RecordLogin(metrics.LoginFailure, "invalid_code")
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
 	if !user.IsActive {
-		return nil, sql.ErrNoRows
+		return nil, apperrors.ErrAccountDisabled
+	}
+
+	assessment := &models.LoginAssessment{
+		Score:       challenge.RiskScore,
+		Decision:    models.LoginDecisionChallenge,
+		Fingerprint: challenge.Fingerprint,
+		Subnet:      utils.IPSubnet(req.IPAddress),
+	}
+	for _, signal := range challenge.Signals {
+		assessment.Signals = append(assessment.Signals, models.LoginSignal(signal))
+	}
+
+	var response *models.LoginResponse
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.risk.CompleteChallenge(ctx, challenge.ID); err != nil {
+			return err
+		}
+		var err error
+		response, err = s.startSession(ctx, user, req.UserAgent, req.IPAddress, assessment, models.LoginOutcomeVerified)
+		return err
+	})
+	return s.loginSucceeded(models.LoginOutcomeVerified, response, err)
+}
+
+// startSession создает сессию и записывает вход. assessment пустой, если риск не оценивался
+func (s *AuthService) startSession(ctx context.Context, user *models.User, userAgent, ip string,
+	assessment *models.LoginAssessment, outcome models.LoginOutcome) (*models.LoginResponse, error) {
+	sessionToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(sessionTTL)
+
+	payload := events.UserLoggedInPayload{
+		UserID:    user.ID,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Verified:  outcome == models.LoginOutcomeVerified,
+	}
+	entry := audit.Entry{
+		Action:     audit.LoginSucceeded,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Actor:      actorOf(user),
+		Metadata:   map[string]interface{}{},
+	}
+	if assessment != nil {
+		payload.RiskScore = &assessment.Score
+		payload.Signals = assessment.SignalNames()
+		entry.Metadata = riskMetadata(assessment)
+	}
+	if payload.Verified {
+		entry.Metadata["verified"] = true
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		err := s.authRepo.CreateSession(ctx, user.ID, sessionToken, expiresAt, userAgent, ip)
+		if err != nil {
+			return err
+		}
+		if assessment != nil {
+			err := s.risk.RecordLogin(ctx, user.ID, ip, userAgent, assessment, outcome)
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.auditLog.Record(ctx, entry); err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, user.ID, events.UserLoggedIn, payload)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	response := &models.LoginResponse{
-		Message: "Login successful",
-		User:    *user,
+		Message:          "Login successful",
+		User:             *user,
+		SessionToken:     sessionToken,
+		SessionExpiresAt: expiresAt,
+	}
+
+	return response, nil
+}
+
+func (s *AuthService) Logout(ctx context.Context, sessionToken string) error {
+	ctx, span := tracing.StartChild(ctx, "AuthService.Logout")
+	defer span.End()
+
+	session, err := s.authRepo.GetSessionByToken(ctx, sessionToken)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		// Сессия уже истекла: удаляем ее без события
+		return s.authRepo.DeleteSession(ctx, sessionToken)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.authRepo.DeleteSession(ctx, sessionToken); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.Logout,
+			TargetType: audit.TargetUser,
+			TargetID:   session.UserID,
+			Metadata:   map[string]interface{}{"session_id": session.ID},
+		})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, session.UserID, events.SessionRevoked, events.SessionRevokedPayload{
+			UserID:           session.UserID,
+			Reason:           events.RevokeLogout,
+			SessionUserAgent: session.UserAgent,
+			SessionIPAddress: session.IPAddress,
+			Client:           events.ClientFrom(ctx),
+		})
+	})
+}
+
+// RevokeSession завершает одну сессию пользователя по ее ID
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "AuthService.RevokeSession")
+	defer span.End()
+
+	sessions, err := s.authRepo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID != sessionID {
+			continue
+		}
+		return s.tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := s.authRepo.DeleteSession(ctx, session.SessionToken); err != nil {
+				return err
+			}
+			err := s.auditLog.Record(ctx, audit.Entry{
+				Action:     audit.SessionRevoked,
+				TargetType: audit.TargetUser,
+				TargetID:   userID,
+				Metadata:   map[string]interface{}{"session_id": session.ID},
+			})
+			if err != nil {
+				return err
+			}
+			return s.events.Record(ctx, events.AggregateUser, userID, events.SessionRevoked, events.SessionRevokedPayload{
+				UserID:           userID,
+				Reason:           events.RevokeUser,
+				SessionUserAgent: session.UserAgent,
+				SessionIPAddress: session.IPAddress,
+				Client:           events.ClientFrom(ctx),
+			})
+		})
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeAllSessions завершает все сессии пользователя
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID uuid.UUID, reason string) error {
+	ctx, span := tracing.StartChild(ctx, "AuthService.RevokeAllSessions")
+	defer span.End()
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.authRepo.DeleteUserSessions(ctx, userID); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.SessionsRevoked,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Metadata:   map[string]interface{}{"reason": reason},
+		})
+		if err != nil {
+			return err
+		}
+		return recordSessionsRevoked(ctx, s.events, userID, reason)
+	})
+}
+
+// ResetPassword задает новый пароль и завершает все сессии пользователя
+func (s *AuthService) ResetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	ctx, span := tracing.StartChild(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	hashedPassword, err := hashPassword(ctx, password)
+	if err != nil {
+		return err
 	}
 
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.PasswordReset,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+		})
+		if err != nil {
+			return err
+		}
+		err = s.events.Record(ctx, events.AggregateUser, userID, events.UserPasswordReset, events.UserPasswordResetPayload{
+			UserID: userID,
+			Client: events.ClientFrom(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		return recordSessionsRevoked(ctx, s.events, userID, events.RevokePasswordReset)
+	})
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.ListSessions")
+	defer span.End()
+
+	return s.authRepo.GetSessionsByUserID(ctx, userID)
+}
+
+// CountActiveSessions возвращает число неистекших сессий для метрик
+func (s *AuthService) CountActiveSessions(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.CountActiveSessions")
+	defer span.End()
+
+	return s.authRepo.CountActiveSessions(ctx)
+}
+
+// PurgeExpiredSessions удаляет истекшие сессии всех пользователей
+func (s *AuthService) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "AuthService.PurgeExpiredSessions")
+	defer span.End()
+
+	return s.authRepo.CleanupExpiredSessions(ctx)
+}
+
+// loginFailed записывает неудачный вход и возвращает причину отказа для клиента.
+// user пустой, если email не найден
+func (s *AuthService) loginFailed(ctx context.Context, user *models.User, email, reason string, cause error) error {
+	entry := audit.Entry{
+		Action:     audit.LoginFailed,
+		TargetType: audit.TargetUser,
+		Metadata:   map[string]interface{}{"email": email, "reason": reason},
+	}
+	if user != nil {
+		entry.TargetID = user.ID
+	}
+	if err := s.auditLog.Record(ctx, entry); err != nil {
+		return err
+	}
+	metrics.RecordLogin(metrics.LoginFailure, reason)
+	return cause
+}
+
+// loginSucceeded считает вход, если сессия создана; причина - allowed или verified (по коду из письма)
+func (s *AuthService) loginSucceeded(outcome models.LoginOutcome, response *models.LoginResponse, err error) (*models.LoginResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	metrics.RecordLogin(metrics.LoginSuccess, string(outcome))
 	return response, nil
 }
 
-func (s *AuthService) Logout(sessionToken string) error {
-	return s.authRepo.DeleteSession(sessionToken)
+// hashPassword считает хеш argon2 в отдельном span, чтобы его время было видно в трассе
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.StartChild(ctx, "argon2.hash")
+	defer span.End()
+	return utils.HashPassword(password)
+}
+
+// checkPassword проверяет пароль по хешу argon2 в отдельном span
+func checkPassword(ctx context.Context, password, hash string) bool {
+	_, span := tracing.StartChild(ctx, "argon2.verify")
+	defer span.End()
+	return utils.CheckPasswordHash(password, hash)
+}
+
+// actorOf - исполнитель входа: до создания сессии его нет в контексте запроса
+func actorOf(user *models.User) *audit.Actor {
+	return &audit.Actor{ID: user.ID, Role: user.Role}
+}
+
+// riskMetadata - оценка риска входа для журнала аудита
+func riskMetadata(assessment *models.LoginAssessment) map[string]interface{} {
+	return map[string]interface{}{
+		"risk_score": assessment.Score,
+		"signals":    assessment.SignalNames(),
+	}
+}
+
+// recordSessionsRevoked записывает завершение всех сессий пользователя
+func recordSessionsRevoked(ctx context.Context, recorder outbox.Recorder, userID uuid.UUID, reason string) error {
+	return recorder.Record(ctx, events.AggregateUser, userID, events.SessionRevoked, events.SessionRevokedPayload{
+		UserID: userID,
+		Reason: reason,
+		All:    true,
+		Client: events.ClientFrom(ctx),
+	})
 }
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/outbox"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type EmailChangeService struct {
+	tx              database.Transactor
+	userRepo        repository.UserRepositoryInterface
+	authRepo        repository.AuthRepositoryInterface
+	emailChangeRepo repository.EmailChangeRepositoryInterface
+	jobs            queue.Enqueuer
+	events          outbox.Recorder
+	auditLog        audit.Recorder
+	templates       *mail.Renderer
+	cfg             *config.Config
+}
+
+func NewEmailChangeService(tx database.Transactor, userRepo repository.UserRepositoryInterface,
+	authRepo repository.AuthRepositoryInterface, emailChangeRepo repository.EmailChangeRepositoryInterface,
+	jobs queue.Enqueuer, events outbox.Recorder, auditLog audit.Recorder, templates *mail.Renderer,
+	cfg *config.Config) *EmailChangeService {
+	return &EmailChangeService{
+		tx:              tx,
+		userRepo:        userRepo,
+		authRepo:        authRepo,
+		emailChangeRepo: emailChangeRepo,
+		jobs:            jobs,
+		events:          events,
+		auditLog:        auditLog,
+		templates:       templates,
+		cfg:             cfg,
+	}
+}
+
+// RequestChange проверяет пароль и отправляет ссылку подтверждения на новый адрес
+// и уведомление со ссылкой отмены на старый. Сам email меняется только в Confirm
+func (s *EmailChangeService) RequestChange(ctx context.Context, userID uuid.UUID, req *models.ChangeEmailRequest) error {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.RequestChange")
+	defer span.End()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !checkPassword(ctx, req.CurrentPassword, user.PasswordHash) {
+		return apperrors.ErrInvalidPassword
+	}
+
+	newEmail := utils.NormalizeEmail(req.NewEmail)
+	if newEmail == utils.NormalizeEmail(user.Email) {
+		return apperrors.ErrEmailUnchanged
+	}
+
+	if existing, _ := s.userRepo.GetByEmail(ctx, newEmail); existing != nil {
+		return apperrors.ErrEmailTaken
+	}
+
+	confirmToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		return err
+	}
+	revertToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	change := &models.EmailChangeRequest{
+		ID:               uuid.New(),
+		UserID:           userID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: utils.HashToken(confirmToken),
+		RevertTokenHash:  utils.HashToken(revertToken),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(s.cfg.EmailChangeTTL),
+		RevertExpiresAt:  now.Add(s.cfg.EmailRevertTTL),
+	}
+
+	locale := i18n.Resolve(req.AcceptLanguage, user.Locale, i18n.Locale(s.cfg.DefaultLocale))
+	confirmMessage, err := s.templates.Render(locale, "email_change_confirm", newEmail, i18n.Args{
+		"name":      user.FirstName,
+		"new_email": newEmail,
+		"link":      s.link("/email/confirm", confirmToken),
+		"hours":     int(s.cfg.EmailChangeTTL.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+	noticeMessage, err := s.templates.Render(locale, "email_change_notice", user.Email, i18n.Args{
+		"name":      user.FirstName,
+		"new_email": newEmail,
+		"link":      s.link("/email/revert", revertToken),
+		"days":      int(s.cfg.EmailRevertTTL.Hours() / 24),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Новый запрос отменяет предыдущие, чтобы старые ссылки перестали работать.
+	// Письма ставятся в очередь в той же транзакции: без запроса в базе они не уйдут
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.emailChangeRepo.CancelPending(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.emailChangeRepo.Create(ctx, change); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.EmailChangeRequest,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Metadata:   map[string]interface{}{"new_email": newEmail},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := s.jobs.Enqueue(ctx, JobSendEmail, confirmMessage); err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, JobSendEmail, noticeMessage)
+		return err
+	})
+}
+
+// CheckConfirm проверяет, что ссылкой подтверждения еще можно сменить email. Сама ссылка из письма
+// открывается GET-запросом и ничего не меняет: смену применяет только POST в Confirm
+func (s *EmailChangeService) CheckConfirm(ctx context.Context, token string) (*models.EmailChangeRequest, error) {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.CheckConfirm")
+	defer span.End()
+
+	change, err := s.emailChangeRepo.GetByConfirmTokenHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+	if change.ConfirmedAt != nil || change.RevertedAt != nil || change.CancelledAt != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+	if time.Now().After(change.ExpiresAt) {
+		return nil, apperrors.ErrTokenExpired
+	}
+	return change, nil
+}
+
+// Confirm применяет смену email. Уникальность проверяется здесь, так как за время
+// ожидания подтверждения адрес мог занять кто-то другой
+func (s *EmailChangeService) Confirm(ctx context.Context, token string) error {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.Confirm")
+	defer span.End()
+
+	change, err := s.CheckConfirm(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// Запрос отмечается первым и только если он еще не подтвержден, не отменен и не откачен:
+		// из одновременных подтверждения и отмены проходит одно, второе увидит его результат
+		err := s.emailChangeRepo.MarkConfirmed(ctx, change.ID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		// Адрес меняется, только если он не менялся с момента запроса
+		err = s.userRepo.UpdateEmail(ctx, change.UserID, change.OldEmail, change.NewEmail)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		err = s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.EmailChanged,
+			TargetType: audit.TargetUser,
+			TargetID:   change.UserID,
+			Changes:    emailChanges(change.OldEmail, change.NewEmail),
+		})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, change.UserID, events.UserEmailChanged, events.UserEmailChangedPayload{
+			UserID:   change.UserID,
+			OldEmail: change.OldEmail,
+			NewEmail: change.NewEmail,
+			Client:   events.ClientFrom(ctx),
+		})
+	})
+}
+
+// CheckRevert проверяет, что ссылкой отмены еще можно вернуть старый email; как и CheckConfirm, ничего не меняет
+func (s *EmailChangeService) CheckRevert(ctx context.Context, token string) (*models.EmailChangeRequest, error) {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.CheckRevert")
+	defer span.End()
+
+	change, err := s.emailChangeRepo.GetByRevertTokenHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+	if err := revertable(change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Revert возвращает старый email по ссылке из уведомления и завершает все сессии
+func (s *EmailChangeService) Revert(ctx context.Context, token string) error {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.Revert")
+	defer span.End()
+
+	found, err := s.CheckRevert(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// Запрос перечитывается под блокировкой: подтверждение, закончившееся после CheckRevert,
+		// должно быть откачено вместе со сменой email
+		change, err := s.emailChangeRepo.GetForUpdate(ctx, found.ID)
+		if err != nil {
+			return err
+		}
+		if err := revertable(change); err != nil {
+			return err
+		}
+		err = s.emailChangeRepo.MarkReverted(ctx, change.ID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if change.ConfirmedAt != nil {
+			// Старый адрес возвращается, только если пользователь с тех пор не сменил email еще раз
+			err := s.userRepo.UpdateEmail(ctx, change.UserID, change.NewEmail, change.OldEmail)
+			if errors.Is(err, apperrors.ErrNotFound) {
+				return apperrors.ErrInvalidToken
+			}
+			if err != nil {
+				return err
+			}
+			err = s.auditLog.Record(ctx, audit.Entry{
+				Action:     audit.EmailReverted,
+				TargetType: audit.TargetUser,
+				TargetID:   change.UserID,
+				Changes:    emailChanges(change.NewEmail, change.OldEmail),
+			})
+			if err != nil {
+				return err
+			}
+			err = s.events.Record(ctx, events.AggregateUser, change.UserID, events.UserEmailChanged, events.UserEmailChangedPayload{
+				UserID:   change.UserID,
+				OldEmail: change.NewEmail,
+				NewEmail: change.OldEmail,
+				Reverted: true,
+				Client:   events.ClientFrom(ctx),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := s.emailChangeRepo.CancelPending(ctx, change.UserID); err != nil {
+			return err
+		}
+		if err := s.authRepo.DeleteUserSessions(ctx, change.UserID); err != nil {
+			return err
+		}
+		return recordSessionsRevoked(ctx, s.events, change.UserID, events.RevokeEmailReverted)
+	})
+}
+
+// PurgeExpired удаляет запросы смены email с истекшими ссылками
+func (s *EmailChangeService) PurgeExpired(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "EmailChangeService.PurgeExpired")
+	defer span.End()
+
+	return s.emailChangeRepo.DeleteExpired(ctx)
+}
+
+func (s *EmailChangeService) link(path, token string) string {
+	return fmt.Sprintf("%s/api/v1%s?token=%s", strings.TrimRight(s.cfg.PublicBaseURL, "/"), path, token)
+}
+
+// revertable проверяет, что ссылка отмены еще не использована и не истекла
+func revertable(change *models.EmailChangeRequest) error {
+	if change.RevertedAt != nil {
+		return apperrors.ErrInvalidToken
+	}
+	if time.Now().After(change.RevertExpiresAt) {
+		return apperrors.ErrTokenExpired
+	}
+	return nil
+}
+
+// emailChanges - смена email для журнала аудита
+func emailChanges(oldEmail, newEmail string) *audit.Changes {
+	return audit.Diff(map[string]interface{}{"email": oldEmail}, map[string]interface{}{"email": newEmail})
+}
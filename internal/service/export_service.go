@@ -0,0 +1,509 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/storage"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrExportRateLimited = apperrors.ErrRateLimited.WithMessage("error.export_rate_limited", "Data export can be requested once per day")
+	errInvalidExportLink = apperrors.ErrInvalidToken.WithMessage("error.invalid_download_link", "Invalid download link")
+)
+
+// JobBuildExport - сборка архива выгрузки в очереди задач
+const JobBuildExport = "export.build"
+
+const (
+	// exportLoginLimit ограничивает историю входов в архиве; она и так хранится только LOGIN_HISTORY_TTL
+	exportLoginLimit = 10000
+	// exportAuditPage - сколько записей журнала аудита читается за один запрос
+	exportAuditPage = 500
+	// exportPurgeBatch - сколько истекших выгрузок удаляется за один запрос к базе
+	exportPurgeBatch = 100
+)
+
+// BuildExportPayload - payload задачи JobBuildExport
+type BuildExportPayload struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+type ExportService struct {
+	tx         database.Transactor
+	jobs       queue.Enqueuer
+	exportRepo repository.ExportRepositoryInterface
+	userRepo   repository.UserRepositoryInterface
+	authRepo   repository.AuthRepositoryInterface
+	riskRepo   repository.LoginRiskRepositoryInterface
+	auditRepo  repository.AuditRepositoryInterface
+	storage    storage.Storage
+	auditLog   audit.Recorder
+	cfg        *config.Config
+	secret     string
+}
+
+func NewExportService(tx database.Transactor, jobs queue.Enqueuer, exportRepo repository.ExportRepositoryInterface, userRepo repository.UserRepositoryInterface,
+	authRepo repository.AuthRepositoryInterface, riskRepo repository.LoginRiskRepositoryInterface,
+	auditRepo repository.AuditRepositoryInterface, fileStorage storage.Storage, auditLog audit.Recorder,
+	cfg *config.Config) (*ExportService, error) {
+	// Случайный ключ на каждый процесс ломал бы ссылки после перезапуска и между экземплярами
+	if cfg.ExportSigningSecret == "" {
+		return nil, errors.New("EXPORT_SIGNING_SECRET is required")
+	}
+
+	return &ExportService{
+		tx:         tx,
+		jobs:       jobs,
+		exportRepo: exportRepo,
+		userRepo:   userRepo,
+		authRepo:   authRepo,
+		riskRepo:   riskRepo,
+		auditRepo:  auditRepo,
+		storage:    fileStorage,
+		auditLog:   auditLog,
+		cfg:        cfg,
+		secret:     cfg.ExportSigningSecret,
+	}, nil
+}
+
+// RequestExport создает выгрузку данных пользователя и ставит ее сборку в очередь.
+// Не чаще одного раза за ExportRateLimit
+func (s *ExportService) RequestExport(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	ctx, span := tracing.StartChild(ctx, "ExportService.RequestExport")
+	defer span.End()
+
+	export := &models.DataExport{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    models.ExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	var latest *models.DataExport
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// Без блокировки два одновременных запроса оба увидят старую выгрузку и обойдут лимит
+		if err := s.exportRepo.LockUser(ctx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		latest, err = s.exportRepo.GetLatestByUserID(ctx, userID)
+		if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+		if latest != nil && latest.Status != models.ExportStatusFailed &&
+			time.Since(latest.CreatedAt) < s.cfg.ExportRateLimit {
+			return ErrExportRateLimited
+		}
+
+		if err := s.exportRepo.Create(ctx, export); err != nil {
+			return err
+		}
+		err = s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.ExportRequested,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Metadata:   map[string]interface{}{"export_id": export.ID},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, JobBuildExport, BuildExportPayload{ExportID: export.ID})
+		return err
+	})
+	if errors.Is(err, ErrExportRateLimited) {
+		return latest, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// NextAllowedAt возвращает время, когда пользователь сможет запросить новую выгрузку
+func (s *ExportService) NextAllowedAt(latest *models.DataExport) time.Time {
+	return latest.CreatedAt.Add(s.cfg.ExportRateLimit)
+}
+
+func (s *ExportService) GetExport(ctx context.Context, userID, exportID uuid.UUID) (*models.DataExport, error) {
+	ctx, span := tracing.StartChild(ctx, "ExportService.GetExport")
+	defer span.End()
+
+	export, err := s.exportRepo.GetByID(ctx, exportID)
+	if err != nil {
+		return nil, err
+	}
+	if export.UserID != userID {
+		return nil, apperrors.ErrNotFound
+	}
+	return export, nil
+}
+
+// DownloadURL возвращает подписанную ссылку на архив, действующую до истечения срока выгрузки
+func (s *ExportService) DownloadURL(export *models.DataExport) (string, error) {
+	if export.Status != models.ExportStatusCompleted || export.ExpiresAt == nil {
+		return "", apperrors.ErrExportNotReady
+	}
+
+	expires := strconv.FormatInt(export.ExpiresAt.Unix(), 10)
+	signature := utils.GenerateSignature(s.secret, export.ID.String()+":"+expires)
+
+	return fmt.Sprintf("/api/v1/exports/%s/download?expires=%s&signature=%s", export.ID, expires, signature), nil
+}
+
+// OpenSigned проверяет подпись ссылки и открывает архив
+func (s *ExportService) OpenSigned(ctx context.Context, exportID uuid.UUID, expires, signature string) (io.ReadCloser, error) {
+	ctx, span := tracing.StartChild(ctx, "ExportService.OpenSigned")
+	defer span.End()
+
+	if !utils.VerifySignature(s.secret, exportID.String()+":"+expires, signature) {
+		return nil, errInvalidExportLink
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, errInvalidExportLink
+	}
+	if time.Now().After(time.Unix(expiresUnix, 0)) {
+		return nil, apperrors.ErrTokenExpired.WithMessage("error.download_link_expired", "Download link expired")
+	}
+
+	export, err := s.exportRepo.GetByID(ctx, exportID)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != models.ExportStatusCompleted {
+		return nil, apperrors.ErrExportNotReady
+	}
+
+	file, err := s.storage.Open(export.FileKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, apperrors.ErrNotFound.Wrap(err)
+	}
+	return file, err
+}
+
+// BuildExport собирает архив выгрузки; обработчик задачи JobBuildExport. При ошибке задача
+// повторяется, а после последней попытки выгрузка помечается неудавшейся, чтобы ее можно было запросить снова
+func (s *ExportService) BuildExport(ctx context.Context, payload BuildExportPayload) error {
+	ctx, span := tracing.StartChild(ctx, "ExportService.BuildExport")
+	defer span.End()
+
+	export, err := s.exportRepo.GetByID(ctx, payload.ExportID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		// Выгрузка удалена вместе с пользователем
+		return queue.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if export.Status != models.ExportStatusPending {
+		return nil
+	}
+
+	if err := s.build(ctx, export); err != nil {
+		if queue.LastAttempt(ctx) {
+			if markErr := s.exportRepo.MarkFailed(context.WithoutCancel(ctx), export.ID, err.Error()); markErr != nil {
+				logging.FromContext(ctx).Error("failed to mark data export as failed", "export_id", export.ID, "error", markErr)
+			}
+		}
+		return fmt.Errorf("error building data export %s: %w", export.ID, err)
+	}
+	return nil
+}
+
+func (s *ExportService) build(ctx context.Context, export *models.DataExport) error {
+	archive, err := s.buildArchive(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	fileKey := fmt.Sprintf("%s/%s.zip", export.UserID, export.ID)
+	if err := s.storage.Put(fileKey, archive); err != nil {
+		return err
+	}
+	return s.exportRepo.MarkCompleted(ctx, export.ID, fileKey, time.Now().Add(s.cfg.ExportLinkTTL))
+}
+
+// PurgeExpired удаляет архивы и записи выгрузок с истекшей ссылкой, а также неудавшиеся выгрузки.
+// Записи моложе EXPORT_RATE_LIMIT остаются: по последней из них считается лимит запросов
+func (s *ExportService) PurgeExpired(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "ExportService.PurgeExpired")
+	defer span.End()
+
+	now := time.Now()
+	var purged int64
+	for {
+		exports, err := s.exportRepo.ListExpired(ctx, now, now.Add(-s.cfg.ExportRateLimit), exportPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+		for _, export := range exports {
+			// Запись удаляется после файла: если файл удалить не удалось, следующий запуск попробует снова
+			if export.FileKey != "" {
+				if err := s.storage.Delete(export.FileKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return purged, fmt.Errorf("error deleting data export %s archive: %w", export.ID, err)
+				}
+			}
+			if err := s.exportRepo.Delete(ctx, export.ID); err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+				return purged, err
+			}
+			purged++
+		}
+		if len(exports) < exportPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+type exportDocument struct {
+	GeneratedAt  time.Time       `json:"generated_at"`
+	Profile      exportProfile   `json:"profile"`
+	Sessions     []exportSession `json:"sessions"`
+	LoginHistory []exportLogin   `json:"login_history"`
+	Activity     []exportEvent   `json:"activity"`
+}
+
+type exportProfile struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type exportSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+}
+
+type exportLogin struct {
+	CreatedAt time.Time        `json:"created_at"`
+	IPAddress string           `json:"ip_address"`
+	UserAgent string           `json:"user_agent"`
+	Location  *models.GeoPoint `json:"location,omitempty"`
+	RiskScore int              `json:"risk_score"`
+	Signals   []string         `json:"signals"`
+	Outcome   string           `json:"outcome"`
+}
+
+// exportEvent - запись журнала аудита о пользователе. Идентификатор исполнителя не выгружается:
+// это может быть другой пользователь, например администратор, поэтому остается только его роль
+type exportEvent struct {
+	OccurredAt time.Time       `json:"occurred_at"`
+	Action     string          `json:"action"`
+	ActorRole  string          `json:"actor_role,omitempty"`
+	Source     string          `json:"source"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+func (s *ExportService) buildArchive(ctx context.Context, userID uuid.UUID) (*bytes.Buffer, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+
+	sessions, err := s.authRepo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading sessions: %w", err)
+	}
+
+	logins, err := s.riskRepo.ListLogins(ctx, userID, exportLoginLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error loading login history: %w", err)
+	}
+
+	generatedAt := time.Now().UTC()
+	activity, err := s.userActivity(ctx, userID, generatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error loading audit events: %w", err)
+	}
+
+	doc := exportDocument{
+		GeneratedAt: generatedAt,
+		Profile: exportProfile{
+			ID:        user.ID,
+			Email:     user.Email,
+			Username:  user.Username,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Role:      string(user.Role),
+			IsActive:  user.IsActive,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		Sessions:     make([]exportSession, len(sessions)),
+		LoginHistory: make([]exportLogin, len(logins)),
+		Activity:     make([]exportEvent, len(activity)),
+	}
+	// Токены сессий не выгружаем: это секреты, а не данные пользователя
+	for i, session := range sessions {
+		doc.Sessions[i] = exportSession{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			UserAgent: session.UserAgent,
+			IPAddress: session.IPAddress,
+		}
+	}
+	// Отпечаток устройства - внутренний хеш для оценки риска, а не данные пользователя
+	for i, login := range logins {
+		signals := login.Signals
+		if signals == nil {
+			signals = []string{}
+		}
+		doc.LoginHistory[i] = exportLogin{
+			CreatedAt: login.CreatedAt,
+			IPAddress: login.IPAddress,
+			UserAgent: login.UserAgent,
+			Location:  login.Location,
+			RiskScore: login.RiskScore,
+			Signals:   signals,
+			Outcome:   string(login.Outcome),
+		}
+	}
+	for i, event := range activity {
+		doc.Activity[i] = exportEvent{
+			OccurredAt: event.OccurredAt,
+			Action:     event.Action,
+			ActorRole:  event.ActorRole,
+			Source:     event.Source,
+			IPAddress:  event.IPAddress,
+			UserAgent:  event.UserAgent,
+			Changes:    event.Changes,
+			Metadata:   event.Metadata,
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	if err := writeZipJSON(zw, "data.json", doc); err != nil {
+		return nil, err
+	}
+
+	profileRows := [][]string{
+		{"id", "email", "username", "first_name", "last_name", "role", "is_active", "created_at", "updated_at"},
+		{doc.Profile.ID.String(), doc.Profile.Email, doc.Profile.Username, doc.Profile.FirstName, doc.Profile.LastName,
+			doc.Profile.Role, strconv.FormatBool(doc.Profile.IsActive),
+			doc.Profile.CreatedAt.Format(time.RFC3339), doc.Profile.UpdatedAt.Format(time.RFC3339)},
+	}
+	if err := writeZipCSV(zw, "profile.csv", profileRows); err != nil {
+		return nil, err
+	}
+
+	sessionRows := [][]string{{"created_at", "expires_at", "user_agent", "ip_address"}}
+	for _, session := range doc.Sessions {
+		sessionRows = append(sessionRows, []string{
+			session.CreatedAt.Format(time.RFC3339), session.ExpiresAt.Format(time.RFC3339),
+			session.UserAgent, session.IPAddress,
+		})
+	}
+	if err := writeZipCSV(zw, "sessions.csv", sessionRows); err != nil {
+		return nil, err
+	}
+
+	loginRows := [][]string{{"created_at", "ip_address", "user_agent", "latitude", "longitude", "risk_score", "signals", "outcome"}}
+	for _, login := range doc.LoginHistory {
+		var latitude, longitude string
+		if login.Location != nil {
+			latitude = strconv.FormatFloat(login.Location.Latitude, 'f', -1, 64)
+			longitude = strconv.FormatFloat(login.Location.Longitude, 'f', -1, 64)
+		}
+		loginRows = append(loginRows, []string{
+			login.CreatedAt.Format(time.RFC3339), login.IPAddress, login.UserAgent, latitude, longitude,
+			strconv.Itoa(login.RiskScore), strings.Join(login.Signals, ";"), login.Outcome,
+		})
+	}
+	if err := writeZipCSV(zw, "login_history.csv", loginRows); err != nil {
+		return nil, err
+	}
+
+	activityRows := [][]string{{"occurred_at", "action", "actor_role", "source", "ip_address", "user_agent", "changes", "metadata"}}
+	for _, event := range doc.Activity {
+		activityRows = append(activityRows, []string{
+			event.OccurredAt.Format(time.RFC3339), event.Action, event.ActorRole, event.Source,
+			event.IPAddress, event.UserAgent, string(event.Changes), string(event.Metadata),
+		})
+	}
+	if err := writeZipCSV(zw, "activity.csv", activityRows); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// userActivity читает все записи журнала аудита о пользователе до момента before постранично.
+// Граница before не дает записям, появившимся во время сборки, сдвинуть страницы
+func (s *ExportService) userActivity(ctx context.Context, userID uuid.UUID, before time.Time) ([]models.AuditEvent, error) {
+	var activity []models.AuditEvent
+	for {
+		page, _, err := s.auditRepo.List(ctx, models.AuditFilter{
+			Subject: &userID,
+			To:      &before,
+			Limit:   exportAuditPage,
+			Offset:  len(activity),
+		})
+		if err != nil {
+			return nil, err
+		}
+		activity = append(activity, page...)
+		if len(page) < exportAuditPage {
+			return activity, nil
+		}
+	}
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeZipCSV(zw *zip.Writer, name string, rows [][]string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/geoip"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"net/netip"
+	"time"
+)
+
+// ErrInvalidLoginCode - код подтверждения входа неверный, истек или исчерпаны попытки
+var ErrInvalidLoginCode = apperrors.ErrInvalidToken.WithMessage("error.invalid_login_code", "Invalid or expired sign-in code")
+
+// ErrDeviceNotFound - у пользователя нет известного устройства с таким ID
+var ErrDeviceNotFound = apperrors.ErrNotFound.WithMessage("error.device_not_found", "Device not found")
+
+// loginSignalWeights - баллы риска за каждый признак. Новое устройство само по себе не дотягивает
+// до порога подтверждения по умолчанию, а вместе с новой подсетью - дотягивает
+var loginSignalWeights = map[models.LoginSignal]int{
+	models.LoginSignalNewDevice:        40,
+	models.LoginSignalNewSubnet:        20,
+	models.LoginSignalImpossibleTravel: 60,
+	models.LoginSignalUnusualHour:      15,
+}
+
+const (
+	// loginHistoryWindow - сколько последних входов учитывается при оценке
+	loginHistoryWindow = 50
+	// unusualHourMinLogins - меньше входов недостаточно, чтобы судить о привычном времени
+	unusualHourMinLogins = 5
+	// minTravelDistanceKm - расстояние, меньше которого перемещение не проверяется: координаты
+	// GeoIP приблизительны, и соседние адреса одного города могут отстоять на десятки километров
+	minTravelDistanceKm = 100
+	loginCodeDigits     = 6
+	loginCodeAttempts   = 5
+)
+
+// LoginRiskService оценивает риск входа по истории входов пользователя и ведет
+// подтверждение рискованных входов кодом из письма
+type LoginRiskService struct {
+	tx        database.Transactor
+	riskRepo  repository.LoginRiskRepositoryInterface
+	locator   geoip.Locator
+	jobs      queue.Enqueuer
+	auditLog  audit.Recorder
+	templates *mail.Renderer
+	cfg       *config.Config
+}
+
+// NewLoginRiskService создает сервис; locator может быть nil - тогда невозможное перемещение не проверяется
+func NewLoginRiskService(tx database.Transactor, riskRepo repository.LoginRiskRepositoryInterface, locator geoip.Locator,
+	jobs queue.Enqueuer, auditLog audit.Recorder, templates *mail.Renderer, cfg *config.Config) *LoginRiskService {
+	return &LoginRiskService{
+		tx:        tx,
+		riskRepo:  riskRepo,
+		locator:   locator,
+		jobs:      jobs,
+		auditLog:  auditLog,
+		templates: templates,
+		cfg:       cfg,
+	}
+}
+
+// Assess оценивает вход пользователя с адреса ip и User-Agent userAgent. Первый вход
+// пользователя без истории ничего не с чем сравнить, поэтому он не считается рискованным
+func (s *LoginRiskService) Assess(ctx context.Context, userID uuid.UUID, ip, userAgent string) (*models.LoginAssessment, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.Assess")
+	defer span.End()
+
+	now := time.Now()
+	assessment := &models.LoginAssessment{
+		Fingerprint: utils.DeviceFingerprint(userAgent),
+		Subnet:      utils.IPSubnet(ip),
+		Location:    s.locate(ip),
+	}
+
+	history, err := s.riskRepo.RecentLogins(ctx, userID, loginHistoryWindow)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		assessment.Decision = models.LoginDecisionAllow
+		return assessment, nil
+	}
+
+	known, err := s.riskRepo.IsKnownDevice(ctx, userID, assessment.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		assessment.Signals = append(assessment.Signals, models.LoginSignalNewDevice)
+	}
+
+	if assessment.Subnet != "" {
+		seen, err := s.riskRepo.HasLoginFromSubnet(ctx, userID, assessment.Subnet)
+		if err != nil {
+			return nil, err
+		}
+		if !seen {
+			assessment.Signals = append(assessment.Signals, models.LoginSignalNewSubnet)
+		}
+	}
+
+	if s.impossibleTravel(assessment.Location, history, now) {
+		assessment.Signals = append(assessment.Signals, models.LoginSignalImpossibleTravel)
+	}
+	if unusualHour(history, now) {
+		assessment.Signals = append(assessment.Signals, models.LoginSignalUnusualHour)
+	}
+
+	for _, signal := range assessment.Signals {
+		assessment.Score += loginSignalWeights[signal]
+	}
+	assessment.Decision = s.decide(assessment.Score)
+	return assessment, nil
+}
+
+func (s *LoginRiskService) decide(score int) models.LoginDecision {
+	switch {
+	case s.cfg.LoginRiskBlockThreshold > 0 && score >= s.cfg.LoginRiskBlockThreshold:
+		return models.LoginDecisionBlock
+	case s.cfg.LoginRiskChallengeThreshold > 0 && score >= s.cfg.LoginRiskChallengeThreshold:
+		return models.LoginDecisionChallenge
+	}
+	return models.LoginDecisionAllow
+}
+
+func (s *LoginRiskService) locate(ip string) *models.GeoPoint {
+	if s.locator == nil {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	point, ok := s.locator.Locate(addr)
+	if !ok {
+		return nil
+	}
+	return &models.GeoPoint{Latitude: point.Latitude, Longitude: point.Longitude}
+}
+
+// impossibleTravel сравнивает место входа с местом последнего входа, для которого известны координаты:
+// чтобы успеть между ними, пришлось бы двигаться быстрее LoginMaxTravelSpeed
+func (s *LoginRiskService) impossibleTravel(location *models.GeoPoint, history []models.LoginRecord, now time.Time) bool {
+	if location == nil || s.cfg.LoginMaxTravelSpeed <= 0 {
+		return false
+	}
+	for _, previous := range history {
+		if previous.Location == nil {
+			continue
+		}
+		distance := geoip.DistanceKm(
+			geoip.Point{Latitude: previous.Location.Latitude, Longitude: previous.Location.Longitude},
+			geoip.Point{Latitude: location.Latitude, Longitude: location.Longitude},
+		)
+		if distance < minTravelDistanceKm {
+			return false
+		}
+		hours := now.Sub(previous.CreatedAt).Hours()
+		return distance > hours*float64(s.cfg.LoginMaxTravelSpeed)
+	}
+	return false
+}
+
+// unusualHour сообщает, что ни один из прошлых входов не был в пределах часа от текущего времени суток (UTC)
+func unusualHour(history []models.LoginRecord, now time.Time) bool {
+	if len(history) < unusualHourMinLogins {
+		return false
+	}
+	hour := now.UTC().Hour()
+	for _, previous := range history {
+		diff := previous.CreatedAt.UTC().Hour() - hour
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= 1 || diff >= 23 {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordLogin записывает вход в историю; успешный вход запоминает устройство
+func (s *LoginRiskService) RecordLogin(ctx context.Context, userID uuid.UUID, ip, userAgent string,
+	assessment *models.LoginAssessment, outcome models.LoginOutcome) error {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.RecordLogin")
+	defer span.End()
+
+	now := time.Now()
+	record := &models.LoginRecord{
+		ID:          uuid.New(),
+		UserID:      userID,
+		IPAddress:   ip,
+		UserAgent:   userAgent,
+		Fingerprint: assessment.Fingerprint,
+		Subnet:      assessment.Subnet,
+		Location:    assessment.Location,
+		RiskScore:   assessment.Score,
+		Signals:     assessment.SignalNames(),
+		Outcome:     outcome,
+		CreatedAt:   now,
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.riskRepo.RecordLogin(ctx, record); err != nil {
+			return err
+		}
+		if outcome != models.LoginOutcomeAllowed && outcome != models.LoginOutcomeVerified {
+			return nil
+		}
+		return s.riskRepo.UpsertKnownDevice(ctx, &models.KnownDevice{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Fingerprint: assessment.Fingerprint,
+			UserAgent:   userAgent,
+			LastIP:      ip,
+			FirstSeen:   now,
+			LastSeen:    now,
+		})
+	})
+}
+
+// StartChallenge отправляет на почту пользователя код подтверждения входа.
+// Код привязан к устройству, с которого начат вход
+func (s *LoginRiskService) StartChallenge(ctx context.Context, user *models.User, ip, userAgent string,
+	assessment *models.LoginAssessment) (*models.LoginChallenge, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.StartChallenge")
+	defer span.End()
+
+	code, err := utils.GenerateNumericCode(loginCodeDigits)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge := &models.LoginChallenge{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Fingerprint: assessment.Fingerprint,
+		IPAddress:   ip,
+		UserAgent:   userAgent,
+		RiskScore:   assessment.Score,
+		Signals:     assessment.SignalNames(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.cfg.LoginChallengeTTL),
+	}
+	challenge.CodeHash = loginCodeHash(challenge.ID, code)
+
+	locale := i18n.Resolve("", user.Locale, i18n.Locale(s.cfg.DefaultLocale))
+	msg, err := s.templates.Render(locale, "login_challenge", user.Email, i18n.Args{
+		"name":    user.FirstName,
+		"code":    code,
+		"time":    now.UTC().Format("2006-01-02 15:04 MST"),
+		"device":  utils.DescribeDevice(userAgent),
+		"ip":      ip,
+		"minutes": int(s.cfg.LoginChallengeTTL.Minutes()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.riskRepo.CreateLoginChallenge(ctx, challenge); err != nil {
+			return err
+		}
+		if err := s.RecordLogin(ctx, user.ID, ip, userAgent, assessment, models.LoginOutcomeChallenged); err != nil {
+			return err
+		}
+		_, err := s.jobs.Enqueue(ctx, JobSendEmail, msg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CheckChallenge проверяет код подтверждения входа. Каждая проверка сначала атомарно расходует
+// попытку, поэтому даже параллельные запросы проверяют не больше loginCodeAttempts вариантов кода
+func (s *LoginRiskService) CheckChallenge(ctx context.Context, challengeID uuid.UUID, code, userAgent string) (*models.LoginChallenge, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.CheckChallenge")
+	defer span.End()
+
+	challenge, err := s.riskRepo.GetLoginChallenge(ctx, challengeID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if challenge.VerifiedAt != nil || challenge.Attempts >= loginCodeAttempts ||
+		challenge.Fingerprint != utils.DeviceFingerprint(userAgent) {
+		return nil, ErrInvalidLoginCode
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, apperrors.ErrTokenExpired
+	}
+
+	attempts, err := s.riskRepo.UseLoginChallengeAttempt(ctx, challenge.ID, loginCodeAttempts)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	challenge.Attempts = attempts
+
+	expected := loginCodeHash(challenge.ID, code)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(challenge.CodeHash)) != 1 {
+		return nil, ErrInvalidLoginCode
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge отмечает код использованным; из двух одновременных подтверждений проходит одно,
+// и ни одно - если попытки уже исчерпаны
+func (s *LoginRiskService) CompleteChallenge(ctx context.Context, challengeID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.CompleteChallenge")
+	defer span.End()
+
+	err := s.riskRepo.MarkLoginChallengeVerified(ctx, challengeID, loginCodeAttempts)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return ErrInvalidLoginCode
+	}
+	return err
+}
+
+func (s *LoginRiskService) ListDevices(ctx context.Context, userID uuid.UUID) ([]models.KnownDevice, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.ListDevices")
+	defer span.End()
+
+	return s.riskRepo.ListKnownDevices(ctx, userID)
+}
+
+// ForgetDevice удаляет устройство из известных: следующий вход с него оценивается как с нового
+func (s *LoginRiskService) ForgetDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.ForgetDevice")
+	defer span.End()
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		err := s.riskRepo.DeleteKnownDevice(ctx, userID, deviceID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return ErrDeviceNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.DeviceForgotten,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Metadata:   map[string]interface{}{"device_id": deviceID},
+		})
+	})
+}
+
+// ListLogins возвращает последние входы пользователя с оценкой риска, включая заблокированные
+func (s *LoginRiskService) ListLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.ListLogins")
+	defer span.End()
+
+	return s.riskRepo.ListLogins(ctx, userID, limit)
+}
+
+// PurgeHistory удаляет историю входов старше LoginHistoryTTL и отработавшие коды подтверждения
+func (s *LoginRiskService) PurgeHistory(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "LoginRiskService.PurgeHistory")
+	defer span.End()
+
+	deleted, err := s.riskRepo.DeleteLoginHistoryBefore(ctx, time.Now().Add(-s.cfg.LoginHistoryTTL))
+	if err != nil {
+		return 0, err
+	}
+	challenges, err := s.riskRepo.DeleteExpiredLoginChallenges(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return deleted + challenges, nil
+}
+
+// loginCodeHash привязывает хеш к коду подтверждения: одинаковые коды разных входов хешируются по-разному
+func loginCodeHash(challengeID uuid.UUID, code string) string {
+	return utils.HashToken(challengeID.String() + ":" + code)
+}
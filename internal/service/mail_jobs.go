@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/queue"
+)
+
+// JobSendEmail - отправка письма через очередь: сбой почтового сервера не ломает запрос,
+// а письмо повторяется с backoff
+const JobSendEmail = "mail.send"
+
+// SendEmailHandler возвращает обработчик задач JobSendEmail. Письма, которые не будут
+// доставлены и при повторе (неверный адрес, отказ сервера), сразу уходят в dead letter
+func SendEmailHandler(mailer mail.Mailer) func(ctx context.Context, msg mail.Message) error {
+	return func(ctx context.Context, msg mail.Message) error {
+		err := mailer.Send(ctx, msg)
+		if mail.IsPermanent(err) {
+			return queue.Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var errInvalidSecurityLink = apperrors.ErrInvalidToken.WithMessage("error.invalid_security_link", "Invalid security link")
+
+// alertDeliveryRetention - сколько помнить обработанные события: повторная доставка события из outbox
+// приходит за минуты или часы, а не за недели
+const alertDeliveryRetention = 30 * 24 * time.Hour
+
+// securityAlert - письмо, в которое превращается событие
+type securityAlert struct {
+	kind   models.SecurityAlert
+	userID uuid.UUID
+	// to - адрес получателя, если письмо идет не на текущий email пользователя
+	to     string
+	client events.Client
+	args   i18n.Args
+}
+
+// SecurityService рассылает уведомления безопасности и ведет сброс пароля по ссылке «это был не я»
+type SecurityService struct {
+	tx           database.Transactor
+	userRepo     repository.UserRepositoryInterface
+	securityRepo repository.SecurityRepositoryInterface
+	authService  *AuthService
+	jobs         queue.Enqueuer
+	auditLog     audit.Recorder
+	templates    *mail.Renderer
+	cfg          *config.Config
+}
+
+func NewSecurityService(tx database.Transactor, userRepo repository.UserRepositoryInterface,
+	securityRepo repository.SecurityRepositoryInterface, authService *AuthService, jobs queue.Enqueuer,
+	auditLog audit.Recorder, templates *mail.Renderer, cfg *config.Config) *SecurityService {
+	return &SecurityService{
+		tx:           tx,
+		userRepo:     userRepo,
+		securityRepo: securityRepo,
+		authService:  authService,
+		jobs:         jobs,
+		auditLog:     auditLog,
+		templates:    templates,
+		cfg:          cfg,
+	}
+}
+
+// AlertSettings возвращает включенность каждого типа уведомлений
+func (s *SecurityService) AlertSettings(ctx context.Context, userID uuid.UUID) (map[models.SecurityAlert]bool, error) {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.AlertSettings")
+	defer span.End()
+
+	disabled, err := s.securityRepo.GetDisabledAlerts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[models.SecurityAlert]bool, len(models.SecurityAlerts))
+	for _, alert := range models.SecurityAlerts {
+		settings[alert] = true
+	}
+	for _, alert := range disabled {
+		if _, ok := settings[models.SecurityAlert(alert)]; ok {
+			settings[models.SecurityAlert(alert)] = false
+		}
+	}
+	return settings, nil
+}
+
+// UpdateAlertSettings меняет настройки переданных уведомлений, остальные остаются как были
+func (s *SecurityService) UpdateAlertSettings(ctx context.Context, userID uuid.UUID, changes map[models.SecurityAlert]bool) (map[models.SecurityAlert]bool, error) {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.UpdateAlertSettings")
+	defer span.End()
+
+	for alert := range changes {
+		if !alert.Valid() {
+			allowed := make([]string, len(models.SecurityAlerts))
+			for i, known := range models.SecurityAlerts {
+				allowed[i] = string(known)
+			}
+			return nil, apperrors.Validation([]apperrors.FieldError{{
+				Field:   "alerts." + string(alert),
+				Rule:    "oneof",
+				Param:   strings.Join(allowed, " "),
+				Message: "must be one of: " + strings.Join(allowed, " "),
+			}})
+		}
+	}
+
+	settings, err := s.AlertSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[models.SecurityAlert]bool, len(settings))
+	for alert, enabled := range settings {
+		before[alert] = enabled
+	}
+	for alert, enabled := range changes {
+		settings[alert] = enabled
+	}
+
+	var disabled []string
+	for _, alert := range models.SecurityAlerts {
+		if !settings[alert] {
+			disabled = append(disabled, string(alert))
+		}
+	}
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.securityRepo.SetDisabledAlerts(ctx, userID, disabled); err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.AlertsUpdated,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Changes:    audit.Diff(before, settings),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// HandleEvent - подписчик шины событий: ставит в очередь уведомление, если событие рискованное
+// и пользователь его не отключил. Повторно полученное событие второе письмо не ставит
+func (s *SecurityService) HandleEvent(ctx context.Context, event events.Event) error {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.HandleEvent")
+	defer span.End()
+
+	alert, err := securityAlertFor(event)
+	if err != nil || alert == nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, alert.userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	settings, err := s.AlertSettings(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !settings[alert.kind] {
+		return nil
+	}
+
+	to := alert.to
+	if to == "" {
+		to = user.Email
+	}
+	locale := i18n.Resolve("", user.Locale, i18n.Locale(s.cfg.DefaultLocale))
+
+	notMe, link, err := s.newNotMeToken(user.ID, to)
+	if err != nil {
+		return err
+	}
+	args := i18n.Args{
+		"name":   user.FirstName,
+		"time":   event.OccurredAt.UTC().Format("2006-01-02 15:04 MST"),
+		"device": utils.DescribeDevice(alert.client.UserAgent),
+		"ip":     alert.client.IPAddress,
+		"link":   link,
+	}
+	for key, value := range alert.args {
+		args[key] = value
+	}
+	if device, ok := args["session_device"].(string); ok && device == "" {
+		args["session_device"] = i18n.T(locale, "email.security.unknown_device", nil)
+	}
+
+	msg, err := s.templates.Render(locale, "security_"+string(alert.kind), to, args)
+	if err != nil {
+		return err
+	}
+	// Отметка о событии пишется в одной транзакции с письмом: повторная доставка события после отправки
+	// письма ничего не поставит, а откат не оставит отметку без письма
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		first, err := s.securityRepo.RecordAlertDelivery(ctx, event.ID, user.ID, alert.kind)
+		if err != nil || !first {
+			return err
+		}
+		if err := s.securityRepo.CreateNotMeToken(ctx, notMe); err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, JobSendEmail, msg)
+		return err
+	})
+}
+
+// securityAlertFor определяет, о каком событии нужно предупредить. Действия, которые пользователь
+// заведомо совершил сам (выход, отмена смены email, ссылка «это был не я»), уведомлений не порождают
+func securityAlertFor(event events.Event) (*securityAlert, error) {
+	switch event.Type {
+	case events.UserLoggedIn:
+		var payload events.UserLoggedInPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, err
+		}
+		// Вход с привычного устройства и из привычной сети оценка риска не отметила ничем
+		if payload.RiskScore != nil && len(payload.Signals) == 0 {
+			return nil, nil
+		}
+		return &securityAlert{
+			kind:   models.SecurityAlertNewLogin,
+			userID: payload.UserID,
+			client: events.Client{IPAddress: payload.IPAddress, UserAgent: payload.UserAgent},
+		}, nil
+
+	case events.UserPasswordReset:
+		var payload events.UserPasswordResetPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return &securityAlert{kind: models.SecurityAlertPasswordChanged, userID: payload.UserID, client: payload.Client}, nil
+
+	case events.UserEmailChanged:
+		var payload events.UserEmailChangedPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Reverted {
+			return nil, nil
+		}
+		// Новый адрес мог указать злоумышленник, поэтому предупреждаем старый
+		return &securityAlert{
+			kind:   models.SecurityAlertEmailChanged,
+			userID: payload.UserID,
+			to:     payload.OldEmail,
+			client: payload.Client,
+			args:   i18n.Args{"new_email": payload.NewEmail},
+		}, nil
+
+	case events.SessionRevoked:
+		var payload events.SessionRevokedPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Reason != events.RevokeUser {
+			return nil, nil
+		}
+		return &securityAlert{
+			kind:   models.SecurityAlertSessionRevoked,
+			userID: payload.UserID,
+			client: payload.Client,
+			args:   i18n.Args{"session_device": utils.DescribeDevice(payload.SessionUserAgent)},
+		}, nil
+	}
+	return nil, nil
+}
+
+// newNotMeToken создает одноразовую ссылку «это был не я» для уведомления на адрес email.
+// В ссылке только случайный токен: пользователь и адрес хранятся на сервере
+func (s *SecurityService) newNotMeToken(userID uuid.UUID, email string) (*models.NotMeToken, string, error) {
+	token, err := utils.GenerateSessionToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	notMe := &models.NotMeToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.SecurityLinkTTL),
+	}
+	link := fmt.Sprintf("%s/api/v1/security/not-me?token=%s", strings.TrimRight(s.cfg.PublicBaseURL, "/"), token)
+	return notMe, link, nil
+}
+
+// CheckNotMe проверяет, что ссылку «это был не я» еще можно использовать. Переход по ссылке из письма
+// только проверяет ее: сессии завершает POST в ReportCompromise
+func (s *SecurityService) CheckNotMe(ctx context.Context, token string) (*models.NotMeToken, error) {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.CheckNotMe")
+	defer span.End()
+
+	notMe, err := s.securityRepo.GetNotMeTokenByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, errInvalidSecurityLink
+	}
+	if notMe.UsedAt != nil {
+		return nil, errInvalidSecurityLink
+	}
+	if time.Now().After(notMe.ExpiresAt) {
+		return nil, apperrors.ErrTokenExpired
+	}
+	return notMe, nil
+}
+
+// ReportCompromise обрабатывает ссылку «это был не я»: завершает все сессии пользователя
+// и отправляет ссылку сброса пароля. Ссылка одноразовая
+func (s *SecurityService) ReportCompromise(ctx context.Context, token string) error {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.ReportCompromise")
+	defer span.End()
+
+	notMe, err := s.CheckNotMe(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, notMe.UserID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return errInvalidSecurityLink
+	}
+	if err != nil {
+		return err
+	}
+	to, err := s.resetRecipient(ctx, user, notMe.Email)
+	if err != nil {
+		return err
+	}
+
+	resetToken, err := utils.GenerateSessionToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	reset := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(resetToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.PasswordResetTTL),
+	}
+
+	locale := i18n.Resolve("", user.Locale, i18n.Locale(s.cfg.DefaultLocale))
+	msg, err := s.templates.Render(locale, "password_reset", to, i18n.Args{
+		"name":    user.FirstName,
+		"link":    fmt.Sprintf("%s/api/v1/password/reset?token=%s", strings.TrimRight(s.cfg.PublicBaseURL, "/"), resetToken),
+		"minutes": int(s.cfg.PasswordResetTTL.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Новая ссылка сброса гасит предыдущие
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		err := s.securityRepo.MarkNotMeTokenUsed(ctx, notMe.ID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return errInvalidSecurityLink
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.authService.RevokeAllSessions(ctx, user.ID, events.RevokeCompromised); err != nil {
+			return err
+		}
+		if err := s.securityRepo.InvalidatePasswordResets(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := s.securityRepo.CreatePasswordReset(ctx, reset); err != nil {
+			return err
+		}
+		err = s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.CompromiseReported,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Metadata:   map[string]interface{}{"email": to},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, JobSendEmail, msg)
+		return err
+	})
+}
+
+// resetRecipient выбирает адрес для ссылки сброса пароля. Адрес уведомления годится, если это текущий
+// email пользователя или адрес, с которого email сменили подтвержденным запросом: после захвата учетной
+// записи сброс должен уйти прежнему владельцу. Иначе ссылка уходит на текущий email
+func (s *SecurityService) resetRecipient(ctx context.Context, user *models.User, alertEmail string) (string, error) {
+	if strings.EqualFold(alertEmail, user.Email) {
+		return user.Email, nil
+	}
+	previous, err := s.securityRepo.IsConfirmedPreviousEmail(ctx, user.ID, alertEmail)
+	if err != nil {
+		return "", err
+	}
+	if previous {
+		return alertEmail, nil
+	}
+	return user.Email, nil
+}
+
+// CheckPasswordReset проверяет, что ссылку сброса пароля еще можно использовать
+func (s *SecurityService) CheckPasswordReset(ctx context.Context, token string) (*models.PasswordResetToken, error) {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.CheckPasswordReset")
+	defer span.End()
+
+	reset, err := s.securityRepo.GetPasswordResetByTokenHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+	if reset.UsedAt != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+	if time.Now().After(reset.ExpiresAt) {
+		return nil, apperrors.ErrTokenExpired
+	}
+	return reset, nil
+}
+
+// ResetPassword задает новый пароль по одноразовой ссылке
+func (s *SecurityService) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.ResetPassword")
+	defer span.End()
+
+	reset, err := s.CheckPasswordReset(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		err := s.securityRepo.MarkPasswordResetUsed(ctx, reset.ID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return apperrors.ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		return s.authService.ResetPassword(ctx, reset.UserID, password)
+	})
+}
+
+// PurgePasswordResets удаляет истекшие и использованные ссылки сброса пароля и «это был не я»,
+// а также старые отметки об отправленных уведомлениях
+func (s *SecurityService) PurgePasswordResets(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "SecurityService.PurgePasswordResets")
+	defer span.End()
+
+	resets, err := s.securityRepo.DeleteExpiredPasswordResets(ctx)
+	if err != nil {
+		return 0, err
+	}
+	notMe, err := s.securityRepo.DeleteExpiredNotMeTokens(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deliveries, err := s.securityRepo.DeleteAlertDeliveriesBefore(ctx, time.Now().Add(-alertDeliveryRetention))
+	return resets + notMe + deliveries, err
+}
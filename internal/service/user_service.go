@@ -1,45 +1,270 @@
 package service
 
 import (
+	"context"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
 	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/outbox"
 	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
 	"github.com/google/uuid"
+	"time"
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
+	tx       database.Transactor
+	userRepo repository.UserRepositoryInterface
+	events   outbox.Recorder
+	auditLog audit.Recorder
+	cfg      *config.Config
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
+func NewUserService(tx database.Transactor, userRepo repository.UserRepositoryInterface, events outbox.Recorder,
+	auditLog audit.Recorder, cfg *config.Config) *UserService {
 	return &UserService{
+		tx:       tx,
 		userRepo: userRepo,
+		events:   events,
+		auditLog: auditLog,
+		cfg:      cfg,
 	}
 }
 
-func (s *UserService) GetProfile(userID uuid.UUID) (*models.User, error) {
-	return s.userRepo.GetByID(userID)
+func (s *UserService) GetProfile(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetProfile")
+	defer span.End()
+
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID uuid.UUID, req *models.UpdateProfileRequest) error {
+	ctx, span := tracing.StartChild(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	if req.Username != "" {
+		req.Username = utils.NormalizeUsername(req.Username)
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.Update(ctx, userID, req); err != nil {
+			return err
+		}
+		err = s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.ProfileUpdated,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Changes:    audit.Diff(before, applyProfileUpdate(*before, req)),
+		})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, userID, events.UserUpdated, events.UserUpdatedPayload{
+			UserID: userID,
+			Fields: updatedFields(req),
+		})
+	})
+}
+
+func (s *UserService) GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetByID")
+	defer span.End()
+
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetByEmail")
+	defer span.End()
+
+	return s.userRepo.GetByEmail(ctx, utils.NormalizeEmail(email))
+}
+
+func (s *UserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetByUsername")
+	defer span.End()
+
+	return s.userRepo.GetByUsername(ctx, utils.NormalizeUsername(username))
+}
+
+// SetRole назначает пользователю роль
+func (s *UserService) SetRole(ctx context.Context, userID uuid.UUID, role models.UserRole) error {
+	ctx, span := tracing.StartChild(ctx, "UserService.SetRole")
+	defer span.End()
+
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.UpdateRole(ctx, userID, role); err != nil {
+			return err
+		}
+		err = s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.RoleChanged,
+			TargetType: audit.TargetUser,
+			TargetID:   userID,
+			Changes: audit.Diff(
+				map[string]interface{}{"role": before.Role},
+				map[string]interface{}{"role": role},
+			),
+		})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, userID, events.UserRoleChanged, events.UserRoleChangedPayload{
+			UserID: userID,
+			Role:   string(role),
+		})
+	})
+}
+
+// Deactivate блокирует вход пользователя и завершает его сессии; данные сохраняются
+func (s *UserService) Deactivate(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "UserService.Deactivate")
+	defer span.End()
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Deactivate(ctx, userID); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{Action: audit.UserDeactivated, TargetType: audit.TargetUser, TargetID: userID})
+		if err != nil {
+			return err
+		}
+		err = s.events.Record(ctx, events.AggregateUser, userID, events.UserDeactivated, events.UserPayload{UserID: userID})
+		if err != nil {
+			return err
+		}
+		return recordSessionsRevoked(ctx, s.events, userID, events.RevokeDeactivated)
+	})
 }
 
-func (s *UserService) UpdateProfile(userID uuid.UUID, req *models.UpdateProfileRequest) error {
-	return s.userRepo.Update(userID, req)
+func (s *UserService) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{Action: audit.UserDeleted, TargetType: audit.TargetUser, TargetID: userID})
+		if err != nil {
+			return err
+		}
+		err = s.events.Record(ctx, events.AggregateUser, userID, events.UserDeleted, events.UserPayload{UserID: userID})
+		if err != nil {
+			return err
+		}
+		return recordSessionsRevoked(ctx, s.events, userID, events.RevokeDeleted)
+	})
 }
 
-func (s *UserService) GetByID(userID uuid.UUID) (*models.User, error) {
-	return s.userRepo.GetByID(userID)
+// RestoreUser восстанавливает удаленного пользователя, пока не истек срок хранения
+func (s *UserService) RestoreUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "UserService.RestoreUser")
+	defer span.End()
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Restore(ctx, userID, time.Now().Add(-s.cfg.UserDeletionRetention)); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{Action: audit.UserRestored, TargetType: audit.TargetUser, TargetID: userID})
+		if err != nil {
+			return err
+		}
+		return s.events.Record(ctx, events.AggregateUser, userID, events.UserRestored, events.UserPayload{UserID: userID})
+	})
 }
 
-func (s *UserService) GetByEmail(email string) (*models.User, error) {
-	return s.userRepo.GetByEmail(email)
+// PurgeDeletedUsers окончательно удаляет пользователей, у которых истек срок хранения
+func (s *UserService) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.PurgeDeletedUsers")
+	defer span.End()
+
+	var purged []uuid.UUID
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		purged, err = s.userRepo.PurgeDeleted(ctx, time.Now().Add(-s.cfg.UserDeletionRetention))
+		if err != nil || len(purged) == 0 {
+			return err
+		}
+		// Одна запись на запуск: кого удалили окончательно, видно по списку ID
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.UsersPurged,
+			TargetType: audit.TargetUser,
+			Metadata:   map[string]interface{}{"count": len(purged), "user_ids": purged},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(purged)), nil
 }
 
-func (s *UserService) GetByUsername(username string) (*models.User, error) {
-	return s.userRepo.GetByUsername(username)
+func (s *UserService) GetDeletedUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetDeletedUsers")
+	defer span.End()
+
+	return s.userRepo.GetDeleted(ctx)
 }
 
-func (s *UserService) DeleteUser(userID uuid.UUID) error {
-	return s.userRepo.Delete(userID)
+func (s *UserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.GetAllUsers")
+	defer span.End()
+
+	return s.userRepo.GetAll(ctx)
+}
+
+func (s *UserService) SearchUsers(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error) {
+	ctx, span := tracing.StartChild(ctx, "UserService.SearchUsers")
+	defer span.End()
+
+	return s.userRepo.Search(ctx, query, limit)
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.userRepo.GetAll()
+// updatedFields - имена полей профиля, переданных в запросе, для события user.updated
+func updatedFields(req *models.UpdateProfileRequest) []string {
+	var fields []string
+	if req.FirstName != "" {
+		fields = append(fields, "first_name")
+	}
+	if req.LastName != "" {
+		fields = append(fields, "last_name")
+	}
+	if req.Username != "" {
+		fields = append(fields, "username")
+	}
+	if req.Locale != "" {
+		fields = append(fields, "locale")
+	}
+	return fields
+}
+
+// applyProfileUpdate - профиль после обновления: пустые поля запроса не меняются
+func applyProfileUpdate(user models.User, req *models.UpdateProfileRequest) models.User {
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		user.LastName = req.LastName
+	}
+	if req.Username != "" {
+		user.Username = req.Username
+	}
+	if req.Locale != "" {
+		user.Locale = req.Locale
+	}
+	return user
 }
@@ -0,0 +1,517 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JobDeliverWebhook - одна попытка доставки вебхука; повторы с backoff делает очередь
+const JobDeliverWebhook = "webhook.deliver"
+
+// Заголовки запроса вебхука. Подпись - HMAC-SHA256 с секретом endpoint от "<timestamp>.<body>":
+// получатель проверяет подпись и отбрасывает запросы со старой меткой времени
+const (
+	WebhookHeaderID        = "X-Webhook-ID"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// webhookResponseLimit - сколько байт ответа получателя сохраняется в журнале попыток
+const webhookResponseLimit = 1024
+
+// DeliverWebhookPayload - payload задачи JobDeliverWebhook
+type DeliverWebhookPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+type WebhookService struct {
+	tx       database.Transactor
+	repo     repository.WebhookRepositoryInterface
+	jobs     queue.Enqueuer
+	auditLog audit.Recorder
+	client   *http.Client
+	cfg      *config.Config
+}
+
+func NewWebhookService(tx database.Transactor, repo repository.WebhookRepositoryInterface, jobs queue.Enqueuer,
+	auditLog audit.Recorder, cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		tx:       tx,
+		repo:     repo,
+		jobs:     jobs,
+		auditLog: auditLog,
+		client: &http.Client{
+			Timeout: cfg.WebhookTimeout,
+			// Редирект считается неудачей: подписанное тело не должно уходить на другой адрес
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+	}
+}
+
+// SignWebhook возвращает значение заголовка X-Webhook-Signature
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	return "v1=" + utils.GenerateSignature(secret, strconv.FormatInt(timestamp, 10)+"."+string(body))
+}
+
+// CreateEndpoint регистрирует endpoint; секрет генерируется, если не задан
+func (s *WebhookService) CreateEndpoint(ctx context.Context, req *models.CreateWebhookRequest) (*models.WebhookEndpoint, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.CreateEndpoint")
+	defer span.End()
+
+	if err := validateWebhookEvents(req.Events); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	endpoint := &models.WebhookEndpoint{
+		ID:          uuid.New(),
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Secret:      secret,
+		IsActive:    true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.WebhookCreated,
+			TargetType: audit.TargetWebhook,
+			TargetID:   endpoint.ID,
+			Changes:    audit.Diff(nil, endpoint),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (s *WebhookService) GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.GetEndpoint")
+	defer span.End()
+
+	return s.repo.GetEndpoint(ctx, id)
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.ListEndpoints")
+	defer span.End()
+
+	return s.repo.ListEndpoints(ctx)
+}
+
+// UpdateEndpoint меняет endpoint. Включение сбрасывает счетчик неудач, отключение вручную
+// останавливает доставки так же, как автоматическое
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, id uuid.UUID, req *models.UpdateWebhookRequest) (*models.WebhookEndpoint, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.UpdateEndpoint")
+	defer span.End()
+
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *endpoint
+
+	if req.URL != nil {
+		endpoint.URL = *req.URL
+	}
+	if req.Description != nil {
+		endpoint.Description = *req.Description
+	}
+	if req.Events != nil {
+		endpoint.Events = req.Events
+	}
+	if req.IsActive != nil && *req.IsActive != endpoint.IsActive {
+		endpoint.IsActive = *req.IsActive
+		if endpoint.IsActive {
+			endpoint.ConsecutiveFailures = 0
+			endpoint.DisabledAt = nil
+			endpoint.DisabledReason = nil
+		} else {
+			now := time.Now()
+			reason := "disabled by administrator"
+			endpoint.DisabledAt = &now
+			endpoint.DisabledReason = &reason
+		}
+	}
+	endpoint.UpdatedAt = time.Now()
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateEndpoint(ctx, endpoint); err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.WebhookUpdated,
+			TargetType: audit.TargetWebhook,
+			TargetID:   endpoint.ID,
+			Changes:    audit.Diff(before, endpoint),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// RotateSecret заменяет секрет подписи; старый перестает действовать сразу
+func (s *WebhookService) RotateSecret(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.RotateSecret")
+	defer span.End()
+
+	endpoint, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Secret, err = generateWebhookSecret(); err != nil {
+		return nil, err
+	}
+	endpoint.UpdatedAt = time.Now()
+
+	// Секрет в журнал не попадает: записывается только факт замены
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpdateEndpoint(ctx, endpoint); err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.WebhookRotated,
+			TargetType: audit.TargetWebhook,
+			TargetID:   endpoint.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// DeleteEndpoint удаляет endpoint вместе с журналом доставок; задачи в очереди завершатся без отправки
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.DeleteEndpoint")
+	defer span.End()
+
+	endpoint, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteEndpoint(ctx, id); err != nil {
+			return err
+		}
+		return s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.WebhookDeleted,
+			TargetType: audit.TargetWebhook,
+			TargetID:   id,
+			Changes:    audit.Diff(endpoint, nil),
+		})
+	})
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.ListDeliveries")
+	defer span.End()
+
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// GetDelivery возвращает доставку endpoint и журнал ее попыток
+func (s *WebhookService) GetDelivery(ctx context.Context, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, []models.WebhookAttempt, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.GetDelivery")
+	defer span.End()
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, nil, apperrors.ErrNotFound
+	}
+
+	attempts, err := s.repo.ListAttempts(ctx, deliveryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return delivery, attempts, nil
+}
+
+// Redeliver отправляет доставку заново с тем же телом и ID события, в том числе успешную.
+// Счетчик попыток очереди начинается заново
+func (s *WebhookService) Redeliver(ctx context.Context, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.Redeliver")
+	defer span.End()
+
+	endpoint, err := s.repo.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.IsActive {
+		return nil, apperrors.ErrWebhookDisabled
+	}
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, apperrors.ErrNotFound
+	}
+
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetDeliveryStatus(ctx, delivery.ID, models.WebhookDeliveryPending, delivery.LastError); err != nil {
+			return err
+		}
+		err := s.auditLog.Record(ctx, audit.Entry{
+			Action:     audit.WebhookRedelivered,
+			TargetType: audit.TargetWebhook,
+			TargetID:   endpointID,
+			Metadata:   map[string]interface{}{"delivery_id": delivery.ID, "event_type": delivery.EventType},
+		})
+		if err != nil {
+			return err
+		}
+		return s.enqueue(ctx, delivery.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	return delivery, nil
+}
+
+// HandleEvent - подписчик шины событий: создает доставки для подписанных endpoint.
+// Повторно полученное событие не создает новых доставок
+func (s *WebhookService) HandleEvent(ctx context.Context, event events.Event) error {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.HandleEvent")
+	defer span.End()
+
+	endpoints, err := s.repo.ListSubscribedEndpoints(ctx, event.Type)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		for _, endpoint := range endpoints {
+			delivery := &models.WebhookDelivery{
+				ID:         uuid.New(),
+				EndpointID: endpoint.ID,
+				EventID:    event.ID,
+				EventType:  event.Type,
+				Payload:    body,
+				CreatedAt:  time.Now(),
+			}
+			created, err := s.repo.CreateDelivery(ctx, delivery)
+			if err != nil {
+				return err
+			}
+			if !created {
+				continue
+			}
+			if err := s.enqueue(ctx, delivery.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deliver - обработчик задач JobDeliverWebhook: одна подписанная отправка и запись попытки в журнал.
+// Ошибка возвращается в очередь для повтора; после WebhookDisableAfter неудач подряд endpoint отключается
+func (s *WebhookService) Deliver(ctx context.Context, payload DeliverWebhookPayload) error {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.Deliver")
+	defer span.End()
+
+	delivery, err := s.repo.GetDelivery(ctx, payload.DeliveryID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		// Endpoint удален вместе с доставками
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status == models.WebhookDeliverySucceeded {
+		return nil
+	}
+
+	endpoint, err := s.repo.GetEndpoint(ctx, delivery.EndpointID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !endpoint.IsActive {
+		reason := "endpoint is disabled"
+		return s.repo.SetDeliveryStatus(ctx, delivery.ID, models.WebhookDeliveryFailed, &reason)
+	}
+
+	attempt := s.send(ctx, endpoint, delivery)
+	if attempt.Error == nil {
+		if err := s.repo.RecordAttempt(ctx, attempt, models.WebhookDeliverySucceeded); err != nil {
+			return err
+		}
+		return s.repo.ResetEndpointFailures(ctx, endpoint.ID)
+	}
+
+	reason := fmt.Sprintf("%d consecutive failed deliveries, last: %s", s.cfg.WebhookDisableAfter, *attempt.Error)
+	disabled, err := s.repo.RecordEndpointFailure(ctx, endpoint.ID, s.cfg.WebhookDisableAfter, reason)
+	if err != nil {
+		return err
+	}
+
+	status := models.WebhookDeliveryPending
+	if disabled || queue.LastAttempt(ctx) {
+		status = models.WebhookDeliveryFailed
+	}
+	if err := s.repo.RecordAttempt(ctx, attempt, status); err != nil {
+		return err
+	}
+
+	if disabled {
+		logging.FromContext(ctx).Warn("webhook endpoint disabled after consecutive failures",
+			"endpoint_id", endpoint.ID, "failures", s.cfg.WebhookDisableAfter)
+		return nil
+	}
+	return fmt.Errorf("webhook delivery %s to %s failed: %s", delivery.ID, endpoint.URL, *attempt.Error)
+}
+
+// PurgeDeliveries удаляет завершенные доставки старше WebhookRetention
+func (s *WebhookService) PurgeDeliveries(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartChild(ctx, "WebhookService.PurgeDeliveries")
+	defer span.End()
+
+	return s.repo.DeleteDeliveriesBefore(ctx, time.Now().Add(-s.cfg.WebhookRetention))
+}
+
+// send выполняет запрос и возвращает попытку для журнала; Error заполнен при любой неудаче
+func (s *WebhookService) send(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) *models.WebhookAttempt {
+	started := time.Now()
+	attempt := &models.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts + 1,
+		CreatedAt:  started,
+	}
+	fail := func(err error) *models.WebhookAttempt {
+		message := err.Error()
+		attempt.Error = &message
+		attempt.DurationMs = time.Since(started).Milliseconds()
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fail(err)
+	}
+	timestamp := started.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "devprep-webhooks")
+	req.Header.Set(WebhookHeaderID, delivery.ID.String())
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhook(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	responseBody := strings.ToValidUTF8(string(body), "")
+	attempt.ResponseStatus = &resp.StatusCode
+	attempt.ResponseBody = &responseBody
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fail(fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	return attempt
+}
+
+// enqueue ставит попытку доставки; ключ уникальности не дает поставить вторую, пока первая ждет
+func (s *WebhookService) enqueue(ctx context.Context, deliveryID uuid.UUID) error {
+	_, err := s.jobs.Enqueue(ctx, JobDeliverWebhook, DeliverWebhookPayload{DeliveryID: deliveryID},
+		queue.WithUniqueKey(deliveryID.String()), queue.WithMaxAttempts(s.cfg.WebhookMaxAttempts))
+	return err
+}
+
+// validateWebhookEvents проверяет список событий подписки: известные типы или "*"
+func validateWebhookEvents(eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return apperrors.Validation([]apperrors.FieldError{{
+			Field:   "events",
+			Rule:    "required",
+			Message: "is required",
+		}})
+	}
+
+	for _, eventType := range eventTypes {
+		if eventType != models.WebhookAllEvents && !events.Known(eventType) {
+			allowed := models.WebhookAllEvents + " " + strings.Join(events.Types, " ")
+			return apperrors.Validation([]apperrors.FieldError{{
+				Field:   "events",
+				Rule:    "oneof",
+				Param:   allowed,
+				Message: "must be one of: " + allowed,
+			}})
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	token, err := utils.GenerateSessionToken()
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
@@ -0,0 +1,108 @@
+// Package tracing настраивает OpenTelemetry: провайдер трасс с экспортом в OTLP или stdout
+// и распространение контекста через заголовок W3C traceparent. HTTP-запросы, методы сервисов,
+// транзакции и SQL-запросы начинают span через Start
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"strings"
+)
+
+// Экспортеры трасс для TRACING_EXPORTER
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	serviceName         = "devprep"
+	instrumentationName = "github.com/AtlasOpx/devprep"
+)
+
+// Setup ставит глобальный пропагатор W3C (traceparent и baggage) и, если экспортер задан,
+// глобальный провайдер трасс. stdout пишет span в w. Без экспортера span не записываются,
+// но ID входящей трассы все равно доходят до логов. Возвращает функцию, которая
+// при остановке отправляет накопленные span
+func Setup(ctx context.Context, cfg *config.Config, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return nil, fmt.Errorf("invalid tracing sample ratio %v: must be between 0 and 1", cfg.TracingSampleRatio)
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.TracingExporter) {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		// Адрес, заголовки и TLS берутся из стандартных OTEL_EXPORTER_OTLP_*
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q: must be %q, %q or %q", cfg.TracingExporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трассировщик приложения из глобального провайдера
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start начинает span name, дочерний для span из ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// StartChild начинает span, только если в ctx уже есть записываемый span. Иначе возвращает
+// span из ctx, End которого ничего не делает: фоновые запросы вне трассы не порождают корневых span
+func StartChild(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if parent := trace.SpanFromContext(ctx); !parent.IsRecording() {
+		return ctx, parent
+	}
+	return Start(ctx, name, opts...)
+}
+
+// End завершает span, отмечая его ошибочным, если err не nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
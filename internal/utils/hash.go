@@ -4,34 +4,40 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"github.com/AtlasOpx/devprep/internal/metrics"
 	"golang.org/x/crypto/argon2"
 	"strings"
+	"time"
 )
 
 const (
 	saltLength = 16
 	keyLength  = 32
-	time       = 1
+	iterations = 1
 	memory     = 64 * 1024
 	threads    = 4
 )
 
 func HashPassword(password string) (string, error) {
+	defer metrics.ObservePasswordHash("hash", time.Now())
+
 	salt := make([]byte, saltLength)
 
 	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLength)
+	hash := argon2.IDKey([]byte(password), salt, iterations, memory, threads, keyLength)
 
 	saltEncoded := base64.RawStdEncoding.EncodeToString(salt)
 	hashEncoded := base64.RawStdEncoding.EncodeToString(hash)
 
-	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, time, threads, saltEncoded, hashEncoded), nil
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, iterations, threads, saltEncoded, hashEncoded), nil
 }
 
 func CheckPasswordHash(password, hashedPassword string) bool {
+	defer metrics.ObservePasswordHash("verify", time.Now())
+
 	parts := strings.Split(hashedPassword, "$")
 	if len(parts) != 6 {
 		return false
@@ -47,7 +53,7 @@ func CheckPasswordHash(password, hashedPassword string) bool {
 		return false
 	}
 
-	newHash := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLength)
+	newHash := argon2.IDKey([]byte(password), salt, iterations, memory, threads, keyLength)
 
 	if len(hash) != len(newHash) {
 		return false
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+// HighlightMatches оборачивает в <mark> все вхождения слов запроса в value
+// без учета регистра. Текст вне тегов экранируется, поэтому результат
+// можно безопасно вставлять в HTML. Второе значение - было ли хоть одно совпадение.
+func HighlightMatches(value, query string) (string, bool) {
+	text := []rune(value)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(text))
+	found := false
+	for _, term := range strings.Fields(query) {
+		needle := []rune(term)
+		for i, r := range needle {
+			needle[i] = unicode.ToLower(r)
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if equalRunes(lower[i:i+len(needle)], needle) {
+				for j := i; j < i+len(needle); j++ {
+					marked[j] = true
+				}
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return html.EscapeString(value), false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		chunk := html.EscapeString(string(text[i:j]))
+		if marked[i] {
+			b.WriteString(highlightOpen + chunk + highlightClose)
+		} else {
+			b.WriteString(chunk)
+		}
+		i = j
+	}
+
+	return b.String(), true
+}
+
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
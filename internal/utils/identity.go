@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// NormalizeEmail приводит email к каноническому виду: NFKC, без пробелов по краям, в нижнем регистре.
+// Так Bob@x.com и bob@x.com считаются одним адресом
+func NormalizeEmail(email string) string {
+	return normalizeIdentifier(email)
+}
+
+// NormalizeUsername приводит username к каноническому виду по тем же правилам, что и email
+func NormalizeUsername(username string) string {
+	return normalizeIdentifier(username)
+}
+
+func normalizeIdentifier(value string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(value)))
+}
@@ -0,0 +1,23 @@
+package utils
+
+import "net/netip"
+
+// IPSubnet возвращает подсеть адреса: /24 для IPv4 и /48 для IPv6. Адреса одной подсети
+// обычно принадлежат одному провайдеру и месту. Пустая строка, если адрес не разобрать
+func IPSubnet(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 )
 
 func GenerateSessionToken() (string, error) {
@@ -14,3 +16,19 @@ func GenerateSessionToken() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// HashToken возвращает SHA-256 от одноразового токена: в БД храним только хеш
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateNumericCode возвращает случайный код из digits цифр для подтверждения по почте
+func GenerateNumericCode(digits int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", fmt.Errorf("error generating code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateSignature возвращает HMAC-SHA256 от payload в hex
+func GenerateSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature сравнивает подпись за постоянное время
+func VerifySignature(secret, payload, signature string) bool {
+	expected := GenerateSignature(secret, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
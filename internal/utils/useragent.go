@@ -0,0 +1,64 @@
+package utils
+
+import "strings"
+
+// Признаки браузеров и систем в User-Agent. Порядок важен: Edge и Opera притворяются Chrome,
+// Chrome - Safari, Android - Linux, а iOS - macOS
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	userAgentSystems = []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DescribeDevice возвращает короткое описание устройства по User-Agent, например
+// "Chrome (macOS)". Пустая строка, если ни браузер, ни систему распознать не удалось
+func DescribeDevice(userAgent string) string {
+	browser := matchUserAgent(userAgent, userAgentBrowsers)
+	system := matchUserAgent(userAgent, userAgentSystems)
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " (" + system + ")"
+	case browser != "":
+		return browser
+	default:
+		return system
+	}
+}
+
+func matchUserAgent(userAgent string, candidates []struct{ token, name string }) string {
+	for _, candidate := range candidates {
+		if strings.Contains(userAgent, candidate.token) {
+			return candidate.name
+		}
+	}
+	return ""
+}
+
+// DeviceFingerprint возвращает отпечаток устройства по User-Agent. Цифры отбрасываются,
+// чтобы обновление браузера не превращало устройство в новое
+func DeviceFingerprint(userAgent string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return -1
+		}
+		return r
+	}, userAgent)
+	return HashToken(strings.TrimSpace(stripped))
+}
@@ -1,43 +1,91 @@
 package utils
 
-import "github.com/go-playground/validator/v10"
-
-type (
-	ErrorResponse struct {
-		Error       bool
-		FailedField string
-		Tag         string
-		Value       interface{}
-	}
+import (
+	"errors"
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"regexp"
+	"strings"
+)
 
-	XValidator struct {
-		Validator *validator.Validate
-	}
+type XValidator struct {
+	Validator *validator.Validate
+}
 
-	GlobalErrorHandlerResp struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-	}
-)
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
 
-var validate = validator.New()
+// reservedUsernames - имена, которые нельзя занять: они путают пользователей или служебные URL
+var reservedUsernames = map[string]struct{}{
+	"admin": {}, "administrator": {}, "root": {}, "system": {}, "support": {},
+	"moderator": {}, "api": {}, "auth": {}, "user": {}, "users": {},
+	"me": {}, "null": {}, "undefined": {}, "devprep": {},
+}
 
-func (v XValidator) Validate(data interface{}) []ErrorResponse {
-	var validationErrors []ErrorResponse
+var validate = newValidator()
 
-	errs := validate.Struct(data)
-	if errs != nil {
-		for _, err := range errs.(validator.ValidationErrors) {
-			var elem ErrorResponse
+// NewXValidator возвращает валидатор с зарегистрированными кастомными правилами
+func NewXValidator() XValidator {
+	return XValidator{Validator: validate}
+}
 
-			elem.FailedField = err.Field()
-			elem.Tag = err.Tag()
-			elem.Value = err.Value()
-			elem.Error = true
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
 
-			validationErrors = append(validationErrors, elem)
+	// В ошибках используем имена из json-тегов, а не имена полей Go
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
 		}
+		return name
+	})
+
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("notreserved", func(fl validator.FieldLevel) bool {
+		_, reserved := reservedUsernames[NormalizeUsername(fl.Field().String())]
+		return !reserved
+	})
+
+	return v
+}
+
+func (v XValidator) Validate(data interface{}) []apperrors.FieldError {
+	var validationErrors []apperrors.FieldError
+
+	errs := v.Validator.Struct(data)
+	if errs == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(errs, &fieldErrs) {
+		return []apperrors.FieldError{{Rule: "invalid", Message: errs.Error()}}
+	}
+
+	for _, err := range fieldErrs {
+		validationErrors = append(validationErrors, apperrors.FieldError{
+			Field:   err.Field(),
+			Rule:    err.Tag(),
+			Message: ValidationMessage(i18n.DefaultLocale, err.Tag(), err.Param()),
+			Param:   err.Param(),
+		})
 	}
 
 	return validationErrors
 }
+
+// ValidationMessage возвращает текст ошибки для правила валидации на нужном языке
+func ValidationMessage(locale i18n.Locale, rule, param string) string {
+	key := "validation." + rule
+	if !i18n.Has(key) {
+		key = "validation.invalid"
+	}
+	return i18n.T(locale, key, i18n.Args{"param": param, "rule": rule})
+}
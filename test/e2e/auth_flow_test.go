@@ -10,9 +10,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AtlasOpx/devprep/internal/app"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/database"
 	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/handlers"
 	"github.com/AtlasOpx/devprep/internal/routes"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -48,6 +50,7 @@ func (suite *AuthFlowTestSuite) SetupSuite() {
 		ReadTimeout:   30 * time.Second,
 		WriteTimeout:  30 * time.Second,
 		IdleTimeout:   120 * time.Second,
+		ErrorHandler:  handlers.ErrorHandler,
 	})
 
 	suite.app.Use(cors.New(cors.Config{
@@ -56,7 +59,13 @@ func (suite *AuthFlowTestSuite) SetupSuite() {
 		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
 	}))
 
-	routes.SetupRoutes(suite.app, suite.db, suite.cfg)
+	suite.cfg.ExportStorageDir = suite.T().TempDir()
+	suite.cfg.ExportSigningSecret = "e2e-export-secret"
+	deps, err := app.NewDependencies(suite.db, suite.cfg)
+	if err != nil {
+		suite.T().Fatalf("Failed to create dependencies: %v", err)
+	}
+	routes.SetupRoutes(suite.app, deps)
 }
 
 func (suite *AuthFlowTestSuite) TearDownSuite() {
@@ -300,7 +309,7 @@ func (suite *AuthFlowTestSuite) TestInvalidRegistrationAttempts() {
 				LastName:  "User",
 				Password:  "password123",
 			},
-			expected: http.StatusBadRequest,
+			expected: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "Empty username",
@@ -311,7 +320,7 @@ func (suite *AuthFlowTestSuite) TestInvalidRegistrationAttempts() {
 				LastName:  "User",
 				Password:  "password123",
 			},
-			expected: http.StatusBadRequest,
+			expected: http.StatusUnprocessableEntity,
 		},
 		{
 			name: "Short password",
@@ -322,7 +331,7 @@ func (suite *AuthFlowTestSuite) TestInvalidRegistrationAttempts() {
 				LastName:  "User",
 				Password:  "123",
 			},
-			expected: http.StatusBadRequest,
+			expected: http.StatusUnprocessableEntity,
 		},
 	}
 
@@ -363,7 +372,7 @@ func (suite *AuthFlowTestSuite) TestDuplicateEmailRegistration() {
 
 	resp2, err := suite.app.Test(req2)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), http.StatusBadRequest, resp2.StatusCode)
+	assert.Equal(suite.T(), http.StatusConflict, resp2.StatusCode)
 }
 
 func extractSessionToken(cookieHeader string) string {
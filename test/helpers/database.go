@@ -1,13 +1,14 @@
 package helpers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/migrator"
 	_ "github.com/lib/pq"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
@@ -71,17 +72,9 @@ func SetupTestDatabase() (*TestDatabase, error) {
 		return nil, fmt.Errorf("could not connect to docker: %s", err)
 	}
 
-	cfg := &config.Config{
-		DatabaseURL: databaseURL,
-	}
-
-	dbWrapper, err := database.Connect(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("could not connect to test database: %s", err)
-	}
-
-	err = runMigrations(dbWrapper.DB)
-	if err != nil {
+	// Схема создается теми же встроенными миграциями, что и в приложении
+	dbWrapper := database.New(db)
+	if err = runMigrations(dbWrapper.DB); err != nil {
 		return nil, fmt.Errorf("could not run migrations: %s", err)
 	}
 
@@ -108,6 +101,10 @@ func (td *TestDatabase) CleanupData() error {
 	}
 
 	queries := []string{
+		"DELETE FROM job_runs",
+		"DELETE FROM jobs",
+		"DELETE FROM outbox",
+		"DELETE FROM webhook_endpoints",
 		"DELETE FROM sessions",
 		"DELETE FROM users",
 	}
@@ -123,43 +120,11 @@ func (td *TestDatabase) CleanupData() error {
 }
 
 func runMigrations(db *sql.DB) error {
-	migrations := []string{
-		`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`,
-		`CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			username VARCHAR(100) UNIQUE NOT NULL,
-			first_name VARCHAR(100) NOT NULL,
-			last_name VARCHAR(100) NOT NULL,
-			password_hash VARCHAR(255) NOT NULL,
-			role VARCHAR(50) NOT NULL DEFAULT 'user',
-			is_active BOOLEAN NOT NULL DEFAULT true,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE TABLE IF NOT EXISTS sessions (
-			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			token VARCHAR(255) UNIQUE NOT NULL,
-			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			user_agent TEXT,
-			ip_address INET,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at)`,
-	}
-
-	for _, migration := range migrations {
-		_, err := db.Exec(migration)
-		if err != nil {
-			return fmt.Errorf("failed to execute migration: %s", err)
-		}
+	m, err := migrator.New(context.Background(), db)
+	if err != nil {
+		return err
 	}
+	defer m.Close()
 
-	return nil
+	return m.Up()
 }
@@ -10,10 +10,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/AtlasOpx/devprep/internal/audit"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/database"
 	"github.com/AtlasOpx/devprep/internal/dto"
 	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/outbox"
 	"github.com/AtlasOpx/devprep/internal/repository"
 	"github.com/AtlasOpx/devprep/internal/service"
 	"github.com/gofiber/fiber/v2"
@@ -46,10 +48,12 @@ func (suite *AuthHandlerTestSuite) SetupSuite() {
 
 	suite.userRepo = repository.NewUserRepository(suite.db).(*repository.UserRepository)
 	suite.authRepo = repository.NewAuthRepository(suite.db).(*repository.AuthRepository)
-	suite.authService = service.NewAuthService(suite.userRepo, suite.authRepo)
-	suite.authHandler = handlers.NewAuthHandler(suite.authService, suite.authRepo, suite.cfg)
+	eventOutbox := outbox.New(repository.NewOutboxRepository(suite.db), suite.db, nil, outbox.Options{})
+	auditLog := audit.New(repository.NewAuditRepository(suite.db))
+	suite.authService = service.NewAuthService(suite.db, suite.userRepo, suite.authRepo, eventOutbox, auditLog, nil)
+	suite.authHandler = handlers.NewAuthHandler(suite.authService, suite.cfg)
 
-	suite.app = fiber.New()
+	suite.app = fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
 	suite.setupRoutes()
 }
 
@@ -127,7 +131,21 @@ func (suite *AuthHandlerTestSuite) TestRegister_InvalidInput() {
 
 	resp, err := suite.app.Test(req)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	assert.Equal(suite.T(), "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var response dto.ProblemResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	assert.NoError(suite.T(), err)
+
+	fields := make(map[string]string)
+	for _, fieldErr := range response.Errors {
+		fields[fieldErr.Field] = fieldErr.Rule
+	}
+	assert.Equal(suite.T(), "email", fields["email"])
+	assert.Equal(suite.T(), "required", fields["username"])
+	assert.Equal(suite.T(), "min", fields["password"])
 }
 
 func (suite *AuthHandlerTestSuite) TestRegister_DuplicateEmail() {
@@ -156,7 +174,13 @@ func (suite *AuthHandlerTestSuite) TestRegister_DuplicateEmail() {
 
 	resp2, err := suite.app.Test(req2)
 	assert.NoError(suite.T(), err)
-	assert.Equal(suite.T(), http.StatusBadRequest, resp2.StatusCode)
+	assert.Equal(suite.T(), http.StatusConflict, resp2.StatusCode)
+
+	var problem dto.ProblemResponse
+	err = json.NewDecoder(resp2.Body).Decode(&problem)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "email_taken", problem.Code)
+	assert.Equal(suite.T(), handlers.ProblemTypeBase+"email-taken", problem.Type)
 }
 
 func (suite *AuthHandlerTestSuite) TestLogin_Success() {
@@ -8,11 +8,13 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/AtlasOpx/devprep/internal/audit"
 	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/database"
 	"github.com/AtlasOpx/devprep/internal/dto"
 	"github.com/AtlasOpx/devprep/internal/handlers"
 	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/AtlasOpx/devprep/internal/outbox"
 	"github.com/AtlasOpx/devprep/internal/repository"
 	"github.com/AtlasOpx/devprep/internal/service"
 	"github.com/gofiber/fiber/v2"
@@ -48,12 +50,14 @@ func (suite *UserHandlerTestSuite) SetupSuite() {
 
 	suite.userRepo = repository.NewUserRepository(suite.db).(*repository.UserRepository)
 	suite.authRepo = repository.NewAuthRepository(suite.db).(*repository.AuthRepository)
-	suite.userService = service.NewUserService(suite.userRepo)
-	suite.authService = service.NewAuthService(suite.userRepo, suite.authRepo)
+	eventOutbox := outbox.New(repository.NewOutboxRepository(suite.db), suite.db, nil, outbox.Options{})
+	auditLog := audit.New(repository.NewAuditRepository(suite.db))
+	suite.userService = service.NewUserService(suite.db, suite.userRepo, eventOutbox, auditLog, suite.cfg)
+	suite.authService = service.NewAuthService(suite.db, suite.userRepo, suite.authRepo, eventOutbox, auditLog, nil)
 	suite.userHandler = handlers.NewUserHandler(suite.userService)
-	suite.authHandler = handlers.NewAuthHandler(suite.authService, suite.authRepo, suite.cfg)
+	suite.authHandler = handlers.NewAuthHandler(suite.authService, suite.cfg)
 
-	suite.app = fiber.New()
+	suite.app = fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
 	suite.setupRoutes()
 }
 
@@ -0,0 +1,456 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditRecorder запоминает записи журнала аудита
+type auditRecorder struct {
+	entries []audit.Entry
+	err     error
+}
+
+func (r *auditRecorder) Record(ctx context.Context, entry audit.Entry) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *auditRecorder) actions() []string {
+	var actions []string
+	for _, entry := range r.entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+// last возвращает последнюю запись с действием action
+func (r *auditRecorder) last(t *testing.T, action string) audit.Entry {
+	t.Helper()
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if r.entries[i].Action == action {
+			return r.entries[i]
+		}
+	}
+	require.Failf(t, "audit entry not recorded", "action %s, recorded %v", action, r.actions())
+	return audit.Entry{}
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := models.User{ID: uuid.New(), Username: "alex", FirstName: "Alex", PasswordHash: "old"}
+	after := before
+	after.FirstName = "Alexander"
+	after.PasswordHash = "new"
+
+	changes := audit.Diff(before, after)
+	require.NotNil(t, changes)
+	// Хеш пароля скрыт тегом json:"-" и в журнал не попадает
+	assert.Equal(t, map[string]interface{}{"first_name": "Alex"}, changes.Before)
+	assert.Equal(t, map[string]interface{}{"first_name": "Alexander"}, changes.After)
+
+	assert.Nil(t, audit.Diff(before, before))
+
+	created := audit.Diff(nil, map[string]interface{}{"url": "https://example.com"})
+	require.NotNil(t, created)
+	assert.Empty(t, created.Before)
+	assert.Equal(t, map[string]interface{}{"url": "https://example.com"}, created.After)
+}
+
+func TestAuditNewEvent_FromContext(t *testing.T) {
+	actorID, targetID := uuid.New(), uuid.New()
+	ctx := events.WithClient(context.Background(), events.Client{IPAddress: "203.0.113.7", UserAgent: "curl/8", RequestID: "req-1"})
+	ctx = audit.WithSource(ctx, audit.SourceAPI)
+	ctx = audit.WithActor(ctx, audit.Actor{ID: actorID, Role: models.UserRoleAdmin})
+
+	event, err := audit.NewEvent(ctx, audit.Entry{
+		Action:     audit.RoleChanged,
+		TargetType: audit.TargetUser,
+		TargetID:   targetID,
+		Changes:    audit.Diff(map[string]interface{}{"role": "user"}, map[string]interface{}{"role": "admin"}),
+		Metadata:   map[string]interface{}{"reason": "promotion"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, audit.SourceAPI, event.Source)
+	require.NotNil(t, event.ActorID)
+	assert.Equal(t, actorID, *event.ActorID)
+	assert.Equal(t, "admin", event.ActorRole)
+	require.NotNil(t, event.TargetID)
+	assert.Equal(t, targetID, *event.TargetID)
+	assert.Equal(t, "203.0.113.7", event.IPAddress)
+	assert.Equal(t, "curl/8", event.UserAgent)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.JSONEq(t, `{"before":{"role":"user"},"after":{"role":"admin"}}`, string(event.Changes))
+	assert.JSONEq(t, `{"reason":"promotion"}`, string(event.Metadata))
+}
+
+func TestAuditNewEvent_ActorOverrideAndDefaults(t *testing.T) {
+	userID := uuid.New()
+
+	event, err := audit.NewEvent(context.Background(), audit.Entry{Action: audit.LoginFailed, TargetType: audit.TargetUser})
+	require.NoError(t, err)
+	assert.Equal(t, audit.SourceSystem, event.Source)
+	assert.Nil(t, event.ActorID)
+	assert.Nil(t, event.TargetID)
+	assert.Nil(t, event.Changes)
+	assert.Nil(t, event.Metadata)
+
+	event, err = audit.NewEvent(context.Background(), audit.Entry{
+		Action: audit.LoginSucceeded,
+		Actor:  &audit.Actor{ID: userID, Role: models.UserRoleUser},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, event.ActorID)
+	assert.Equal(t, userID, *event.ActorID)
+}
+
+// fakeAuditStore - таблицы audit_events и audit_checkpoints в памяти
+type fakeAuditStore struct {
+	events      []*models.AuditEvent
+	checkpoints []models.AuditCheckpoint
+}
+
+func (s *fakeAuditStore) Append(ctx context.Context, event *models.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// SealPending ставит в цепочку записи без номера в порядке добавления
+func (s *fakeAuditStore) SealPending(ctx context.Context, limit int, seal func(event *models.AuditEvent, last *models.AuditChainLink) error) (int64, error) {
+	var sealed int64
+	for _, event := range s.events {
+		if event.Seq != 0 || sealed == int64(limit) {
+			continue
+		}
+		last, _ := s.ChainHead(ctx)
+		if err := seal(event, last); err != nil {
+			return sealed, err
+		}
+		sealed++
+	}
+	return sealed, nil
+}
+
+func (s *fakeAuditStore) ChainHead(ctx context.Context) (*models.AuditChainLink, error) {
+	var head *models.AuditChainLink
+	for _, event := range s.events {
+		if event.Seq != 0 && (head == nil || event.Seq > head.Seq) {
+			head = &models.AuditChainLink{Seq: event.Seq, Hash: event.Hash}
+		}
+	}
+	if head == nil {
+		return nil, apperrors.ErrNotFound
+	}
+	return head, nil
+}
+
+// List поддерживает только фильтры по пользователю и времени, новые записи первыми
+func (s *fakeAuditStore) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, int64, error) {
+	var matched []models.AuditEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if filter.Subject != nil && !sameID(event.ActorID, *filter.Subject) && !sameID(event.TargetID, *filter.Subject) {
+			continue
+		}
+		if filter.To != nil && !event.OccurredAt.Before(*filter.To) {
+			continue
+		}
+		matched = append(matched, *event)
+	}
+
+	total := int64(len(matched))
+	if filter.Offset >= len(matched) {
+		return nil, total, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched, total, nil
+}
+
+func sameID(id *uuid.UUID, want uuid.UUID) bool {
+	return id != nil && *id == want
+}
+
+func (s *fakeAuditStore) ListChain(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEvent, error) {
+	var result []models.AuditEvent
+	for _, event := range s.events {
+		if event.Seq > afterSeq && len(result) < limit {
+			result = append(result, *event)
+		}
+	}
+	return result, nil
+}
+
+func (s *fakeAuditStore) CreateCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	s.checkpoints = append(s.checkpoints, *checkpoint)
+	return nil
+}
+
+func (s *fakeAuditStore) LatestCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	if len(s.checkpoints) == 0 {
+		return nil, apperrors.ErrNotFound
+	}
+	checkpoint := s.checkpoints[len(s.checkpoints)-1]
+	return &checkpoint, nil
+}
+
+func (s *fakeAuditStore) ListCheckpoints(ctx context.Context, afterSeq int64) ([]models.AuditCheckpoint, error) {
+	var result []models.AuditCheckpoint
+	for _, checkpoint := range s.checkpoints {
+		if checkpoint.Seq > afterSeq {
+			result = append(result, checkpoint)
+		}
+	}
+	return result, nil
+}
+
+func TestAuditLog_Record(t *testing.T) {
+	store := &fakeAuditStore{}
+	log := audit.New(store)
+
+	ctx := audit.WithSource(context.Background(), audit.SourceCLI)
+	require.NoError(t, log.Record(ctx, audit.Entry{
+		Action:     audit.UserCreated,
+		TargetType: audit.TargetUser,
+		TargetID:   uuid.New(),
+		Metadata:   map[string]interface{}{"role": "admin"},
+	}))
+
+	require.Len(t, store.events, 1)
+	assert.Equal(t, audit.SourceCLI, store.events[0].Source)
+	var metadata map[string]string
+	require.NoError(t, json.Unmarshal(store.events[0].Metadata, &metadata))
+	assert.Equal(t, "admin", metadata["role"])
+}
+
+// chainedStore записывает n действий через журнал и возвращает хранилище
+func chainedStore(t *testing.T, n int) *fakeAuditStore {
+	store := &fakeAuditStore{}
+	log := audit.New(store)
+	for i := 0; i < n; i++ {
+		require.NoError(t, log.Record(context.Background(), audit.Entry{
+			Action:     audit.ProfileUpdated,
+			TargetType: audit.TargetUser,
+			TargetID:   uuid.New(),
+			Changes:    &audit.Changes{Before: map[string]interface{}{"n": i}, After: map[string]interface{}{"n": i + 1}},
+		}))
+	}
+	_, err := audit.NewChain(store, nil).Seal(context.Background())
+	require.NoError(t, err)
+	return store
+}
+
+func TestAuditChain_Seal(t *testing.T) {
+	store := chainedStore(t, 3)
+
+	// Новая запись попадает в журнал без номера и встает в цепочку при следующем проходе
+	require.NoError(t, audit.New(store).Record(context.Background(), audit.Entry{Action: audit.Logout}))
+	pending := store.events[3]
+	assert.Zero(t, pending.Seq)
+	assert.Empty(t, pending.Hash)
+
+	report, err := audit.NewChain(store, nil).Verify(context.Background(), nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report.Broken)
+	assert.Equal(t, int64(3), report.Records)
+
+	sealed, err := audit.NewChain(store, nil).Seal(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), sealed)
+
+	for i, event := range store.events {
+		assert.Equal(t, int64(i+1), event.Seq)
+		assert.Len(t, event.Hash, 64)
+		if i == 0 {
+			assert.Empty(t, event.PrevHash)
+		} else {
+			assert.Equal(t, store.events[i-1].Hash, event.PrevHash)
+		}
+	}
+}
+
+func TestAuditChain_HashIsStable(t *testing.T) {
+	store := chainedStore(t, 1)
+	event := *store.events[0]
+
+	// JSONB переставляет ключи и убирает пробелы, а время возвращается в часовом поясе сессии
+	event.Changes = json.RawMessage(`{"after": {"n": 1},  "before": {"n": 0}}`)
+	event.OccurredAt = event.OccurredAt.In(time.FixedZone("MSK", 3*60*60))
+
+	hash, err := audit.ChainHash(&event)
+	require.NoError(t, err)
+	assert.Equal(t, store.events[0].Hash, hash)
+}
+
+func TestAuditChain_VerifyIntact(t *testing.T) {
+	store := chainedStore(t, 5)
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	chain := audit.NewChain(store, key)
+
+	created, err := chain.Checkpoint(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created)
+
+	// Голова не сдвинулась - новая точка не нужна
+	created, err = chain.Checkpoint(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, created)
+
+	report, err := chain.Verify(context.Background(), chain.PublicKey())
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report.Broken)
+	assert.Equal(t, int64(5), report.Records)
+	assert.Equal(t, int64(5), report.HeadSeq)
+	assert.Equal(t, 1, report.Checkpoints)
+}
+
+func TestAuditChain_VerifyDetectsTampering(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+	t.Run("modified record", func(t *testing.T) {
+		store := chainedStore(t, 5)
+		store.events[2].Action = audit.RoleChanged
+
+		report, err := audit.NewChain(store, key).Verify(context.Background(), nil)
+		require.NoError(t, err)
+		require.NotNil(t, report.Broken)
+		assert.Equal(t, int64(3), report.Broken.Seq)
+		assert.Equal(t, store.events[2].ID, *report.Broken.EventID)
+		assert.Equal(t, int64(2), report.Records)
+	})
+
+	t.Run("deleted record", func(t *testing.T) {
+		store := chainedStore(t, 5)
+		store.events = append(store.events[:1], store.events[2:]...)
+
+		report, err := audit.NewChain(store, key).Verify(context.Background(), nil)
+		require.NoError(t, err)
+		require.NotNil(t, report.Broken)
+		assert.Equal(t, int64(3), report.Broken.Seq)
+		assert.Contains(t, report.Broken.Reason, "missing")
+	})
+
+	t.Run("rehashed chain", func(t *testing.T) {
+		store := chainedStore(t, 5)
+		chain := audit.NewChain(store, key)
+		_, err := chain.Checkpoint(context.Background())
+		require.NoError(t, err)
+
+		// Запись изменена, а хеши всех записей после нее пересчитаны: цепочка сходится,
+		// но не совпадает с подписанной контрольной точкой
+		store.events[1].Action = audit.RoleChanged
+		for i := 1; i < len(store.events); i++ {
+			last := &models.AuditChainLink{Seq: store.events[i-1].Seq, Hash: store.events[i-1].Hash}
+			require.NoError(t, audit.Seal(store.events[i], last))
+		}
+
+		report, err := chain.Verify(context.Background(), chain.PublicKey())
+		require.NoError(t, err)
+		require.NotNil(t, report.Broken)
+		assert.Equal(t, int64(5), report.Broken.Seq)
+		assert.NotNil(t, report.Broken.CheckpointID)
+	})
+
+	t.Run("truncated tail", func(t *testing.T) {
+		store := chainedStore(t, 5)
+		chain := audit.NewChain(store, key)
+		_, err := chain.Checkpoint(context.Background())
+		require.NoError(t, err)
+		store.events = store.events[:3]
+
+		report, err := chain.Verify(context.Background(), chain.PublicKey())
+		require.NoError(t, err)
+		require.NotNil(t, report.Broken)
+		assert.Equal(t, int64(5), report.Broken.Seq)
+		assert.Contains(t, report.Broken.Reason, "log ends at 3")
+	})
+
+	t.Run("untrusted signer", func(t *testing.T) {
+		store := chainedStore(t, 2)
+		forged := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+		_, err := audit.NewChain(store, forged).Checkpoint(context.Background())
+		require.NoError(t, err)
+
+		report, err := audit.NewChain(store, key).Verify(context.Background(), key.Public().(ed25519.PublicKey))
+		require.NoError(t, err)
+		require.NotNil(t, report.Broken)
+		assert.Contains(t, report.Broken.Reason, "untrusted")
+	})
+}
+
+func TestAuditChain_VerifyLegacyRecords(t *testing.T) {
+	// Записи, сделанные до миграции цепочки, не имеют хеша, но номер у них есть
+	store := &fakeAuditStore{events: []*models.AuditEvent{
+		{ID: uuid.New(), Seq: 1, Action: audit.LoginSucceeded},
+		{ID: uuid.New(), Seq: 2, Action: audit.Logout},
+	}}
+	require.NoError(t, audit.New(store).Record(context.Background(), audit.Entry{Action: audit.LoginSucceeded}))
+	_, err := audit.NewChain(store, nil).Seal(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), store.events[2].Seq)
+	assert.Empty(t, store.events[2].PrevHash)
+
+	report, err := audit.NewChain(store, nil).Verify(context.Background(), nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, int64(2), report.Unchained)
+
+	// Запись без хеша после начала цепочки - подмена
+	store.events = append(store.events, &models.AuditEvent{ID: uuid.New(), Seq: 4, Action: audit.Logout})
+	report, err = audit.NewChain(store, nil).Verify(context.Background(), nil)
+	require.NoError(t, err)
+	require.NotNil(t, report.Broken)
+	assert.Equal(t, int64(4), report.Broken.Seq)
+}
+
+func TestAuditChain_CheckpointSignature(t *testing.T) {
+	store := chainedStore(t, 1)
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	_, err := audit.NewChain(store, key).Checkpoint(context.Background())
+	require.NoError(t, err)
+
+	// Точку можно проверить вне системы по опубликованному формату сообщения
+	checkpoint := store.checkpoints[0]
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	require.NoError(t, err)
+	message := fmt.Sprintf("devprep-audit-checkpoint\n%d\n%s\n%s", checkpoint.Seq, checkpoint.Hash,
+		checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano))
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(message), signature))
+}
+
+func TestParseSigningKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+
+	parsed, err := audit.ParseSigningKey(base64.StdEncoding.EncodeToString(seed))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = audit.ParseSigningKey(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = audit.ParseSigningKey(base64.StdEncoding.EncodeToString(seed[:16]))
+	assert.Error(t, err)
+	_, err = audit.ParseSigningKey("not base64!")
+	assert.Error(t, err)
+}
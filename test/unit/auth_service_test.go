@@ -1,28 +1,34 @@
 package unit
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
 
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/events"
 	"github.com/AtlasOpx/devprep/internal/models"
 	"github.com/AtlasOpx/devprep/internal/service"
 	"github.com/AtlasOpx/devprep/internal/utils"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) Create(user *models.User) error {
+func (m *MockUserRepository) Create(ctx context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) GetByID(id uuid.UUID) (*models.User, error) {
+func (m *MockUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -30,7 +36,7 @@ func (m *MockUserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetByEmail(email string) (*models.User, error) {
+func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -38,7 +44,7 @@ func (m *MockUserRepository) GetByEmail(email string) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetByUsername(username string) (*models.User, error) {
+func (m *MockUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	args := m.Called(username)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -46,31 +52,71 @@ func (m *MockUserRepository) GetByUsername(username string) (*models.User, error
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(id uuid.UUID, req *models.UpdateProfileRequest) error {
+func (m *MockUserRepository) Update(ctx context.Context, id uuid.UUID, req *models.UpdateProfileRequest) error {
 	args := m.Called(id, req)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) Delete(id uuid.UUID) error {
+func (m *MockUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) GetAll() ([]models.User, error) {
+func (m *MockUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
 	args := m.Called()
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, id uuid.UUID, oldEmail, newEmail string) error {
+	args := m.Called(id, oldEmail, newEmail)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role models.UserRole) error {
+	args := m.Called(id, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	args := m.Called(id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Deactivate(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Restore(ctx context.Context, id uuid.UUID, deletedAfter time.Time) error {
+	args := m.Called(id, deletedAfter)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error) {
+	args := m.Called(deletedBefore)
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockUserRepository) GetDeleted(ctx context.Context) ([]models.User, error) {
+	args := m.Called()
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Search(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]models.UserSearchResult), args.Error(1)
+}
+
 type MockAuthRepository struct {
 	mock.Mock
 }
 
-func (m *MockAuthRepository) CreateSession(userID uuid.UUID, token string, expiresAt time.Time, userAgent, ipAddress string) error {
+func (m *MockAuthRepository) CreateSession(ctx context.Context, userID uuid.UUID, token string, expiresAt time.Time, userAgent, ipAddress string) error {
 	args := m.Called(userID, token, expiresAt, userAgent, ipAddress)
 	return args.Error(0)
 }
 
-func (m *MockAuthRepository) GetSessionByToken(token string) (*models.Session, error) {
+func (m *MockAuthRepository) GetSessionByToken(ctx context.Context, token string) (*models.Session, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -78,7 +124,7 @@ func (m *MockAuthRepository) GetSessionByToken(token string) (*models.Session, e
 	return args.Get(0).(*models.Session), args.Error(1)
 }
 
-func (m *MockAuthRepository) ValidateSession(token string) (*models.User, error) {
+func (m *MockAuthRepository) ValidateSession(ctx context.Context, token string) (*models.User, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -86,20 +132,70 @@ func (m *MockAuthRepository) ValidateSession(token string) (*models.User, error)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockAuthRepository) DeleteSession(token string) error {
+func (m *MockAuthRepository) DeleteSession(ctx context.Context, token string) error {
 	args := m.Called(token)
 	return args.Error(0)
 }
 
-func (m *MockAuthRepository) CleanupExpiredSessions() error {
+func (m *MockAuthRepository) CountActiveSessions(ctx context.Context) (int64, error) {
 	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAuthRepository) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAuthRepository) GetSessionsByUserID(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockAuthRepository) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(userID)
 	return args.Error(0)
 }
 
+// fakeTx выполняет тело транзакции сразу, без базы
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn database.TxFunc) error {
+	return fn(ctx)
+}
+
+type recordedEvent struct {
+	aggregateID uuid.UUID
+	eventType   string
+	payload     interface{}
+}
+
+// eventRecorder запоминает записанные в outbox события
+type eventRecorder struct {
+	events []recordedEvent
+	err    error
+}
+
+func (r *eventRecorder) Record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, eventType string, payload interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.events = append(r.events, recordedEvent{aggregateID: aggregateID, eventType: eventType, payload: payload})
+	return nil
+}
+
+func (r *eventRecorder) types() []string {
+	var types []string
+	for _, event := range r.events {
+		types = append(types, event.eventType)
+	}
+	return types
+}
+
 func TestAuthService_Register_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
 
 	req := &models.RegisterRequest{
 		Email:     "test@example.com",
@@ -110,9 +206,10 @@ func TestAuthService_Register_Success(t *testing.T) {
 	}
 
 	mockUserRepo.On("GetByEmail", req.Email).Return(nil, sql.ErrNoRows)
+	mockUserRepo.On("GetByUsername", req.Username).Return(nil, sql.ErrNoRows)
 	mockUserRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
 
-	userID, err := authService.Register(req)
+	userID, err := authService.Register(context.Background(), req)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, userID)
@@ -122,7 +219,7 @@ func TestAuthService_Register_Success(t *testing.T) {
 func TestAuthService_Register_UserAlreadyExists(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
 
 	req := &models.RegisterRequest{
 		Email:     "test@example.com",
@@ -139,18 +236,73 @@ func TestAuthService_Register_UserAlreadyExists(t *testing.T) {
 
 	mockUserRepo.On("GetByEmail", req.Email).Return(existingUser, nil)
 
-	userID, err := authService.Register(req)
+	userID, err := authService.Register(context.Background(), req)
 
 	assert.Error(t, err)
 	assert.Nil(t, userID)
-	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, apperrors.ErrEmailTaken, err)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Register_NormalizesIdentifiers(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	req := &models.RegisterRequest{
+		Email:     "  Bob@Example.COM ",
+		Username:  "Bob",
+		FirstName: "Bob",
+		LastName:  "User",
+		Password:  "password123",
+	}
+
+	mockUserRepo.On("GetByEmail", "bob@example.com").Return(nil, sql.ErrNoRows)
+	mockUserRepo.On("GetByUsername", "bob").Return(nil, sql.ErrNoRows)
+	mockUserRepo.On("Create", mock.MatchedBy(func(user *models.User) bool {
+		return user.Email == "bob@example.com" && user.Username == "bob"
+	})).Return(nil)
+
+	userID, err := authService.Register(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, userID)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Register_UsernameTaken(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	req := &models.RegisterRequest{
+		Email:     "new@example.com",
+		Username:  "testuser",
+		FirstName: "Test",
+		LastName:  "User",
+		Password:  "password123",
+	}
+
+	existingUser := &models.User{
+		ID:       uuid.New(),
+		Username: req.Username,
+	}
+
+	mockUserRepo.On("GetByEmail", req.Email).Return(nil, sql.ErrNoRows)
+	mockUserRepo.On("GetByUsername", req.Username).Return(existingUser, nil)
+
+	userID, err := authService.Register(context.Background(), req)
+
+	assert.Nil(t, userID)
+	assert.Equal(t, apperrors.ErrUsernameTaken, err)
 	mockUserRepo.AssertExpectations(t)
 }
 
 func TestAuthService_Login_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	auditLog := &auditRecorder{}
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, auditLog, nil)
 
 	req := &models.LoginRequest{
 		Email:    "test@example.com",
@@ -166,21 +318,34 @@ func TestAuthService_Login_Success(t *testing.T) {
 		IsActive:     true,
 	}
 
+	req.UserAgent, req.IPAddress = "curl/8.0", "10.0.0.1"
+
 	mockUserRepo.On("GetByEmail", req.Email).Return(user, nil)
+	mockAuthRepo.On("CreateSession", user.ID, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time"), "curl/8.0", "10.0.0.1").Return(nil)
 
-	response, err := authService.Login(req)
+	response, err := authService.Login(context.Background(), req)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, response)
 	assert.Equal(t, "Login successful", response.Message)
 	assert.Equal(t, user.ID, response.User.ID)
+	assert.NotEmpty(t, response.SessionToken)
+	assert.True(t, response.SessionExpiresAt.After(time.Now()))
 	mockUserRepo.AssertExpectations(t)
+	mockAuthRepo.AssertExpectations(t)
+
+	// До входа в контексте нет пользователя, поэтому исполнитель передается явно
+	entry := auditLog.last(t, audit.LoginSucceeded)
+	require.NotNil(t, entry.Actor)
+	assert.Equal(t, user.ID, entry.Actor.ID)
+	assert.Equal(t, user.ID, entry.TargetID)
 }
 
 func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	auditLog := &auditRecorder{}
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, auditLog, nil)
 
 	req := &models.LoginRequest{
 		Email:    "test@example.com",
@@ -198,38 +363,60 @@ func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 
 	mockUserRepo.On("GetByEmail", req.Email).Return(user, nil)
 
-	response, err := authService.Login(req)
+	response, err := authService.Login(context.Background(), req)
 
 	assert.Error(t, err)
 	assert.Nil(t, response)
-	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, apperrors.ErrInvalidCredentials, err)
 	mockUserRepo.AssertExpectations(t)
+
+	entry := auditLog.last(t, audit.LoginFailed)
+	assert.Equal(t, user.ID, entry.TargetID)
+	assert.Equal(t, "invalid_password", entry.Metadata["reason"])
 }
 
 func TestAuthService_Login_UserNotFound(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	auditLog := &auditRecorder{}
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, auditLog, nil)
 
 	req := &models.LoginRequest{
 		Email:    "nonexistent@example.com",
 		Password: "password123",
 	}
 
-	mockUserRepo.On("GetByEmail", req.Email).Return(nil, sql.ErrNoRows)
+	mockUserRepo.On("GetByEmail", req.Email).Return(nil, apperrors.ErrNotFound)
 
-	response, err := authService.Login(req)
+	response, err := authService.Login(context.Background(), req)
 
 	assert.Error(t, err)
 	assert.Nil(t, response)
-	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, apperrors.ErrInvalidCredentials, err)
 	mockUserRepo.AssertExpectations(t)
+
+	entry := auditLog.last(t, audit.LoginFailed)
+	assert.Equal(t, uuid.Nil, entry.TargetID)
+	assert.Equal(t, "unknown_email", entry.Metadata["reason"])
+	assert.Equal(t, req.Email, entry.Metadata["email"])
+}
+
+func TestAuthService_Login_FailsWhenAuditNotRecorded(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{},
+		&auditRecorder{err: sql.ErrConnDone}, nil)
+
+	mockUserRepo.On("GetByEmail", "test@example.com").Return(nil, apperrors.ErrNotFound)
+
+	_, err := authService.Login(context.Background(), &models.LoginRequest{Email: "test@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, sql.ErrConnDone)
 }
 
 func TestAuthService_Login_InactiveUser(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
 
 	req := &models.LoginRequest{
 		Email:    "test@example.com",
@@ -247,25 +434,154 @@ func TestAuthService_Login_InactiveUser(t *testing.T) {
 
 	mockUserRepo.On("GetByEmail", req.Email).Return(user, nil)
 
-	response, err := authService.Login(req)
+	response, err := authService.Login(context.Background(), req)
 
 	assert.Error(t, err)
 	assert.Nil(t, response)
-	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, apperrors.ErrAccountDisabled, err)
 	mockUserRepo.AssertExpectations(t)
 }
 
 func TestAuthService_Logout_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
 	mockAuthRepo := new(MockAuthRepository)
-	authService := service.NewAuthService(mockUserRepo, mockAuthRepo)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
 
 	sessionToken := "valid-session-token"
 
+	mockAuthRepo.On("GetSessionByToken", sessionToken).Return(&models.Session{UserID: uuid.New()}, nil)
 	mockAuthRepo.On("DeleteSession", sessionToken).Return(nil)
 
-	err := authService.Logout(sessionToken)
+	err := authService.Logout(context.Background(), sessionToken)
+
+	assert.NoError(t, err)
+	mockAuthRepo.AssertExpectations(t)
+}
+
+func TestAuthService_RecordsEventsInTransaction(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	recorder := &eventRecorder{}
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, recorder, &auditRecorder{}, nil)
+
+	req := &models.RegisterRequest{
+		Email:    "events@example.com",
+		Username: "events",
+		Password: "password123",
+	}
+	mockUserRepo.On("GetByEmail", req.Email).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("GetByUsername", req.Username).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
+
+	userID, err := authService.Register(context.Background(), req)
+	assert.NoError(t, err)
+
+	mockUserRepo.On("UpdatePassword", *userID, mock.AnythingOfType("string")).Return(nil)
+	assert.NoError(t, authService.ResetPassword(context.Background(), *userID, "new-password"))
+
+	mockAuthRepo.On("GetSessionByToken", "token").Return(&models.Session{UserID: *userID}, nil)
+	mockAuthRepo.On("DeleteSession", "token").Return(nil)
+	assert.NoError(t, authService.Logout(context.Background(), "token"))
+
+	assert.Equal(t, []string{
+		events.UserRegistered,
+		events.UserPasswordReset,
+		events.SessionRevoked,
+		events.SessionRevoked,
+	}, recorder.types())
+	for _, event := range recorder.events {
+		assert.Equal(t, *userID, event.aggregateID)
+	}
+	assert.Equal(t, events.UserRegisteredPayload{
+		UserID:   *userID,
+		Email:    "events@example.com",
+		Username: "events",
+		Role:     "user",
+	}, recorder.events[0].payload)
+	assert.Equal(t, events.RevokePasswordReset, recorder.events[2].payload.(events.SessionRevokedPayload).Reason)
+	assert.Equal(t, events.RevokeLogout, recorder.events[3].payload.(events.SessionRevokedPayload).Reason)
+}
+
+func TestAuthService_Register_FailsWhenEventNotRecorded(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{err: sql.ErrConnDone}, &auditRecorder{}, nil)
+
+	req := &models.RegisterRequest{Email: "a@example.com", Username: "a", Password: "password123"}
+	mockUserRepo.On("GetByEmail", req.Email).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("GetByUsername", req.Username).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
+
+	userID, err := authService.Register(context.Background(), req)
+
+	assert.ErrorIs(t, err, sql.ErrConnDone, "the transaction must be rolled back together with the event")
+	assert.Nil(t, userID)
+}
+
+func TestAuthService_CreateUser_WithRole(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	req := &models.RegisterRequest{
+		Email:     "ops@example.com",
+		Username:  "ops",
+		FirstName: "Ops",
+		LastName:  "Admin",
+		Password:  "password123",
+	}
+
+	mockUserRepo.On("GetByEmail", req.Email).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("GetByUsername", req.Username).Return(nil, apperrors.ErrNotFound)
+	mockUserRepo.On("Create", mock.MatchedBy(func(user *models.User) bool {
+		return user.Role == models.UserRoleAdmin && user.IsActive
+	})).Return(nil)
+
+	userID, err := authService.CreateUser(context.Background(), req, models.UserRoleAdmin)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, userID)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_CreateUser_InvalidRole(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	userID, err := authService.CreateUser(context.Background(), &models.RegisterRequest{}, models.UserRole("superuser"))
+
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.Nil(t, userID)
+	mockUserRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestAuthService_ResetPassword_HashesPassword(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	userID := uuid.New()
+	mockUserRepo.On("UpdatePassword", userID, mock.MatchedBy(func(hash string) bool {
+		return utils.CheckPasswordHash("new-password", hash)
+	})).Return(nil)
+
+	err := authService.ResetPassword(context.Background(), userID, "new-password")
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthService_PurgeExpiredSessions(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	mockAuthRepo := new(MockAuthRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, mockAuthRepo, &eventRecorder{}, &auditRecorder{}, nil)
+
+	mockAuthRepo.On("CleanupExpiredSessions").Return(int64(3), nil)
+
+	deleted, err := authService.PurgeExpiredSessions(context.Background())
 
 	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
 	mockAuthRepo.AssertExpectations(t)
 }
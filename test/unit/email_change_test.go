@@ -0,0 +1,276 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEmailChangeRepo - запросы смены email в памяти; отметки проверяют состояние так же, как UPDATE ... WHERE
+type fakeEmailChangeRepo struct {
+	requests map[uuid.UUID]*models.EmailChangeRequest
+	// beforeLock вызывается в GetForUpdate, чтобы вклинить конкурирующий запрос
+	beforeLock func()
+}
+
+func newFakeEmailChangeRepo() *fakeEmailChangeRepo {
+	return &fakeEmailChangeRepo{requests: make(map[uuid.UUID]*models.EmailChangeRequest)}
+}
+
+func (r *fakeEmailChangeRepo) Create(ctx context.Context, req *models.EmailChangeRequest) error {
+	copied := *req
+	r.requests[req.ID] = &copied
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) GetByConfirmTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error) {
+	return r.find(func(req *models.EmailChangeRequest) bool { return req.ConfirmTokenHash == tokenHash })
+}
+
+func (r *fakeEmailChangeRepo) GetByRevertTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error) {
+	return r.find(func(req *models.EmailChangeRequest) bool { return req.RevertTokenHash == tokenHash })
+}
+
+func (r *fakeEmailChangeRepo) GetForUpdate(ctx context.Context, id uuid.UUID) (*models.EmailChangeRequest, error) {
+	if r.beforeLock != nil {
+		lock := r.beforeLock
+		r.beforeLock = nil
+		lock()
+	}
+	return r.find(func(req *models.EmailChangeRequest) bool { return req.ID == id })
+}
+
+func (r *fakeEmailChangeRepo) find(match func(req *models.EmailChangeRequest) bool) (*models.EmailChangeRequest, error) {
+	for _, req := range r.requests {
+		if match(req) {
+			copied := *req
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeEmailChangeRepo) CancelPending(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, req := range r.requests {
+		if req.UserID == userID && req.ConfirmedAt == nil && req.CancelledAt == nil {
+			req.CancelledAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) MarkConfirmed(ctx context.Context, id uuid.UUID) error {
+	req, ok := r.requests[id]
+	if !ok || req.ConfirmedAt != nil || req.RevertedAt != nil || req.CancelledAt != nil {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	req.ConfirmedAt = &now
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) MarkReverted(ctx context.Context, id uuid.UUID) error {
+	req, ok := r.requests[id]
+	if !ok || req.RevertedAt != nil {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	req.RevertedAt = &now
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+type emailChangeTestEnv struct {
+	service  *service.EmailChangeService
+	userRepo *MockUserRepository
+	authRepo *MockAuthRepository
+	repo     *fakeEmailChangeRepo
+	recorder *eventRecorder
+	audit    *auditRecorder
+	change   *models.EmailChangeRequest
+}
+
+const (
+	testConfirmToken = "confirm-token"
+	testRevertToken  = "revert-token"
+)
+
+// newEmailChangeTestEnv создает запрос смены alex@example.com на alex@new.example.com со ссылками testConfirmToken и testRevertToken
+func newEmailChangeTestEnv(t *testing.T) *emailChangeTestEnv {
+	renderer, err := mail.NewRenderer()
+	require.NoError(t, err)
+
+	env := &emailChangeTestEnv{
+		userRepo: new(MockUserRepository),
+		authRepo: new(MockAuthRepository),
+		repo:     newFakeEmailChangeRepo(),
+		recorder: &eventRecorder{},
+		audit:    &auditRecorder{},
+	}
+	cfg := &config.Config{
+		PublicBaseURL:  "https://devprep.example.com",
+		EmailChangeTTL: time.Hour,
+		EmailRevertTTL: 7 * 24 * time.Hour,
+		DefaultLocale:  "en",
+	}
+	env.service = service.NewEmailChangeService(fakeTx{}, env.userRepo, env.authRepo, env.repo, &fakeEnqueuer{},
+		env.recorder, env.audit, renderer, cfg)
+
+	now := time.Now()
+	env.change = &models.EmailChangeRequest{
+		ID:               uuid.New(),
+		UserID:           uuid.New(),
+		OldEmail:         "alex@example.com",
+		NewEmail:         "alex@new.example.com",
+		ConfirmTokenHash: utils.HashToken(testConfirmToken),
+		RevertTokenHash:  utils.HashToken(testRevertToken),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(cfg.EmailChangeTTL),
+		RevertExpiresAt:  now.Add(cfg.EmailRevertTTL),
+	}
+	require.NoError(t, env.repo.Create(context.Background(), env.change))
+	return env
+}
+
+func (env *emailChangeTestEnv) stored() *models.EmailChangeRequest {
+	return env.repo.requests[env.change.ID]
+}
+
+func TestEmailChange_CheckConfirmChangesNothing(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+
+	change, err := env.service.CheckConfirm(context.Background(), testConfirmToken)
+
+	require.NoError(t, err)
+	assert.Equal(t, "alex@new.example.com", change.NewEmail)
+	assert.Nil(t, env.stored().ConfirmedAt)
+	env.userRepo.AssertNotCalled(t, "UpdateEmail")
+}
+
+func TestEmailChange_ConfirmWorksOnce(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(nil).Once()
+
+	require.NoError(t, env.service.Confirm(context.Background(), testConfirmToken))
+	assert.NotNil(t, env.stored().ConfirmedAt)
+	assert.Equal(t, []string{audit.EmailChanged}, env.audit.actions())
+
+	err := env.service.Confirm(context.Background(), testConfirmToken)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+	env.userRepo.AssertNumberOfCalls(t, "UpdateEmail", 1)
+}
+
+func TestEmailChange_ConfirmAfterEmailChangedElsewhere(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	// С момента запроса email пользователя сменили другим способом
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(apperrors.ErrNotFound).Once()
+
+	err := env.service.Confirm(context.Background(), testConfirmToken)
+
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+	assert.Empty(t, env.audit.actions())
+	env.userRepo.AssertExpectations(t)
+}
+
+func TestEmailChange_ConfirmExpired(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.stored().ExpiresAt = time.Now().Add(-time.Minute)
+
+	err := env.service.Confirm(context.Background(), testConfirmToken)
+
+	assert.ErrorIs(t, err, apperrors.ErrTokenExpired)
+	assert.Nil(t, env.stored().ConfirmedAt)
+	env.userRepo.AssertNotCalled(t, "UpdateEmail")
+}
+
+func TestEmailChange_RevertAfterConfirmRestoresOldEmail(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(nil).Once()
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@new.example.com", "alex@example.com").Return(nil).Once()
+	env.authRepo.On("DeleteUserSessions", env.change.UserID).Return(nil).Once()
+	require.NoError(t, env.service.Confirm(context.Background(), testConfirmToken))
+
+	require.NoError(t, env.service.Revert(context.Background(), testRevertToken))
+
+	assert.NotNil(t, env.stored().RevertedAt)
+	assert.Equal(t, []string{audit.EmailChanged, audit.EmailReverted}, env.audit.actions())
+	assert.Contains(t, env.recorder.types(), events.SessionRevoked)
+	env.userRepo.AssertExpectations(t)
+	env.authRepo.AssertExpectations(t)
+
+	// Ссылка отмены одноразовая
+	err := env.service.Revert(context.Background(), testRevertToken)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+}
+
+func TestEmailChange_RevertAfterAnotherEmailChange(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(nil).Once()
+	require.NoError(t, env.service.Confirm(context.Background(), testConfirmToken))
+
+	// Пользователь уже сменил email еще раз: старая ссылка не должна затереть новый адрес
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@new.example.com", "alex@example.com").Return(apperrors.ErrNotFound).Once()
+
+	err := env.service.Revert(context.Background(), testRevertToken)
+
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+	assert.Equal(t, []string{audit.EmailChanged}, env.audit.actions())
+	env.authRepo.AssertNotCalled(t, "DeleteUserSessions")
+	env.userRepo.AssertExpectations(t)
+}
+
+func TestEmailChange_ConfirmAfterRevertRejected(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.authRepo.On("DeleteUserSessions", env.change.UserID).Return(nil)
+	require.NoError(t, env.service.Revert(context.Background(), testRevertToken))
+
+	err := env.service.Confirm(context.Background(), testConfirmToken)
+
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+	env.userRepo.AssertNotCalled(t, "UpdateEmail")
+}
+
+func TestEmailChange_RevertSeesConfirmFinishedAfterCheck(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(nil).Once()
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@new.example.com", "alex@example.com").Return(nil).Once()
+	env.authRepo.On("DeleteUserSessions", env.change.UserID).Return(nil)
+
+	// Подтверждение завершается, пока отмена ждет блокировку строки
+	env.repo.beforeLock = func() {
+		require.NoError(t, env.service.Confirm(context.Background(), testConfirmToken))
+	}
+	require.NoError(t, env.service.Revert(context.Background(), testRevertToken))
+
+	env.userRepo.AssertExpectations(t)
+}
+
+func TestEmailChange_RevertAfterWindow(t *testing.T) {
+	env := newEmailChangeTestEnv(t)
+	env.userRepo.On("UpdateEmail", env.change.UserID, "alex@example.com", "alex@new.example.com").Return(nil).Once()
+	require.NoError(t, env.service.Confirm(context.Background(), testConfirmToken))
+	env.stored().RevertExpiresAt = time.Now().Add(-time.Minute)
+
+	err := env.service.Revert(context.Background(), testRevertToken)
+
+	assert.ErrorIs(t, err, apperrors.ErrTokenExpired)
+	assert.Nil(t, env.stored().RevertedAt)
+	env.userRepo.AssertNumberOfCalls(t, "UpdateEmail", 1)
+	env.authRepo.AssertNotCalled(t, "DeleteUserSessions")
+}
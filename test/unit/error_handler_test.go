@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func problemFor(t *testing.T, handlerErr error) (*http.Response, dto.ProblemResponse) {
+	return localizedProblemFor(t, handlerErr, "")
+}
+
+func localizedProblemFor(t *testing.T, handlerErr error, acceptLanguage string) (*http.Response, dto.ProblemResponse) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Get("/test", func(c *fiber.Ctx) error {
+		return handlerErr
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var problem dto.ProblemResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	return resp, problem
+}
+
+func TestErrorHandler_DomainError(t *testing.T) {
+	resp, problem := problemFor(t, apperrors.ErrEmailTaken)
+
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, handlers.ProblemTypeBase+"email-taken", problem.Type)
+	assert.Equal(t, "email_taken", problem.Code)
+	assert.Equal(t, http.StatusConflict, problem.Status)
+	assert.Equal(t, "/test", problem.Instance)
+}
+
+func TestErrorHandler_ValidationFields(t *testing.T) {
+	resp, problem := problemFor(t, apperrors.Validation([]apperrors.FieldError{
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+	}))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "email", problem.Errors[0].Field)
+}
+
+func TestErrorHandler_UnknownErrorIsInternal(t *testing.T) {
+	resp, problem := problemFor(t, errors.New("connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "internal_error", problem.Code)
+	assert.NotContains(t, problem.Detail, "connection refused")
+}
+
+func TestAppError_IsMatchesByCode(t *testing.T) {
+	err := apperrors.ErrNotFound.WithMessage("error.export_not_found", "Export not found").Wrap(errors.New("sql: no rows"))
+
+	assert.True(t, errors.Is(err, apperrors.ErrNotFound))
+	assert.False(t, errors.Is(err, apperrors.ErrEmailTaken))
+}
+
+func TestErrorHandler_LocalizedDetail(t *testing.T) {
+	resp, problem := localizedProblemFor(t, apperrors.ErrEmailTaken, "ru-RU,ru;q=0.9,en;q=0.8")
+
+	assert.Equal(t, "ru", resp.Header.Get("Content-Language"))
+	assert.Equal(t, "Email уже занят", problem.Detail)
+	assert.Equal(t, "email_taken", problem.Code)
+}
+
+func TestErrorHandler_LocalizedValidationFields(t *testing.T) {
+	_, problem := localizedProblemFor(t, apperrors.Validation([]apperrors.FieldError{
+		{Field: "username", Rule: "min", Param: "3", Message: "must be at least 3 characters long"},
+	}), "ru")
+
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "минимальная длина - 3 символа", problem.Errors[0].Message)
+}
+
+func TestErrorHandler_UnsupportedLanguageFallsBackToDefault(t *testing.T) {
+	_, problem := localizedProblemFor(t, apperrors.ErrNotFound.WithMessage("error.export_not_found", "Export not found"), "de")
+
+	assert.Equal(t, "Export not found", problem.Detail)
+}
@@ -0,0 +1,378 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExportRepo - таблица data_exports в памяти
+type fakeExportRepo struct {
+	exports map[uuid.UUID]*models.DataExport
+	locked  []uuid.UUID
+}
+
+func newFakeExportRepo() *fakeExportRepo {
+	return &fakeExportRepo{exports: make(map[uuid.UUID]*models.DataExport)}
+}
+
+func (r *fakeExportRepo) Create(ctx context.Context, export *models.DataExport) error {
+	r.exports[export.ID] = export
+	return nil
+}
+
+func (r *fakeExportRepo) LockUser(ctx context.Context, userID uuid.UUID) error {
+	r.locked = append(r.locked, userID)
+	return nil
+}
+
+func (r *fakeExportRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.DataExport, error) {
+	export, ok := r.exports[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	return export, nil
+}
+
+func (r *fakeExportRepo) GetLatestByUserID(ctx context.Context, userID uuid.UUID) (*models.DataExport, error) {
+	var latest *models.DataExport
+	for _, export := range r.exports {
+		if export.UserID == userID && (latest == nil || export.CreatedAt.After(latest.CreatedAt)) {
+			latest = export
+		}
+	}
+	if latest == nil {
+		return nil, apperrors.ErrNotFound
+	}
+	return latest, nil
+}
+
+func (r *fakeExportRepo) MarkCompleted(ctx context.Context, id uuid.UUID, fileKey string, expiresAt time.Time) error {
+	export, ok := r.exports[id]
+	if !ok {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	export.Status, export.FileKey, export.CompletedAt, export.ExpiresAt = models.ExportStatusCompleted, fileKey, &now, &expiresAt
+	return nil
+}
+
+func (r *fakeExportRepo) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	export, ok := r.exports[id]
+	if !ok {
+		return apperrors.ErrNotFound
+	}
+	export.Status, export.Error = models.ExportStatusFailed, reason
+	return nil
+}
+
+func (r *fakeExportRepo) ListExpired(ctx context.Context, now, createdBefore time.Time, limit int) ([]models.DataExport, error) {
+	var result []models.DataExport
+	for _, export := range r.exports {
+		expired := export.Status == models.ExportStatusCompleted && export.ExpiresAt.Before(now)
+		if export.CreatedAt.Before(createdBefore) && (expired || export.Status == models.ExportStatusFailed) && len(result) < limit {
+			result = append(result, *export)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeExportRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, ok := r.exports[id]; !ok {
+		return apperrors.ErrNotFound
+	}
+	delete(r.exports, id)
+	return nil
+}
+
+type exportFixture struct {
+	service   *service.ExportService
+	repo      *fakeExportRepo
+	riskRepo  *fakeLoginRiskRepo
+	auditRepo *fakeAuditStore
+	storage   storage.Storage
+	user      *models.User
+}
+
+func newExportFixture(t *testing.T) *exportFixture {
+	fileStorage, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	user := &models.User{
+		ID:       uuid.New(),
+		Email:    "export@example.com",
+		Username: "exporter",
+		Role:     models.UserRoleUser,
+		IsActive: true,
+	}
+	userRepo := new(MockUserRepository)
+	userRepo.On("GetByID", user.ID).Return(user, nil)
+	authRepo := new(MockAuthRepository)
+	authRepo.On("GetSessionsByUserID", user.ID).Return([]models.Session{}, nil)
+
+	f := &exportFixture{
+		repo:      newFakeExportRepo(),
+		riskRepo:  newFakeLoginRiskRepo(),
+		auditRepo: &fakeAuditStore{},
+		storage:   fileStorage,
+		user:      user,
+	}
+	cfg := &config.Config{ExportSigningSecret: "test-secret", ExportLinkTTL: time.Hour, ExportRateLimit: 24 * time.Hour}
+	f.service, err = service.NewExportService(fakeTx{}, &fakeEnqueuer{}, f.repo, userRepo, authRepo, f.riskRepo, f.auditRepo,
+		fileStorage, &auditRecorder{}, cfg)
+	require.NoError(t, err)
+	return f
+}
+
+// build собирает выгрузку и возвращает файлы архива по имени
+func (f *exportFixture) build(t *testing.T) map[string][]byte {
+	export := &models.DataExport{ID: uuid.New(), UserID: f.user.ID, Status: models.ExportStatusPending, CreatedAt: time.Now()}
+	require.NoError(t, f.repo.Create(context.Background(), export))
+	require.NoError(t, f.service.BuildExport(context.Background(), service.BuildExportPayload{ExportID: export.ID}))
+	require.Equal(t, models.ExportStatusCompleted, export.Status)
+
+	file, err := f.storage.Open(export.FileKey)
+	require.NoError(t, err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, entry := range zr.File {
+		r, err := entry.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		r.Close()
+		require.NoError(t, err)
+		files[entry.Name] = content
+	}
+	return files
+}
+
+// addExport сохраняет выгрузку и, если у нее есть архив, его файл
+func (f *exportFixture) addExport(t *testing.T, status models.ExportStatus, createdAt time.Time, expiresAt *time.Time) *models.DataExport {
+	export := &models.DataExport{ID: uuid.New(), UserID: f.user.ID, Status: status, CreatedAt: createdAt, ExpiresAt: expiresAt}
+	if status == models.ExportStatusCompleted {
+		export.FileKey = fmt.Sprintf("%s/%s.zip", f.user.ID, export.ID)
+		require.NoError(t, f.storage.Put(export.FileKey, strings.NewReader("archive")))
+	}
+	require.NoError(t, f.repo.Create(context.Background(), export))
+	return export
+}
+
+func (f *exportFixture) archiveExists(t *testing.T, export *models.DataExport) bool {
+	file, err := f.storage.Open(export.FileKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false
+	}
+	require.NoError(t, err)
+	file.Close()
+	return true
+}
+
+func readExportCSV(t *testing.T, content []byte) [][]string {
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func TestExportService_RequiresSigningSecret(t *testing.T) {
+	_, err := service.NewExportService(fakeTx{}, &fakeEnqueuer{}, newFakeExportRepo(), new(MockUserRepository),
+		new(MockAuthRepository), newFakeLoginRiskRepo(), &fakeAuditStore{}, nil, &auditRecorder{}, &config.Config{})
+	assert.Error(t, err)
+}
+
+func TestExportService_RequestExport_RateLimited(t *testing.T) {
+	f := newExportFixture(t)
+
+	export, err := f.service.RequestExport(context.Background(), f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusPending, export.Status)
+
+	latest, err := f.service.RequestExport(context.Background(), f.user.ID)
+	assert.ErrorIs(t, err, service.ErrExportRateLimited)
+	assert.Equal(t, export.ID, latest.ID)
+	assert.Len(t, f.repo.exports, 1)
+
+	// Лимит проверяется под блокировкой пользователя, иначе параллельные запросы его обойдут
+	assert.Equal(t, []uuid.UUID{f.user.ID, f.user.ID}, f.repo.locked)
+}
+
+func TestExportService_PurgeExpired(t *testing.T) {
+	f := newExportFixture(t)
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	valid := now.Add(time.Hour)
+
+	expiredExport := f.addExport(t, models.ExportStatusCompleted, now.Add(-48*time.Hour), &expired)
+	failedExport := f.addExport(t, models.ExportStatusFailed, now.Add(-48*time.Hour), nil)
+	validExport := f.addExport(t, models.ExportStatusCompleted, now.Add(-48*time.Hour), &valid)
+	// Ссылка истекла, но по этой записи еще считается лимит запросов
+	recentExport := f.addExport(t, models.ExportStatusCompleted, now.Add(-time.Hour), &expired)
+	pendingExport := f.addExport(t, models.ExportStatusPending, now.Add(-48*time.Hour), nil)
+
+	purged, err := f.service.PurgeExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	for _, export := range []*models.DataExport{expiredExport, failedExport} {
+		_, err := f.repo.GetByID(context.Background(), export.ID)
+		assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	}
+	assert.False(t, f.archiveExists(t, expiredExport))
+
+	for _, export := range []*models.DataExport{validExport, recentExport, pendingExport} {
+		_, err := f.repo.GetByID(context.Background(), export.ID)
+		assert.NoError(t, err)
+	}
+	assert.True(t, f.archiveExists(t, validExport))
+	assert.True(t, f.archiveExists(t, recentExport))
+}
+
+func TestExportService_ArchiveIncludesLoginHistory(t *testing.T) {
+	f := newExportFixture(t)
+	f.riskRepo.logins = []models.LoginRecord{
+		{
+			ID:          uuid.New(),
+			UserID:      f.user.ID,
+			IPAddress:   "203.0.113.7",
+			UserAgent:   "Mozilla/5.0",
+			Fingerprint: "device-fingerprint-hash",
+			Location:    &models.GeoPoint{Latitude: 52.52, Longitude: 13.405},
+			RiskScore:   70,
+			Signals:     []string{"new_device", "new_country"},
+			Outcome:     models.LoginOutcomeBlocked,
+			CreatedAt:   time.Now().Add(-time.Hour),
+		},
+		{
+			ID:        uuid.New(),
+			UserID:    f.user.ID,
+			IPAddress: "198.51.100.1",
+			UserAgent: "curl/8.0",
+			Outcome:   models.LoginOutcomeAllowed,
+			CreatedAt: time.Now().Add(-2 * time.Hour),
+		},
+	}
+
+	files := f.build(t)
+
+	var doc struct {
+		LoginHistory []struct {
+			IPAddress string           `json:"ip_address"`
+			Location  *models.GeoPoint `json:"location"`
+			RiskScore int              `json:"risk_score"`
+			Signals   []string         `json:"signals"`
+			Outcome   string           `json:"outcome"`
+		} `json:"login_history"`
+	}
+	require.NoError(t, json.Unmarshal(files["data.json"], &doc))
+	require.Len(t, doc.LoginHistory, 2)
+	assert.Equal(t, "203.0.113.7", doc.LoginHistory[0].IPAddress)
+	assert.Equal(t, 70, doc.LoginHistory[0].RiskScore)
+	assert.Equal(t, []string{"new_device", "new_country"}, doc.LoginHistory[0].Signals)
+	assert.Equal(t, "blocked", doc.LoginHistory[0].Outcome)
+	require.NotNil(t, doc.LoginHistory[0].Location)
+	assert.Equal(t, 52.52, doc.LoginHistory[0].Location.Latitude)
+	assert.Nil(t, doc.LoginHistory[1].Location)
+	assert.Equal(t, []string{}, doc.LoginHistory[1].Signals)
+	// Отпечаток устройства - внутренние данные оценки риска
+	assert.NotContains(t, string(files["data.json"]), "device-fingerprint-hash")
+
+	rows := readExportCSV(t, files["login_history.csv"])
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"created_at", "ip_address", "user_agent", "latitude", "longitude", "risk_score", "signals", "outcome"}, rows[0])
+	assert.Equal(t, []string{"203.0.113.7", "Mozilla/5.0", "52.52", "13.405", "70", "new_device;new_country", "blocked"}, rows[1][1:])
+	assert.Equal(t, []string{"198.51.100.1", "curl/8.0", "", "", "0", "", "allowed"}, rows[2][1:])
+}
+
+func TestExportService_ArchiveIncludesActivity(t *testing.T) {
+	f := newExportFixture(t)
+	adminID := uuid.New()
+	otherID := uuid.New()
+	start := time.Now().Add(-24 * time.Hour)
+
+	// Больше одной страницы записей о пользователе, чтобы проверить постраничное чтение
+	const ownEvents = 501
+	for i := 0; i < ownEvents; i++ {
+		f.auditRepo.events = append(f.auditRepo.events, &models.AuditEvent{
+			ID:         uuid.New(),
+			Seq:        int64(i + 1),
+			OccurredAt: start.Add(time.Duration(i) * time.Second),
+			ActorID:    &f.user.ID,
+			ActorRole:  string(models.UserRoleUser),
+			Source:     "api",
+			Action:     "user.profile_updated",
+			IPAddress:  "203.0.113.7",
+		})
+	}
+	f.auditRepo.events = append(f.auditRepo.events,
+		&models.AuditEvent{
+			ID:         uuid.New(),
+			Seq:        ownEvents + 1,
+			Hash:       "chain-hash",
+			OccurredAt: start.Add(time.Hour),
+			ActorID:    &adminID,
+			ActorRole:  string(models.UserRoleAdmin),
+			Source:     "api",
+			Action:     "admin.user_deactivated",
+			TargetType: "user",
+			TargetID:   &f.user.ID,
+			Changes:    json.RawMessage(`{"before":{"is_active":true},"after":{"is_active":false}}`),
+		},
+		&models.AuditEvent{
+			ID:         uuid.New(),
+			Seq:        ownEvents + 2,
+			OccurredAt: start.Add(time.Hour),
+			ActorID:    &otherID,
+			Source:     "api",
+			Action:     "user.login_succeeded",
+		},
+	)
+
+	files := f.build(t)
+
+	var doc struct {
+		Activity []struct {
+			Action    string          `json:"action"`
+			ActorRole string          `json:"actor_role"`
+			Source    string          `json:"source"`
+			Changes   json.RawMessage `json:"changes"`
+		} `json:"activity"`
+	}
+	require.NoError(t, json.Unmarshal(files["data.json"], &doc))
+	require.Len(t, doc.Activity, ownEvents+1)
+	assert.Equal(t, "admin.user_deactivated", doc.Activity[0].Action)
+	assert.Equal(t, string(models.UserRoleAdmin), doc.Activity[0].ActorRole)
+	assert.JSONEq(t, `{"before":{"is_active":true},"after":{"is_active":false}}`, string(doc.Activity[0].Changes))
+	for _, event := range doc.Activity {
+		assert.NotEqual(t, "user.login_succeeded", event.Action, "other users' events must not be exported")
+	}
+	// Идентификатор администратора и служебные поля цепочки не выгружаются
+	assert.NotContains(t, string(files["data.json"]), adminID.String())
+	assert.NotContains(t, string(files["data.json"]), "chain-hash")
+
+	rows := readExportCSV(t, files["activity.csv"])
+	require.Len(t, rows, ownEvents+2)
+	assert.Equal(t, []string{"occurred_at", "action", "actor_role", "source", "ip_address", "user_agent", "changes", "metadata"}, rows[0])
+	assert.Equal(t, "admin.user_deactivated", rows[1][1])
+	assert.Equal(t, "user.profile_updated", rows[2][1])
+}
@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/geoip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const geoipBlocksCSV = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider,postal_code,latitude,longitude,accuracy_radius
+203.0.113.0/24,1,1,,0,0,,55.7558,37.6173,20
+203.0.0.0/16,2,2,,0,0,,51.5074,-0.1278,100
+198.51.100.0/24,3,3,,0,0,,,,
+2001:db8::/32,4,4,,0,0,,40.7128,-74.0060,50
+`
+
+func TestGeoIP_LocateUsesNarrowestNetwork(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	require.NoError(t, os.WriteFile(path, []byte(geoipBlocksCSV), 0o600))
+
+	table, err := geoip.LoadCSV(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, table.Len(), "networks without coordinates are skipped")
+
+	point, ok := table.Locate(netip.MustParseAddr("203.0.113.7"))
+	require.True(t, ok)
+	assert.Equal(t, 55.7558, point.Latitude)
+
+	point, ok = table.Locate(netip.MustParseAddr("203.0.50.1"))
+	require.True(t, ok)
+	assert.Equal(t, 51.5074, point.Latitude)
+
+	_, ok = table.Locate(netip.MustParseAddr("::ffff:203.0.113.7"))
+	assert.True(t, ok, "IPv4-mapped addresses are looked up as IPv4")
+
+	_, ok = table.Locate(netip.MustParseAddr("2001:db8::1"))
+	assert.True(t, ok)
+
+	_, ok = table.Locate(netip.MustParseAddr("198.51.100.1"))
+	assert.False(t, ok)
+}
+
+func TestGeoIP_LoadCSVRequiresColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	require.NoError(t, os.WriteFile(path, []byte("network,latitude\n203.0.113.0/24,1\n"), 0o600))
+
+	_, err := geoip.LoadCSV(path)
+	assert.ErrorContains(t, err, "longitude")
+}
+
+func TestGeoIP_DistanceKm(t *testing.T) {
+	moscow := geoip.Point{Latitude: 55.7558, Longitude: 37.6173}
+	london := geoip.Point{Latitude: 51.5074, Longitude: -0.1278}
+
+	assert.InDelta(t, 2500, geoip.DistanceKm(moscow, london), 10)
+	assert.Zero(t, geoip.DistanceKm(moscow, moscow))
+}
@@ -0,0 +1,314 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/health"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkReturning(err error) health.CheckFunc {
+	return func(ctx context.Context) error {
+		return err
+	}
+}
+
+func TestHealthRegistry_OverallStatus(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name     string
+		checks   []health.Check
+		expected health.Status
+		ready    bool
+	}{
+		{
+			name: "all up",
+			checks: []health.Check{
+				{Name: "database", Critical: true, Func: checkReturning(nil)},
+				{Name: "queue_lag", Func: checkReturning(nil)},
+			},
+			expected: health.StatusUp,
+			ready:    true,
+		},
+		{
+			name: "non-critical check down",
+			checks: []health.Check{
+				{Name: "database", Critical: true, Func: checkReturning(nil)},
+				{Name: "redis", Func: checkReturning(errDown)},
+			},
+			expected: health.StatusDegraded,
+			ready:    true,
+		},
+		{
+			name: "critical check degraded",
+			checks: []health.Check{
+				{Name: "migrations", Critical: true, Func: checkReturning(health.Degraded(errDown))},
+			},
+			expected: health.StatusDegraded,
+			ready:    true,
+		},
+		{
+			name: "critical check down",
+			checks: []health.Check{
+				{Name: "database", Critical: true, Func: checkReturning(errDown)},
+				{Name: "redis", Func: checkReturning(errDown)},
+			},
+			expected: health.StatusDown,
+			ready:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := health.NewRegistry(health.Options{})
+			for _, check := range tt.checks {
+				registry.Register(check)
+			}
+
+			report := registry.Check(context.Background())
+			assert.Equal(t, tt.expected, report.Status)
+			assert.Equal(t, tt.ready, report.Ready())
+			assert.Len(t, report.Checks, len(tt.checks))
+		})
+	}
+}
+
+func TestHealthRegistry_CachesResults(t *testing.T) {
+	var calls atomic.Int32
+	registry := health.NewRegistry(health.Options{CacheTTL: 50 * time.Millisecond})
+	registry.Register(health.Check{Name: "database", Critical: true, Func: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	first := registry.Check(context.Background())
+	second := registry.Check(context.Background())
+	assert.Same(t, first, second)
+	assert.Equal(t, int32(1), calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	registry.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestHealthRegistry_TimeoutAndPanic(t *testing.T) {
+	registry := health.NewRegistry(health.Options{Timeout: 20 * time.Millisecond})
+	registry.Register(health.Check{Name: "slow", Critical: true, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	registry.Register(health.Check{Name: "broken", Func: func(ctx context.Context) error {
+		panic("boom")
+	}})
+
+	start := time.Now()
+	report := registry.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, health.StatusDown, report.Status)
+
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "broken", report.Checks[0].Name)
+	assert.Equal(t, health.StatusDown, report.Checks[0].Status)
+	assert.Contains(t, report.Checks[0].Error, "panic: boom")
+	assert.Equal(t, "slow", report.Checks[1].Name)
+	assert.Contains(t, report.Checks[1].Error, "timed out")
+}
+
+func TestHealth_MaxLag(t *testing.T) {
+	lag := func(value time.Duration) func(ctx context.Context) (time.Duration, error) {
+		return func(ctx context.Context) (time.Duration, error) {
+			return value, nil
+		}
+	}
+
+	assert.NoError(t, health.MaxLag("queue", time.Minute, lag(time.Second))(context.Background()))
+
+	registry := health.NewRegistry(health.Options{})
+	registry.Register(health.Check{Name: "queue_lag", Critical: true, Func: health.MaxLag("queue", time.Minute, lag(time.Hour))})
+	report := registry.Check(context.Background())
+	assert.Equal(t, health.StatusDegraded, report.Checks[0].Status)
+	assert.Contains(t, report.Checks[0].Error, "queue lag 1h0m0s exceeds 1m0s")
+}
+
+// fakeRedis отвечает на AUTH и PING как Redis с паролем password
+func fakeRedis(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				authorized := password == ""
+				for {
+					header, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					// Команда RESP: *<число аргументов>, затем $<длина> и значение каждого
+					count, err := strconv.Atoi(strings.TrimSpace(header)[1:])
+					if err != nil {
+						return
+					}
+					var args []string
+					for i := 0; i < count; i++ {
+						_, _ = reader.ReadString('\n')
+						arg, _ := reader.ReadString('\n')
+						args = append(args, strings.TrimSpace(arg))
+					}
+
+					switch {
+					case args[0] == "AUTH" && args[1] == password:
+						authorized = true
+						_, _ = conn.Write([]byte("+OK\r\n"))
+					case args[0] == "AUTH":
+						_, _ = conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+					case !authorized:
+						_, _ = conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+					default:
+						_, _ = conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestHealth_PingRedis(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	addr := fakeRedis(t, "secret")
+	assert.NoError(t, health.PingRedis(addr, "secret")(ctx))
+	assert.ErrorContains(t, health.PingRedis(addr, "wrong")(ctx), "WRONGPASS")
+	assert.ErrorContains(t, health.PingRedis(addr, "")(ctx), "NOAUTH")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := listener.Addr().String()
+	listener.Close()
+	assert.Error(t, health.PingRedis(closed, "")(ctx))
+}
+
+func healthApp(registry *health.Registry) *fiber.App {
+	app := fiber.New()
+	handler := handlers.NewHealthHandler(registry)
+	app.Get("/livez", handler.Liveness)
+	app.Get("/startupz", handler.Startup)
+	app.Get("/readyz", handler.Readiness)
+	app.Get("/internal/readyz", handler.ReadinessDetail)
+	return app
+}
+
+func getReadiness(t *testing.T, app *fiber.App, target string) (int, dto.ReadinessResponse) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body dto.ReadinessResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+func TestHealthHandler_Lifecycle(t *testing.T) {
+	registry := health.NewRegistry(health.Options{})
+	registry.Register(health.Check{Name: "database", Critical: true, Func: checkReturning(nil)})
+	app := healthApp(registry)
+
+	status, body := getReadiness(t, app, "/readyz")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.Equal(t, "starting", body.Reason)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+
+	registry.MarkStarted()
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	status, body = getReadiness(t, app, "/readyz")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.True(t, body.Ready)
+	assert.Equal(t, "up", body.Status)
+	assert.Empty(t, body.Checks, "checks are only listed with ?verbose")
+
+	registry.MarkShuttingDown()
+	status, body = getReadiness(t, app, "/readyz")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.False(t, body.Ready)
+	assert.Equal(t, "shutting_down", body.Reason)
+
+	// Liveness не зависит ни от остановки, ни от зависимостей
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/livez", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestHealthHandler_ReadinessDetail(t *testing.T) {
+	registry := health.NewRegistry(health.Options{})
+	registry.Register(health.Check{Name: "database", Critical: true, Func: checkReturning(nil)})
+	registry.Register(health.Check{Name: "redis", Func: checkReturning(errors.New("connection refused"))})
+	registry.MarkStarted()
+	app := healthApp(registry)
+
+	status, body := getReadiness(t, app, "/readyz?verbose")
+	assert.Equal(t, fiber.StatusOK, status, "a degraded instance keeps serving")
+	assert.True(t, body.Ready)
+	assert.Equal(t, "degraded", body.Status)
+	require.Len(t, body.Checks, 2)
+	assert.Equal(t, "database", body.Checks[0].Name)
+	assert.Equal(t, "up", body.Checks[0].Status)
+	assert.Equal(t, "redis", body.Checks[1].Name)
+	assert.Equal(t, "down", body.Checks[1].Status)
+	// Публичный порт не раскрывает текст ошибок и устройство проверок
+	for _, check := range body.Checks {
+		assert.Empty(t, check.Error)
+		assert.Nil(t, check.Critical)
+		assert.Nil(t, check.DurationMs)
+	}
+
+	status, body = getReadiness(t, app, "/internal/readyz")
+	assert.Equal(t, fiber.StatusOK, status)
+	require.Len(t, body.Checks, 2)
+	require.NotNil(t, body.Checks[0].Critical)
+	assert.True(t, *body.Checks[0].Critical)
+	assert.NotNil(t, body.Checks[0].DurationMs)
+	assert.Equal(t, "connection refused", body.Checks[1].Error)
+
+	down := health.NewRegistry(health.Options{})
+	down.Register(health.Check{Name: "database", Critical: true, Func: checkReturning(errors.New("connection refused"))})
+	down.MarkStarted()
+
+	status, body = getReadiness(t, healthApp(down), "/readyz")
+	assert.Equal(t, fiber.StatusServiceUnavailable, status)
+	assert.False(t, body.Ready)
+	assert.Equal(t, "down", body.Status)
+	assert.Equal(t, "dependency_unavailable", body.Reason)
+}
@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralIndex_Russian(t *testing.T) {
+	cases := map[int]int{
+		0: 2, 1: 0, 2: 1, 4: 1, 5: 2, 11: 2, 12: 2, 14: 2,
+		21: 0, 22: 1, 25: 2, 101: 0, 111: 2, 112: 2, 122: 1,
+	}
+
+	for n, expected := range cases {
+		assert.Equal(t, expected, i18n.PluralIndex(i18n.Russian, n), "n=%d", n)
+	}
+}
+
+func TestPluralIndex_English(t *testing.T) {
+	assert.Equal(t, 0, i18n.PluralIndex(i18n.English, 1))
+	assert.Equal(t, 1, i18n.PluralIndex(i18n.English, 0))
+	assert.Equal(t, 1, i18n.PluralIndex(i18n.English, 21))
+}
+
+func TestFormat_PluralForms(t *testing.T) {
+	message := "{n} {n|файл|файла|файлов}"
+
+	assert.Equal(t, "1 файл", i18n.Format(i18n.Russian, message, i18n.Args{"n": 1}))
+	assert.Equal(t, "3 файла", i18n.Format(i18n.Russian, message, i18n.Args{"n": 3}))
+	assert.Equal(t, "11 файлов", i18n.Format(i18n.Russian, message, i18n.Args{"n": 11}))
+	assert.Equal(t, "2 hours", i18n.Format(i18n.English, "{n} {n|hour|hours}", i18n.Args{"n": "2"}))
+}
+
+func TestFormat_MissingArgumentIsKept(t *testing.T) {
+	assert.Equal(t, "Hi {name}!", i18n.Format(i18n.English, "Hi {name}!", nil))
+}
+
+func TestT_FallsBackToEnglishAndKey(t *testing.T) {
+	assert.Equal(t, "Email уже занят", i18n.T(i18n.Russian, "error.email_taken", nil))
+	assert.Equal(t, "Email is already taken", i18n.T(i18n.Locale("de"), "error.email_taken", nil))
+	assert.Equal(t, "error.unknown_key", i18n.T(i18n.Russian, "error.unknown_key", nil))
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, i18n.Russian, i18n.ParseAcceptLanguage("ru-RU,ru;q=0.9,en;q=0.8"))
+	assert.Equal(t, i18n.English, i18n.ParseAcceptLanguage("de-DE,en;q=0.5,ru;q=0.3"))
+	assert.Equal(t, i18n.Russian, i18n.ParseAcceptLanguage("en;q=0.2, ru;q=0.7"))
+	assert.Equal(t, i18n.Locale(""), i18n.ParseAcceptLanguage("de, fr;q=0.8"))
+	assert.Equal(t, i18n.Locale(""), i18n.ParseAcceptLanguage("ru;q=0"))
+}
+
+func TestResolve_Order(t *testing.T) {
+	assert.Equal(t, i18n.English, i18n.Resolve("en", "ru", i18n.Russian))
+	assert.Equal(t, i18n.Russian, i18n.Resolve("", "ru", i18n.English))
+	assert.Equal(t, i18n.Russian, i18n.Resolve("de", "", i18n.Russian))
+	assert.Equal(t, i18n.English, i18n.Resolve("", "xx", i18n.Locale("xx")))
+}
+
+func TestCatalogs_RussianCoversEnglishKeys(t *testing.T) {
+	for _, key := range []string{
+		"error.internal_error", "error.validation_failed", "validation.required",
+		"email.email_change_confirm.subject", "email.email_change_notice.warning", "email.footer",
+	} {
+		assert.NotEqual(t, i18n.T(i18n.English, key, nil), i18n.T(i18n.Russian, key, nil), key)
+	}
+}
@@ -0,0 +1,119 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogging_New(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "warn", "json")
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "key", "value")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "shown", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "value", record["key"])
+
+	_, err = logging.New(&buf, "loud", "json")
+	assert.Error(t, err)
+	_, err = logging.New(&buf, "info", "xml")
+	assert.Error(t, err)
+}
+
+func TestLogging_Context(t *testing.T) {
+	assert.Same(t, slog.Default(), logging.FromContext(context.Background()))
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	require.NoError(t, err)
+
+	ctx := logging.With(logging.WithLogger(context.Background(), logger), "request_id", "req-1")
+	logging.FromContext(ctx).Info("from service")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "req-1", record["request_id"])
+}
+
+// accessLogApp собирает приложение с цепочкой middleware, как в serve, и пишет лог в buf
+func accessLogApp(buf *bytes.Buffer) *fiber.App {
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(middleware.RequestID())
+	app.Use(middleware.AccessLog(logger))
+	app.Use(middleware.RequestContext(logging.WithLogger(context.Background(), logger), 0))
+	return app
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	app := accessLogApp(&buf)
+
+	userID := uuid.New()
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		c.Locals("user_id", userID)
+		return c.SendString("hello")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-access")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, "req-access", resp.Header.Get(fiber.HeaderXRequestID))
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "request", record["msg"])
+	assert.Equal(t, "req-access", record["request_id"])
+	assert.Equal(t, http.MethodGet, record["method"])
+	assert.Equal(t, "/users/:id", record["route"])
+	assert.Equal(t, "/users/42", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, float64(len("hello")), record["bytes"])
+	assert.Equal(t, userID.String(), record["user_id"])
+	assert.Contains(t, record, "latency_ms")
+}
+
+func TestAccessLog_ErrorStatus(t *testing.T) {
+	var buf bytes.Buffer
+	app := accessLogApp(&buf)
+
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return apperrors.ErrInternal.Wrap(assert.AnError)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/fail", nil), -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	// Первая запись - ошибка от ErrorHandler с ID запроса, вторая - access log с итоговым статусом
+	decoder := json.NewDecoder(&buf)
+	var failure, access map[string]interface{}
+	require.NoError(t, decoder.Decode(&failure))
+	require.NoError(t, decoder.Decode(&access))
+
+	assert.Equal(t, "request failed", failure["msg"])
+	assert.Equal(t, access["request_id"], failure["request_id"])
+	assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), access["request_id"])
+	assert.Equal(t, float64(http.StatusInternalServerError), access["status"])
+	assert.Equal(t, "ERROR", access["level"])
+}
@@ -0,0 +1,527 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/geoip"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLoginRiskRepo - известные устройства, история входов и коды подтверждения в памяти
+type fakeLoginRiskRepo struct {
+	devices    []models.KnownDevice
+	logins     []models.LoginRecord
+	challenges map[uuid.UUID]*models.LoginChallenge
+	// mu защищает challenges: проверки кода идут параллельно
+	mu sync.Mutex
+}
+
+func newFakeLoginRiskRepo() *fakeLoginRiskRepo {
+	return &fakeLoginRiskRepo{challenges: make(map[uuid.UUID]*models.LoginChallenge)}
+}
+
+func (r *fakeLoginRiskRepo) IsKnownDevice(ctx context.Context, userID uuid.UUID, fingerprint string) (bool, error) {
+	for _, device := range r.devices {
+		if device.UserID == userID && device.Fingerprint == fingerprint {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeLoginRiskRepo) UpsertKnownDevice(ctx context.Context, device *models.KnownDevice) error {
+	for i, known := range r.devices {
+		if known.UserID == device.UserID && known.Fingerprint == device.Fingerprint {
+			r.devices[i].LastIP, r.devices[i].LastSeen = device.LastIP, device.LastSeen
+			return nil
+		}
+	}
+	r.devices = append(r.devices, *device)
+	return nil
+}
+
+func (r *fakeLoginRiskRepo) ListKnownDevices(ctx context.Context, userID uuid.UUID) ([]models.KnownDevice, error) {
+	return r.devices, nil
+}
+
+func (r *fakeLoginRiskRepo) DeleteKnownDevice(ctx context.Context, userID, deviceID uuid.UUID) error {
+	for i, device := range r.devices {
+		if device.ID == deviceID && device.UserID == userID {
+			r.devices = append(r.devices[:i], r.devices[i+1:]...)
+			return nil
+		}
+	}
+	return apperrors.ErrNotFound
+}
+
+func (r *fakeLoginRiskRepo) HasLoginFromSubnet(ctx context.Context, userID uuid.UUID, subnet string) (bool, error) {
+	for _, login := range r.successful(userID) {
+		if login.Subnet == subnet {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeLoginRiskRepo) RecentLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error) {
+	return r.successful(userID), nil
+}
+
+func (r *fakeLoginRiskRepo) ListLogins(ctx context.Context, userID uuid.UUID, limit int) ([]models.LoginRecord, error) {
+	return r.logins, nil
+}
+
+// successful возвращает успешные входы новыми первыми, как репозиторий
+func (r *fakeLoginRiskRepo) successful(userID uuid.UUID) []models.LoginRecord {
+	var records []models.LoginRecord
+	for i := len(r.logins) - 1; i >= 0; i-- {
+		login := r.logins[i]
+		if login.UserID == userID && (login.Outcome == models.LoginOutcomeAllowed || login.Outcome == models.LoginOutcomeVerified) {
+			records = append(records, login)
+		}
+	}
+	return records
+}
+
+func (r *fakeLoginRiskRepo) RecordLogin(ctx context.Context, record *models.LoginRecord) error {
+	r.logins = append(r.logins, *record)
+	return nil
+}
+
+func (r *fakeLoginRiskRepo) DeleteLoginHistoryBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeLoginRiskRepo) CreateLoginChallenge(ctx context.Context, challenge *models.LoginChallenge) error {
+	copied := *challenge
+	r.challenges[challenge.ID] = &copied
+	return nil
+}
+
+func (r *fakeLoginRiskRepo) GetLoginChallenge(ctx context.Context, id uuid.UUID) (*models.LoginChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	copied := *challenge
+	return &copied, nil
+}
+
+func (r *fakeLoginRiskRepo) UseLoginChallengeAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge := r.challenges[id]
+	if challenge.VerifiedAt != nil || challenge.Attempts >= maxAttempts {
+		return 0, apperrors.ErrNotFound
+	}
+	challenge.Attempts++
+	return challenge.Attempts, nil
+}
+
+func (r *fakeLoginRiskRepo) MarkLoginChallengeVerified(ctx context.Context, id uuid.UUID, maxAttempts int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge := r.challenges[id]
+	if challenge.VerifiedAt != nil || challenge.Attempts > maxAttempts {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	challenge.VerifiedAt = &now
+	return nil
+}
+
+func (r *fakeLoginRiskRepo) DeleteExpiredLoginChallenges(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// fakeLocator - координаты по точным адресам
+type fakeLocator map[string]geoip.Point
+
+func (l fakeLocator) Locate(addr netip.Addr) (geoip.Point, bool) {
+	point, ok := l[addr.String()]
+	return point, ok
+}
+
+const (
+	homeUserAgent   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	homeIP          = "203.0.113.7"
+	strangerAgent   = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	strangerIP      = "198.51.100.23"
+	loginRiskPasswd = "password123"
+)
+
+type loginRiskTestEnv struct {
+	auth     *service.AuthService
+	risk     *service.LoginRiskService
+	repo     *fakeLoginRiskRepo
+	userRepo *MockUserRepository
+	authRepo *MockAuthRepository
+	jobs     *fakeEnqueuer
+	recorder *eventRecorder
+	audit    *auditRecorder
+	cfg      *config.Config
+	user     *models.User
+}
+
+func newLoginRiskTestEnv(t *testing.T, locator geoip.Locator) *loginRiskTestEnv {
+	renderer, err := mail.NewRenderer()
+	require.NoError(t, err)
+	hashedPassword, err := utils.HashPassword(loginRiskPasswd)
+	require.NoError(t, err)
+
+	env := &loginRiskTestEnv{
+		repo:     newFakeLoginRiskRepo(),
+		userRepo: new(MockUserRepository),
+		authRepo: new(MockAuthRepository),
+		jobs:     &fakeEnqueuer{},
+		recorder: &eventRecorder{},
+		audit:    &auditRecorder{},
+		cfg: &config.Config{
+			LoginRiskChallengeThreshold: 50,
+			LoginRiskBlockThreshold:     90,
+			LoginMaxTravelSpeed:         1000,
+			LoginChallengeTTL:           10 * time.Minute,
+			DefaultLocale:               "en",
+		},
+		user: &models.User{
+			ID:           uuid.New(),
+			Email:        "alex@example.com",
+			FirstName:    "Alex",
+			PasswordHash: hashedPassword,
+			IsActive:     true,
+		},
+	}
+	env.risk = service.NewLoginRiskService(fakeTx{}, env.repo, locator, env.jobs, env.audit, renderer, env.cfg)
+	env.auth = service.NewAuthService(fakeTx{}, env.userRepo, env.authRepo, env.recorder, env.audit, env.risk)
+
+	env.userRepo.On("GetByEmail", env.user.Email).Return(env.user, nil).Maybe()
+	env.userRepo.On("GetByID", env.user.ID).Return(env.user, nil).Maybe()
+	env.authRepo.On("CreateSession", env.user.ID, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return env
+}
+
+// seedHistory записывает успешные входы с домашнего устройства и адреса
+func (env *loginRiskTestEnv) seedHistory(createdAt ...time.Time) {
+	for _, at := range createdAt {
+		env.repo.logins = append(env.repo.logins, models.LoginRecord{
+			ID:          uuid.New(),
+			UserID:      env.user.ID,
+			IPAddress:   homeIP,
+			UserAgent:   homeUserAgent,
+			Fingerprint: utils.DeviceFingerprint(homeUserAgent),
+			Subnet:      utils.IPSubnet(homeIP),
+			Outcome:     models.LoginOutcomeAllowed,
+			CreatedAt:   at,
+		})
+	}
+	_ = env.repo.UpsertKnownDevice(context.Background(), &models.KnownDevice{
+		ID:          uuid.New(),
+		UserID:      env.user.ID,
+		Fingerprint: utils.DeviceFingerprint(homeUserAgent),
+		UserAgent:   homeUserAgent,
+		LastIP:      homeIP,
+	})
+}
+
+func (env *loginRiskTestEnv) login(userAgent, ip string) (*models.LoginResponse, error) {
+	return env.auth.Login(context.Background(), &models.LoginRequest{
+		Email:     env.user.Email,
+		Password:  loginRiskPasswd,
+		UserAgent: userAgent,
+		IPAddress: ip,
+	})
+}
+
+var loginCodePattern = regexp.MustCompile(`(?m)^(\d{6})$`)
+
+// sentCode достает код из последнего письма с кодом подтверждения
+func (env *loginRiskTestEnv) sentCode(t *testing.T) string {
+	require.NotEmpty(t, env.jobs.payloads)
+	msg := env.jobs.payloads[len(env.jobs.payloads)-1].(mail.Message)
+	assert.Equal(t, []string{env.user.Email}, msg.To)
+	assert.Equal(t, "Your sign-in code", msg.Subject)
+	match := loginCodePattern.FindStringSubmatch(msg.Text)
+	require.NotNil(t, match, "code not found in %q", msg.Text)
+	return match[1]
+}
+
+func TestLoginRisk_FirstLoginIsAllowedAndRemembersDevice(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.SessionToken)
+
+	require.Len(t, env.repo.logins, 1)
+	assert.Equal(t, models.LoginOutcomeAllowed, env.repo.logins[0].Outcome)
+	assert.Zero(t, env.repo.logins[0].RiskScore)
+	require.Len(t, env.repo.devices, 1)
+	assert.Equal(t, strangerIP, env.repo.devices[0].LastIP)
+
+	payload := env.recorder.events[0].payload.(events.UserLoggedInPayload)
+	require.NotNil(t, payload.RiskScore)
+	assert.Zero(t, *payload.RiskScore)
+}
+
+func TestLoginRisk_KnownDeviceAndSubnetIsAllowed(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(homeUserAgent, "203.0.113.99")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.SessionToken)
+	assert.Empty(t, env.repo.logins[len(env.repo.logins)-1].Signals)
+}
+
+func TestLoginRisk_NewDeviceAloneStaysBelowThreshold(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(strangerAgent, homeIP)
+	require.NoError(t, err)
+	assert.Nil(t, response.Challenge)
+
+	last := env.repo.logins[len(env.repo.logins)-1]
+	assert.Equal(t, 40, last.RiskScore)
+	assert.Equal(t, []string{"new_device"}, last.Signals)
+}
+
+func TestLoginRisk_RiskyLoginRequiresEmailCode(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+	ctx := context.Background()
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	require.NotNil(t, response.Challenge)
+	assert.Empty(t, response.SessionToken)
+	env.authRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, models.LoginOutcomeChallenged, env.repo.logins[len(env.repo.logins)-1].Outcome)
+	assert.Len(t, env.repo.devices, 1, "device is remembered only after verification")
+
+	code := env.sentCode(t)
+	verify := func(code, userAgent string) (*models.LoginResponse, error) {
+		return env.auth.VerifyLogin(ctx, &models.VerifyLoginRequest{
+			ChallengeID: response.Challenge.ID,
+			Code:        code,
+			UserAgent:   userAgent,
+			IPAddress:   strangerIP,
+		})
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	_, err = verify(wrong, strangerAgent)
+	assert.ErrorIs(t, err, service.ErrInvalidLoginCode)
+	assert.Equal(t, 1, env.repo.challenges[response.Challenge.ID].Attempts)
+
+	_, err = verify(code, homeUserAgent)
+	assert.ErrorIs(t, err, service.ErrInvalidLoginCode, "the code is bound to the device that started the login")
+
+	verified, err := verify(code, strangerAgent)
+	require.NoError(t, err)
+	assert.NotEmpty(t, verified.SessionToken)
+	assert.Equal(t, models.LoginOutcomeVerified, env.repo.logins[len(env.repo.logins)-1].Outcome)
+	assert.Len(t, env.repo.devices, 2)
+
+	payload := env.recorder.events[len(env.recorder.events)-1].payload.(events.UserLoggedInPayload)
+	assert.True(t, payload.Verified)
+	assert.ElementsMatch(t, []string{"new_device", "new_subnet"}, payload.Signals)
+
+	_, err = verify(code, strangerAgent)
+	assert.ErrorIs(t, err, service.ErrInvalidLoginCode, "the code is single-use")
+
+	assert.Equal(t, []string{audit.LoginChallenged, audit.LoginSucceeded}, env.audit.actions())
+	assert.Equal(t, true, env.audit.last(t, audit.LoginSucceeded).Metadata["verified"])
+}
+
+func TestLoginRisk_CodeStopsWorkingAfterTooManyAttempts(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+	ctx := context.Background()
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	code := env.sentCode(t)
+
+	env.repo.challenges[response.Challenge.ID].Attempts = 5
+	_, err = env.auth.VerifyLogin(ctx, &models.VerifyLoginRequest{
+		ChallengeID: response.Challenge.ID,
+		Code:        code,
+		UserAgent:   strangerAgent,
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidLoginCode)
+}
+
+func TestLoginRisk_LastAttemptWithCorrectCodeSucceeds(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	code := env.sentCode(t)
+
+	env.repo.challenges[response.Challenge.ID].Attempts = 4
+	verified, err := env.auth.VerifyLogin(context.Background(), &models.VerifyLoginRequest{
+		ChallengeID: response.Challenge.ID,
+		Code:        code,
+		UserAgent:   strangerAgent,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, verified.SessionToken)
+	assert.Equal(t, 5, env.repo.challenges[response.Challenge.ID].Attempts)
+}
+
+func TestLoginRisk_ParallelGuessesAreCapped(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	code := env.sentCode(t)
+
+	// Все 20 запросов прочитали код до того, как кто-то из них потратил попытку
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(guess int) {
+			defer wg.Done()
+			_, _ = env.risk.CheckChallenge(context.Background(), response.Challenge.ID, fmt.Sprintf("x%05d", guess), strangerAgent)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, env.repo.challenges[response.Challenge.ID].Attempts)
+	_, err = env.auth.VerifyLogin(context.Background(), &models.VerifyLoginRequest{
+		ChallengeID: response.Challenge.ID,
+		Code:        code,
+		UserAgent:   strangerAgent,
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidLoginCode, "no attempts are left for the correct code")
+}
+
+func TestLoginRisk_ExpiredCode(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	code := env.sentCode(t)
+
+	env.repo.challenges[response.Challenge.ID].ExpiresAt = time.Now().Add(-time.Second)
+	_, err = env.auth.VerifyLogin(context.Background(), &models.VerifyLoginRequest{
+		ChallengeID: response.Challenge.ID,
+		Code:        code,
+		UserAgent:   strangerAgent,
+	})
+	assert.ErrorIs(t, err, apperrors.ErrTokenExpired)
+}
+
+func TestLoginRisk_ImpossibleTravelFromNewDeviceIsBlocked(t *testing.T) {
+	env := newLoginRiskTestEnv(t, fakeLocator{
+		homeIP:     {Latitude: 55.7558, Longitude: 37.6173},
+		strangerIP: {Latitude: 40.7128, Longitude: -74.0060},
+	})
+	env.seedHistory(time.Now().Add(-time.Hour))
+	env.repo.logins[0].Location = &models.GeoPoint{Latitude: 55.7558, Longitude: 37.6173}
+
+	_, err := env.login(strangerAgent, strangerIP)
+	assert.ErrorIs(t, err, apperrors.ErrLoginBlocked)
+	env.authRepo.AssertNotCalled(t, "CreateSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	last := env.repo.logins[len(env.repo.logins)-1]
+	assert.Equal(t, models.LoginOutcomeBlocked, last.Outcome)
+	assert.Equal(t, 120, last.RiskScore)
+	assert.ElementsMatch(t, []string{"new_device", "new_subnet", "impossible_travel"}, last.Signals)
+
+	assert.Equal(t, []string{events.UserLoginBlocked}, env.recorder.types())
+	payload := env.recorder.events[0].payload.(events.UserLoginBlockedPayload)
+	assert.Equal(t, 120, payload.RiskScore)
+
+	entry := env.audit.last(t, audit.LoginBlocked)
+	assert.Equal(t, env.user.ID, entry.TargetID)
+	assert.Equal(t, 120, entry.Metadata["risk_score"])
+}
+
+func TestLoginRisk_TravelWithEnoughTimeIsNotFlagged(t *testing.T) {
+	env := newLoginRiskTestEnv(t, fakeLocator{
+		strangerIP: {Latitude: 40.7128, Longitude: -74.0060},
+	})
+	env.seedHistory(time.Now().Add(-24 * time.Hour))
+	env.repo.logins[0].Location = &models.GeoPoint{Latitude: 55.7558, Longitude: 37.6173}
+
+	assessment, err := env.risk.Assess(context.Background(), env.user.ID, strangerIP, homeUserAgent)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LoginSignal{models.LoginSignalNewSubnet}, assessment.Signals)
+	assert.Equal(t, models.LoginDecisionAllow, assessment.Decision)
+}
+
+func TestLoginRisk_UnusualHour(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	now := time.Now()
+	var history []time.Time
+	for day := 1; day <= 5; day++ {
+		history = append(history, now.Add(-time.Duration(day)*24*time.Hour-12*time.Hour))
+	}
+	env.seedHistory(history...)
+
+	assessment, err := env.risk.Assess(context.Background(), env.user.ID, homeIP, homeUserAgent)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LoginSignal{models.LoginSignalUnusualHour}, assessment.Signals)
+	assert.Equal(t, 15, assessment.Score)
+
+	env.seedHistory(now.Add(-24*time.Hour + 30*time.Minute))
+	assessment, err = env.risk.Assess(context.Background(), env.user.ID, homeIP, homeUserAgent)
+	require.NoError(t, err)
+	assert.Empty(t, assessment.Signals, "a login around the same time of day is usual")
+}
+
+func TestLoginRisk_ZeroThresholdsDisableDecisions(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.cfg.LoginRiskChallengeThreshold, env.cfg.LoginRiskBlockThreshold = 0, 0
+	env.seedHistory(time.Now().Add(-time.Hour))
+
+	response, err := env.login(strangerAgent, strangerIP)
+	require.NoError(t, err)
+	assert.Nil(t, response.Challenge)
+	assert.Equal(t, 60, env.repo.logins[len(env.repo.logins)-1].RiskScore, "the score is still recorded")
+}
+
+func TestLoginRisk_ForgetDevice(t *testing.T) {
+	env := newLoginRiskTestEnv(t, nil)
+	env.seedHistory(time.Now().Add(-time.Hour))
+	ctx := context.Background()
+
+	err := env.risk.ForgetDevice(ctx, env.user.ID, uuid.New())
+	assert.ErrorIs(t, err, service.ErrDeviceNotFound)
+
+	deviceID := env.repo.devices[0].ID
+	require.NoError(t, env.risk.ForgetDevice(ctx, env.user.ID, deviceID))
+	assert.Equal(t, []string{audit.DeviceForgotten}, env.audit.actions(), "a missing device is not logged")
+	assert.Equal(t, deviceID, env.audit.last(t, audit.DeviceForgotten).Metadata["device_id"])
+
+	assessment, err := env.risk.Assess(ctx, env.user.ID, homeIP, homeUserAgent)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LoginSignal{models.LoginSignalNewDevice}, assessment.Signals)
+}
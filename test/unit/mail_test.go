@@ -0,0 +1,323 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/i18n"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderer_RendersAllTemplatesInAllLocales(t *testing.T) {
+	renderer, err := mail.NewRenderer()
+	require.NoError(t, err)
+	require.Contains(t, renderer.Templates(), "email_change_confirm")
+
+	for _, name := range renderer.Templates() {
+		for _, locale := range []i18n.Locale{i18n.English, i18n.Russian} {
+			msg, err := renderer.Preview(locale, name)
+			require.NoError(t, err, "%s/%s", name, locale)
+
+			assert.NotContains(t, msg.Subject, "email.", "%s/%s: missing subject", name, locale)
+			assert.NotContains(t, msg.Text, "email.", "%s/%s: missing translation in text", name, locale)
+			assert.NotContains(t, msg.HTML, "email.", "%s/%s: missing translation in HTML", name, locale)
+			assert.Contains(t, msg.HTML, "<!DOCTYPE html>", "%s/%s: layout not applied", name, locale)
+			assert.Contains(t, msg.Text, i18n.T(locale, "email.footer", nil))
+			assert.Contains(t, msg.HTML, i18n.T(locale, "email.footer", nil))
+		}
+	}
+}
+
+func TestRenderer_EscapesHTMLButNotText(t *testing.T) {
+	renderer, err := mail.NewRenderer()
+	require.NoError(t, err)
+
+	msg, err := renderer.Render(i18n.English, "email_change_confirm", "alex@example.com", i18n.Args{
+		"name":      "<b>Alex</b>",
+		"new_email": "alex@example.com",
+		"link":      "https://example.com/confirm?token=abc&x=1",
+		"hours":     24,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"alex@example.com"}, msg.To)
+	assert.Equal(t, "Confirm your new email address", msg.Subject)
+	assert.Contains(t, msg.Text, "Hi <b>Alex</b>,")
+	assert.Contains(t, msg.Text, "https://example.com/confirm?token=abc&x=1")
+	assert.Contains(t, msg.Text, "24 hours")
+	assert.Contains(t, msg.HTML, "Hi &lt;b&gt;Alex&lt;/b&gt;,")
+	assert.Contains(t, msg.HTML, `href="https://example.com/confirm?token=abc&amp;x=1"`)
+	assert.Contains(t, msg.HTML, `<html lang="en">`)
+
+	_, err = renderer.Render(i18n.English, "missing", "alex@example.com", nil)
+	assert.ErrorIs(t, err, mail.ErrUnknownTemplate)
+}
+
+func TestBuild_MultipartAlternative(t *testing.T) {
+	data, err := mail.Build("DevPrep <no-reply@devprep.dev>", mail.Message{
+		To:      []string{"Алекс <alex@example.com>"},
+		Subject: "Подтвердите адрес\r\nBcc: victim@example.com",
+		Text:    "Привет",
+		HTML:    "<p>Привет</p>",
+	}, time.Now())
+	require.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Empty(t, parsed.Header.Get("Bcc"), "newlines in the subject must not add headers")
+	assert.Regexp(t, `^<[0-9a-f]{32}@devprep\.dev>$`, parsed.Header.Get("Message-ID"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Подтвердите адрес  Bcc: victim@example.com", subject)
+
+	to, err := parsed.Header.AddressList("To")
+	require.NoError(t, err)
+	assert.Equal(t, "alex@example.com", to[0].Address)
+	assert.Equal(t, "Алекс", to[0].Name)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8: Привет", "text/html; charset=utf-8: <p>Привет</p>"}, bodies)
+}
+
+func TestBuild_InvalidRecipientIsPermanent(t *testing.T) {
+	_, err := mail.Build("no-reply@devprep.dev", mail.Message{To: []string{"not an address"}}, time.Now())
+	assert.True(t, mail.IsPermanent(err))
+
+	_, err = mail.Build("no-reply@devprep.dev", mail.Message{}, time.Now())
+	assert.True(t, mail.IsPermanent(err))
+}
+
+func TestFileMailer_WritesMaildir(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := mail.NewFileMailer(dir, "no-reply@devprep.dev")
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send(context.Background(), mail.Message{
+		To:      []string{"alex@example.com"},
+		Subject: "Hello",
+		Text:    "Plain body",
+	}))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasSuffix(entries[0].Name(), ".eml"))
+
+	tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+
+	file, err := os.Open(filepath.Join(dir, "new", entries[0].Name()))
+	require.NoError(t, err)
+	defer file.Close()
+	parsed, err := netmail.ReadMessage(file)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", parsed.Header.Get("Subject"))
+}
+
+func TestConsoleMailer_PrintsMessage(t *testing.T) {
+	var out strings.Builder
+	mailer := mail.NewConsoleMailerTo(&out)
+
+	require.NoError(t, mailer.Send(context.Background(), mail.Message{
+		To:      []string{"alex@example.com"},
+		Subject: "Hello",
+		Text:    "Plain body",
+		HTML:    "<p>Plain body</p>",
+	}))
+	assert.Contains(t, out.String(), "To: alex@example.com")
+	assert.Contains(t, out.String(), "Plain body")
+	assert.Contains(t, out.String(), "[HTML version: 17 bytes]")
+}
+
+// smtpStandIn - минимальный SMTP-сервер для тестов: принимает письма и может отклонять получателей
+type smtpStandIn struct {
+	listener net.Listener
+	reject   string
+
+	mu       sync.Mutex
+	from     string
+	rcpts    []string
+	messages []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *smtpStandIn) port() string {
+	return fmt.Sprint(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			rcpt := strings.Trim(line[len("RCPT TO:"):], "<> ")
+			if rcpt == s.reject {
+				reply("550 5.1.1 Mailbox unavailable")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, rcpt)
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK: queued")
+		case command == "RSET", command == "NOOP":
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func newTestSMTPMailer(t *testing.T, port string) *mail.SMTPMailer {
+	mailer, err := mail.NewSMTPMailer(mail.SMTPOptions{
+		Host:    "127.0.0.1",
+		Port:    port,
+		From:    "DevPrep <no-reply@devprep.dev>",
+		TLS:     mail.SMTPPlain,
+		Timeout: 2 * time.Second,
+	})
+	require.NoError(t, err)
+	return mailer
+}
+
+func TestSMTPMailer_SendsToStandIn(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer := newTestSMTPMailer(t, server.port())
+
+	err := mailer.Send(context.Background(), mail.Message{
+		To:      []string{"Alex <alex@example.com>", "ops@example.com"},
+		Subject: "Hello",
+		Text:    "Plain body",
+		HTML:    "<p>Plain body</p>",
+	})
+	require.NoError(t, err)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, "no-reply@devprep.dev", server.from)
+	assert.Equal(t, []string{"alex@example.com", "ops@example.com"}, server.rcpts)
+	require.Len(t, server.messages, 1)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(server.messages[0]))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", parsed.Header.Get("Subject"))
+	assert.Contains(t, parsed.Header.Get("Content-Type"), "multipart/alternative")
+}
+
+func TestSMTPMailer_RejectedRecipientIsPermanent(t *testing.T) {
+	server := newSMTPStandIn(t)
+	server.reject = "gone@example.com"
+	mailer := newTestSMTPMailer(t, server.port())
+
+	err := mailer.Send(context.Background(), mail.Message{To: []string{"gone@example.com"}, Subject: "Hi", Text: "Hi"})
+	require.Error(t, err)
+	assert.True(t, mail.IsPermanent(err))
+	assert.Contains(t, err.Error(), "550")
+}
+
+func TestSMTPMailer_UnavailableServerIsRetryable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := fmt.Sprint(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	err = newTestSMTPMailer(t, port).Send(context.Background(), mail.Message{To: []string{"alex@example.com"}, Text: "Hi"})
+	require.Error(t, err)
+	assert.False(t, mail.IsPermanent(err))
+}
+
+func TestSMTPMailer_RequiresSTARTTLSByDefault(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer, err := mail.NewSMTPMailer(mail.SMTPOptions{Host: "127.0.0.1", Port: server.port(), From: "no-reply@devprep.dev"})
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), mail.Message{To: []string{"alex@example.com"}, Text: "Hi"})
+	assert.ErrorContains(t, err, "STARTTLS")
+
+	_, err = mail.NewSMTPMailer(mail.SMTPOptions{Host: "127.0.0.1", TLS: "ssl3"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/metrics"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_HTTPByRouteTemplate(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(middleware.Metrics())
+	app.Get("/metrics-test/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "missing" {
+			return apperrors.ErrNotFound
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	ok := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/metrics-test/:id", "204")
+	notFound := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/metrics-test/:id", "404")
+	okBefore, notFoundBefore := testutil.ToFloat64(ok), testutil.ToFloat64(notFound)
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/metrics-test/missing"} {
+		_, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+		require.NoError(t, err)
+	}
+
+	// Разные id попадают в один ряд с шаблоном маршрута; ошибка учитывается с итоговым статусом
+	assert.Equal(t, okBefore+2, testutil.ToFloat64(ok))
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(notFound))
+}
+
+func TestMetrics_PasswordHashDuration(t *testing.T) {
+	hash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+	assert.True(t, utils.CheckPasswordHash("password123", hash))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.PasswordHashDuration), "hash and verify series")
+}
+
+func TestMetrics_LoginFailures(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, new(MockAuthRepository), &eventRecorder{}, &auditRecorder{}, nil)
+	mockUserRepo.On("GetByEmail", "metrics@example.com").Return(nil, apperrors.ErrNotFound)
+
+	counter := metrics.LoginsTotal.WithLabelValues(metrics.LoginFailure, "unknown_email")
+	before := testutil.ToFloat64(counter)
+
+	_, err := authService.Login(context.Background(), &models.LoginRequest{Email: "metrics@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	require.NoError(t, metrics.RegisterCount("test_items", "Items counted for the test.", time.Second,
+		func(ctx context.Context) (int64, error) { return 7, nil }))
+	require.NoError(t, metrics.RegisterCount("test_broken", "Count that always fails.", time.Second,
+		func(ctx context.Context) (int64, error) { return 0, errors.New("database is down") }))
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	text := string(body)
+	assert.Contains(t, text, "devprep_test_items 7")
+	// Неудачный подсчет пропускает ряд, но не ломает остальные метрики
+	assert.False(t, strings.Contains(text, "devprep_test_broken "))
+	assert.Contains(t, text, "go_goroutines")
+}
@@ -0,0 +1,39 @@
+package unit
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/AtlasOpx/devprep/database/migrations"
+	"github.com/AtlasOpx/devprep/internal/migrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations_SequentialWithUpAndDown(t *testing.T) {
+	embedded, err := migrator.Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, embedded)
+
+	for i, migration := range embedded {
+		assert.Equal(t, uint(i+1), migration.Version, "migration versions must be sequential")
+
+		for _, direction := range []string{"up", "down"} {
+			name := fmt.Sprintf("%06d_%s.%s.sql", migration.Version, migration.Name, direction)
+			_, err := fs.Stat(migrations.FS, name)
+			assert.NoError(t, err, "missing %s", name)
+		}
+	}
+
+	latest, err := migrator.LatestVersion()
+	require.NoError(t, err)
+	assert.Equal(t, embedded[len(embedded)-1].Version, latest)
+}
+
+func TestCheckStatus(t *testing.T) {
+	assert.NoError(t, migrator.CheckStatus(&migrator.Status{Current: 8, Latest: 8}))
+	assert.NoError(t, migrator.CheckStatus(&migrator.Status{Current: 9, Latest: 8}))
+	assert.Error(t, migrator.CheckStatus(&migrator.Status{Current: 7, Latest: 8}))
+	assert.Error(t, migrator.CheckStatus(&migrator.Status{Current: 8, Latest: 8, Dirty: true}))
+}
@@ -0,0 +1,340 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/outbox"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOutboxStore - таблица outbox в памяти с тем же правилом выборки, что и FetchPending
+type fakeOutboxStore struct {
+	mu     sync.Mutex
+	nextID int64
+	events []*models.OutboxEvent
+}
+
+func (s *fakeOutboxStore) Append(ctx context.Context, event *models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	stored := *event
+	stored.ID = s.nextID
+	stored.NextAttemptAt = event.OccurredAt
+	s.events = append(s.events, &stored)
+	return nil
+}
+
+func (s *fakeOutboxStore) FetchPending(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	waiting := make(map[uuid.UUID]bool)
+	var pending []models.OutboxEvent
+	for _, event := range s.events {
+		if event.PublishedAt != nil {
+			continue
+		}
+		if event.NextAttemptAt.After(now) {
+			waiting[event.AggregateID] = true
+			continue
+		}
+		if !waiting[event.AggregateID] && len(pending) < limit {
+			pending = append(pending, *event)
+		}
+	}
+	return pending, nil
+}
+
+func (s *fakeOutboxStore) MarkPublished(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	event := s.events[id-1]
+	event.PublishedAt = &now
+	event.Attempts++
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkFailed(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event := s.events[id-1]
+	event.NextAttemptAt = nextAttemptAt
+	event.LastError = &lastError
+	event.Attempts++
+	return nil
+}
+
+func (s *fakeOutboxStore) DeletePublished(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeOutboxStore) retryNow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.events {
+		event.NextAttemptAt = time.Now()
+	}
+}
+
+// memorySink запоминает опубликованные события и может отказывать выбранным
+type memorySink struct {
+	mu        sync.Mutex
+	published []events.Event
+	reject    func(event events.Event) bool
+}
+
+func (s *memorySink) Name() string { return "memory" }
+
+func (s *memorySink) Publish(ctx context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reject != nil && s.reject(event) {
+		return errors.New("broker unavailable")
+	}
+	s.published = append(s.published, event)
+	return nil
+}
+
+func (s *memorySink) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, event := range s.published {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func newTestOutbox(store *fakeOutboxStore, locker *fakeLocker, sinks ...outbox.Sink) *outbox.Outbox {
+	return outbox.New(store, locker, sinks, outbox.Options{PollInterval: 10 * time.Millisecond, BatchSize: 2})
+}
+
+func TestOutbox_RelayPublishesInOrder(t *testing.T) {
+	store := &fakeOutboxStore{}
+	sink := &memorySink{}
+	o := newTestOutbox(store, &fakeLocker{}, sink)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	require.NoError(t, o.Record(ctx, events.AggregateUser, userID, events.UserRegistered, events.UserPayload{UserID: userID}))
+	require.NoError(t, o.Record(ctx, events.AggregateUser, userID, events.UserLoggedIn, events.UserPayload{UserID: userID}))
+	require.NoError(t, o.Record(ctx, events.AggregateUser, userID, events.UserDeleted, events.UserPayload{UserID: userID}))
+
+	published, err := o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published, "batches are fetched until the outbox is drained")
+	assert.Equal(t, []string{events.UserRegistered, events.UserLoggedIn, events.UserDeleted}, sink.types())
+
+	var payload events.UserPayload
+	require.NoError(t, json.Unmarshal(sink.published[0].Payload, &payload))
+	assert.Equal(t, userID, payload.UserID)
+	assert.Equal(t, userID, sink.published[0].AggregateID)
+
+	published, err = o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published, "published events are not sent again")
+}
+
+func TestOutbox_FailedEventBlocksOnlyItsAggregate(t *testing.T) {
+	store := &fakeOutboxStore{}
+	failing := uuid.New()
+	healthy := uuid.New()
+	var broken sync.Once
+	sink := &memorySink{}
+	sink.reject = func(event events.Event) bool {
+		rejected := false
+		if event.AggregateID == failing {
+			broken.Do(func() { rejected = true })
+		}
+		return rejected
+	}
+	o := newTestOutbox(store, &fakeLocker{}, sink)
+	ctx := context.Background()
+
+	require.NoError(t, o.Record(ctx, events.AggregateUser, failing, events.UserRegistered, nil))
+	require.NoError(t, o.Record(ctx, events.AggregateUser, healthy, events.UserRegistered, nil))
+	require.NoError(t, o.Record(ctx, events.AggregateUser, failing, events.UserDeleted, nil))
+
+	published, err := o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	require.Len(t, sink.published, 1)
+	assert.Equal(t, healthy, sink.published[0].AggregateID, "later events of the failed aggregate must wait")
+	require.NotNil(t, store.events[0].LastError)
+	assert.Contains(t, *store.events[0].LastError, "memory: broker unavailable")
+
+	store.retryNow()
+	published, err = o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{events.UserRegistered, events.UserRegistered, events.UserDeleted}, sink.types())
+	assert.Equal(t, failing, sink.published[1].AggregateID)
+}
+
+func TestOutbox_RelaySkipsWhenLockHeldElsewhere(t *testing.T) {
+	store := &fakeOutboxStore{}
+	locker := &fakeLocker{}
+	sink := &memorySink{}
+	o := newTestOutbox(store, locker, sink)
+	ctx := context.Background()
+
+	require.NoError(t, o.Record(ctx, events.AggregateUser, uuid.New(), events.UserRegistered, nil))
+
+	unlock, acquired, err := locker.TryAdvisoryLock(ctx, "outbox:relay")
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	published, err := o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Empty(t, sink.published)
+
+	unlock()
+	published, err = o.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+}
+
+func TestOutbox_StartPublishesRecordedEvents(t *testing.T) {
+	store := &fakeOutboxStore{}
+	bus := events.NewBus()
+	received := make(chan events.Event, 1)
+	bus.Subscribe(events.UserLoggedIn, func(ctx context.Context, event events.Event) error {
+		received <- event
+		return nil
+	})
+
+	o := newTestOutbox(store, &fakeLocker{}, bus)
+	o.Start()
+
+	userID := uuid.New()
+	require.NoError(t, o.Record(context.Background(), events.AggregateUser, userID, events.UserLoggedIn, nil))
+
+	select {
+	case event := <-received:
+		assert.Equal(t, userID, event.AggregateID)
+	case <-time.After(3 * time.Second):
+		t.Fatal("event was not delivered to the bus")
+	}
+	assert.NoError(t, o.Shutdown(context.Background()))
+}
+
+func TestBus_DeliversToTypeAndWildcardSubscribers(t *testing.T) {
+	bus := events.NewBus()
+	var calls []string
+	bus.Subscribe(events.UserDeleted, func(ctx context.Context, event events.Event) error {
+		calls = append(calls, "deleted")
+		return nil
+	})
+	bus.Subscribe(events.AllEvents, func(ctx context.Context, event events.Event) error {
+		calls = append(calls, "all")
+		return errors.New("audit unavailable")
+	})
+	bus.Subscribe(events.AllEvents, func(ctx context.Context, event events.Event) error {
+		panic("nil pointer")
+	})
+
+	err := bus.Publish(context.Background(), events.Event{Type: events.UserDeleted})
+	assert.ErrorContains(t, err, "audit unavailable")
+	assert.ErrorContains(t, err, "panic")
+	assert.Equal(t, []string{"deleted", "all"}, calls)
+
+	calls = nil
+	_ = bus.Publish(context.Background(), events.Event{Type: events.UserLoggedIn})
+	assert.Equal(t, []string{"all"}, calls)
+}
+
+// fakeJetStream - локальная замена JetStream
+type fakeJetStream struct {
+	msgs []*nats.Msg
+}
+
+func (js *fakeJetStream) PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	js.msgs = append(js.msgs, m)
+	return &nats.PubAck{Stream: "EVENTS", Sequence: uint64(len(js.msgs))}, nil
+}
+
+func TestNATSSink_PublishesWithDeduplicationID(t *testing.T) {
+	js := &fakeJetStream{}
+	sink := outbox.NewNATSSink(js, "devprep.events")
+	event := events.Event{ID: uuid.New(), Type: events.SessionRevoked, AggregateID: uuid.New()}
+
+	require.NoError(t, sink.Publish(context.Background(), event))
+
+	require.Len(t, js.msgs, 1)
+	assert.Equal(t, "devprep.events.session.revoked", js.msgs[0].Subject)
+	assert.Equal(t, event.ID.String(), js.msgs[0].Header.Get(nats.MsgIdHdr))
+
+	var decoded events.Event
+	require.NoError(t, json.Unmarshal(js.msgs[0].Data, &decoded))
+	assert.Equal(t, event.ID, decoded.ID)
+}
+
+// fakeKafkaWriter - локальная замена kafka.Writer
+type fakeKafkaWriter struct {
+	msgs []kafka.Message
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestKafkaSink_KeysByAggregate(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	sink := outbox.NewKafkaSink(writer)
+	event := events.Event{ID: uuid.New(), Type: events.UserRegistered, AggregateID: uuid.New()}
+
+	require.NoError(t, sink.Publish(context.Background(), event))
+
+	require.Len(t, writer.msgs, 1)
+	assert.Equal(t, event.AggregateID.String(), string(writer.msgs[0].Key))
+	headers := map[string]string{}
+	for _, header := range writer.msgs[0].Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, event.ID.String(), headers["event_id"])
+	assert.Equal(t, events.UserRegistered, headers["event_type"])
+}
+
+func TestWebhookSink_RequiresSuccessStatus(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get("X-Event-Type"))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := outbox.NewWebhookSink(server.URL, time.Second)
+	event := events.Event{ID: uuid.New(), Type: events.UserUpdated}
+
+	assert.NoError(t, sink.Publish(context.Background(), event))
+
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, sink.Publish(context.Background(), event), "503")
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(received)
+	assert.Equal(t, []string{events.UserUpdated, events.UserUpdated}, received)
+}
@@ -0,0 +1,483 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueueStore - хранилище очереди в памяти с той же семантикой, что и таблица jobs
+type fakeQueueStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*models.QueueJob
+}
+
+func newFakeQueueStore() *fakeQueueStore {
+	return &fakeQueueStore{jobs: make(map[uuid.UUID]*models.QueueJob)}
+}
+
+func (s *fakeQueueStore) Enqueue(ctx context.Context, job *models.QueueJob) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.UniqueKey != nil {
+		for _, existing := range s.jobs {
+			active := existing.Status == models.QueueJobStatusPending || existing.Status == models.QueueJobStatusRunning
+			if active && existing.Kind == job.Kind && existing.UniqueKey != nil && *existing.UniqueKey == *job.UniqueKey {
+				return false, nil
+			}
+		}
+	}
+	stored := *job
+	stored.Status = models.QueueJobStatusPending
+	s.jobs[job.ID] = &stored
+	return true, nil
+}
+
+func (s *fakeQueueStore) Claim(ctx context.Context, kinds []string, worker string) (*models.QueueJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Status != models.QueueJobStatusPending || job.RunAt.After(time.Now()) {
+			continue
+		}
+		for _, kind := range kinds {
+			if job.Kind == kind {
+				job.Status = models.QueueJobStatusRunning
+				job.Attempts++
+				job.LockedBy = &worker
+				claimed := *job
+				return &claimed, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeQueueStore) Complete(ctx context.Context, id uuid.UUID, worker string) error {
+	return s.finish(id, worker, models.QueueJobStatusCompleted, "")
+}
+
+func (s *fakeQueueStore) Retry(ctx context.Context, id uuid.UUID, worker string, runAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if !ownedBy(job, worker) {
+		return apperrors.ErrNotFound
+	}
+	job.Status, job.RunAt, job.LastError, job.LockedBy = models.QueueJobStatusPending, runAt, &lastError, nil
+	return nil
+}
+
+func (s *fakeQueueStore) Bury(ctx context.Context, id uuid.UUID, worker string, lastError string) error {
+	return s.finish(id, worker, models.QueueJobStatusDead, lastError)
+}
+
+func (s *fakeQueueStore) finish(id uuid.UUID, worker string, status models.QueueJobStatus, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if !ownedBy(job, worker) {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	job.Status, job.FinishedAt, job.LockedBy = status, &now, nil
+	if lastError != "" {
+		job.LastError = &lastError
+	}
+	return nil
+}
+
+func ownedBy(job *models.QueueJob, worker string) bool {
+	return job.Status == models.QueueJobStatusRunning && job.LockedBy != nil && *job.LockedBy == worker
+}
+
+// rescue возвращает выполняемую задачу в очередь, как RescueStale
+func (s *fakeQueueStore) rescue(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	job.Status, job.LockedBy = models.QueueJobStatusPending, nil
+}
+
+func (s *fakeQueueStore) RescueStale(ctx context.Context, lockedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeQueueStore) Requeue(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.QueueJobStatusDead {
+		return false, nil
+	}
+	job.Status, job.Attempts, job.RunAt, job.FinishedAt = models.QueueJobStatusPending, 0, time.Now(), nil
+	return true, nil
+}
+
+func (s *fakeQueueStore) DeleteCompleted(ctx context.Context, finishedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeQueueStore) GetByID(ctx context.Context, id uuid.UUID) (*models.QueueJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+func (s *fakeQueueStore) List(ctx context.Context, filter models.QueueJobFilter) ([]models.QueueJob, int64, error) {
+	return nil, 0, nil
+}
+
+func (s *fakeQueueStore) Stats(ctx context.Context) ([]models.QueueStat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups := make(map[[2]string]*models.QueueStat)
+	var stats []models.QueueStat
+	for _, job := range s.jobs {
+		key := [2]string{job.Kind, string(job.Status)}
+		stat, ok := groups[key]
+		if !ok {
+			stat = &models.QueueStat{Kind: job.Kind, Status: job.Status, OldestRunAt: job.RunAt}
+			groups[key] = stat
+		}
+		stat.Count++
+		if job.RunAt.Before(stat.OldestRunAt) {
+			stat.OldestRunAt = job.RunAt
+		}
+	}
+	for _, stat := range groups {
+		stats = append(stats, *stat)
+	}
+	return stats, nil
+}
+
+func waitForStatus(t *testing.T, store *fakeQueueStore, id uuid.UUID, status models.QueueJobStatus) *models.QueueJob {
+	t.Helper()
+	var job *models.QueueJob
+	require.Eventually(t, func() bool {
+		job, _ = store.GetByID(context.Background(), id)
+		return job != nil && job.Status == status
+	}, 3*time.Second, 5*time.Millisecond, "job did not reach status %s", status)
+	return job
+}
+
+type testPayload struct {
+	Value string `json:"value"`
+}
+
+func TestBackoff_GrowsExponentiallyWithinBounds(t *testing.T) {
+	base, max := 10*time.Second, time.Hour
+
+	for attempt := 1; attempt <= 20; attempt++ {
+		expected := base << (attempt - 1)
+		if attempt > 12 || expected > max {
+			expected = max
+		}
+
+		delay := queue.Backoff(attempt, base, max)
+		assert.GreaterOrEqual(t, delay, expected/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, expected, "attempt %d", attempt)
+	}
+
+	assert.Equal(t, time.Duration(0), queue.Backoff(3, 0, max))
+	assert.LessOrEqual(t, queue.Backoff(1000, base, max), max, "a large attempt number must not overflow")
+}
+
+func TestQueue_Enqueue_UnknownKind(t *testing.T) {
+	q := queue.New(newFakeQueueStore(), queue.Options{})
+
+	_, err := q.Enqueue(context.Background(), "missing", testPayload{})
+	assert.Error(t, err)
+}
+
+func TestQueue_Enqueue_UniqueKey(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{MaxAttempts: 3})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error { return nil })
+
+	first, err := q.Enqueue(context.Background(), "test", testPayload{Value: "a"}, queue.WithUniqueKey("user-1"))
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, first)
+
+	second, err := q.Enqueue(context.Background(), "test", testPayload{Value: "b"}, queue.WithUniqueKey("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, second, "a pending job with the same key already exists")
+
+	job, err := store.GetByID(context.Background(), first)
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.MaxAttempts)
+	assert.JSONEq(t, `{"value":"a"}`, string(job.Payload))
+}
+
+func TestQueue_ProcessesTypedPayload(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{Workers: 2, PollInterval: 10 * time.Millisecond, MaxAttempts: 3})
+
+	received := make(chan string, 1)
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		received <- payload.Value
+		return nil
+	})
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{Value: "hello"})
+	require.NoError(t, err)
+
+	job := waitForStatus(t, store, id, models.QueueJobStatusCompleted)
+	assert.Equal(t, "hello", <-received)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestQueue_DiscardsResultOfRescuedJob(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{Workers: 1, PollInterval: 5 * time.Millisecond, MaxAttempts: 3})
+
+	var id uuid.UUID
+	var once sync.Once
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		// Первая попытка длилась слишком долго: задачу вернули в очередь, пока она выполнялась
+		once.Do(func() { store.rescue(id) })
+		return nil
+	})
+
+	var err error
+	id, err = q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	// Результат первой попытки не записан, задачу выполнила вторая
+	job := waitForStatus(t, store, id, models.QueueJobStatusCompleted)
+	assert.Equal(t, 2, job.Attempts)
+}
+
+func TestQueue_RetriesThenDeadLetters(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 3})
+
+	var mu sync.Mutex
+	var lastAttempts []bool
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		mu.Lock()
+		defer mu.Unlock()
+		lastAttempts = append(lastAttempts, queue.LastAttempt(ctx))
+		return errors.New("smtp unavailable")
+	})
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+
+	job := waitForStatus(t, store, id, models.QueueJobStatusDead)
+	assert.Equal(t, 3, job.Attempts)
+	require.NotNil(t, job.LastError)
+	assert.Equal(t, "smtp unavailable", *job.LastError)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{false, false, true}, lastAttempts)
+}
+
+func TestQueue_PermanentErrorSkipsRetries(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 5})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		return queue.Permanent(errors.New("recipient rejected"))
+	})
+	q.Handle("raw", func(ctx context.Context, job *models.QueueJob) error {
+		panic("unexpected payload")
+	})
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	job := waitForStatus(t, store, id, models.QueueJobStatusDead)
+	assert.Equal(t, 1, job.Attempts)
+
+	// Паника обработчика - обычная ошибка: задача повторяется, пока не кончатся попытки
+	id, err = q.Enqueue(context.Background(), "raw", nil, queue.WithMaxAttempts(2))
+	require.NoError(t, err)
+	job = waitForStatus(t, store, id, models.QueueJobStatusDead)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Contains(t, *job.LastError, "panic: unexpected payload")
+}
+
+func TestQueue_InvalidPayloadIsDeadLettered(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 5})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error { return nil })
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", []int{1, 2})
+	require.NoError(t, err)
+
+	job := waitForStatus(t, store, id, models.QueueJobStatusDead)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Contains(t, *job.LastError, "invalid payload")
+}
+
+func TestQueue_DelayedJobWaitsForRunAt(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 1})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error { return nil })
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{}, queue.WithDelay(time.Hour))
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	job, err := store.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, models.QueueJobStatusPending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+}
+
+func TestQueue_Lag(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error { return nil })
+
+	lag, err := q.Lag(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), lag)
+
+	overdue, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	_, err = q.Enqueue(context.Background(), "test", testPayload{}, queue.WithDelay(time.Hour))
+	require.NoError(t, err)
+
+	store.mu.Lock()
+	store.jobs[overdue].RunAt = time.Now().Add(-10 * time.Minute)
+	store.mu.Unlock()
+
+	lag, err = q.Lag(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, (10 * time.Minute).Seconds(), lag.Seconds(), 5)
+}
+
+func TestQueue_RetryJob(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 1})
+
+	var fail sync.Once
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		var err error
+		fail.Do(func() { err = errors.New("temporary") })
+		return err
+	})
+	q.Start()
+	defer q.Shutdown(context.Background())
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	waitForStatus(t, store, id, models.QueueJobStatusDead)
+
+	job, err := q.RetryJob(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, job.Attempts)
+
+	waitForStatus(t, store, id, models.QueueJobStatusCompleted)
+
+	_, err = q.RetryJob(context.Background(), id)
+	assert.ErrorIs(t, err, apperrors.ErrJobNotRetryable, "only dead jobs can be retried")
+
+	_, err = q.RetryJob(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}
+
+func TestQueue_ShutdownDrainsRunningJob(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 3})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		close(started)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	q.Start()
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not started")
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- q.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("shutdown returned before the running job finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-shutdownDone:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after the job finished")
+	}
+
+	job, err := store.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, models.QueueJobStatusCompleted, job.Status)
+}
+
+func TestQueue_ShutdownReturnsCancelledJobToQueue(t *testing.T) {
+	store := newFakeQueueStore()
+	q := queue.New(store, queue.Options{PollInterval: 5 * time.Millisecond, MaxAttempts: 3, BackoffBase: time.Hour})
+
+	started := make(chan struct{})
+	queue.Register(q, "test", func(ctx context.Context, payload testPayload) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start()
+
+	id, err := q.Enqueue(context.Background(), "test", testPayload{})
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+
+	job, err := store.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, models.QueueJobStatusPending, job.Status, "an interrupted job must be retried later")
+}
@@ -0,0 +1,40 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginRateLimit_SharedBetweenLoginAndVerify(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	limit := middleware.LoginRateLimit(3, time.Minute)
+	ok := func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	}
+	app.Post("/auth/login", limit, ok)
+	app.Post("/auth/login/verify", limit, ok)
+
+	post := func(path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, post("/auth/login").StatusCode)
+	assert.Equal(t, http.StatusOK, post("/auth/login/verify").StatusCode)
+	assert.Equal(t, http.StatusOK, post("/auth/login/verify").StatusCode)
+
+	// Четвертый запрос с того же адреса отклоняется, на какой бы маршрут он ни шел
+	resp := post("/auth/login/verify")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, post("/auth/login").StatusCode)
+}
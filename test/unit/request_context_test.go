@@ -0,0 +1,92 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContext_SetsDeadline(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.RequestContext(context.Background(), 50*time.Millisecond))
+
+	var ctxErr error
+	var hasDeadline bool
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		_, hasDeadline = ctx.Deadline()
+
+		select {
+		case <-ctx.Done():
+			ctxErr = ctx.Err()
+		case <-time.After(time.Second):
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	start := time.Now()
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil), -1)
+	require.NoError(t, err)
+
+	assert.True(t, hasDeadline)
+	assert.ErrorIs(t, ctxErr, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestRequestContext_CanceledWithBase(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+
+	app := fiber.New()
+	app.Use(middleware.RequestContext(base, 0))
+
+	var ctxErr error
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		// Имитируем остановку сервера посреди запроса
+		cancel()
+		<-c.UserContext().Done()
+		ctxErr = c.UserContext().Err()
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil), -1)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, ctxErr, context.Canceled)
+}
+
+func TestRequestContext_RequestID(t *testing.T) {
+	app := fiber.New()
+	app.Use(middleware.RequestContext(context.Background(), 0))
+
+	var client events.Client
+	app.Get("/", func(c *fiber.Ctx) error {
+		client = events.ClientFrom(c.UserContext())
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-123")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, "req-123", resp.Header.Get(fiber.HeaderXRequestID))
+	assert.Equal(t, "req-123", client.RequestID)
+
+	// Заголовок с пробелами не принимается: вместо него генерируется новый ID
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "bad id")
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.NotEqual(t, "bad id", client.RequestID)
+	assert.Equal(t, client.RequestID, resp.Header.Get(fiber.HeaderXRequestID))
+	_, err = uuid.Parse(client.RequestID)
+	assert.NoError(t, err)
+}
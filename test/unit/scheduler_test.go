@@ -0,0 +1,282 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/scheduler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLocker struct {
+	mu     sync.Mutex
+	held   map[string]bool
+	failed bool
+}
+
+func (l *fakeLocker) TryAdvisoryLock(ctx context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failed {
+		return nil, false, errors.New("connection refused")
+	}
+	if l.held == nil {
+		l.held = make(map[string]bool)
+	}
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, name)
+	}, true, nil
+}
+
+type fakeRun struct {
+	jobName     string
+	scheduledAt time.Time
+	processed   int64
+	err         error
+	finished    bool
+}
+
+type fakeHistory struct {
+	mu   sync.Mutex
+	runs map[uuid.UUID]*fakeRun
+}
+
+func (h *fakeHistory) StartRun(ctx context.Context, jobName string, scheduledAt time.Time, instance string) (uuid.UUID, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.runs == nil {
+		h.runs = make(map[uuid.UUID]*fakeRun)
+	}
+	for _, run := range h.runs {
+		if run.jobName == jobName && run.scheduledAt.Equal(scheduledAt) {
+			return uuid.Nil, false, nil
+		}
+	}
+	id := uuid.New()
+	h.runs[id] = &fakeRun{jobName: jobName, scheduledAt: scheduledAt}
+	return id, true, nil
+}
+
+func (h *fakeHistory) FinishRun(ctx context.Context, id uuid.UUID, processed int64, runErr error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	run := h.runs[id]
+	run.processed, run.err, run.finished = processed, runErr, true
+	return nil
+}
+
+func (h *fakeHistory) finished() []fakeRun {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var runs []fakeRun
+	for _, run := range h.runs {
+		if run.finished {
+			runs = append(runs, *run)
+		}
+	}
+	return runs
+}
+
+func TestScheduler_Register_InvalidSpec(t *testing.T) {
+	s := scheduler.New(&fakeLocker{}, &fakeHistory{}, scheduler.Options{})
+
+	err := s.Register("broken", "every minute", func(ctx context.Context) (int64, error) { return 0, nil })
+	assert.Error(t, err)
+
+	noop := func(ctx context.Context) (int64, error) { return 0, nil }
+	require.NoError(t, s.Register("cleanup", "*/5 * * * *", noop))
+	assert.Error(t, s.Register("cleanup", "@hourly", noop), "duplicate job names must be rejected")
+	assert.Equal(t, []string{"cleanup"}, s.Jobs())
+}
+
+func TestScheduler_RunNow_RecordsHistory(t *testing.T) {
+	history := &fakeHistory{}
+	s := scheduler.New(&fakeLocker{}, history, scheduler.Options{})
+	require.NoError(t, s.Register("cleanup", "@hourly", func(ctx context.Context) (int64, error) {
+		return 7, nil
+	}))
+
+	require.NoError(t, s.RunNow(context.Background(), "cleanup"))
+
+	runs := history.finished()
+	require.Len(t, runs, 1)
+	assert.Equal(t, "cleanup", runs[0].jobName)
+	assert.Equal(t, int64(7), runs[0].processed)
+	assert.NoError(t, runs[0].err)
+}
+
+func TestScheduler_RunNow_RecordsFailureAndPanic(t *testing.T) {
+	history := &fakeHistory{}
+	s := scheduler.New(&fakeLocker{}, history, scheduler.Options{})
+	require.NoError(t, s.Register("failing", "@hourly", func(ctx context.Context) (int64, error) {
+		return 0, errors.New("boom")
+	}))
+	require.NoError(t, s.Register("panicking", "@hourly", func(ctx context.Context) (int64, error) {
+		panic("nil map")
+	}))
+
+	assert.EqualError(t, s.RunNow(context.Background(), "failing"), "boom")
+	assert.ErrorContains(t, s.RunNow(context.Background(), "panicking"), "panic: nil map")
+
+	runs := history.finished()
+	require.Len(t, runs, 2)
+	for _, run := range runs {
+		assert.Error(t, run.err)
+	}
+}
+
+func TestScheduler_SkipsWhenLockHeldElsewhere(t *testing.T) {
+	locker := &fakeLocker{}
+	history := &fakeHistory{}
+	s := scheduler.New(locker, history, scheduler.Options{})
+
+	called := false
+	require.NoError(t, s.Register("cleanup", "@hourly", func(ctx context.Context) (int64, error) {
+		called = true
+		return 0, nil
+	}))
+
+	unlock, acquired, err := locker.TryAdvisoryLock(context.Background(), "job:cleanup")
+	require.NoError(t, err)
+	require.True(t, acquired)
+	defer unlock()
+
+	assert.NoError(t, s.RunNow(context.Background(), "cleanup"))
+	assert.False(t, called)
+	assert.Empty(t, history.finished())
+}
+
+func TestScheduler_LockErrorIsReported(t *testing.T) {
+	s := scheduler.New(&fakeLocker{failed: true}, &fakeHistory{}, scheduler.Options{})
+	require.NoError(t, s.Register("cleanup", "@hourly", func(ctx context.Context) (int64, error) { return 0, nil }))
+
+	assert.Error(t, s.RunNow(context.Background(), "cleanup"))
+}
+
+func TestScheduler_RunNow_AppliesTimeout(t *testing.T) {
+	history := &fakeHistory{}
+	s := scheduler.New(&fakeLocker{}, history, scheduler.Options{Timeout: 20 * time.Millisecond})
+	require.NoError(t, s.Register("slow", "@hourly", func(ctx context.Context) (int64, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+
+	err := s.RunNow(context.Background(), "slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	runs := history.finished()
+	require.Len(t, runs, 1, "the result must be recorded even after the timeout")
+	assert.ErrorIs(t, runs[0].err, context.DeadlineExceeded)
+}
+
+func TestScheduler_EveryIsAlignedAcrossReplicas(t *testing.T) {
+	history := &fakeHistory{}
+	job := func(ctx context.Context) (int64, error) { return 1, nil }
+
+	// Реплики запущены в разное время, но планируют @every на одни и те же моменты
+	first := scheduler.New(&fakeLocker{}, history, scheduler.Options{Instance: "a"})
+	second := scheduler.New(&fakeLocker{}, history, scheduler.Options{Instance: "b"})
+	require.NoError(t, first.Register("tick", "@every 1s", job))
+	require.NoError(t, second.Register("tick", "@every 1s", job))
+	first.Start()
+	time.Sleep(400 * time.Millisecond)
+	second.Start()
+	time.Sleep(2100 * time.Millisecond)
+	require.NoError(t, first.Shutdown(context.Background()))
+	require.NoError(t, second.Shutdown(context.Background()))
+
+	runs := history.finished()
+	require.NotEmpty(t, runs)
+	for _, run := range runs {
+		assert.Equal(t, run.scheduledAt.Truncate(time.Second), run.scheduledAt)
+	}
+	// Без выравнивания каждая реплика выполнила бы свои запуски: около четырех за 2,5 секунды
+	assert.LessOrEqual(t, len(runs), 3)
+}
+
+func TestScheduler_ShutdownDrainsRunningJob(t *testing.T) {
+	history := &fakeHistory{}
+	s := scheduler.New(&fakeLocker{}, history, scheduler.Options{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	require.NoError(t, s.Register("long", "@every 1s", func(ctx context.Context) (int64, error) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}))
+
+	s.Start()
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not started")
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- s.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("shutdown returned before the running job finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-shutdownDone:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after the job finished")
+	}
+
+	runs := history.finished()
+	require.Len(t, runs, 1)
+	assert.NoError(t, runs[0].err)
+}
+
+func TestScheduler_ShutdownCancelsJobsAfterDeadline(t *testing.T) {
+	history := &fakeHistory{}
+	s := scheduler.New(&fakeLocker{}, history, scheduler.Options{})
+
+	started := make(chan struct{})
+	var once sync.Once
+	require.NoError(t, s.Register("stuck", "@every 1s", func(ctx context.Context) (int64, error) {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}))
+
+	s.Start()
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not started")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+
+	runs := history.finished()
+	require.Len(t, runs, 1)
+	assert.ErrorIs(t, runs[0].err, context.Canceled)
+}
@@ -0,0 +1,498 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/mail"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecurityRepo - настройки уведомлений, ссылки сброса пароля и «это был не я» в памяти
+type fakeSecurityRepo struct {
+	disabled map[uuid.UUID][]string
+	resets   map[uuid.UUID]*models.PasswordResetToken
+	notMe    map[uuid.UUID]*models.NotMeToken
+	// previousEmails - адреса, с которых email сменили подтвержденным запросом
+	previousEmails map[string]bool
+	// delivered - события, по которым уже поставлено уведомление
+	delivered map[uuid.UUID]bool
+}
+
+func newFakeSecurityRepo() *fakeSecurityRepo {
+	return &fakeSecurityRepo{
+		disabled:       make(map[uuid.UUID][]string),
+		resets:         make(map[uuid.UUID]*models.PasswordResetToken),
+		notMe:          make(map[uuid.UUID]*models.NotMeToken),
+		previousEmails: make(map[string]bool),
+		delivered:      make(map[uuid.UUID]bool),
+	}
+}
+
+func (r *fakeSecurityRepo) GetDisabledAlerts(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return r.disabled[userID], nil
+}
+
+func (r *fakeSecurityRepo) SetDisabledAlerts(ctx context.Context, userID uuid.UUID, alerts []string) error {
+	r.disabled[userID] = alerts
+	return nil
+}
+
+func (r *fakeSecurityRepo) CreatePasswordReset(ctx context.Context, token *models.PasswordResetToken) error {
+	copied := *token
+	r.resets[token.ID] = &copied
+	return nil
+}
+
+func (r *fakeSecurityRepo) GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	for _, reset := range r.resets {
+		if reset.TokenHash == tokenHash {
+			copied := *reset
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeSecurityRepo) InvalidatePasswordResets(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now()
+	for _, reset := range r.resets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			reset.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSecurityRepo) MarkPasswordResetUsed(ctx context.Context, id uuid.UUID) error {
+	reset, ok := r.resets[id]
+	if !ok || reset.UsedAt != nil {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	reset.UsedAt = &now
+	return nil
+}
+
+func (r *fakeSecurityRepo) DeleteExpiredPasswordResets(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeSecurityRepo) CreateNotMeToken(ctx context.Context, token *models.NotMeToken) error {
+	copied := *token
+	r.notMe[token.ID] = &copied
+	return nil
+}
+
+func (r *fakeSecurityRepo) GetNotMeTokenByHash(ctx context.Context, tokenHash string) (*models.NotMeToken, error) {
+	for _, token := range r.notMe {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeSecurityRepo) MarkNotMeTokenUsed(ctx context.Context, id uuid.UUID) error {
+	token, ok := r.notMe[id]
+	if !ok || token.UsedAt != nil {
+		return apperrors.ErrNotFound
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return nil
+}
+
+func (r *fakeSecurityRepo) DeleteExpiredNotMeTokens(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeSecurityRepo) RecordAlertDelivery(ctx context.Context, eventID, userID uuid.UUID, alert models.SecurityAlert) (bool, error) {
+	if r.delivered[eventID] {
+		return false, nil
+	}
+	r.delivered[eventID] = true
+	return true, nil
+}
+
+func (r *fakeSecurityRepo) DeleteAlertDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeSecurityRepo) IsConfirmedPreviousEmail(ctx context.Context, userID uuid.UUID, email string) (bool, error) {
+	return r.previousEmails[email], nil
+}
+
+type securityTestEnv struct {
+	service  *service.SecurityService
+	userRepo *MockUserRepository
+	authRepo *MockAuthRepository
+	repo     *fakeSecurityRepo
+	jobs     *fakeEnqueuer
+	recorder *eventRecorder
+	audit    *auditRecorder
+	user     *models.User
+}
+
+func newSecurityTestEnv(t *testing.T) *securityTestEnv {
+	renderer, err := mail.NewRenderer()
+	require.NoError(t, err)
+
+	env := &securityTestEnv{
+		userRepo: new(MockUserRepository),
+		authRepo: new(MockAuthRepository),
+		repo:     newFakeSecurityRepo(),
+		jobs:     &fakeEnqueuer{},
+		recorder: &eventRecorder{},
+		audit:    &auditRecorder{},
+		user: &models.User{
+			ID:        uuid.New(),
+			Email:     "alex@example.com",
+			FirstName: "Alex",
+			IsActive:  true,
+		},
+	}
+	cfg := &config.Config{
+		PublicBaseURL:    "https://devprep.example.com",
+		SecurityLinkTTL:  time.Hour,
+		PasswordResetTTL: 30 * time.Minute,
+		DefaultLocale:    "en",
+	}
+	authService := service.NewAuthService(fakeTx{}, env.userRepo, env.authRepo, env.recorder, env.audit, nil)
+	env.service = service.NewSecurityService(fakeTx{}, env.userRepo, env.repo, authService, env.jobs, env.audit, renderer, cfg)
+	env.userRepo.On("GetByID", env.user.ID).Return(env.user, nil).Maybe()
+	return env
+}
+
+func (env *securityTestEnv) event(t *testing.T, eventType string, payload interface{}) events.Event {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return events.Event{
+		ID:          uuid.New(),
+		Type:        eventType,
+		AggregateID: env.user.ID,
+		OccurredAt:  time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC),
+		Payload:     data,
+	}
+}
+
+func (env *securityTestEnv) messages() []mail.Message {
+	var messages []mail.Message
+	for _, payload := range env.jobs.payloads {
+		messages = append(messages, payload.(mail.Message))
+	}
+	return messages
+}
+
+var linkPattern = regexp.MustCompile(`https://devprep\.example\.com/api/v1/\S+`)
+
+// notMeToken отправляет уведомление о смене email на адрес to и возвращает токен ссылки «это был не я» из письма
+func (env *securityTestEnv) notMeToken(t *testing.T, to string) string {
+	alert := env.event(t, events.UserEmailChanged, events.UserEmailChangedPayload{
+		UserID:   env.user.ID,
+		OldEmail: to,
+		NewEmail: env.user.Email,
+	})
+	require.NoError(t, env.service.HandleEvent(context.Background(), alert))
+
+	messages := env.messages()
+	link, err := url.Parse(linkPattern.FindString(messages[len(messages)-1].Text))
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/security/not-me", link.Path)
+	env.jobs.payloads = nil
+	return link.Query().Get("token")
+}
+
+func TestSecurityService_AlertSettings(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	ctx := context.Background()
+
+	settings, err := env.service.AlertSettings(ctx, env.user.ID)
+	require.NoError(t, err)
+	for _, alert := range models.SecurityAlerts {
+		assert.True(t, settings[alert], "%s must be enabled by default", alert)
+	}
+
+	settings, err = env.service.UpdateAlertSettings(ctx, env.user.ID, map[models.SecurityAlert]bool{
+		models.SecurityAlertNewLogin: false,
+	})
+	require.NoError(t, err)
+	assert.False(t, settings[models.SecurityAlertNewLogin])
+	assert.True(t, settings[models.SecurityAlertPasswordChanged])
+	assert.Equal(t, []string{"new_login"}, env.repo.disabled[env.user.ID])
+
+	entry := env.audit.last(t, audit.AlertsUpdated)
+	assert.Equal(t, map[string]interface{}{"new_login": true}, entry.Changes.Before)
+	assert.Equal(t, map[string]interface{}{"new_login": false}, entry.Changes.After)
+
+	_, err = env.service.UpdateAlertSettings(ctx, env.user.ID, map[models.SecurityAlert]bool{"two_factor": false})
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+}
+
+func TestSecurityService_NewLoginAlert(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	env.user.Locale = "ru"
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.UserLoggedIn, events.UserLoggedInPayload{
+		UserID:    env.user.ID,
+		IPAddress: "203.0.113.7",
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+	}))
+	require.NoError(t, err)
+
+	messages := env.messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"alex@example.com"}, messages[0].To)
+	assert.Equal(t, "Новый вход в учетную запись", messages[0].Subject)
+	assert.Contains(t, messages[0].Text, "Chrome (macOS)")
+	assert.Contains(t, messages[0].Text, "203.0.113.7")
+	assert.Contains(t, messages[0].Text, "2025-01-15 09:30 UTC")
+	assert.Contains(t, linkPattern.FindString(messages[0].Text), "/security/not-me?")
+}
+
+func TestSecurityService_RedeliveredEventSendsOneAlert(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	event := env.event(t, events.UserPasswordReset, events.UserPasswordResetPayload{UserID: env.user.ID})
+
+	require.NoError(t, env.service.HandleEvent(context.Background(), event))
+	// Письмо уже ушло из очереди, и событие доставлено снова
+	env.jobs.payloads = nil
+	require.NoError(t, env.service.HandleEvent(context.Background(), event))
+
+	assert.Empty(t, env.jobs.payloads)
+	assert.Len(t, env.repo.notMe, 1)
+
+	require.NoError(t, env.service.HandleEvent(context.Background(), env.event(t, events.UserPasswordReset,
+		events.UserPasswordResetPayload{UserID: env.user.ID})))
+	assert.Len(t, env.jobs.payloads, 1, "another event is alerted")
+}
+
+func TestSecurityService_SkipsLoginsWithoutRiskSignals(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	score := 0
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.UserLoggedIn, events.UserLoggedInPayload{
+		UserID:    env.user.ID,
+		RiskScore: &score,
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, env.jobs.payloads)
+
+	score = 40
+	err = env.service.HandleEvent(context.Background(), env.event(t, events.UserLoggedIn, events.UserLoggedInPayload{
+		UserID:    env.user.ID,
+		RiskScore: &score,
+		Signals:   []string{"new_device"},
+	}))
+	require.NoError(t, err)
+	assert.Len(t, env.jobs.payloads, 1)
+}
+
+func TestSecurityService_SkipsDisabledAlerts(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	env.repo.disabled[env.user.ID] = []string{"new_login"}
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.UserLoggedIn, events.UserLoggedInPayload{UserID: env.user.ID}))
+	require.NoError(t, err)
+	assert.Empty(t, env.jobs.payloads)
+
+	err = env.service.HandleEvent(context.Background(), env.event(t, events.UserPasswordReset, events.UserPasswordResetPayload{UserID: env.user.ID}))
+	require.NoError(t, err)
+	assert.Len(t, env.jobs.payloads, 1, "other alerts stay enabled")
+}
+
+func TestSecurityService_EmailChangedAlertGoesToOldAddress(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	env.user.Email = "attacker@example.com"
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.UserEmailChanged, events.UserEmailChangedPayload{
+		UserID:   env.user.ID,
+		OldEmail: "alex@example.com",
+		NewEmail: "attacker@example.com",
+		Client:   events.Client{IPAddress: "198.51.100.1"},
+	}))
+	require.NoError(t, err)
+
+	err = env.service.HandleEvent(context.Background(), env.event(t, events.UserEmailChanged, events.UserEmailChangedPayload{
+		UserID:   env.user.ID,
+		OldEmail: "attacker@example.com",
+		NewEmail: "alex@example.com",
+		Reverted: true,
+	}))
+	require.NoError(t, err)
+
+	messages := env.messages()
+	require.Len(t, messages, 1, "a revert is done by the owner and needs no alert")
+	assert.Equal(t, []string{"alex@example.com"}, messages[0].To)
+	assert.Contains(t, messages[0].Text, "attacker@example.com")
+	assert.Contains(t, messages[0].Text, "198.51.100.1")
+	assert.NotContains(t, linkPattern.FindString(messages[0].Text), "alex%40example.com", "the link carries only a token")
+}
+
+func TestSecurityService_SessionRevokedAlert(t *testing.T) {
+	env := newSecurityTestEnv(t)
+
+	for _, reason := range []string{events.RevokeLogout, events.RevokePasswordReset, events.RevokeCompromised} {
+		err := env.service.HandleEvent(context.Background(), env.event(t, events.SessionRevoked, events.SessionRevokedPayload{
+			UserID: env.user.ID,
+			Reason: reason,
+		}))
+		require.NoError(t, err)
+	}
+	assert.Empty(t, env.jobs.payloads)
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.SessionRevoked, events.SessionRevokedPayload{
+		UserID:           env.user.ID,
+		Reason:           events.RevokeUser,
+		SessionUserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	}))
+	require.NoError(t, err)
+
+	messages := env.messages()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0].Text, "The session on Firefox (Linux) was signed out")
+}
+
+func TestSecurityService_IgnoresDeletedUsers(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	missing := uuid.New()
+	env.userRepo.On("GetByID", missing).Return(nil, apperrors.ErrNotFound)
+
+	err := env.service.HandleEvent(context.Background(), env.event(t, events.UserLoggedIn, events.UserLoggedInPayload{UserID: missing}))
+	assert.NoError(t, err)
+	assert.Empty(t, env.jobs.payloads)
+}
+
+func TestSecurityService_NotMeRevokesSessionsAndResetsPassword(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	ctx := context.Background()
+	env.authRepo.On("DeleteUserSessions", env.user.ID).Return(nil)
+	env.userRepo.On("UpdatePassword", env.user.ID, mock.AnythingOfType("string")).Return(nil)
+	token := env.notMeToken(t, env.user.Email)
+
+	_, err := env.service.CheckNotMe(ctx, token)
+	require.NoError(t, err)
+	env.authRepo.AssertNotCalled(t, "DeleteUserSessions", env.user.ID)
+
+	require.NoError(t, env.service.ReportCompromise(ctx, token))
+	env.authRepo.AssertCalled(t, "DeleteUserSessions", env.user.ID)
+	require.Len(t, env.recorder.events, 1)
+	assert.Equal(t, events.RevokeCompromised, env.recorder.events[0].payload.(events.SessionRevokedPayload).Reason)
+
+	messages := env.messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, []string{"alex@example.com"}, messages[0].To)
+	assert.Equal(t, "Set a new password", messages[0].Subject)
+	assert.Contains(t, messages[0].Text, "30 minutes")
+
+	resetLink, err := url.Parse(linkPattern.FindString(messages[0].Text))
+	require.NoError(t, err)
+	resetToken := resetLink.Query().Get("token")
+
+	_, err = env.service.CheckPasswordReset(ctx, resetToken)
+	require.NoError(t, err)
+	require.NoError(t, env.service.ResetPassword(ctx, resetToken, "new-password"))
+	env.userRepo.AssertCalled(t, "UpdatePassword", env.user.ID, mock.AnythingOfType("string"))
+	assert.Equal(t, events.UserPasswordReset, env.recorder.events[1].eventType)
+
+	assert.ErrorIs(t, env.service.ResetPassword(ctx, resetToken, "another-password"), apperrors.ErrInvalidToken, "the link is single-use")
+}
+
+func TestSecurityService_NotMeLinkIsSingleUse(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	ctx := context.Background()
+	env.authRepo.On("DeleteUserSessions", env.user.ID).Return(nil)
+	token := env.notMeToken(t, env.user.Email)
+
+	require.NoError(t, env.service.ReportCompromise(ctx, token))
+	assert.ErrorIs(t, env.service.ReportCompromise(ctx, token), apperrors.ErrInvalidToken)
+	_, err := env.service.CheckNotMe(ctx, token)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+	assert.Len(t, env.messages(), 1)
+
+	assert.ErrorIs(t, env.service.ReportCompromise(ctx, "forged"), apperrors.ErrInvalidToken)
+}
+
+func TestSecurityService_NotMeLinkExpires(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	token := env.notMeToken(t, env.user.Email)
+	for _, notMe := range env.repo.notMe {
+		notMe.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+
+	err := env.service.ReportCompromise(context.Background(), token)
+	assert.ErrorIs(t, err, apperrors.ErrTokenExpired)
+}
+
+func TestSecurityService_NotMeResetRecipientIsChosenByServer(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	ctx := context.Background()
+	env.authRepo.On("DeleteUserSessions", env.user.ID).Return(nil)
+	env.user.Email = "attacker@example.com"
+
+	// Адрес, с которого email сменили подтвержденным запросом, принадлежит прежнему владельцу
+	env.repo.previousEmails["alex@example.com"] = true
+	require.NoError(t, env.service.ReportCompromise(ctx, env.notMeToken(t, "alex@example.com")))
+	assert.Equal(t, []string{"alex@example.com"}, env.messages()[0].To)
+	env.jobs.payloads = nil
+
+	// Адрес, который пользователю никогда не принадлежал, заменяется текущим email
+	require.NoError(t, env.service.ReportCompromise(ctx, env.notMeToken(t, "stranger@example.com")))
+	assert.Equal(t, []string{"attacker@example.com"}, env.messages()[0].To)
+}
+
+func TestSecurityService_RepeatedNotMeInvalidatesOlderResetLinks(t *testing.T) {
+	env := newSecurityTestEnv(t)
+	ctx := context.Background()
+	env.authRepo.On("DeleteUserSessions", env.user.ID).Return(nil)
+
+	var messages []mail.Message
+	for i := 0; i < 2; i++ {
+		require.NoError(t, env.service.ReportCompromise(ctx, env.notMeToken(t, env.user.Email)))
+		messages = append(messages, env.messages()...)
+	}
+
+	require.Len(t, messages, 2)
+	firstLink, err := url.Parse(linkPattern.FindString(messages[0].Text))
+	require.NoError(t, err)
+	_, err = env.service.CheckPasswordReset(ctx, firstLink.Query().Get("token"))
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+}
+
+func TestAuthService_RevokeSession(t *testing.T) {
+	mockAuthRepo := new(MockAuthRepository)
+	recorder := &eventRecorder{}
+	authService := service.NewAuthService(fakeTx{}, new(MockUserRepository), mockAuthRepo, recorder, &auditRecorder{}, nil)
+
+	userID := uuid.New()
+	session := models.Session{ID: uuid.New(), UserID: userID, SessionToken: "token", UserAgent: "curl/8.4.0", IPAddress: "203.0.113.7"}
+	mockAuthRepo.On("GetSessionsByUserID", userID).Return([]models.Session{session}, nil)
+	mockAuthRepo.On("DeleteSession", "token").Return(nil)
+
+	ctx := events.WithClient(context.Background(), events.Client{IPAddress: "198.51.100.1"})
+	assert.ErrorIs(t, authService.RevokeSession(ctx, userID, uuid.New()), apperrors.ErrNotFound)
+	require.NoError(t, authService.RevokeSession(ctx, userID, session.ID))
+
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, events.SessionRevokedPayload{
+		UserID:           userID,
+		Reason:           events.RevokeUser,
+		SessionUserAgent: "curl/8.4.0",
+		SessionIPAddress: "203.0.113.7",
+		Client:           events.Client{IPAddress: "198.51.100.1"},
+	}, recorder.events[0].payload)
+}
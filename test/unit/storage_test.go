@@ -0,0 +1,37 @@
+package unit
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage_PutOpenDelete(t *testing.T) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	err = store.Put("user/export.zip", strings.NewReader("archive"))
+	require.NoError(t, err)
+
+	file, err := store.Open("user/export.zip")
+	require.NoError(t, err)
+	content, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "archive", string(content))
+
+	assert.NoError(t, store.Delete("user/export.zip"))
+	_, err = store.Open("user/export.zip")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestLocalStorage_RejectsPathTraversal(t *testing.T) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+
+	err = store.Put("../outside.zip", strings.NewReader("archive"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,356 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/database"
+	"github.com/AtlasOpx/devprep/internal/handlers"
+	"github.com/AtlasOpx/devprep/internal/logging"
+	"github.com/AtlasOpx/devprep/internal/middleware"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/repository"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/AtlasOpx/devprep/internal/tracing"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentSpanID = "00f067aa0ba902b7"
+)
+
+// recordSpans подменяет глобальный провайдер трасс на записывающий span в память
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not recorded", name)
+	return nil
+}
+
+func TestTracing_ServerSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var buf bytes.Buffer
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(middleware.RequestID())
+	app.Use(middleware.Tracing())
+	app.Use(middleware.AccessLog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	app.Use(middleware.RequestContext(context.Background(), 0))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		_, span := tracing.StartChild(c.UserContext(), "UserService.GetUser")
+		span.End()
+		if c.Params("id") == "boom" {
+			return fiber.ErrInternalServerError
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-"+testTraceID+"-"+testParentSpanID+"-01")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	spans := recorder.Ended()
+	server := findSpan(t, spans, "GET /users/:id")
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, testTraceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, testParentSpanID, server.Parent().SpanID().String())
+	assert.Equal(t, "/users/:id", spanAttribute(server, "http.route").AsString())
+	assert.Equal(t, int64(fiber.StatusNoContent), spanAttribute(server, "http.response.status_code").AsInt64())
+	assert.Equal(t, codes.Unset, server.Status().Code)
+
+	child := findSpan(t, spans, "UserService.GetUser")
+	assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, testTraceID, record["trace_id"])
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/users/boom", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+
+	failed := recorder.Ended()[len(recorder.Ended())-1]
+	assert.Equal(t, "GET /users/:id", failed.Name())
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.NotEqual(t, testTraceID, failed.SpanContext().TraceID().String())
+}
+
+func TestTracing_RequestLoggerHasTraceID(t *testing.T) {
+	recordSpans(t)
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(middleware.Tracing())
+	app.Use(middleware.RequestContext(logging.WithLogger(context.Background(), logger), 0))
+	app.Get("/", func(c *fiber.Ctx) error {
+		logging.FromContext(c.UserContext()).Info("from handler")
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-"+testTraceID+"-"+testParentSpanID+"-01")
+	_, err = app.Test(req)
+	require.NoError(t, err)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, testTraceID, record["trace_id"])
+	assert.NotEmpty(t, record["span_id"])
+}
+
+func TestTracing_StartChildOutsideTrace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, span := tracing.StartChild(context.Background(), "orphan")
+	span.End()
+	assert.False(t, span.IsRecording())
+	assert.Equal(t, context.Background(), ctx)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, root := tracing.Start(context.Background(), "root")
+	_, child := tracing.StartChild(ctx, "child")
+	tracing.End(child, assert.AnError)
+	root.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, root.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func TestLogging_WithTrace(t *testing.T) {
+	recordSpans(t)
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "info", "json")
+	require.NoError(t, err)
+	ctx := logging.WithLogger(context.Background(), logger)
+
+	assert.Equal(t, ctx, logging.WithTrace(ctx))
+
+	ctx, span := tracing.Start(ctx, "job")
+	defer span.End()
+	logging.FromContext(logging.WithTrace(ctx)).Info("traced")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, span.SpanContext().TraceID().String(), record["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), record["span_id"])
+}
+
+func TestTracing_Setup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	_, err := tracing.Setup(context.Background(), &config.Config{TracingExporter: "zipkin", TracingSampleRatio: 1}, nil)
+	assert.Error(t, err)
+	_, err = tracing.Setup(context.Background(), &config.Config{TracingExporter: "stdout", TracingSampleRatio: 2}, nil)
+	assert.Error(t, err)
+
+	shutdown, err := tracing.Setup(context.Background(), &config.Config{TracingExporter: "none", TracingSampleRatio: 1}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	var buf bytes.Buffer
+	shutdown, err = tracing.Setup(context.Background(), &config.Config{TracingExporter: "stdout", TracingSampleRatio: 1}, &buf)
+	require.NoError(t, err)
+
+	_, span := tracing.Start(context.Background(), "exported")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	var exported map[string]interface{}
+	require.NoError(t, json.NewDecoder(&buf).Decode(&exported))
+	assert.Equal(t, "exported", exported["Name"])
+}
+
+// scriptedConn - соединение драйвера без базы: SELECT отвечает строками из rows, остальные запросы
+// меняют одну строку. Оно подставляется под обертку трассировки вместо pq
+type scriptedConn struct {
+	rows func(query string) [][]driver.Value
+}
+
+func (c *scriptedConn) Connect(ctx context.Context) (driver.Conn, error) { return c, nil }
+func (c *scriptedConn) Driver() driver.Driver                            { return nil }
+func (c *scriptedConn) Prepare(query string) (driver.Stmt, error)        { return nil, driver.ErrSkip }
+func (c *scriptedConn) Close() error                                     { return nil }
+func (c *scriptedConn) Begin() (driver.Tx, error)                        { return scriptedTx{}, nil }
+
+func (c *scriptedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (c *scriptedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &scriptedRows{values: c.rows(query)}, nil
+}
+
+type scriptedTx struct{}
+
+func (scriptedTx) Commit() error   { return nil }
+func (scriptedTx) Rollback() error { return nil }
+
+type scriptedRows struct {
+	values [][]driver.Value
+}
+
+func (r *scriptedRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+
+func (r *scriptedRows) Close() error { return nil }
+
+func (r *scriptedRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestTracing_UnknownEmailStillVerifiesPassword(t *testing.T) {
+	recorder := recordSpans(t)
+
+	mockUserRepo := new(MockUserRepository)
+	mockUserRepo.On("GetByEmail", "ghost@example.com").Return(nil, apperrors.ErrNotFound)
+	authService := service.NewAuthService(fakeTx{}, mockUserRepo, new(MockAuthRepository), &eventRecorder{}, &auditRecorder{}, nil)
+
+	ctx, root := tracing.Start(context.Background(), "login")
+	_, err := authService.Login(ctx, &models.LoginRequest{Email: "ghost@example.com", Password: "password123"})
+	root.End()
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+
+	// Неизвестный email проверяется по фиктивному хешу, чтобы ответ занимал столько же времени
+	findSpan(t, recorder.Ended(), "argon2.verify")
+}
+
+func TestTracing_LoginPathEmitsSQLSpans(t *testing.T) {
+	recorder := recordSpans(t)
+
+	passwordHash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+	now := time.Now()
+	userID := uuid.New()
+	userRow := []driver.Value{userID.String(), "alex@example.com", "alex", "Alex", "Smith", passwordHash, "user", true, "en", now, now}
+	conn := &scriptedConn{rows: func(query string) [][]driver.Value {
+		switch {
+		case strings.Contains(query, "FROM sessions"):
+			return [][]driver.Value{{userID.String(), "token", now.Add(time.Hour), "", "", now}}
+		case strings.Contains(query, "FROM users"):
+			return [][]driver.Value{userRow}
+		}
+		return nil
+	}}
+
+	db := database.Open(conn)
+	userRepo := repository.NewUserRepository(db)
+	authRepo := repository.NewAuthRepository(db)
+	authService := service.NewAuthService(db, userRepo, authRepo, &eventRecorder{}, &auditRecorder{}, nil)
+	authHandler := handlers.NewAuthHandler(authService, &config.Config{})
+
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(middleware.Tracing())
+	app.Use(middleware.RequestContext(context.Background(), 0))
+	app.Post("/auth/login", authHandler.Login)
+	app.Get("/user/me", middleware.NewAuthMiddleware(authRepo).RequireAuth, func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"alex@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "/user/me", nil)
+	req.Header.Set("Cookie", "session_token=token")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	spans := recorder.Ended()
+	login := findSpan(t, spans, "POST /auth/login")
+	me := findSpan(t, spans, "GET /user/me")
+
+	// Каждый запрос к базе на пути входа и проверки сессии попадает в трассу своего HTTP-запроса
+	queries := map[string]trace.TraceID{}
+	for _, span := range spans {
+		if span.SpanKind() != trace.SpanKindClient {
+			continue
+		}
+		assert.True(t, span.Parent().IsValid(), "SQL span %s must have a parent", span.Name())
+		queries[spanAttribute(span, "db.query.text").AsString()] = span.SpanContext().TraceID()
+	}
+	assert.Equal(t, login.SpanContext().TraceID(), traceOfQuery(t, queries, "FROM users WHERE LOWER(email)"), "GetByEmail")
+	assert.Equal(t, login.SpanContext().TraceID(), traceOfQuery(t, queries, "INSERT INTO sessions"), "CreateSession")
+	assert.Equal(t, me.SpanContext().TraceID(), traceOfQuery(t, queries, "FROM sessions WHERE session_token"), "GetSessionByToken")
+	assert.Equal(t, me.SpanContext().TraceID(), traceOfQuery(t, queries, "JOIN sessions"), "ValidateSession")
+}
+
+// traceOfQuery возвращает трассу SQL span, текст запроса которого содержит fragment
+func traceOfQuery(t *testing.T, queries map[string]trace.TraceID, fragment string) trace.TraceID {
+	for query, traceID := range queries {
+		if strings.Contains(query, fragment) {
+			return traceID
+		}
+	}
+	t.Fatalf("no SQL span for %q", fragment)
+	return trace.TraceID{}
+}
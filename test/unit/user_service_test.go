@@ -1,18 +1,24 @@
 package unit
 
 import (
+	"context"
 	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/audit"
+	"github.com/AtlasOpx/devprep/internal/config"
 	"github.com/AtlasOpx/devprep/internal/models"
 	"github.com/AtlasOpx/devprep/internal/service"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestUserService_GetByID_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	userID := uuid.New()
 	expectedUser := &models.User{
@@ -26,7 +32,7 @@ func TestUserService_GetByID_Success(t *testing.T) {
 
 	mockUserRepo.On("GetByID", userID).Return(expectedUser, nil)
 
-	user, err := userService.GetByID(userID)
+	user, err := userService.GetByID(context.Background(), userID)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -37,13 +43,13 @@ func TestUserService_GetByID_Success(t *testing.T) {
 
 func TestUserService_GetByID_NotFound(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	userID := uuid.New()
 
 	mockUserRepo.On("GetByID", userID).Return(nil, sql.ErrNoRows)
 
-	user, err := userService.GetByID(userID)
+	user, err := userService.GetByID(context.Background(), userID)
 
 	assert.Error(t, err)
 	assert.Nil(t, user)
@@ -53,7 +59,8 @@ func TestUserService_GetByID_NotFound(t *testing.T) {
 
 func TestUserService_UpdateProfile_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	auditLog := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, auditLog, &config.Config{})
 
 	userID := uuid.New()
 	req := &models.UpdateProfileRequest{
@@ -62,31 +69,115 @@ func TestUserService_UpdateProfile_Success(t *testing.T) {
 		Username:  "newusername",
 	}
 
+	mockUserRepo.On("GetByID", userID).Return(&models.User{
+		ID:        userID,
+		Username:  "oldusername",
+		FirstName: "Old",
+		LastName:  "Name",
+	}, nil)
 	mockUserRepo.On("Update", userID, req).Return(nil)
 
-	err := userService.UpdateProfile(userID, req)
+	err := userService.UpdateProfile(context.Background(), userID, req)
 
 	assert.NoError(t, err)
 	mockUserRepo.AssertExpectations(t)
+
+	entry := auditLog.last(t, audit.ProfileUpdated)
+	assert.Equal(t, userID, entry.TargetID)
+	assert.Equal(t, map[string]interface{}{"first_name": "Old", "username": "oldusername"}, entry.Changes.Before)
+	assert.Equal(t, map[string]interface{}{"first_name": "Updated", "username": "newusername"}, entry.Changes.After)
 }
 
 func TestUserService_DeleteUser_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	userID := uuid.New()
 
 	mockUserRepo.On("Delete", userID).Return(nil)
 
-	err := userService.DeleteUser(userID)
+	err := userService.DeleteUser(context.Background(), userID)
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// withinSecond сравнивает время с ожидаемым: сервис берет time.Now сам
+func withinSecond(expected time.Time) interface{} {
+	return mock.MatchedBy(func(actual time.Time) bool {
+		return actual.Sub(expected).Abs() < time.Second
+	})
+}
+
+func TestUserService_RestoreUser_UsesRetention(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	cfg := &config.Config{UserDeletionRetention: 30 * 24 * time.Hour}
+	audits := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, audits, cfg)
+
+	userID := uuid.New()
+	mockUserRepo.On("Restore", userID, withinSecond(time.Now().Add(-cfg.UserDeletionRetention))).Return(nil)
+
+	err := userService.RestoreUser(context.Background(), userID)
 
 	assert.NoError(t, err)
+	assert.Equal(t, []string{audit.UserRestored}, audits.actions())
 	mockUserRepo.AssertExpectations(t)
 }
 
+func TestUserService_RestoreUser_RetentionExpired(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	audits := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, audits, &config.Config{})
+
+	userID := uuid.New()
+	mockUserRepo.On("Restore", userID, mock.Anything).Return(apperrors.ErrNotFound)
+
+	err := userService.RestoreUser(context.Background(), userID)
+
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+	assert.Empty(t, audits.actions())
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestUserService_PurgeDeletedUsers_UsesRetention(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	cfg := &config.Config{UserDeletionRetention: 30 * 24 * time.Hour}
+	audits := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, audits, cfg)
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	mockUserRepo.On("PurgeDeleted", withinSecond(time.Now().Add(-cfg.UserDeletionRetention))).Return(ids, nil)
+
+	purged, err := userService.PurgeDeletedUsers(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	mockUserRepo.AssertExpectations(t)
+
+	// Одна запись журнала на запуск со списком удаленных
+	entry := audits.last(t, audit.UsersPurged)
+	assert.Equal(t, 3, entry.Metadata["count"])
+	assert.Equal(t, ids, entry.Metadata["user_ids"])
+}
+
+func TestUserService_PurgeDeletedUsers_NothingToPurge(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	audits := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, audits, &config.Config{})
+
+	mockUserRepo.On("PurgeDeleted", mock.Anything).Return([]uuid.UUID(nil), nil)
+
+	purged, err := userService.PurgeDeletedUsers(context.Background())
+
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.Empty(t, audits.actions())
+}
+
 func TestUserService_GetAllUsers_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	expectedUsers := []models.User{
 		{
@@ -109,7 +200,7 @@ func TestUserService_GetAllUsers_Success(t *testing.T) {
 
 	mockUserRepo.On("GetAll").Return(expectedUsers, nil)
 
-	users, err := userService.GetAllUsers()
+	users, err := userService.GetAllUsers(context.Background())
 
 	assert.NoError(t, err)
 	assert.NotNil(t, users)
@@ -121,7 +212,7 @@ func TestUserService_GetAllUsers_Success(t *testing.T) {
 
 func TestUserService_GetByEmail_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	email := "test@example.com"
 	expectedUser := &models.User{
@@ -135,7 +226,7 @@ func TestUserService_GetByEmail_Success(t *testing.T) {
 
 	mockUserRepo.On("GetByEmail", email).Return(expectedUser, nil)
 
-	user, err := userService.GetByEmail(email)
+	user, err := userService.GetByEmail(context.Background(), email)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
@@ -145,7 +236,7 @@ func TestUserService_GetByEmail_Success(t *testing.T) {
 
 func TestUserService_GetByUsername_Success(t *testing.T) {
 	mockUserRepo := new(MockUserRepository)
-	userService := service.NewUserService(mockUserRepo)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
 
 	username := "testuser"
 	expectedUser := &models.User{
@@ -159,10 +250,52 @@ func TestUserService_GetByUsername_Success(t *testing.T) {
 
 	mockUserRepo.On("GetByUsername", username).Return(expectedUser, nil)
 
-	user, err := userService.GetByUsername(username)
+	user, err := userService.GetByUsername(context.Background(), username)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, expectedUser.Username, user.Username)
 	mockUserRepo.AssertExpectations(t)
 }
+
+func TestUserService_SetRole_Success(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	auditLog := &auditRecorder{}
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, auditLog, &config.Config{})
+
+	userID := uuid.New()
+	mockUserRepo.On("GetByID", userID).Return(&models.User{ID: userID, Role: models.UserRoleUser}, nil)
+	mockUserRepo.On("UpdateRole", userID, models.UserRoleModerator).Return(nil)
+
+	err := userService.SetRole(context.Background(), userID, models.UserRoleModerator)
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+
+	entry := auditLog.last(t, audit.RoleChanged)
+	assert.Equal(t, map[string]interface{}{"role": "user"}, entry.Changes.Before)
+	assert.Equal(t, map[string]interface{}{"role": "moderator"}, entry.Changes.After)
+}
+
+func TestUserService_SetRole_InvalidRole(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
+
+	err := userService.SetRole(context.Background(), uuid.New(), models.UserRole("root"))
+
+	assert.ErrorIs(t, err, service.ErrInvalidRole)
+	mockUserRepo.AssertNotCalled(t, "UpdateRole", mock.Anything, mock.Anything)
+}
+
+func TestUserService_Deactivate_Success(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	userService := service.NewUserService(fakeTx{}, mockUserRepo, &eventRecorder{}, &auditRecorder{}, &config.Config{})
+
+	userID := uuid.New()
+	mockUserRepo.On("Deactivate", userID).Return(nil)
+
+	err := userService.Deactivate(context.Background(), userID)
+
+	assert.NoError(t, err)
+	mockUserRepo.AssertExpectations(t)
+}
@@ -62,3 +62,94 @@ func TestGenerateSessionToken_Uniqueness(t *testing.T) {
 
 	assert.Equal(t, iterations, len(tokens))
 }
+
+func TestHighlightMatches(t *testing.T) {
+	highlighted, ok := utils.HighlightMatches("Bob.Smith@example.com", "smith")
+
+	assert.True(t, ok)
+	assert.Equal(t, "Bob.<mark>Smith</mark>@example.com", highlighted)
+}
+
+func TestHighlightMatches_MultipleTermsAndEscaping(t *testing.T) {
+	highlighted, ok := utils.HighlightMatches("<Анна> Иванова", "анна ива")
+
+	assert.True(t, ok)
+	assert.Equal(t, "&lt;<mark>Анна</mark>&gt; <mark>Ива</mark>нова", highlighted)
+}
+
+func TestHighlightMatches_NoMatch(t *testing.T) {
+	highlighted, ok := utils.HighlightMatches("alice", "bob")
+
+	assert.False(t, ok)
+	assert.Equal(t, "alice", highlighted)
+}
+
+func TestVerifySignature(t *testing.T) {
+	signature := utils.GenerateSignature("secret", "export-id:1700000000")
+
+	assert.True(t, utils.VerifySignature("secret", "export-id:1700000000", signature))
+	assert.False(t, utils.VerifySignature("secret", "export-id:1700000001", signature))
+	assert.False(t, utils.VerifySignature("other-secret", "export-id:1700000000", signature))
+}
+
+func TestHashToken(t *testing.T) {
+	token, _ := utils.GenerateSessionToken()
+
+	assert.Equal(t, utils.HashToken(token), utils.HashToken(token))
+	assert.NotEqual(t, token, utils.HashToken(token))
+	assert.Len(t, utils.HashToken(token), 64)
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "bob@x.com", utils.NormalizeEmail("  Bob@X.com "))
+	// NFKC сворачивает полноширинные символы в ASCII
+	assert.Equal(t, "bob@x.com", utils.NormalizeEmail("ＢＯＢ@x.com"))
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "анна", utils.NormalizeUsername("Анна"))
+	assert.Equal(t, utils.NormalizeUsername("ﬁle"), utils.NormalizeUsername("FILE"))
+}
+
+func TestDescribeDevice(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36":         "Chrome (macOS)",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0":     "Edge (Windows)",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/604.1": "Safari (iOS)",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36":         "Chrome (Android)",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                    "Firefox (Linux)",
+		"curl/8.4.0": "curl",
+		"":           "",
+	}
+	for userAgent, expected := range cases {
+		assert.Equal(t, expected, utils.DescribeDevice(userAgent), userAgent)
+	}
+}
+
+func TestDeviceFingerprint_IgnoresVersions(t *testing.T) {
+	chrome120 := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	chrome121 := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0 Safari/537.36"
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+
+	assert.Equal(t, utils.DeviceFingerprint(chrome120), utils.DeviceFingerprint(chrome121))
+	assert.NotEqual(t, utils.DeviceFingerprint(chrome120), utils.DeviceFingerprint(firefox))
+}
+
+func TestIPSubnet(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.7":         "203.0.113.0/24",
+		"::ffff:203.0.113.7":  "203.0.113.0/24",
+		"2001:db8:abcd:12::1": "2001:db8:abcd::/48",
+		"not an address":      "",
+		"":                    "",
+	}
+	for ip, expected := range cases {
+		assert.Equal(t, expected, utils.IPSubnet(ip), ip)
+	}
+}
+
+func TestGenerateNumericCode(t *testing.T) {
+	code, err := utils.GenerateNumericCode(6)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\d{6}$`, code)
+}
@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/dto"
+	"github.com/AtlasOpx/devprep/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldRules(errs []apperrors.FieldError) map[string]string {
+	rules := make(map[string]string)
+	for _, err := range errs {
+		rules[err.Field] = err.Rule
+	}
+	return rules
+}
+
+func TestValidate_RegisterRequest_Valid(t *testing.T) {
+	v := utils.NewXValidator()
+
+	errs := v.Validate(&dto.RegisterRequest{
+		Email:     "test@example.com",
+		Username:  "test.user",
+		FirstName: "Test",
+		LastName:  "User",
+		Password:  "password123",
+	})
+
+	assert.Empty(t, errs)
+}
+
+func TestValidate_RegisterRequest_UsesJSONFieldNames(t *testing.T) {
+	v := utils.NewXValidator()
+
+	errs := v.Validate(&dto.RegisterRequest{
+		Email:    "not-an-email",
+		Username: "ab",
+		Password: "123",
+	})
+
+	rules := fieldRules(errs)
+	assert.Equal(t, "email", rules["email"])
+	assert.Equal(t, "min", rules["username"])
+	assert.Equal(t, "required", rules["first_name"])
+	assert.Equal(t, "required", rules["last_name"])
+	assert.Equal(t, "min", rules["password"])
+	for _, err := range errs {
+		assert.NotEmpty(t, err.Message)
+	}
+}
+
+func TestValidate_Username_CharsetAndReserved(t *testing.T) {
+	v := utils.NewXValidator()
+
+	assert.Equal(t, "username", fieldRules(v.Validate(&dto.UpdateProfileRequest{Username: "bad name!"}))["username"])
+	assert.Equal(t, "notreserved", fieldRules(v.Validate(&dto.UpdateProfileRequest{Username: "Admin"}))["username"])
+	assert.Empty(t, v.Validate(&dto.UpdateProfileRequest{}))
+}
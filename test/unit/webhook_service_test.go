@@ -0,0 +1,410 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AtlasOpx/devprep/internal/apperrors"
+	"github.com/AtlasOpx/devprep/internal/config"
+	"github.com/AtlasOpx/devprep/internal/events"
+	"github.com/AtlasOpx/devprep/internal/models"
+	"github.com/AtlasOpx/devprep/internal/queue"
+	"github.com/AtlasOpx/devprep/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookRepo - endpoint и доставки в памяти
+type fakeWebhookRepo struct {
+	mu         sync.Mutex
+	endpoints  map[uuid.UUID]*models.WebhookEndpoint
+	deliveries map[uuid.UUID]*models.WebhookDelivery
+	attempts   []models.WebhookAttempt
+}
+
+func newFakeWebhookRepo() *fakeWebhookRepo {
+	return &fakeWebhookRepo{
+		endpoints:  make(map[uuid.UUID]*models.WebhookEndpoint),
+		deliveries: make(map[uuid.UUID]*models.WebhookDelivery),
+	}
+}
+
+func (r *fakeWebhookRepo) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *endpoint
+	r.endpoints[endpoint.ID] = &stored
+	return nil
+}
+
+func (r *fakeWebhookRepo) GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endpoint, ok := r.endpoints[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	found := *endpoint
+	return &found, nil
+}
+
+func (r *fakeWebhookRepo) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var endpoints []models.WebhookEndpoint
+	for _, endpoint := range r.endpoints {
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints, nil
+}
+
+func (r *fakeWebhookRepo) ListSubscribedEndpoints(ctx context.Context, eventType string) ([]models.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var endpoints []models.WebhookEndpoint
+	for _, endpoint := range r.endpoints {
+		if endpoint.IsActive && endpoint.Subscribed(eventType) {
+			endpoints = append(endpoints, *endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+func (r *fakeWebhookRepo) UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.endpoints[endpoint.ID]; !ok {
+		return apperrors.ErrNotFound
+	}
+	stored := *endpoint
+	r.endpoints[endpoint.ID] = &stored
+	return nil
+}
+
+func (r *fakeWebhookRepo) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.endpoints, id)
+	return nil
+}
+
+func (r *fakeWebhookRepo) RecordEndpointFailure(ctx context.Context, id uuid.UUID, threshold int, reason string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endpoint := r.endpoints[id]
+	endpoint.ConsecutiveFailures++
+	if endpoint.IsActive && endpoint.ConsecutiveFailures >= threshold {
+		now := time.Now()
+		endpoint.IsActive = false
+		endpoint.DisabledAt = &now
+		endpoint.DisabledReason = &reason
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *fakeWebhookRepo) ResetEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.endpoints[id].ConsecutiveFailures = 0
+	return nil
+}
+
+func (r *fakeWebhookRepo) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.deliveries {
+		if existing.EndpointID == delivery.EndpointID && existing.EventID == delivery.EventID {
+			return false, nil
+		}
+	}
+	stored := *delivery
+	stored.Status = models.WebhookDeliveryPending
+	r.deliveries[delivery.ID] = &stored
+	return true, nil
+}
+
+func (r *fakeWebhookRepo) GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	found := *delivery
+	return &found, nil
+}
+
+func (r *fakeWebhookRepo) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.EndpointID == filter.EndpointID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, int64(len(deliveries)), nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(ctx context.Context, attempt *models.WebhookAttempt, status models.WebhookDeliveryStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *attempt)
+	delivery := r.deliveries[attempt.DeliveryID]
+	delivery.Status = status
+	delivery.Attempts = attempt.Attempt
+	delivery.ResponseStatus = attempt.ResponseStatus
+	delivery.LastError = attempt.Error
+	return nil
+}
+
+func (r *fakeWebhookRepo) SetDeliveryStatus(ctx context.Context, id uuid.UUID, status models.WebhookDeliveryStatus, lastError *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[id].Status = status
+	r.deliveries[id].LastError = lastError
+	return nil
+}
+
+func (r *fakeWebhookRepo) ListAttempts(ctx context.Context, deliveryID uuid.UUID) ([]models.WebhookAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var attempts []models.WebhookAttempt
+	for _, attempt := range r.attempts {
+		if attempt.DeliveryID == deliveryID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+func (r *fakeWebhookRepo) DeleteDeliveriesBefore(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeWebhookRepo) deliveryStatus(id uuid.UUID) models.WebhookDeliveryStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deliveries[id].Status
+}
+
+// fakeEnqueuer запоминает поставленные задачи, не выполняя их
+type fakeEnqueuer struct {
+	payloads []interface{}
+}
+
+func (e *fakeEnqueuer) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...queue.Option) (uuid.UUID, error) {
+	e.payloads = append(e.payloads, payload)
+	return uuid.New(), nil
+}
+
+func (e *fakeEnqueuer) deliveryIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, payload := range e.payloads {
+		ids = append(ids, payload.(service.DeliverWebhookPayload).DeliveryID)
+	}
+	return ids
+}
+
+func newWebhookTestService(repo *fakeWebhookRepo, jobs *fakeEnqueuer) *service.WebhookService {
+	cfg := &config.Config{
+		WebhookTimeout:      time.Second,
+		WebhookMaxAttempts:  5,
+		WebhookDisableAfter: 3,
+	}
+	return service.NewWebhookService(fakeTx{}, repo, jobs, &auditRecorder{}, cfg)
+}
+
+func createEndpoint(t *testing.T, s *service.WebhookService, url string, eventTypes ...string) *models.WebhookEndpoint {
+	endpoint, err := s.CreateEndpoint(context.Background(), &models.CreateWebhookRequest{URL: url, Events: eventTypes})
+	require.NoError(t, err)
+	return endpoint
+}
+
+func TestWebhookService_CreateEndpoint(t *testing.T) {
+	s := newWebhookTestService(newFakeWebhookRepo(), &fakeEnqueuer{})
+
+	endpoint := createEndpoint(t, s, "https://example.com/hooks", events.UserRegistered)
+	assert.True(t, endpoint.IsActive)
+	assert.Regexp(t, `^whsec_[0-9a-f]{64}$`, endpoint.Secret)
+
+	_, err := s.CreateEndpoint(context.Background(), &models.CreateWebhookRequest{
+		URL:    "https://example.com/hooks",
+		Events: []string{"user.exploded"},
+	})
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+
+	_, err = s.CreateEndpoint(context.Background(), &models.CreateWebhookRequest{URL: "https://example.com/hooks"})
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+}
+
+func TestWebhookService_HandleEvent_CreatesDeliveriesForSubscribers(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	jobs := &fakeEnqueuer{}
+	s := newWebhookTestService(repo, jobs)
+
+	registered := createEndpoint(t, s, "https://a.example.com", events.UserRegistered)
+	all := createEndpoint(t, s, "https://b.example.com", models.WebhookAllEvents)
+	createEndpoint(t, s, "https://c.example.com", events.UserDeleted)
+
+	event := events.Event{ID: uuid.New(), Type: events.UserRegistered, AggregateID: uuid.New()}
+	require.NoError(t, s.HandleEvent(context.Background(), event))
+	require.Len(t, jobs.payloads, 2)
+
+	endpoints := map[uuid.UUID]bool{}
+	for _, id := range jobs.deliveryIDs() {
+		delivery, err := repo.GetDelivery(context.Background(), id)
+		require.NoError(t, err)
+		endpoints[delivery.EndpointID] = true
+
+		var body events.Event
+		require.NoError(t, json.Unmarshal(delivery.Payload, &body))
+		assert.Equal(t, event.ID, body.ID)
+	}
+	assert.Equal(t, map[uuid.UUID]bool{registered.ID: true, all.ID: true}, endpoints)
+
+	// Повторно доставленное шиной событие не создает новых доставок
+	require.NoError(t, s.HandleEvent(context.Background(), event))
+	assert.Len(t, jobs.payloads, 2)
+}
+
+func TestWebhookService_Deliver_SignsRequest(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header.Clone(), body: body}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepo()
+	jobs := &fakeEnqueuer{}
+	s := newWebhookTestService(repo, jobs)
+	endpoint := createEndpoint(t, s, server.URL, events.UserDeleted)
+
+	event := events.Event{ID: uuid.New(), Type: events.UserDeleted, AggregateID: uuid.New()}
+	require.NoError(t, s.HandleEvent(context.Background(), event))
+	deliveryID := jobs.deliveryIDs()[0]
+
+	require.NoError(t, s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: deliveryID}))
+
+	req := <-requests
+	timestamp, err := strconv.ParseInt(req.header.Get(service.WebhookHeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(timestamp, 0), 5*time.Second)
+	assert.Equal(t, service.SignWebhook(endpoint.Secret, timestamp, req.body), req.header.Get(service.WebhookHeaderSignature))
+	assert.Equal(t, deliveryID.String(), req.header.Get(service.WebhookHeaderID))
+	assert.Equal(t, events.UserDeleted, req.header.Get(service.WebhookHeaderEvent))
+
+	assert.Equal(t, models.WebhookDeliverySucceeded, repo.deliveryStatus(deliveryID))
+	attempts, _ := repo.ListAttempts(context.Background(), deliveryID)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, http.StatusOK, *attempts[0].ResponseStatus)
+	assert.Equal(t, "ok", *attempts[0].ResponseBody)
+	assert.Nil(t, attempts[0].Error)
+}
+
+func TestWebhookService_Deliver_FailureIsLoggedAndRetried(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepo()
+	jobs := &fakeEnqueuer{}
+	s := newWebhookTestService(repo, jobs)
+	createEndpoint(t, s, server.URL, models.WebhookAllEvents)
+
+	require.NoError(t, s.HandleEvent(context.Background(), events.Event{ID: uuid.New(), Type: events.UserUpdated}))
+	deliveryID := jobs.deliveryIDs()[0]
+
+	err := s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: deliveryID})
+	assert.ErrorContains(t, err, "unexpected status 503")
+	assert.Equal(t, models.WebhookDeliveryPending, repo.deliveryStatus(deliveryID), "the queue retries the delivery")
+
+	attempts, _ := repo.ListAttempts(context.Background(), deliveryID)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, *attempts[0].ResponseStatus)
+	assert.Contains(t, *attempts[0].ResponseBody, "maintenance")
+}
+
+func TestWebhookService_Deliver_DisablesEndpointAfterRepeatedFailures(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	repo := newFakeWebhookRepo()
+	jobs := &fakeEnqueuer{}
+	s := newWebhookTestService(repo, jobs)
+	endpoint := createEndpoint(t, s, server.URL, models.WebhookAllEvents)
+
+	require.NoError(t, s.HandleEvent(context.Background(), events.Event{ID: uuid.New(), Type: events.UserUpdated}))
+	require.NoError(t, s.HandleEvent(context.Background(), events.Event{ID: uuid.New(), Type: events.UserDeleted}))
+	first, second := jobs.deliveryIDs()[0], jobs.deliveryIDs()[1]
+
+	assert.Error(t, s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: first}))
+	assert.Error(t, s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: first}))
+	// Третья неудача подряд отключает endpoint; повторять задачу больше незачем
+	assert.NoError(t, s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: first}))
+
+	disabled, _ := repo.GetEndpoint(context.Background(), endpoint.ID)
+	assert.False(t, disabled.IsActive)
+	require.NotNil(t, disabled.DisabledReason)
+	assert.Contains(t, *disabled.DisabledReason, "3 consecutive failed deliveries")
+	assert.Equal(t, models.WebhookDeliveryFailed, repo.deliveryStatus(first))
+
+	// Доставки отключенного endpoint завершаются без запроса
+	assert.NoError(t, s.Deliver(context.Background(), service.DeliverWebhookPayload{DeliveryID: second}))
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, models.WebhookDeliveryFailed, repo.deliveryStatus(second))
+
+	_, err := s.Redeliver(context.Background(), endpoint.ID, first)
+	assert.ErrorIs(t, err, apperrors.ErrWebhookDisabled)
+
+	// Включение сбрасывает счетчик и позволяет отправить доставку заново
+	enabled := true
+	updated, err := s.UpdateEndpoint(context.Background(), endpoint.ID, &models.UpdateWebhookRequest{IsActive: &enabled})
+	require.NoError(t, err)
+	assert.Zero(t, updated.ConsecutiveFailures)
+	assert.Nil(t, updated.DisabledAt)
+
+	delivery, err := s.Redeliver(context.Background(), endpoint.ID, first)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, first, jobs.deliveryIDs()[len(jobs.payloads)-1])
+}
+
+func TestWebhookService_Redeliver_ChecksEndpoint(t *testing.T) {
+	repo := newFakeWebhookRepo()
+	jobs := &fakeEnqueuer{}
+	s := newWebhookTestService(repo, jobs)
+	endpoint := createEndpoint(t, s, "https://a.example.com", models.WebhookAllEvents)
+	other := createEndpoint(t, s, "https://b.example.com", models.WebhookAllEvents)
+
+	require.NoError(t, s.HandleEvent(context.Background(), events.Event{ID: uuid.New(), Type: events.UserUpdated}))
+	var delivery uuid.UUID
+	for _, id := range jobs.deliveryIDs() {
+		if d, _ := repo.GetDelivery(context.Background(), id); d.EndpointID == endpoint.ID {
+			delivery = id
+		}
+	}
+
+	_, err := s.Redeliver(context.Background(), other.ID, delivery)
+	assert.ErrorIs(t, err, apperrors.ErrNotFound)
+}